@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Adapters let agent CLIs other than Claude Code use the guard. Each one
+// reads its CLI's hook input into a HookInput, with native tools translated
+// to Claude Code's (see translateNativeTool), and writes the verdict in the
+// CLI's format. The adapter comes from --adapter or is detected from the
+// input.
+
+// Adapter names for --adapter.
+const (
+	AdapterClaude  = "claude"  // Claude Code's PermissionRequest, PreToolUse and PostToolUse hooks
+	AdapterGeneric = "generic" // {"tool", "input", "cwd"} in, {"decision", "reason"} out
+	AdapterGemini  = "gemini"  // Gemini CLI's BeforeTool and AfterTool hooks
+)
+
+type adapter interface {
+	// parse reads a hook input. A HookInput with no tool name is passed
+	// through unanswered.
+	parse(data []byte) (*HookInput, error)
+
+	// respond writes the answer for a verdict.
+	respond(event string, policy *Policy, verdict Verdict, reason string)
+
+	// rewrites reports whether the CLI accepts a rewritten tool input.
+	rewrites() bool
+}
+
+var adapters = map[string]adapter{
+	AdapterClaude:  claudeAdapter{},
+	AdapterGeneric: genericAdapter{},
+	AdapterGemini:  geminiAdapter{},
+}
+
+// detectAdapter picks the adapter for an input when none is given.
+func detectAdapter(data []byte) string {
+	var fields struct {
+		ToolName      *string         `json:"tool_name"`
+		Tool          json.RawMessage `json:"tool"`
+		HookEventName string          `json:"hook_event_name"`
+	}
+	if json.Unmarshal(data, &fields) != nil {
+		return AdapterClaude
+	}
+	switch {
+	case fields.ToolName == nil && fields.Tool != nil:
+		return AdapterGeneric
+	case fields.HookEventName == geminiBeforeTool || fields.HookEventName == geminiAfterTool:
+		return AdapterGemini
+	}
+	return AdapterClaude
+}
+
+// readHookInput reads stdin with the named adapter, or the detected one if
+// name is empty.
+func readHookInput(name string) (*HookInput, string, adapter, error) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, name, nil, err
+	}
+	if name == "" {
+		name = detectAdapter(data)
+	}
+	a, ok := adapters[name]
+	if !ok {
+		return nil, name, nil, fmt.Errorf("unknown adapter %q", name)
+	}
+	hookInput, err := a.parse(data)
+	if err != nil {
+		return nil, name, a, err
+	}
+	return hookInput, name, a, nil
+}
+
+// translate replaces another agent's tool with Claude Code's equivalent.
+// A call that becomes several keeps its own name, with the calls in Calls.
+func (h *HookInput) translate() {
+	calls, ok := translateNativeTool(h.ToolName, h.ToolInput, h.WorkingDir)
+	if !ok {
+		return
+	}
+	if len(calls) == 1 {
+		h.ToolName, h.ToolInput = calls[0].ToolName, calls[0].ToolInput
+		return
+	}
+	h.Calls = calls
+}
+
+// calls returns the calls the rules evaluate.
+func (h *HookInput) calls() []toolCall {
+	if len(h.Calls) > 0 {
+		return h.Calls
+	}
+	return []toolCall{{h.ToolName, h.ToolInput}}
+}
+
+// --- Claude Code ---
+
+type claudeAdapter struct{}
+
+func (claudeAdapter) parse(data []byte) (*HookInput, error) {
+	var hookInput HookInput
+	if err := json.Unmarshal(data, &hookInput); err != nil {
+		return nil, err
+	}
+	return &hookInput, nil
+}
+
+func (claudeAdapter) respond(event string, policy *Policy, verdict Verdict, reason string) {
+	respond(event, policy, verdict, reason)
+}
+
+func (claudeAdapter) rewrites() bool { return true }
+
+// --- generic ---
+
+// genericInput is the generic adapter's input. Only tool is required.
+type genericInput struct {
+	Tool      string          `json:"tool"`
+	Input     json.RawMessage `json:"input"`
+	Cwd       string          `json:"cwd"`
+	SessionID string          `json:"session_id"`
+}
+
+// genericOutput answers with allow, ask or deny. No output at all means the
+// guard has no opinion.
+type genericOutput struct {
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type genericAdapter struct{}
+
+func (genericAdapter) parse(data []byte) (*HookInput, error) {
+	var input genericInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, err
+	}
+	hookInput := &HookInput{
+		SessionID:     input.SessionID,
+		HookEventName: EventPermissionRequest,
+		ToolName:      input.Tool,
+		ToolInput:     input.Input,
+		WorkingDir:    input.Cwd,
+	}
+	if len(hookInput.ToolInput) == 0 {
+		hookInput.ToolInput = json.RawMessage(`{}`)
+	}
+	hookInput.translate()
+	return hookInput, nil
+}
+
+func (genericAdapter) respond(event string, policy *Policy, verdict Verdict, reason string) {
+	output := genericOutput{Decision: "ask", Reason: reason}
+	switch verdict {
+	case VerdictAllow:
+		output.Decision = "allow"
+	case VerdictDeny:
+		output.Decision, output.Reason = "deny", denyMessage(reason)
+	}
+	json.NewEncoder(os.Stdout).Encode(output)
+}
+
+func (genericAdapter) rewrites() bool { return false }
+
+// --- Gemini CLI ---
+
+const (
+	geminiBeforeTool = "BeforeTool"
+	geminiAfterTool  = "AfterTool"
+)
+
+// geminiInput is Gemini CLI's BeforeTool and AfterTool hook input.
+type geminiInput struct {
+	SessionID      string          `json:"session_id"`
+	HookEventName  string          `json:"hook_event_name"`
+	ToolName       string          `json:"tool_name"`
+	ToolInput      json.RawMessage `json:"tool_input"`
+	ToolResponse   json.RawMessage `json:"tool_response"`
+	WorkingDir     string          `json:"cwd"`
+	TranscriptPath string          `json:"transcript_path"`
+}
+
+// geminiOutput answers a BeforeTool hook. The reason goes to the model,
+// the system message to the user.
+type geminiOutput struct {
+	Decision      string `json:"decision"` // "allow", "ask" or "deny"
+	Reason        string `json:"reason,omitempty"`
+	SystemMessage string `json:"systemMessage,omitempty"`
+}
+
+type geminiAdapter struct{}
+
+func (geminiAdapter) parse(data []byte) (*HookInput, error) {
+	var input geminiInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, err
+	}
+	hookInput := &HookInput{
+		SessionID:      input.SessionID,
+		ToolName:       input.ToolName,
+		ToolInput:      input.ToolInput,
+		WorkingDir:     input.WorkingDir,
+		TranscriptPath: input.TranscriptPath,
+		ToolResponse:   input.ToolResponse,
+	}
+	switch input.HookEventName {
+	case geminiBeforeTool:
+		// Gemini CLI has no separate permission hook, so every verdict is
+		// answered here
+		hookInput.HookEventName = EventPermissionRequest
+	case geminiAfterTool:
+		hookInput.HookEventName = EventPostToolUse
+	default:
+		hookInput.ToolName = "" // not a tool event
+	}
+	hookInput.translate()
+	return hookInput, nil
+}
+
+func (geminiAdapter) respond(event string, policy *Policy, verdict Verdict, reason string) {
+	var output geminiOutput
+	switch verdict {
+	case VerdictAllow:
+		output = geminiOutput{Decision: "allow"}
+	case VerdictDeny:
+		output = geminiOutput{Decision: "deny", Reason: denyMessage(reason), SystemMessage: "almost-yolo-guard: denied because " + reason}
+	default:
+		output = geminiOutput{Decision: "ask", Reason: askContext(reason), SystemMessage: askMessage(reason)}
+	}
+	json.NewEncoder(os.Stdout).Encode(output)
+}
+
+func (geminiAdapter) rewrites() bool { return false }
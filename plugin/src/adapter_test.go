@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDetectAdapter(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"session_id":"s","tool_name":"Bash","tool_input":{"command":"ls"},"cwd":"/p"}`, AdapterClaude},
+		{`{"hook_event_name":"PreToolUse","tool_name":"Bash","tool_input":{}}`, AdapterClaude},
+		{`{"tool":"shell","input":{"command":["ls"]},"cwd":"/p"}`, AdapterGeneric},
+		{`{"hook_event_name":"BeforeTool","tool_name":"run_shell_command","tool_input":{"command":"ls"}}`, AdapterGemini},
+		{`{"hook_event_name":"AfterTool","tool_name":"write_file","tool_input":{}}`, AdapterGemini},
+		{`not json`, AdapterClaude},
+	}
+	for _, tt := range tests {
+		if got := detectAdapter([]byte(tt.input)); got != tt.want {
+			t.Errorf("detectAdapter(%s) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestTranslateNativeTool(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  string
+		input string
+		want  []toolCall
+	}{
+		{"codex shell script", "shell", `{"command":["bash","-lc","go test ./..."],"workdir":"/p/sub"}`,
+			[]toolCall{{"Bash", json.RawMessage(`{"command":"cd /p/sub \u0026\u0026 go test ./..."}`)}}},
+		{"codex shell argv", "shell", `{"command":["rm","-rf","build dir"]}`,
+			[]toolCall{{"Bash", json.RawMessage(`{"command":"rm -rf 'build dir'"}`)}}},
+		{"codex local_shell", "local_shell", `{"action":{"type":"exec","command":["rm","-rf","build"],"working_directory":"/tmp"}}`,
+			[]toolCall{{"Bash", json.RawMessage(`{"command":"cd /tmp \u0026\u0026 rm -rf build"}`)}}},
+		{"codex exec_command", "exec_command", `{"cmd":"git status"}`,
+			[]toolCall{{"Bash", json.RawMessage(`{"command":"git status"}`)}}},
+		{"gemini shell", "run_shell_command", `{"command":"npm test","directory":"web"}`,
+			[]toolCall{{"Bash", json.RawMessage(`{"command":"cd /p/web \u0026\u0026 npm test"}`)}}},
+		{"gemini shell outside project", "run_shell_command", `{"command":"ls","directory":"/home/me/other dir"}`,
+			[]toolCall{{"Bash", json.RawMessage(`{"command":"cd '/home/me/other dir' \u0026\u0026 ls"}`)}}},
+		{"gemini write", "write_file", `{"file_path":"/p/a.go","content":"x"}`,
+			[]toolCall{{"Write", json.RawMessage(`{"file_path":"/p/a.go","content":"x"}`)}}},
+		{"gemini read", "read_file", `{"absolute_path":"/p/a.go"}`,
+			[]toolCall{{"Read", json.RawMessage(`{"file_path":"/p/a.go"}`)}}},
+		{"gemini read many", "read_many_files", `{"paths":["src/*.go","/etc/shadow"]}`,
+			[]toolCall{
+				{"Read", json.RawMessage(`{"file_path":"/p/src/*.go"}`)},
+				{"Read", json.RawMessage(`{"file_path":"/etc/shadow"}`)},
+			}},
+		{"apply_patch", "apply_patch", `{"input":"*** Begin Patch\n*** Add File: docs/new.md\n+# New\n*** Update File: main.go\n@@ func main\n-\told()\n+\tnew()\n*** Delete File: /tmp/old.txt\n*** End Patch"}`,
+			[]toolCall{
+				{"Write", json.RawMessage(`{"content":"# New","file_path":"/p/docs/new.md"}`)},
+				{"Edit", json.RawMessage(`{"file_path":"/p/main.go","new_string":"\tnew()","old_string":"\told()"}`)},
+				{"Bash", json.RawMessage(`{"command":"rm -- /tmp/old.txt"}`)},
+			}},
+		{"codex shell apply_patch", "shell", `{"command":["apply_patch","*** Begin Patch\n*** Update File: a.go\n*** Move to: b.go\n*** End Patch"]}`,
+			[]toolCall{
+				{"Bash", json.RawMessage(`{"command":"mv -- /p/a.go /p/b.go"}`)},
+				{"Edit", json.RawMessage(`{"file_path":"/p/b.go","new_string":"","old_string":""}`)},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, ok := translateNativeTool(tt.tool, json.RawMessage(tt.input), "/p")
+			if !ok {
+				t.Fatalf("translateNativeTool(%s) not translated", tt.tool)
+			}
+			if len(calls) != len(tt.want) {
+				t.Fatalf("got %d calls, want %d: %v", len(calls), len(tt.want), calls)
+			}
+			for i, call := range calls {
+				if call.ToolName != tt.want[i].ToolName || string(call.ToolInput) != string(tt.want[i].ToolInput) {
+					t.Errorf("call %d = %s %s, want %s %s", i, call.ToolName, call.ToolInput, tt.want[i].ToolName, tt.want[i].ToolInput)
+				}
+			}
+		})
+	}
+
+	for _, tool := range []string{"Bash", "mcp__github__create_issue"} {
+		if _, ok := translateNativeTool(tool, json.RawMessage(`{}`), "/p"); ok {
+			t.Errorf("translateNativeTool(%s) translated", tool)
+		}
+	}
+	if _, ok := translateNativeTool("apply_patch", json.RawMessage(`{"input":"not a patch"}`), "/p"); ok {
+		t.Errorf("empty patch translated")
+	}
+}
+
+func TestEvaluateCalls(t *testing.T) {
+	workDir := "/tmp/project"
+	write := toolCall{"Write", json.RawMessage(`{"file_path":"/tmp/project/a.go","content":"x"}`)}
+	tests := []struct {
+		name  string
+		calls []toolCall
+		want  Verdict
+	}{
+		{"all allowed", []toolCall{write, {"Edit", json.RawMessage(`{"file_path":"/tmp/project/b.go","old_string":"a","new_string":"b"}`)}}, VerdictAllow},
+		{"outside project asks", []toolCall{write, {"Write", json.RawMessage(`{"file_path":"/etc/hosts","content":"x"}`)}}, VerdictAsk},
+		{"deny wins", []toolCall{{"Write", json.RawMessage(`{"file_path":"/etc/hosts","content":"x"}`)}, {"Bash", json.RawMessage(`{"command":"rm -rf ~"}`)}}, VerdictDeny},
+		{"unknown command", []toolCall{write, {"Bash", json.RawMessage(`{"command":"some-unknown-tool"}`)}}, VerdictUncertain},
+		{"none", nil, VerdictUncertain},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, reason := evaluateCalls(tt.calls, workDir); got != tt.want {
+				t.Errorf("evaluateCalls() = %v (%s), want %v", got, reason, tt.want)
+			}
+		})
+	}
+
+	// Notes of every call are kept
+	evaluateCalls([]toolCall{write, {"Bash", json.RawMessage(`{"command":"scp build1:app.log /tmp/"}`)}}, workDir)
+	if !ruleLeanedSafe() {
+		t.Errorf("ruleLeanedSafe() = false after an allowed write and a download outside the project")
+	}
+	evaluateCalls([]toolCall{write, {"Bash", json.RawMessage(`{"command":"some-unknown-tool"}`)}}, workDir)
+	if ruleLeanedSafe() {
+		t.Errorf("ruleLeanedSafe() = true after an unknown command")
+	}
+}
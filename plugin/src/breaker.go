@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// When the evaluator can't answer, the policy's daemon_failure settings
+// decide the call: ask, deny, or allow it if the rules leaned safe. A
+// circuit breaker stops a wedged daemon from costing every uncertain call
+// the full request deadline: after repeated failures the client skips the
+// daemon for a cool-down. Its state lives in configDir() so every hook
+// process shares it.
+
+// Daemon failure actions for DaemonFailurePolicy.
+const (
+	FailureAsk         = "ask"           // show the dialog (the default)
+	FailureDeny        = "deny"          // refuse the call
+	FailureAllowIfSafe = "allow_if_safe" // allow if the rules leaned safe, else ask
+)
+
+var knownFailureActions = map[string]bool{FailureAsk: true, FailureDeny: true, FailureAllowIfSafe: true}
+
+const (
+	defaultBreakerThreshold = 3
+	defaultBreakerCoolDown  = 2 * time.Minute
+)
+
+// failureAction returns the action for a call the evaluator couldn't
+// answer. The target profile comes first, then the tool (exact name, then
+// patterns), then the default.
+func (d DaemonFailurePolicy) failureAction(toolName, profile string) string {
+	if action := d.Profiles[profile]; profile != "" && action != "" {
+		return action
+	}
+	if action := d.Tools[toolName]; action != "" {
+		return action
+	}
+	patterns := make([]string, 0, len(d.Tools))
+	for pattern := range d.Tools {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if matchesAnyPattern(toolName, []string{pattern}) {
+			return d.Tools[pattern]
+		}
+	}
+	if d.Action != "" {
+		return d.Action
+	}
+	return FailureAsk
+}
+
+func (d DaemonFailurePolicy) validate() error {
+	if d.Action != "" && !knownFailureActions[d.Action] {
+		return fmt.Errorf("daemon_failure.action: unknown action %q", d.Action)
+	}
+	for tool, action := range d.Tools {
+		if err := validatePatterns("daemon_failure.tools", []string{tool}); err != nil {
+			return err
+		}
+		if !knownFailureActions[action] {
+			return fmt.Errorf("daemon_failure.tools.%s: unknown action %q", tool, action)
+		}
+	}
+	for profile, action := range d.Profiles {
+		if profile != profileProduction && profile != profileDev {
+			return fmt.Errorf("daemon_failure.profiles: unknown profile %q", profile)
+		}
+		if !knownFailureActions[action] {
+			return fmt.Errorf("daemon_failure.profiles.%s: unknown action %q", profile, action)
+		}
+	}
+	if d.BreakerThreshold < 0 {
+		return fmt.Errorf("daemon_failure.breaker_threshold: must not be negative")
+	}
+	if c := d.BreakerCoolDown; c != "" {
+		if d, err := time.ParseDuration(c); err != nil || d <= 0 {
+			return fmt.Errorf("daemon_failure.breaker_cooldown: bad duration %q", c)
+		}
+	}
+	return nil
+}
+
+// failureVerdict turns a failure action into a verdict.
+func failureVerdict(action string, leanedSafe bool) Verdict {
+	switch {
+	case action == FailureDeny:
+		return VerdictDeny
+	case action == FailureAllowIfSafe && leanedSafe:
+		return VerdictAllow
+	}
+	return VerdictAsk
+}
+
+func (d DaemonFailurePolicy) threshold() int {
+	if d.BreakerThreshold > 0 {
+		return d.BreakerThreshold
+	}
+	return defaultBreakerThreshold
+}
+
+// coolDown returns how long an open breaker skips the daemon. validate has
+// checked the value.
+func (d DaemonFailurePolicy) coolDown() time.Duration {
+	if c, err := time.ParseDuration(d.BreakerCoolDown); err == nil && c > 0 {
+		return c
+	}
+	return defaultBreakerCoolDown
+}
+
+// breakerState is the persisted circuit breaker.
+type breakerState struct {
+	Failures    int       `json:"failures"` // consecutive
+	LastError   string    `json:"last_error,omitempty"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+	OpenUntil   time.Time `json:"open_until,omitempty"`
+}
+
+func breakerPath() string {
+	return filepath.Join(configDir(), "circuit-breaker.json")
+}
+
+func loadBreaker() breakerState {
+	var state breakerState
+	if data, err := os.ReadFile(breakerPath()); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func saveBreaker(state breakerState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(configDir(), 0755)
+	tmp := breakerPath() + ".tmp"
+	if os.WriteFile(tmp, data, 0644) == nil {
+		os.Rename(tmp, breakerPath())
+	}
+}
+
+func (b breakerState) isOpen(now time.Time) bool {
+	return now.Before(b.OpenUntil)
+}
+
+// recordDaemonFailure counts a failure, opening the breaker at the
+// threshold. Once open, each failed retry after the cool-down opens it again.
+func recordDaemonFailure(policy DaemonFailurePolicy, err error) (state breakerState) {
+	withFileLock(breakerPath(), func() {
+		state = loadBreaker()
+		now := time.Now()
+		state.Failures++
+		state.LastError = err.Error()
+		state.LastFailure = now
+		if state.Failures >= policy.threshold() {
+			state.OpenUntil = now.Add(policy.coolDown())
+		}
+		saveBreaker(state)
+	})
+	return state
+}
+
+// recordDaemonSuccess closes the breaker.
+func recordDaemonSuccess() {
+	if loadBreaker().Failures == 0 {
+		return
+	}
+	withFileLock(breakerPath(), func() {
+		os.Remove(breakerPath())
+	})
+}
+
+// describe reports the breaker for daemon status.
+func (b breakerState) describe(now time.Time) string {
+	switch {
+	case b.isOpen(now):
+		return fmt.Sprintf("open until %s after %d consecutive failures (last: %s)",
+			b.OpenUntil.Format("15:04:05"), b.Failures, b.LastError)
+	case b.Failures > 0:
+		return fmt.Sprintf("closed, %d recent failures (last at %s: %s)",
+			b.Failures, b.LastFailure.Format("15:04:05"), b.LastError)
+	}
+	return "closed"
+}
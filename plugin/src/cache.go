@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Verdicts for task files and scripts are cached by the hash of the file
+// contents, so an unchanged Makefile isn't parsed again on every `make test`.
+// Editing the file (or the policy) changes the key. Everything else the
+// verdict read (scripts a recipe calls, sourced files, kubeconfigs, the
+// environment) is recorded as an input with its fingerprint, and an entry
+// whose inputs changed is computed again. Entries also expire.
+
+const (
+	verdictCacheTTL        = 24 * time.Hour
+	verdictCacheMaxEntries = 500
+)
+
+type cachedVerdict struct {
+	Verdict Verdict           `json:"verdict"`
+	Reason  string            `json:"reason"`
+	Notes   []string          `json:"notes,omitempty"`  // ruleNotes made while computing it
+	Inputs  map[string]string `json:"inputs,omitempty"` // input -> fingerprint, see dependOnFile
+	Time    time.Time         `json:"time"`
+}
+
+func verdictCachePath() string {
+	return filepath.Join(configDir(), "verdict-cache.json")
+}
+
+// contentKey hashes the parts that determine a verdict, together with the
+// active policy.
+func contentKey(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	if policy, err := json.Marshal(currentPolicy()); err == nil {
+		h.Write(policy)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cacheInputs collects the inputs read by the verdict being computed; nil
+// outside withVerdictCache. Rules are evaluated one request at a time.
+var cacheInputs map[string]string
+
+// dependOnFile records that the verdict being computed read path, or found
+// it missing.
+func dependOnFile(path string) {
+	if cacheInputs != nil && path != "" {
+		input := "file:" + path
+		cacheInputs[input] = inputFingerprint(input)
+	}
+}
+
+// dependOnEnv records that the verdict being computed read an environment
+// variable of the hook process.
+func dependOnEnv(name string) {
+	if cacheInputs != nil {
+		input := "env:" + name
+		cacheInputs[input] = inputFingerprint(input)
+	}
+}
+
+// inputFingerprint describes an input's current state: a file's contents
+// (size and modification time for large files), or a variable's value.
+func inputFingerprint(input string) string {
+	kind, name, _ := strings.Cut(input, ":")
+	switch kind {
+	case "env":
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "unset"
+		}
+		return "=" + value
+	case "file":
+		info, err := os.Stat(name)
+		switch {
+		case os.IsNotExist(err):
+			return "missing"
+		case err != nil:
+			return "error"
+		case info.IsDir():
+			return "dir"
+		case info.Size() > maxScriptSize:
+			return fmt.Sprintf("%d@%d", info.Size(), info.ModTime().UnixNano())
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "error"
+		}
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	return ""
+}
+
+// inputsUnchanged reports whether every recorded input still has its
+// fingerprint.
+func inputsUnchanged(inputs map[string]string) bool {
+	for input, fingerprint := range inputs {
+		if inputFingerprint(input) != fingerprint {
+			return false
+		}
+	}
+	return true
+}
+
+func loadVerdictCache() map[string]cachedVerdict {
+	cache := map[string]cachedVerdict{}
+	data, err := os.ReadFile(verdictCachePath())
+	if err != nil {
+		return cache
+	}
+	json.Unmarshal(data, &cache)
+	return cache
+}
+
+// withVerdictCache returns the cached verdict for key, or computes and
+// stores it. A cache that can't be read or written only costs the lookup.
+// Inputs are passed on to an enclosing withVerdictCache, so a Makefile's
+// entry also depends on the scripts its recipes call.
+func withVerdictCache(key string, compute func() (Verdict, string)) (Verdict, string) {
+	note(noteInput, key[:16])
+	cache := loadVerdictCache()
+	if entry, ok := cache[key]; ok && time.Since(entry.Time) < verdictCacheTTL && inputsUnchanged(entry.Inputs) {
+		for _, n := range entry.Notes {
+			ruleNotes[n] = true
+		}
+		for input, fingerprint := range entry.Inputs {
+			if cacheInputs != nil {
+				cacheInputs[input] = fingerprint
+			}
+		}
+		return entry.Verdict, entry.Reason
+	}
+
+	outer, outerInputs := ruleNotes, cacheInputs
+	ruleNotes, cacheInputs = map[string]bool{}, map[string]string{}
+	verdict, reason := compute()
+	var notes []string
+	for n := range ruleNotes {
+		notes = append(notes, n)
+		outer[n] = true
+	}
+	inputs := cacheInputs
+	for input, fingerprint := range inputs {
+		if outerInputs != nil {
+			outerInputs[input] = fingerprint
+		}
+	}
+	ruleNotes, cacheInputs = outer, outerInputs
+	sort.Strings(notes)
+
+	cache[key] = cachedVerdict{Verdict: verdict, Reason: reason, Notes: notes, Inputs: inputs, Time: time.Now()}
+	pruneVerdictCache(cache)
+	if data, err := json.Marshal(cache); err == nil {
+		os.MkdirAll(configDir(), 0755)
+		tmp := verdictCachePath() + ".tmp"
+		if os.WriteFile(tmp, data, 0644) == nil {
+			os.Rename(tmp, verdictCachePath())
+		}
+	}
+	return verdict, reason
+}
+
+// pruneVerdictCache drops expired entries, then the oldest ones beyond the
+// size limit.
+func pruneVerdictCache(cache map[string]cachedVerdict) {
+	keys := make([]string, 0, len(cache))
+	for key, entry := range cache {
+		if time.Since(entry.Time) >= verdictCacheTTL {
+			delete(cache, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) <= verdictCacheMaxEntries {
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return cache[keys[i]].Time.Before(cache[keys[j]].Time) })
+	for _, key := range keys[:len(keys)-verdictCacheMaxEntries] {
+		delete(cache, key)
+	}
+}
@@ -6,12 +6,28 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"strings"
 	"time"
 )
 
-// runClient is the main hook client entry point.
-func runClient() {
-	hookInput, err := readHookInput()
+// runClient is the main hook client entry point. --adapter NAME picks the
+// agent CLI's hook format; without it the format is detected.
+func runClient(args []string) {
+	adapterName := ""
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--adapter" && i+1 < len(args):
+			adapterName = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--adapter="):
+			adapterName = strings.TrimPrefix(args[i], "--adapter=")
+		default:
+			exitPassthrough("unknown argument: " + args[i])
+			return
+		}
+	}
+
+	hookInput, adapterName, agent, err := readHookInput(adapterName)
 	if err != nil {
 		exitPassthrough("failed to read input: " + err.Error())
 		return
@@ -22,55 +38,249 @@ func runClient() {
 		return
 	}
 
+	policy, err := loadPolicy(defaultPolicyPath())
+	if err != nil {
+		// Keep going with the defaults; doctor reports the broken file
+		logDecision("(error)", "", hookInput.WorkingDir, "ERROR", "policy", err.Error())
+	}
+	activePolicy = policy
+	// Settle auto for this call's permission mode
+	policy.PreToolUse = policy.preToolUseMode(hookInput.PermissionMode)
+
+	event := hookInput.event()
+	if event == EventPostToolUse || event == EventPostToolUseFailure {
+		recordOutcome(hookInput)
+		exitPassthrough("")
+		return
+	}
+	if event == EventPreToolUse && policy.PreToolUse == PreToolUseOff {
+		exitPassthrough("")
+		return
+	}
+
 	// Skip evaluation for tools that don't need security review
-	if shouldSkipEvaluation(hookInput.ToolName) {
+	if shouldSkipEvaluation(hookInput.ToolName, hookInput.ToolInput) {
 		exitPassthrough("")
 		return
 	}
 
 	toolInputStr := string(hookInput.ToolInput)
+	mode := hookInput.PermissionMode
+	modeRule := policy.permissionMode(mode)
+
+	// Some modes leave tools to Claude Code (edits in acceptEdits), but
+	// never the guard's own files or Claude's permission settings
+	if modeRule.passesThrough(hookInput.ToolName) {
+		if _, _, hit := evaluateSelfProtection(hookInput.ToolName, hookInput.ToolInput, hookInput.WorkingDir); !hit {
+			logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, "PASSTHROUGH", "mode "+mode, "left to Claude Code in "+mode+" mode")
+			exitPassthrough("")
+			return
+		}
+	}
 
 	// Step 1: Try rule engine (instant, ~90% of cases)
-	verdict, reason := EvaluateRules(hookInput.ToolName, hookInput.ToolInput, hookInput.WorkingDir)
-	switch verdict {
-	case VerdictAllow:
-		logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, "ALLOW", "rules", reason)
-		writeAllowOutput()
+	verdict, reason := evaluateCalls(hookInput.calls(), hookInput.WorkingDir)
+	source := "rules"
+	shown := reason // what the user and Claude are told
+	categories := ruleCategories()
+
+	// Plan mode only reads
+	if modeRule.deniesMutating() && hookInput.ToolName == "Bash" && verdict != VerdictDeny {
+		if part := mutatingBash(hookInput.ToolInput); part != "" {
+			verdict, source = VerdictDeny, "mode "+mode
+			reason = mode + " mode only runs read-only commands, and this one changes things: " + part
+			shown = reason + ". Present the plan and leave " + mode + " mode first"
+		}
+	}
+
+	// applyMode applies the mode's action for the verdict once; false
+	// means the call was left to Claude Code
+	modeApplied := false
+	applyMode := func() bool {
+		if modeApplied {
+			return true
+		}
+		modeApplied = true
+		mapped, ok := modeAction(modeRule, verdict)
+		if !ok {
+			logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, "PASSTHROUGH",
+				source+" (mode "+mode+": "+verdict.String()+" → passthrough)", reason)
+			exitPassthrough("")
+			return false
+		}
+		if mapped != verdict {
+			source += " (mode " + mode + ": " + verdict.String() + " → " + mapped.String() + ")"
+			shown = mode + " mode: " + shown
+			verdict = mapped
+		}
+		return true
+	}
+
+	// For an uncertain verdict, the mode's action decides whether the
+	// evaluator is asked
+	if verdict == VerdictUncertain && modeRule.action(verdict) != ModeActionKeep && !applyMode() {
 		return
-	case VerdictAsk:
-		logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, "ASK", "rules", reason)
-		exitPassthrough("")
+	}
+
+	if verdict == VerdictUncertain {
+		if event == EventPreToolUse && policy.PreToolUse == PreToolUseDeny {
+			// Left to the permission mode; PermissionRequest evaluates it
+			// if a dialog comes up
+			exitPassthrough("")
+			return
+		}
+
+		// Step 2: try daemon, unless the circuit breaker is open
+		var resp *EvalResponse
+		failure := "daemon-failure"
+		if breaker := loadBreaker(); breaker.isOpen(time.Now()) {
+			failure = "circuit-breaker"
+			err = fmt.Errorf("daemon skipped until %s after %d consecutive failures (last: %s)",
+				breaker.OpenUntil.Format("15:04:05"), breaker.Failures, breaker.LastError)
+		} else {
+			resp, err = queryDaemon(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, hookInput.TranscriptPath)
+			if err == nil && resp.Failed {
+				err = fmt.Errorf("%s", resp.Reason)
+			}
+			if err != nil {
+				recordDaemonFailure(policy.DaemonFailure, err)
+			} else {
+				recordDaemonSuccess()
+			}
+		}
+
+		if err != nil {
+			// Step 3: Daemon unavailable — the policy's failure action
+			action := policy.DaemonFailure.failureAction(hookInput.ToolName, ruleProfile())
+			verdict = failureVerdict(action, ruleLeanedSafe())
+			source = failure + " (" + action + ")"
+			reason = err.Error()
+			shown = "the security evaluator is unavailable (" + reason + ")"
+		} else {
+			verdict, source, reason = parseVerdict(resp.Decision), "daemon", resp.Reason
+			if resp.Excerpt != "" {
+				source += " (transcript: " + resp.Excerpt + ")"
+			}
+			shown = evaluatorExplanation(resp.Reason)
+			if verdict == VerdictDeny && policy.categoryVerdict(CategoryEvaluator) != VerdictDeny {
+				verdict = VerdictAsk
+				categories = append(categories, CategoryEvaluator)
+			}
+		}
+	}
+
+	if !applyMode() {
 		return
 	}
 
-	// Step 2: VerdictUncertain — try daemon
-	resp, err := queryDaemon(hookInput.ToolName, toolInputStr, hookInput.WorkingDir)
-	if err != nil {
-		// Step 3: Daemon unavailable — fail-safe to ASK
-		logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, "ASK", "fail-safe", err.Error())
+	if event == EventPreToolUse {
+		source += " (PreToolUse)"
+	}
+	if adapterName != AdapterClaude {
+		source += " (" + adapterName + ")"
+	}
+
+	// Deny mode answers only denials from PreToolUse. Anything else is left
+	// to the permission mode, so no outcome is recorded as this verdict: an
+	// ASK passed through under bypassPermissions runs without the user
+	// approving it
+	if event == EventPreToolUse && policy.PreToolUse == PreToolUseDeny && verdict != VerdictDeny {
+		logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, "PASSTHROUGH",
+			source+" ("+verdict.String()+" left to mode "+mode+")", reason)
 		exitPassthrough("")
 		return
 	}
 
-	logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, resp.Decision, "daemon", resp.Reason)
+	// An ASK the user already approved in this session is allowed
+	answersAsk := event == EventPermissionRequest || policy.PreToolUse == PreToolUseAsk
+	approval := ""
+	if verdict == VerdictAsk && answersAsk && policy.rememberable(categories) {
+		approval = approvalKey(policy, hookInput.ToolName, hookInput.ToolInput, hookInput.WorkingDir)
+		if recallApproval(hookInput.SessionID, approval) {
+			verdict, source, reason = VerdictAllow, "session", "approved earlier in this session: "+reason
+			approval = ""
+		}
+	}
+
+	// A safer equivalent, if the policy enables one, replaces an ASK
+	if verdict == VerdictAsk && answersAsk && agent.rewrites() {
+		if rewritten, ok := rewriteInput(hookInput.ToolName, hookInput.ToolInput, hookInput.SessionID, hookInput.WorkingDir); ok {
+			logRewrite(hookInput.ToolName, toolInputStr, string(rewritten.input), hookInput.WorkingDir, source, rewritten.name, reason)
+			// The tool runs with the rewritten input; its outcome is keyed by that
+			recordPendingDecision(hookInput, rewritten.input, "ALLOW", "rewrite "+rewritten.name, "")
+			writeRewriteOutput(event, rewritten)
+			return
+		}
+	}
 
-	if resp.Decision == "ALLOW" {
+	logDecision(hookInput.ToolName, toolInputStr, hookInput.WorkingDir, verdict.String(), source, reason)
+	recordPendingDecision(hookInput, hookInput.ToolInput, verdict.String(), source, approval)
+	agent.respond(event, policy, verdict, shown)
+}
+
+// modeAction returns the verdict after the permission mode's action, or
+// false if the call is left to Claude Code.
+func modeAction(rule PermissionModeRule, verdict Verdict) (Verdict, bool) {
+	switch rule.action(verdict) {
+	case ModeActionAllow:
+		return VerdictAllow, true
+	case ModeActionAsk:
+		return VerdictAsk, true
+	case ModeActionDeny:
+		return VerdictDeny, true
+	case ModeActionPassthrough:
+		return verdict, false
+	}
+	return verdict, true
+}
+
+// respond writes the hook output for a verdict. In PermissionRequest mode an
+// ASK falls through to the dialog with the reason shown above it. In PreToolUse mode the policy's
+// pre_tool_use setting decides which verdicts are answered:
+//
+//	ask:  ALLOW allows, ASK shows the dialog, DENY denies
+//	deny: only DENY denies; the rest is left to the permission mode
+func respond(event string, policy *Policy, verdict Verdict, reason string) {
+	if event == EventPreToolUse {
+		switch {
+		case verdict == VerdictDeny:
+			writeDenyOutput(event, reason)
+		case policy.PreToolUse == PreToolUseDeny:
+			exitPassthrough("")
+		case verdict == VerdictAllow:
+			writePreToolUseOutput("allow", reason)
+		default:
+			writeAskOutput(event, reason)
+		}
+		return
+	}
+
+	switch verdict {
+	case VerdictAllow:
 		writeAllowOutput()
-	} else {
-		exitPassthrough("")
+	case VerdictDeny:
+		writeDenyOutput(event, reason)
+	default:
+		writeAskOutput(event, reason)
 	}
 }
 
-// queryDaemon connects to the daemon, auto-starting it if needed.
-func queryDaemon(toolName, toolInput, workDir string) (*EvalResponse, error) {
+// queryDaemon asks the daemon to evaluate a tool call.
+func queryDaemon(toolName, toolInput, workDir, transcriptPath string) (*EvalResponse, error) {
+	return daemonRequest(EvalRequest{
+		ToolName:       toolName,
+		ToolInput:      toolInput,
+		WorkDir:        workDir,
+		TranscriptPath: transcriptPath,
+	})
+}
+
+// daemonRequest connects to the daemon, auto-starting it if needed.
+func daemonRequest(req EvalRequest) (*EvalResponse, error) {
 	socketPath := defaultSocketPath()
 
 	// Try connecting to existing daemon
-	resp, err := sendDaemonRequest(socketPath, EvalRequest{
-		ToolName:  toolName,
-		ToolInput: toolInput,
-		WorkDir:   workDir,
-	})
+	resp, err := sendDaemonRequest(socketPath, req)
 	if err == nil {
 		return resp, nil
 	}
@@ -83,11 +293,7 @@ func queryDaemon(toolName, toolInput, workDir string) (*EvalResponse, error) {
 	// Retry with backoff (wait up to 2s for daemon to start)
 	for i := 0; i < 10; i++ {
 		time.Sleep(200 * time.Millisecond)
-		resp, err = sendDaemonRequest(socketPath, EvalRequest{
-			ToolName:  toolName,
-			ToolInput: toolInput,
-			WorkDir:   workDir,
-		})
+		resp, err = sendDaemonRequest(socketPath, req)
 		if err == nil {
 			return resp, nil
 		}
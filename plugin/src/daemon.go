@@ -18,9 +18,10 @@ import (
 
 // DaemonConfig holds daemon configuration.
 type DaemonConfig struct {
-	IdleTimeout time.Duration
-	SocketPath  string // override for testing; empty = default
-	PIDPath     string // override for testing; empty = default
+	IdleTimeout   time.Duration
+	SocketPath    string // override for testing; empty = default
+	PIDPath       string // override for testing; empty = default
+	ApprovalsPath string // override for testing; empty = default
 }
 
 func (c DaemonConfig) socketPath() string {
@@ -37,13 +38,22 @@ func (c DaemonConfig) pidPath() string {
 	return defaultPIDPath()
 }
 
+func (c DaemonConfig) approvalsPath() string {
+	if c.ApprovalsPath != "" {
+		return c.ApprovalsPath
+	}
+	return defaultSessionApprovalsPath()
+}
+
 // Daemon is a persistent Unix socket server that evaluates tool calls via Claude.
 type Daemon struct {
 	evaluator    Evaluator
 	config       DaemonConfig
+	approvals    *sessionApprovals
 	listener     net.Listener
 	shuttingDown atomic.Bool
 	wg           sync.WaitGroup
+	evalMu       sync.Mutex // one evaluation at a time, matching Claude Code's behavior
 }
 
 // NewDaemon creates a new daemon with the given evaluator and config.
@@ -51,6 +61,7 @@ func NewDaemon(evaluator Evaluator, config DaemonConfig) *Daemon {
 	return &Daemon{
 		evaluator: evaluator,
 		config:    config,
+		approvals: newSessionApprovals(config.approvalsPath()),
 	}
 }
 
@@ -107,9 +118,12 @@ func (d *Daemon) Run() error {
 			}
 			idleTimer.Reset(idleTimeout)
 			d.wg.Add(1)
-			// Handle sequentially (one request at a time, matching Claude Code's behavior)
-			d.handleConnection(conn)
-			d.wg.Done()
+			// Evaluations still run one at a time (see evalMu); session
+			// approval lookups are answered while one is in progress
+			go func() {
+				defer d.wg.Done()
+				d.handleConnection(conn)
+			}()
 		}
 	}()
 
@@ -135,17 +149,25 @@ func (d *Daemon) handleConnection(conn net.Conn) {
 
 	var req EvalRequest
 	if err := json.NewDecoder(conn).Decode(&req); err != nil {
-		resp := EvalResponse{Decision: "ASK", Reason: "failed to decode request: " + err.Error()}
+		resp := EvalResponse{Decision: "ASK", Reason: "failed to decode request: " + err.Error(), Failed: true}
 		json.NewEncoder(conn).Encode(resp)
 		return
 	}
 
+	if req.Type != RequestEvaluate {
+		json.NewEncoder(conn).Encode(d.approvals.handle(req))
+		return
+	}
+
+	d.evalMu.Lock()
+	defer d.evalMu.Unlock()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	resp, err := d.evaluator.Evaluate(ctx, req)
 	if err != nil {
-		resp = EvalResponse{Decision: "ASK", Reason: "evaluator error: " + err.Error()}
+		resp = EvalResponse{Decision: "ASK", Reason: "evaluator error: " + err.Error(), Failed: true}
 	}
 
 	json.NewEncoder(conn).Encode(resp)
@@ -186,30 +208,38 @@ func defaultPIDPath() string {
 // --- Daemon control commands ---
 
 func daemonStatus() {
+	status, running := daemonStatusLine()
+	fmt.Println(status)
+	fmt.Println("circuit breaker: " + loadBreaker().describe(time.Now()))
+	if !running {
+		os.Exit(1)
+	}
+}
+
+// daemonStatusLine describes the daemon's state, cleaning up after a daemon
+// that died without removing its PID file.
+func daemonStatusLine() (string, bool) {
 	pidPath := defaultPIDPath()
 	socketPath := defaultSocketPath()
 
 	pid, err := readPIDFile(pidPath)
 	if err != nil {
-		fmt.Println("not running")
-		os.Exit(1)
+		return "not running", false
 	}
 
 	if !processAlive(pid) {
-		fmt.Printf("not running (stale PID %d)\n", pid)
 		os.Remove(pidPath)
 		os.Remove(socketPath)
-		os.Exit(1)
+		return fmt.Sprintf("not running (stale PID %d)", pid), false
 	}
 
 	conn, err := net.DialTimeout("unix", socketPath, 1*time.Second)
 	if err != nil {
-		fmt.Printf("process %d alive but socket not responding\n", pid)
-		os.Exit(1)
+		return fmt.Sprintf("process %d alive but socket not responding", pid), false
 	}
 	conn.Close()
 
-	fmt.Printf("running (PID %d)\n", pid)
+	return fmt.Sprintf("running (PID %d)", pid), true
 }
 
 func daemonStop() {
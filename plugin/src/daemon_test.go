@@ -195,6 +195,51 @@ func TestDaemonCleanupOnShutdown(t *testing.T) {
 
 // --- Test helpers ---
 
+func TestDaemonSessionApprovals(t *testing.T) {
+	tmpDir := t.TempDir()
+	socketPath := filepath.Join(tmpDir, "test.sock")
+	approvalsPath := filepath.Join(tmpDir, "approvals.json")
+
+	mock := &mockEvaluator{response: EvalResponse{Decision: "ASK", Reason: "dangerous"}}
+	d := NewDaemon(mock, DaemonConfig{
+		IdleTimeout:   5 * time.Second,
+		SocketPath:    socketPath,
+		PIDPath:       filepath.Join(tmpDir, "test.pid"),
+		ApprovalsPath: approvalsPath,
+	})
+	go d.Run()
+	waitForSocket(t, socketPath, 2*time.Second)
+	defer d.Shutdown()
+
+	recall := EvalRequest{Type: RequestRecall, SessionID: "s1", Key: "k"}
+	if resp := sendTestRequest(t, socketPath, recall); resp.Decision != "ASK" {
+		t.Errorf("recall before approval = %s, want ASK", resp.Decision)
+	}
+	sendTestRequest(t, socketPath, EvalRequest{Type: RequestRemember, SessionID: "s1", Key: "k", TTL: 60})
+	if resp := sendTestRequest(t, socketPath, recall); resp.Decision != "ALLOW" {
+		t.Errorf("recall after approval = %s, want ALLOW", resp.Decision)
+	}
+	other := EvalRequest{Type: RequestRecall, SessionID: "s2", Key: "k"}
+	if resp := sendTestRequest(t, socketPath, other); resp.Decision != "ASK" {
+		t.Errorf("approval leaked to another session")
+	}
+	if mock.called != 0 {
+		t.Errorf("approval requests reached the evaluator %d times", mock.called)
+	}
+
+	// Persisted for the next daemon
+	if !newSessionApprovals(approvalsPath).recall("s1", "k") {
+		t.Errorf("approval not persisted to %s", approvalsPath)
+	}
+
+	if resp := sendTestRequest(t, socketPath, EvalRequest{Type: RequestForget, SessionID: "s1"}); resp.Reason != "forgot 1 approvals" {
+		t.Errorf("forget = %q", resp.Reason)
+	}
+	if resp := sendTestRequest(t, socketPath, recall); resp.Decision != "ASK" {
+		t.Errorf("recall after forget = %s, want ASK", resp.Decision)
+	}
+}
+
 func waitForSocket(t *testing.T, socketPath string, timeout time.Duration) {
 	t.Helper()
 	deadline := time.Now().Add(timeout)
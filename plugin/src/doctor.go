@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runDoctor is the entry point for `almost-yolo-guard doctor`. It reports the
+// policy and daemon state, and lists tools that reached the hook without being
+// classified by either the skip list or the rule engine.
+func runDoctor() {
+	policyPath := defaultPolicyPath()
+	policy, err := loadPolicy(policyPath)
+	switch {
+	case err != nil:
+		fmt.Printf("policy:  %s (invalid, using defaults: %v)\n", policyPath, err)
+	case fileExistsAt(policyPath):
+		fmt.Printf("policy:  %s\n", policyPath)
+	default:
+		fmt.Printf("policy:  defaults (no %s)\n", policyPath)
+	}
+
+	status, _ := daemonStatusLine()
+	fmt.Printf("daemon:  %s\n", status)
+	fmt.Printf("breaker: %s\n", loadBreaker().describe(time.Now()))
+
+	logPath := filepath.Join(configDir(), "decisions.log")
+	f, err := os.Open(logPath)
+	if err != nil {
+		fmt.Printf("log:     %s not found\n", logPath)
+		return
+	}
+	defer f.Close()
+
+	unclassified, err := unclassifiedTools(f, policy)
+	if err != nil {
+		fmt.Printf("log:     %s (stopped reading early: %v)\n", logPath, err)
+	}
+	if len(unclassified) == 0 {
+		fmt.Println("\nno unclassified tools")
+		return
+	}
+
+	fmt.Println("\nunclassified tools (add them to skip_tools in policy.json):")
+	for _, t := range unclassified {
+		fmt.Printf("  %-40s %d calls, last %s\n", t.Name, t.Count, t.LastSeen)
+	}
+}
+
+// toolSighting summarizes how often an unclassified tool appeared in the log.
+type toolSighting struct {
+	Name     string
+	Count    int
+	LastSeen string
+}
+
+// unclassifiedTools scans the decision log for tools that the policy neither
+// skips nor explicitly evaluates and that have no dedicated rules. Results are
+// sorted by call count, most frequent first. A read error is returned along
+// with the tools seen before it.
+func unclassifiedTools(log io.Reader, policy *Policy) ([]toolSighting, error) {
+	seen := map[string]*toolSighting{}
+
+	scanner := bufio.NewScanner(log)
+	// Older entries were logged without truncating rewritten inputs, so lines
+	// can be far longer than the scanner's 64KB default.
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name := logField(line, "tool")
+		if name == "" || strings.HasPrefix(name, "(") {
+			continue
+		}
+		if _, classified := policy.SkipTools[name]; classified || isRuleEngineTool(name) {
+			continue
+		}
+
+		s, ok := seen[name]
+		if !ok {
+			s = &toolSighting{Name: name}
+			seen[name] = s
+		}
+		s.Count++
+		if end := strings.Index(line, "]"); strings.HasPrefix(line, "[") && end > 0 {
+			s.LastSeen = line[1:end]
+		}
+	}
+
+	out := make([]toolSighting, 0, len(seen))
+	for _, s := range seen {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, scanner.Err()
+}
+
+// maxLogLine is the longest decision log line the doctor reads.
+const maxLogLine = 16 << 20
+
+// logField extracts the value of a "| key=value |" field from a log line.
+func logField(line, key string) string {
+	marker := "| " + key + "="
+	start := strings.Index(line, marker)
+	if start < 0 {
+		return ""
+	}
+	value := line[start+len(marker):]
+	if end := strings.Index(value, " |"); end >= 0 {
+		value = value[:end]
+	}
+	return value
+}
+
+func fileExistsAt(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
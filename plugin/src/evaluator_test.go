@@ -24,6 +24,10 @@ func TestParseDecision(t *testing.T) {
 		{"ALLOW\n\nThis is safe.", "ALLOW"},
 		{"  ALLOW  ", "ALLOW"},
 		{"  ASK  ", "ASK"},
+		{"DENY", "DENY"},
+		{"deny: wipes the home directory", "DENY"},
+		{"ALLOW - nothing here to deny", "ALLOW"},
+		{"I would DENY this", "ASK"}, // DENY must lead
 	}
 
 	for _, tt := range tests {
@@ -36,15 +40,32 @@ func TestParseDecision(t *testing.T) {
 	}
 }
 
+func TestEvaluatorExplanation(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+	}{
+		{"ASK", "the security evaluator flagged it"},
+		{"ASK - this modifies infrastructure", "the security evaluator flagged it: this modifies infrastructure"},
+		{"deny: wipes the home directory", "the security evaluator flagged it: wipes the home directory"},
+		{"This should be confirmed", "the security evaluator flagged it: This should be confirmed"},
+	}
+	for _, tt := range tests {
+		if got := evaluatorExplanation(tt.response); got != tt.want {
+			t.Errorf("evaluatorExplanation(%q) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
 func TestFormatPrompt(t *testing.T) {
-	prompt := FormatPrompt("Bash", `{"command":"ls"}`, "/proj")
+	prompt := FormatPrompt("Bash", `{"command":"ls"}`, "/proj", nil)
 
 	// Verify it contains the key parts
 	if got := prompt; got == "" {
 		t.Fatal("FormatPrompt returned empty string")
 	}
 
-	mustContain := []string{"Bash", `{"command":"ls"}`, "/proj", "ALLOW", "ASK"}
+	mustContain := []string{"Bash", `{"command":"ls"}`, "/proj", "ALLOW", "ASK", "DENY"}
 	for _, s := range mustContain {
 		if !containsString(prompt, s) {
 			t.Errorf("FormatPrompt missing %q in:\n%s", s, prompt)
@@ -2,91 +2,200 @@ package main
 
 import (
 	"encoding/json"
-	"io"
 	"os"
 )
 
-// HookInput matches Claude Code's PermissionRequest hook input
+// Hook events the client answers.
+const (
+	EventPermissionRequest = "PermissionRequest"
+	EventPreToolUse        = "PreToolUse"
+
+	// Outcome events; recorded, never answered
+	EventPostToolUse        = "PostToolUse"
+	EventPostToolUseFailure = "PostToolUseFailure"
+)
+
+// HookInput matches Claude Code's PermissionRequest, PreToolUse and
+// PostToolUse hook input
 type HookInput struct {
-	SessionID  string          `json:"session_id"`
-	ToolName   string          `json:"tool_name"`
-	ToolInput  json.RawMessage `json:"tool_input"`
-	WorkingDir string          `json:"cwd"`
+	SessionID      string          `json:"session_id"`
+	HookEventName  string          `json:"hook_event_name"`
+	ToolName       string          `json:"tool_name"`
+	ToolInput      json.RawMessage `json:"tool_input"`
+	WorkingDir     string          `json:"cwd"`
+	TranscriptPath string          `json:"transcript_path"`
+	ToolUseID      string          `json:"tool_use_id"`     // Claude Code's id for the call, where the event carries it
+	PermissionMode string          `json:"permission_mode"` // default, plan, acceptEdits or bypassPermissions
+
+	// PostToolUse and PostToolUseFailure only
+	ToolResponse json.RawMessage `json:"tool_response"`
+	Error        string          `json:"error"`
+	IsInterrupt  bool            `json:"is_interrupt"`
+
+	// Calls are the Claude Code calls another agent's tool call was
+	// translated into, when there is more than one (see translate)
+	Calls []toolCall `json:"-"`
 }
 
-// HookOutput for PermissionRequest uses hookSpecificOutput format
+// event returns the hook event, defaulting to PermissionRequest for inputs
+// that don't name one.
+func (h *HookInput) event() string {
+	if h.HookEventName == "" {
+		return EventPermissionRequest
+	}
+	return h.HookEventName
+}
+
+// HookOutput uses the hookSpecificOutput format. PermissionRequest answers
+// with Decision; PreToolUse with PermissionDecision and its reason.
+// SystemMessage is shown to the user whatever the event.
 type HookOutput struct {
 	HookSpecificOutput *HookSpecificOutput `json:"hookSpecificOutput,omitempty"`
+	SystemMessage      string              `json:"systemMessage,omitempty"`
 }
 
 type HookSpecificOutput struct {
-	HookEventName string    `json:"hookEventName"`
-	Decision      *Decision `json:"decision,omitempty"`
+	HookEventName            string          `json:"hookEventName"`
+	Decision                 *Decision       `json:"decision,omitempty"`
+	PermissionDecision       string          `json:"permissionDecision,omitempty"` // "allow", "deny" or "ask"
+	PermissionDecisionReason string          `json:"permissionDecisionReason,omitempty"`
+	UpdatedInput             json.RawMessage `json:"updatedInput,omitempty"`
+	AdditionalContext        string          `json:"additionalContext,omitempty"` // for Claude
 }
 
 type Decision struct {
-	Behavior string `json:"behavior"` // "allow" or "deny"
-	Message  string `json:"message,omitempty"`
+	Behavior     string          `json:"behavior"` // "allow" or "deny"
+	Message      string          `json:"message,omitempty"`
+	UpdatedInput json.RawMessage `json:"updatedInput,omitempty"` // replaces the tool input on allow
+}
+
+// shouldSkipEvaluation reports whether the active policy exempts this tool
+// call from security review.
+func shouldSkipEvaluation(toolName string, toolInput json.RawMessage) bool {
+	return currentPolicy().shouldSkipEvaluation(toolName, toolInput)
 }
 
-// skipEvaluationTools contains tools that don't need security evaluation.
-// These are either read-only, user-facing, or internal tracking tools.
-var skipEvaluationTools = map[string]bool{
-	// Plan mode - separate UX flow for plan approval
-	"ExitPlanMode":  true,
-	"EnterPlanMode": true,
-
-	// User interaction - just prompts the user
-	"AskUserQuestion": true,
-
-	// Task tracking - internal state management
-	"TaskCreate": true,
-	"TaskUpdate": true,
-	"TaskList":   true,
-	"TaskGet":    true,
-	"TaskStop":   true,
-	"TaskOutput": true,
-
-	// Read-only tools - no side effects
-	"Read":      true,
-	"Glob":      true,
-	"Grep":      true,
-	"WebFetch":  true,
-	"WebSearch": true,
-
-	// Subagent/skill invocation - spawns isolated work
-	"Task":  true,
-	"Skill": true,
+func writeAllowOutput() {
+	output := HookOutput{
+		HookSpecificOutput: &HookSpecificOutput{
+			HookEventName: "PermissionRequest",
+			Decision: &Decision{
+				Behavior: "allow",
+			},
+		},
+	}
+	json.NewEncoder(os.Stdout).Encode(output)
 }
 
-func shouldSkipEvaluation(toolName string) bool {
-	return skipEvaluationTools[toolName]
+// writeDenyOutput refuses the tool call. The message goes back to Claude, so
+// it says why and what to do instead of retrying.
+func writeDenyOutput(event, reason string) {
+	if event == EventPreToolUse {
+		output := HookOutput{
+			HookSpecificOutput: &HookSpecificOutput{
+				HookEventName:            "PreToolUse",
+				PermissionDecision:       "deny",
+				PermissionDecisionReason: denyMessage(reason),
+			},
+			SystemMessage: "almost-yolo-guard: denied because " + reason,
+		}
+		json.NewEncoder(os.Stdout).Encode(output)
+		return
+	}
+	output := HookOutput{
+		HookSpecificOutput: &HookSpecificOutput{
+			HookEventName: "PermissionRequest",
+			Decision: &Decision{
+				Behavior: "deny",
+				Message:  denyMessage(reason),
+			},
+		},
+		SystemMessage: "almost-yolo-guard: denied because " + reason,
+	}
+	json.NewEncoder(os.Stdout).Encode(output)
 }
 
-func readHookInput() (*HookInput, error) {
-	input, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		return nil, err
+// writeAskOutput leaves the call to the user with the guard's reason. For
+// PermissionRequest the dialog comes up as usual (there is no decision) with
+// the reason above it; for PreToolUse the dialog is forced. Either way Claude
+// gets the reason too, so it can pick a safer command next time.
+func writeAskOutput(event, reason string) {
+	if event == EventPreToolUse {
+		output := HookOutput{
+			HookSpecificOutput: &HookSpecificOutput{
+				HookEventName:            "PreToolUse",
+				PermissionDecision:       "ask",
+				PermissionDecisionReason: askMessage(reason),
+				AdditionalContext:        askContext(reason),
+			},
+		}
+		json.NewEncoder(os.Stdout).Encode(output)
+		return
 	}
-	var hookInput HookInput
-	if err := json.Unmarshal(input, &hookInput); err != nil {
-		return nil, err
+	output := HookOutput{
+		HookSpecificOutput: &HookSpecificOutput{
+			HookEventName:     "PermissionRequest",
+			AdditionalContext: askContext(reason),
+		},
+		SystemMessage: askMessage(reason),
 	}
-	return &hookInput, nil
+	json.NewEncoder(os.Stdout).Encode(output)
 }
 
-func writeAllowOutput() {
+// writeRewriteOutput allows the call with a rewritten tool input.
+func writeRewriteOutput(event string, rewritten rewrittenInput) {
+	if event == EventPreToolUse {
+		output := HookOutput{
+			HookSpecificOutput: &HookSpecificOutput{
+				HookEventName:            "PreToolUse",
+				PermissionDecision:       "allow",
+				PermissionDecisionReason: "almost-yolo-guard rewrote the command (" + rewritten.name + "): " + rewritten.command,
+				UpdatedInput:             rewritten.input,
+			},
+		}
+		json.NewEncoder(os.Stdout).Encode(output)
+		return
+	}
 	output := HookOutput{
 		HookSpecificOutput: &HookSpecificOutput{
 			HookEventName: "PermissionRequest",
 			Decision: &Decision{
-				Behavior: "allow",
+				Behavior:     "allow",
+				UpdatedInput: rewritten.input,
 			},
 		},
 	}
 	json.NewEncoder(os.Stdout).Encode(output)
 }
 
+// writePreToolUseOutput answers a PreToolUse event with a bare decision.
+func writePreToolUseOutput(decision, reason string) {
+	output := HookOutput{
+		HookSpecificOutput: &HookSpecificOutput{
+			HookEventName:            "PreToolUse",
+			PermissionDecision:       decision,
+			PermissionDecisionReason: reason,
+		},
+	}
+	json.NewEncoder(os.Stdout).Encode(output)
+}
+
+func askMessage(reason string) string {
+	return "almost-yolo-guard: asking because " + reason
+}
+
+func askContext(reason string) string {
+	return "almost-yolo-guard did not auto-approve this call because " + reason + ". " +
+		"The user is being asked to approve it. If a narrower command would do the job " +
+		"(a dry run, a read-only variant, a path inside the project, no --force), prefer it."
+}
+
+func denyMessage(reason string) string {
+	return "Blocked by almost-yolo-guard policy: " + reason + ". " +
+		"This was refused automatically, not by the user. Don't retry it or work around it; " +
+		"find a safer way to reach the goal, or ask the user to run it themselves if it is really intended."
+}
+
 func exitPassthrough(reason string) {
 	if reason != "" {
 		logDecision("(error)", "", "", "ASK", "passthrough", reason)
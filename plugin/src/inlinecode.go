@@ -0,0 +1,895 @@
+package main
+
+import (
+	"strings"
+)
+
+// Languages understood by analyzeInlineCode.
+const (
+	langPython = "python"
+	langJS     = "javascript"
+	langRuby   = "ruby"
+)
+
+// Token kinds produced by codeTokens.
+const (
+	tokName    = iota // identifier or dotted name (os.path.join, Net.HTTP, .rmSync)
+	tokString         // string literal contents
+	tokPunct          // single punctuation character; ";" also ends a line
+	tokCommand        // shell command literal (Ruby backticks, %x{})
+)
+
+type codeToken struct {
+	kind int
+	text string
+}
+
+// codeTokens splits source code into names, strings and punctuation,
+// dropping comments. Code inside string interpolation (f"{...}", `${...}`,
+// "#{...}") is tokenized as code, so it can't hide a call.
+func codeTokens(lang, code string) []codeToken {
+	var tokens []codeToken
+	emit := func(kind int, text string) {
+		// Join name.name and name::name into one dotted name
+		if kind == tokName && len(tokens) >= 1 {
+			last := tokens[len(tokens)-1]
+			if last.kind == tokPunct && last.text == "." {
+				if len(tokens) >= 2 && tokens[len(tokens)-2].kind == tokName {
+					tokens = tokens[:len(tokens)-1]
+					tokens[len(tokens)-1].text += "." + text
+					return
+				}
+				tokens[len(tokens)-1] = codeToken{tokName, "." + text}
+				return
+			}
+		}
+		tokens = append(tokens, codeToken{kind, text})
+	}
+
+	depth := 0
+	for i := 0; i < len(code); i++ {
+		ch := code[i]
+		switch {
+		case ch == '\n':
+			if depth == 0 {
+				emit(tokPunct, ";")
+			}
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\\':
+		case ch == '#' && lang != langJS:
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+			i--
+		case lang == langJS && strings.HasPrefix(code[i:], "//"):
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+			i--
+		case lang == langJS && strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 3
+		case isNameStart(lang, ch):
+			start := i
+			for i+1 < len(code) && isNameChar(lang, code[i+1]) {
+				i++
+			}
+			word := code[start : i+1]
+			// Python string prefixes: r"", b"", f"", rb"", ...
+			if lang == langPython && i+1 < len(code) && (code[i+1] == '"' || code[i+1] == '\'') && len(word) <= 2 &&
+				strings.Trim(strings.ToLower(word), "rbfu") == "" {
+				text, end := readCodeString(code, i+1, !strings.ContainsAny(strings.ToLower(word), "r"))
+				emitString(lang, text, strings.ContainsAny(strings.ToLower(word), "f"), emit, &tokens)
+				i = end
+				continue
+			}
+			emit(tokName, word)
+		case lang == langRuby && ch == '%' && i+2 < len(code) && strings.IndexByte("wWqQiIx", code[i+1]) >= 0 && strings.IndexByte("([{<|!/", code[i+2]) >= 0:
+			text, end := readDelimited(code, i+2)
+			switch code[i+1] {
+			case 'x':
+				emit(tokCommand, text)
+			case 'Q', 'W':
+				emitString(lang, text, true, emit, &tokens)
+			default:
+				emit(tokString, text)
+			}
+			i = end
+		case ch == '"' || ch == '\'' || ch == '`':
+			text, end := readCodeString(code, i, true)
+			switch {
+			case ch == '`' && lang == langRuby:
+				emit(tokCommand, text)
+			case ch == '`' || (ch == '"' && lang == langRuby):
+				emitString(lang, text, true, emit, &tokens)
+			default:
+				emit(tokString, text)
+			}
+			i = end
+		case ch >= '0' && ch <= '9':
+			for i+1 < len(code) && (isNameChar(lang, code[i+1]) || code[i+1] == '.') {
+				i++
+			}
+		default:
+			switch ch {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				if depth > 0 {
+					depth--
+				}
+			}
+			if ch == ':' && lang == langRuby && i+1 < len(code) && code[i+1] == ':' {
+				emit(tokPunct, ".")
+				i++
+				continue
+			}
+			emit(tokPunct, string(ch))
+		}
+	}
+	return tokens
+}
+
+// emitString emits a string literal, tokenizing interpolated code as well.
+func emitString(lang, text string, interpolated bool, emit func(int, string), tokens *[]codeToken) {
+	emit(tokString, text)
+	if !interpolated {
+		return
+	}
+	open := "{"
+	switch lang {
+	case langJS:
+		open = "${"
+	case langRuby:
+		open = "#{"
+	}
+	for {
+		start := strings.Index(text, open)
+		if start < 0 {
+			return
+		}
+		end := matchingBracket(text, start+len(open)-1)
+		if end < 0 {
+			return
+		}
+		inner := codeTokens(lang, text[start+len(open):end])
+		*tokens = append(*tokens, codeToken{tokPunct, ";"})
+		*tokens = append(*tokens, inner...)
+		*tokens = append(*tokens, codeToken{tokPunct, ";"})
+		text = text[end+1:]
+	}
+}
+
+// readCodeString reads a quoted string starting at the quote at start,
+// including Python triple quotes, and returns its contents and the index of
+// the closing quote.
+func readCodeString(code string, start int, escapes bool) (string, int) {
+	quote := code[start : start+1]
+	if strings.HasPrefix(code[start:], strings.Repeat(quote, 3)) {
+		quote = strings.Repeat(quote, 3)
+	}
+	var b strings.Builder
+	for i := start + len(quote); i < len(code); i++ {
+		if escapes && code[i] == '\\' && i+1 < len(code) {
+			b.WriteByte(code[i+1])
+			i++
+			continue
+		}
+		if strings.HasPrefix(code[i:], quote) {
+			return b.String(), i + len(quote) - 1
+		}
+		b.WriteByte(code[i])
+	}
+	return b.String(), len(code) - 1
+}
+
+// readDelimited reads a Ruby percent literal whose opening delimiter is at
+// start.
+func readDelimited(code string, start int) (string, int) {
+	closer := map[byte]byte{'(': ')', '[': ']', '{': '}', '<': '>'}[code[start]]
+	if closer == 0 {
+		closer = code[start]
+	}
+	depth := 0
+	for i := start + 1; i < len(code); i++ {
+		switch code[i] {
+		case code[start]:
+			if closer != code[start] {
+				depth++
+			}
+		case closer:
+			if depth == 0 {
+				return code[start+1 : i], i
+			}
+			depth--
+		}
+	}
+	return code[start+1:], len(code) - 1
+}
+
+func isNameStart(lang string, ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (lang == langJS && ch == '$') ||
+		(lang == langRuby && (ch == '@' || ch == '$'))
+}
+
+func isNameChar(lang string, ch byte) bool {
+	return isNameStart(lang, ch) || (ch >= '0' && ch <= '9') || (lang == langRuby && (ch == '?' || ch == '!'))
+}
+
+// codeFindings collects what a piece of code does, without duplicates.
+type codeFindings struct {
+	list []string
+	seen map[string]bool
+}
+
+func (f *codeFindings) add(finding string) {
+	if f.seen == nil {
+		f.seen = map[string]bool{}
+	}
+	if !f.seen[finding] {
+		f.seen[finding] = true
+		f.list = append(f.list, finding)
+	}
+}
+
+// analyzeInlineCode returns what inline code does beyond parsing data and
+// printing: modules outside a standard-library allowlist, commands, file
+// writes outside workDir, deletes, network access and dynamic evaluation.
+// Dynamic attribute access and calls by name (getattr, send, obj[name]) are
+// findings too, since they can reach any of those. Nothing found means the
+// code is safe to run without review.
+func analyzeInlineCode(lang, code, workDir string) []string {
+	tokens := codeTokens(lang, code)
+	var f codeFindings
+	switch lang {
+	case langPython:
+		analyzePython(tokens, workDir, &f)
+	case langJS:
+		analyzeJS(tokens, workDir, &f)
+	case langRuby:
+		analyzeRuby(tokens, workDir, &f)
+	}
+	return f.list
+}
+
+// callArgs returns the comma-separated arguments of the call whose opening
+// parenthesis is at tokens[open].
+func callArgs(tokens []codeToken, open int) [][]codeToken {
+	if open >= len(tokens) || tokens[open].text != "(" {
+		return nil
+	}
+	var args [][]codeToken
+	var current []codeToken
+	depth := 0
+	for i := open + 1; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind == tokPunct {
+			switch t.text {
+			case "(", "[", "{":
+				depth++
+			case ")", "]", "}":
+				if depth == 0 {
+					if len(current) > 0 {
+						args = append(args, current)
+					}
+					return args
+				}
+				depth--
+			case ",":
+				if depth == 0 {
+					args = append(args, current)
+					current = nil
+					continue
+				}
+			}
+		}
+		current = append(current, t)
+	}
+	return append(args, current)
+}
+
+// literalArg returns the string literal an argument consists of.
+func literalArg(arg []codeToken) (string, bool) {
+	if len(arg) == 1 && arg[0].kind == tokString {
+		return arg[0].text, true
+	}
+	return "", false
+}
+
+// checkWritePath records a write unless it targets a literal path inside
+// workDir.
+func checkWritePath(f *codeFindings, what string, pathArg []codeToken, workDir string) {
+	path, ok := literalArg(pathArg)
+	if !ok {
+		f.add(what + " writes a computed path")
+		return
+	}
+	if resolved := resolvePath(expandHome(path), workDir); workDir != "" && isWithinDir(resolved, workDir) {
+		return
+	}
+	f.add(what + " writes " + path + " outside the project")
+}
+
+// moduleAllowed reports whether a module or one of its parents is listed.
+func moduleAllowed(module string, allowed map[string]bool) bool {
+	for name := module; name != ""; {
+		if allowed[name] {
+			return true
+		}
+		i := strings.LastIndexAny(name, "./")
+		if i < 0 {
+			return false
+		}
+		name = name[:i]
+	}
+	return false
+}
+
+// lastSegment returns the part of a dotted name after the last dot.
+func lastSegment(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// --- Python ---
+
+// pythonSafeModules are imported freely: parsing, formatting, math, and
+// modules whose dangerous functions are checked one by one (os, shutil,
+// pathlib).
+var pythonSafeModules = map[string]bool{
+	"json": true, "sys": true, "re": true, "math": true, "cmath": true, "collections": true,
+	"itertools": true, "functools": true, "datetime": true, "time": true, "base64": true,
+	"hashlib": true, "hmac": true, "csv": true, "string": true, "textwrap": true, "pprint": true,
+	"statistics": true, "decimal": true, "fractions": true, "uuid": true, "random": true,
+	"operator": true, "typing": true, "dataclasses": true, "enum": true, "copy": true,
+	"heapq": true, "bisect": true, "html": true, "unicodedata": true, "binascii": true,
+	"struct": true, "zlib": true, "gzip": true, "bz2": true, "lzma": true, "tomllib": true,
+	"configparser": true, "xml.etree": true, "xml.dom.minidom": true, "urllib.parse": true,
+	"os": true, "pathlib": true, "glob": true, "fnmatch": true, "shlex": true, "platform": true,
+	"locale": true, "ast": true, "difflib": true, "argparse": true, "io": true, "contextlib": true,
+	"traceback": true, "calendar": true, "codecs": true, "array": true, "secrets": true,
+	"ipaddress": true, "email": true, "yaml": true, "toml": true, "tempfile": true,
+	"logging": true, "inspect": true, "keyword": true, "shutil": true, "zoneinfo": true,
+	"abc": true, "numbers": true, "graphlib": true, "quopri": true, "mimetypes": true,
+	"colorsys": true, "tokenize": true, "token": true, "warnings": true, "weakref": true,
+	"__future__": true,
+}
+
+// pythonModuleNotes explain the risky modules that aren't allowed.
+var pythonModuleNotes = map[string]string{
+	"subprocess": "runs commands", "socket": "network", "urllib.request": "network",
+	"http.client": "network", "requests": "network", "httpx": "network", "aiohttp": "network",
+	"ftplib": "network", "smtplib": "network", "telnetlib": "network", "paramiko": "network",
+	"ctypes": "native code", "pty": "runs commands", "multiprocessing": "runs processes",
+	"importlib": "dynamic import", "pickle": "deserializes code", "marshal": "deserializes code",
+	"sqlite3": "writes databases", "zipfile": "writes archives", "tarfile": "writes archives",
+	"webbrowser": "opens programs", "signal": "signals processes",
+}
+
+var pythonDangerousCalls = map[string]string{
+	"os.system": "runs commands", "os.popen": "runs commands", "os.remove": "deletes files",
+	"os.unlink": "deletes files", "os.rmdir": "deletes directories", "os.removedirs": "deletes directories",
+	"os.rename": "moves files", "os.renames": "moves files", "os.replace": "moves files",
+	"os.chmod": "changes permissions", "os.chown": "changes ownership", "os.kill": "signals processes",
+	"os.killpg": "signals processes", "os.fork": "forks", "os.truncate": "truncates files",
+	"os.symlink": "creates links", "os.link": "creates links", "os.putenv": "changes the environment",
+	"os.startfile": "runs programs", "os.forkpty": "forks", "os.mkfifo": "creates files",
+	"os.mknod": "creates files", "sys.modules": "dynamic module access",
+	"shutil.rmtree": "deletes directories", "shutil.move": "moves files", "shutil.copy": "copies files",
+	"shutil.copy2": "copies files", "shutil.copyfile": "copies files", "shutil.copytree": "copies files",
+	"shutil.chown": "changes ownership", "shutil.unpack_archive": "writes archives",
+	"shutil.make_archive": "writes archives",
+}
+
+// pythonWriteMethods change the filesystem whatever object they are called on.
+var pythonWriteMethods = map[string]bool{
+	"write_text": true, "write_bytes": true, "unlink": true, "rmdir": true, "rename": true,
+	"chmod": true, "touch": true, "symlink_to": true, "hardlink_to": true,
+}
+
+// pythonProgramPrefixes start the os.exec* and os.spawn* families.
+var pythonProgramPrefixes = []string{"os.exec", "os.spawn", "os.posix_spawn"}
+
+// pythonOpenFunctions open files; their mode or flags decide whether they
+// write.
+var pythonOpenFunctions = map[string]bool{
+	"open": true, "io.open": true, "codecs.open": true, "builtins.open": true, "os.open": true,
+}
+
+// pythonOpenWriteFlags make os.open open a file for writing.
+var pythonOpenWriteFlags = []string{"O_WRONLY", "O_RDWR", "O_CREAT", "O_APPEND", "O_TRUNC"}
+
+var pythonBuiltins = map[string]string{
+	"eval": "eval", "exec": "exec", "compile": "compiles code", "__import__": "dynamic import",
+	"breakpoint": "debugger", "getattr": "dynamic attribute access",
+	"setattr": "dynamic attribute access", "delattr": "dynamic attribute access",
+	"vars": "dynamic attribute access", "globals": "dynamic attribute access",
+	"locals": "dynamic attribute access",
+}
+
+// pythonPlainDunders are double-underscore names that don't reach other
+// objects' attributes.
+var pythonPlainDunders = map[string]bool{
+	"__name__": true, "__main__": true, "__file__": true, "__doc__": true,
+	"__version__": true, "__init__": true, "__all__": true,
+}
+
+// pythonDunderAccess returns the first dunder attribute in a dotted name
+// (os.__dict__, __builtins__.eval), or "".
+func pythonDunderAccess(name string) string {
+	for _, part := range strings.Split(name, ".") {
+		if len(part) > 4 && strings.HasPrefix(part, "__") && strings.HasSuffix(part, "__") && !pythonPlainDunders[part] {
+			return part
+		}
+	}
+	return ""
+}
+
+func analyzePython(tokens []codeToken, workDir string, f *codeFindings) {
+	aliases := map[string]string{}
+	importModule := func(module string) {
+		if moduleAllowed(module, pythonSafeModules) {
+			return
+		}
+		if note := pythonModuleNotes[module]; note != "" {
+			f.add("imports " + module + " (" + note + ")")
+		} else if note := pythonModuleNotes[strings.Split(module, ".")[0]]; note != "" {
+			f.add("imports " + module + " (" + note + ")")
+		} else {
+			f.add("imports " + module)
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != tokName {
+			continue
+		}
+		switch t.text {
+		case "import":
+			// import a.b [as c], d
+			for i++; i < len(tokens) && tokens[i].text != ";"; i++ {
+				if tokens[i].kind != tokName {
+					continue
+				}
+				module := tokens[i].text
+				importModule(module)
+				if i+2 < len(tokens) && tokens[i+1].text == "as" {
+					aliases[tokens[i+2].text] = module
+					i += 2
+				}
+			}
+			continue
+		case "from":
+			// from a.b import c [as d], e
+			if i+2 >= len(tokens) || tokens[i+2].text != "import" {
+				continue
+			}
+			module := tokens[i+1].text
+			importModule(module)
+			for i += 3; i < len(tokens) && tokens[i].text != ";"; i++ {
+				if tokens[i].kind != tokName {
+					if tokens[i].text == "*" {
+						f.add("from " + module + " import *")
+					}
+					continue
+				}
+				name := tokens[i].text
+				if i+2 < len(tokens) && tokens[i+1].text == "as" {
+					aliases[tokens[i+2].text] = module + "." + name
+					i += 2
+				} else {
+					aliases[name] = module + "." + name
+				}
+			}
+			continue
+		}
+
+		name := t.text
+		if head, rest, _ := strings.Cut(name, "."); aliases[head] != "" {
+			name = strings.TrimSuffix(aliases[head]+"."+rest, ".")
+		}
+		calls := i+1 < len(tokens) && tokens[i+1].text == "("
+
+		if note, ok := pythonDangerousCalls[name]; ok {
+			f.add(name + " " + note)
+			continue
+		}
+		if hasAnyPrefix(name, pythonProgramPrefixes) {
+			f.add(name + " runs programs")
+			continue
+		}
+		if note, ok := pythonBuiltins[t.text]; ok && calls {
+			f.add(note)
+			continue
+		}
+		if dunder := pythonDunderAccess(name); dunder != "" {
+			f.add(dunder + " dynamic attribute access")
+			continue
+		}
+		if (name == "os" || name == "sys") && i+1 < len(tokens) && tokens[i+1].text == "[" {
+			f.add(name + "[...] dynamic attribute access")
+			continue
+		}
+		if name == "os.open" && calls {
+			// os.open(path, os.O_WRONLY | os.O_CREAT), then os.write to the fd
+			args := callArgs(tokens, i+1)
+			if len(args) > 1 && !pythonReadOnlyFlags(args[1]) {
+				checkWritePath(f, "os.open()", args[0], workDir)
+			}
+			continue
+		}
+		if strings.Contains(t.text, ".") && pythonWriteMethods[lastSegment(t.text)] && calls {
+			f.add(lastSegment(t.text) + "() changes files")
+			continue
+		}
+		if pythonOpenFunctions[name] && !calls {
+			// o = open; o(path, 'w') hides the mode from the checks below
+			f.add(name + " used without a call")
+			continue
+		}
+		if pythonOpenFunctions[name] && name != "os.open" {
+			args := callArgs(tokens, i+1)
+			if len(args) == 0 {
+				continue
+			}
+			mode, known := "r", true
+			if len(args) > 1 && !isKeywordArg(args[1]) {
+				mode, known = literalArg(args[1])
+			}
+			for _, arg := range args[1:] {
+				if isKeywordArg(arg) && arg[0].text == "mode" {
+					mode, known = literalArg(arg[2:])
+				}
+			}
+			if !known || strings.ContainsAny(mode, "wax+") {
+				checkWritePath(f, "open()", args[0], workDir)
+			}
+		}
+	}
+}
+
+// isKeywordArg reports whether a call argument is name=value.
+func isKeywordArg(arg []codeToken) bool {
+	return len(arg) > 2 && arg[0].kind == tokName && arg[1].text == "="
+}
+
+// pythonReadOnlyFlags reports whether os.open flags are literal names without
+// a write flag.
+func pythonReadOnlyFlags(arg []codeToken) bool {
+	for _, t := range arg {
+		switch {
+		case t.kind == tokName:
+			for _, flag := range pythonOpenWriteFlags {
+				if lastSegment(t.text) == flag {
+					return false
+				}
+			}
+		case t.kind != tokPunct || t.text != "|":
+			return false // numeric or computed flags
+		}
+	}
+	return true
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// --- JavaScript ---
+
+// jsSafeModules are Node built-ins for parsing, formatting and reading.
+// fs is allowed; its writing and deleting functions are checked one by one.
+var jsSafeModules = map[string]bool{
+	"path": true, "util": true, "url": true, "querystring": true, "assert": true, "os": true,
+	"readline": true, "string_decoder": true, "buffer": true, "events": true, "zlib": true,
+	"crypto": true, "fs": true, "fs/promises": true, "stream": true, "timers": true,
+	"perf_hooks": true, "process": true, "console": true,
+}
+
+var jsModuleNotes = map[string]string{
+	"child_process": "runs commands", "net": "network", "http": "network", "https": "network",
+	"http2": "network", "dgram": "network", "tls": "network", "dns": "network",
+	"vm": "evaluates code", "worker_threads": "runs threads", "cluster": "runs processes",
+	"inspector": "debugger",
+}
+
+// jsDangerousFunctions change files whatever object they are called on (fs,
+// fs.promises, destructured imports).
+var jsDangerousFunctions = map[string]string{
+	"rm": "deletes files", "rmSync": "deletes files", "rmdir": "deletes directories",
+	"rmdirSync": "deletes directories", "unlink": "deletes files", "unlinkSync": "deletes files",
+	"rename": "moves files", "renameSync": "moves files", "chmod": "changes permissions",
+	"chmodSync": "changes permissions", "chown": "changes ownership", "chownSync": "changes ownership",
+	"copyFile": "copies files", "copyFileSync": "copies files", "cp": "copies files", "cpSync": "copies files",
+	"symlink": "creates links", "symlinkSync": "creates links", "link": "creates links",
+	"linkSync": "creates links", "truncate": "truncates files", "truncateSync": "truncates files",
+	"ftruncate": "truncates files", "ftruncateSync": "truncates files", "lchown": "changes ownership",
+	"lchownSync": "changes ownership", "fchown": "changes ownership", "fchownSync": "changes ownership",
+	"lchmod": "changes permissions", "lchmodSync": "changes permissions", "fchmod": "changes permissions",
+	"fchmodSync": "changes permissions",
+}
+
+// jsOpenFunctions open a file descriptor that write and writeSync then use.
+var jsOpenFunctions = map[string]bool{
+	"open": true, "openSync": true,
+}
+
+// jsDynamicRoots are objects whose properties reach modules, processes or
+// code, so indexing them by name is a finding.
+var jsDynamicRoots = map[string]bool{
+	"process": true, "global": true, "globalThis": true, "require": true, "module": true,
+	"Deno": true, "Bun": true,
+}
+
+var jsWriteFunctions = map[string]bool{
+	"writeFile": true, "writeFileSync": true, "appendFile": true, "appendFileSync": true,
+	"createWriteStream": true,
+}
+
+var jsGlobals = map[string]string{
+	"eval": "eval", "Function": "evaluates code", "fetch": "network", "XMLHttpRequest": "network",
+	"WebSocket": "network", "process.kill": "signals processes", "process.binding": "native bindings",
+	"process.dlopen": "native code", "process.mainModule": "module loader",
+	"module.constructor": "module loader", "Deno.run": "runs commands", "Deno.Command": "runs commands",
+	"Deno.remove": "deletes files", "Deno.writeFile": "writes files", "Deno.writeTextFile": "writes files",
+	"Deno.connect": "network", "Bun.spawn": "runs commands", "Bun.spawnSync": "runs commands",
+	"Bun.write": "writes files", "Bun.$": "runs commands",
+}
+
+func analyzeJS(tokens []codeToken, workDir string, f *codeFindings) {
+	requireModule := func(module string) {
+		module = strings.TrimPrefix(module, "node:")
+		if moduleAllowed(module, jsSafeModules) {
+			return
+		}
+		if note := jsModuleNotes[module]; note != "" {
+			f.add("requires " + module + " (" + note + ")")
+		} else {
+			f.add("requires " + module)
+		}
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != tokName {
+			continue
+		}
+		calls := i+1 < len(tokens) && tokens[i+1].text == "("
+
+		switch {
+		case (t.text == "require" || t.text == "import" || strings.HasSuffix(t.text, ".require")) && calls:
+			args := callArgs(tokens, i+1)
+			if len(args) > 0 {
+				if module, ok := literalArg(args[0]); ok {
+					requireModule(module)
+					continue
+				}
+			}
+			f.add("dynamic " + t.text + "()")
+			continue
+		case t.text == "import" || (t.text == "from" && i > 0):
+			// import x from 'mod', import 'mod', export ... from 'mod'
+			if i+1 < len(tokens) && tokens[i+1].kind == tokString {
+				requireModule(tokens[i+1].text)
+			}
+			continue
+		}
+
+		if note, ok := jsGlobals[t.text]; ok && (calls || strings.Contains(t.text, ".") || t.text == "Function") {
+			f.add(t.text + " " + note)
+			continue
+		}
+		if jsDynamicRoots[t.text] && i+1 < len(tokens) && tokens[i+1].text == "[" {
+			f.add(t.text + "[...] dynamic property access")
+			continue
+		}
+		fn := lastSegment(t.text)
+		if note, ok := jsDangerousFunctions[fn]; ok && calls {
+			f.add(fn + "() " + note)
+			continue
+		}
+		if jsOpenFunctions[fn] && calls && strings.Contains(t.text, ".") {
+			// fs.openSync(path, 'a'): the default flag 'r' only reads
+			if args := callArgs(tokens, i+1); len(args) > 1 {
+				if flags, ok := literalArg(args[1]); !ok || strings.ContainsAny(flags, "wa+") {
+					checkWritePath(f, fn+"()", args[0], workDir)
+				}
+			}
+			continue
+		}
+		if jsWriteFunctions[fn] && calls {
+			if args := callArgs(tokens, i+1); len(args) > 0 {
+				checkWritePath(f, fn+"()", args[0], workDir)
+			}
+		}
+	}
+}
+
+// --- Ruby ---
+
+var rubySafeLibraries = map[string]bool{
+	"json": true, "yaml": true, "psych": true, "set": true, "date": true, "time": true,
+	"csv": true, "pp": true, "securerandom": true, "digest": true, "base64": true, "uri": true,
+	"erb": true, "optparse": true, "stringio": true, "bigdecimal": true, "pathname": true,
+	"fileutils": true, "English": true, "shellwords": true, "ostruct": true, "benchmark": true,
+	"logger": true, "tempfile": true, "zlib": true, "strscan": true, "prettyprint": true,
+}
+
+var rubyLibraryNotes = map[string]string{
+	"open3": "runs commands", "net/http": "network", "socket": "network", "open-uri": "network",
+	"net/ssh": "network", "pty": "runs commands", "fiddle": "native code",
+}
+
+var rubyDangerousCalls = map[string]string{
+	"system": "runs commands", "exec": "runs programs", "spawn": "runs commands", "fork": "forks",
+	"syscall": "system calls", "eval": "eval", "instance_eval": "eval", "class_eval": "eval",
+	"module_eval": "eval", "IO.popen": "runs commands", "Kernel.system": "runs commands",
+	"Kernel.exec": "runs programs", "Kernel.spawn": "runs commands", "Process.spawn": "runs commands",
+	"Process.exec": "runs programs", "Process.kill": "signals processes", "File.delete": "deletes files",
+	"File.unlink": "deletes files", "File.rename": "moves files", "File.chmod": "changes permissions",
+	"File.chown": "changes ownership", "File.symlink": "creates links", "Dir.rmdir": "deletes directories",
+	"Dir.delete": "deletes directories", "Dir.unlink": "deletes directories", "URI.open": "network",
+	"Net.HTTP": "network", "TCPSocket": "network", "UDPSocket": "network", "Socket": "network",
+}
+
+// rubyDynamicCalls call methods or reach constants by name.
+var rubyDynamicCalls = map[string]string{
+	"send": "dynamic call", "public_send": "dynamic call", "__send__": "dynamic call",
+	"method": "dynamic call", "const_get": "dynamic constant access",
+	"instance_variable_get": "dynamic attribute access",
+}
+
+// rubyPipeOpeners run a command when their path starts with "|".
+var rubyPipeOpeners = map[string]bool{
+	"open": true, "Kernel.open": true, "IO.read": true, "IO.readlines": true,
+	"IO.foreach": true, "IO.binread": true, "IO.write": true, "IO.binwrite": true,
+}
+
+func analyzeRuby(tokens []codeToken, workDir string, f *codeFindings) {
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind == tokCommand {
+			f.add("runs `" + t.text + "`")
+			continue
+		}
+		if t.kind != tokName {
+			continue
+		}
+		calls := i+1 < len(tokens) && (tokens[i+1].text == "(" || tokens[i+1].kind == tokString)
+		args := rubyArgs(tokens, i)
+
+		switch t.text {
+		case "require", "require_relative", "load":
+			if len(args) > 0 {
+				if lib, ok := literalArg(args[0]); ok {
+					if t.text == "require" {
+						rubyRequire(f, lib)
+					} else {
+						f.add(t.text + " " + lib)
+					}
+					continue
+				}
+			}
+			f.add("dynamic " + t.text)
+			continue
+		}
+
+		name := t.text
+		if rubyPipeOpeners[name] && len(args) > 0 {
+			path, ok := literalArg(args[0])
+			switch {
+			case !ok:
+				f.add(name + " of a computed path (can run a command)")
+				continue
+			case strings.HasPrefix(path, "|"):
+				f.add(name + " runs `" + strings.TrimPrefix(path, "|") + "`")
+				continue
+			}
+		}
+		// send(:system, ...) and friends, on any receiver and without
+		// parentheses
+		if note, ok := rubyDynamicCalls[lastSegment(name)]; ok && i+1 < len(tokens) &&
+			(calls || tokens[i+1].text == ":") {
+			f.add(name + " " + note)
+			continue
+		}
+		if strings.HasPrefix(name, "FileUtils.") {
+			switch lastSegment(name) {
+			case "pwd", "compare_file", "identical?", "cmp", "uptodate?":
+			default:
+				f.add(name + " changes files")
+			}
+			continue
+		}
+		if strings.HasPrefix(name, "Open3.") {
+			f.add(name + " runs commands")
+			continue
+		}
+		if note, ok := rubyDangerousCalls[name]; ok && (calls || strings.Contains(name, ".") || isCapitalized(name)) {
+			f.add(name + " " + note)
+			continue
+		}
+		if strings.HasPrefix(name, "Net.") {
+			f.add(name + " network")
+			continue
+		}
+		if name == "File.write" || name == "IO.write" || name == "File.binwrite" {
+			if len(args) > 0 {
+				checkWritePath(f, name, args[0], workDir)
+			}
+			continue
+		}
+		if name == "File.open" || name == "File.new" {
+			if len(args) > 1 {
+				if mode, ok := literalArg(args[1]); ok && strings.ContainsAny(mode, "wa+") {
+					checkWritePath(f, name, args[0], workDir)
+				}
+			}
+		}
+	}
+}
+
+// rubyArgs returns the arguments of a call written with or without
+// parentheses (require 'json', File.write("x", y)).
+func rubyArgs(tokens []codeToken, name int) [][]codeToken {
+	if name+1 >= len(tokens) {
+		return nil
+	}
+	if tokens[name+1].text == "(" {
+		return callArgs(tokens, name+1)
+	}
+	var args [][]codeToken
+	var current []codeToken
+	for i := name + 1; i < len(tokens) && tokens[i].text != ";"; i++ {
+		if tokens[i].text == "," {
+			args = append(args, current)
+			current = nil
+			continue
+		}
+		if tokens[i].kind == tokName && (tokens[i].text == "do" || tokens[i].text == "if" || tokens[i].text == "unless") {
+			break
+		}
+		current = append(current, tokens[i])
+	}
+	if len(current) > 0 {
+		args = append(args, current)
+	}
+	return args
+}
+
+func rubyRequire(f *codeFindings, lib string) {
+	if rubySafeLibraries[lib] {
+		return
+	}
+	if note := rubyLibraryNotes[lib]; note != "" {
+		f.add("requires " + lib + " (" + note + ")")
+		return
+	}
+	f.add("requires " + lib)
+}
+
+func isCapitalized(name string) bool {
+	return name != "" && name[0] >= 'A' && name[0] <= 'Z'
+}
+
+// inlineLanguage maps an interpreter to the language analyzeInlineCode
+// understands, or "".
+func inlineLanguage(cmd string) string {
+	switch {
+	case strings.HasPrefix(cmd, "python"):
+		return langPython
+	case cmd == "node" || cmd == "deno" || cmd == "bun":
+		return langJS
+	case cmd == "ruby":
+		return langRuby
+	}
+	return ""
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 var testBinary string
@@ -19,6 +20,9 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	// Keep kubectl/helm decisions independent of the developer's kubeconfig
+	os.Setenv("KUBECONFIG", filepath.Join(tmpDir, "kubeconfig"))
+
 	testBinary = filepath.Join(tmpDir, "almost-yolo-guard")
 	cmd := exec.Command("go", "build", "-o", testBinary, ".")
 	if output, err := cmd.CombinedOutput(); err != nil {
@@ -34,11 +38,17 @@ func TestMain(m *testing.M) {
 
 func runBinary(t *testing.T, input string) (string, int) {
 	t.Helper()
-	cmd := exec.Command(testBinary)
-	cmd.Stdin = strings.NewReader(input)
-
 	// Prevent daemon auto-start by using a nonexistent socket path
-	cmd.Env = append(os.Environ(), "HOME="+t.TempDir())
+	return runBinaryInHome(t, t.TempDir(), input)
+}
+
+// runBinaryInHome runs the binary with a given HOME, so that several calls
+// share the guard's state.
+func runBinaryInHome(t *testing.T, home, input string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(testBinary, args...)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(os.Environ(), "HOME="+home, "CLAUDE_CONFIG_DIR="+filepath.Join(home, ".claude"))
 
 	output, err := cmd.Output()
 	exitCode := 0
@@ -122,24 +132,20 @@ func TestIntegrationDangerousBashCommand(t *testing.T) {
 			if exitCode != 0 {
 				t.Errorf("expected exit code 0, got %d", exitCode)
 			}
-			// Dangerous commands should produce no output (ASK = passthrough)
-			if strings.TrimSpace(output) != "" {
-				t.Errorf("expected no output for dangerous command %q, got: %s", tt.command, output)
-			}
+			// Dangerous commands fall through to the dialog with the reason shown
+			assertAskOutput(t, output, "")
 		})
 	}
 }
 
 func TestIntegrationUnknownCommandFallsafe(t *testing.T) {
-	// Unknown command + no daemon = fail-safe to ASK (no output)
+	// Unknown command + no daemon = fail-safe to ASK (dialog, no decision)
 	input := `{"session_id":"test","tool_name":"Bash","tool_input":{"command":"some-unknown-tool --flag"},"cwd":"/tmp/project"}`
 	output, exitCode := runBinary(t, input)
 	if exitCode != 0 {
 		t.Errorf("expected exit code 0, got %d", exitCode)
 	}
-	if strings.TrimSpace(output) != "" {
-		t.Errorf("expected no output for unknown command with no daemon, got: %s", output)
-	}
+	assertAskOutput(t, output, "")
 }
 
 func TestIntegrationWriteProjectFile(t *testing.T) {
@@ -165,9 +171,7 @@ func TestIntegrationWriteSystemFile(t *testing.T) {
 	if exitCode != 0 {
 		t.Errorf("expected exit code 0, got %d", exitCode)
 	}
-	if strings.TrimSpace(output) != "" {
-		t.Errorf("expected no output for system file write, got: %s", output)
-	}
+	assertAskOutput(t, output, "Write targeting system path: /etc/hosts")
 }
 
 func TestIntegrationEditProjectFile(t *testing.T) {
@@ -224,7 +228,300 @@ func TestIntegrationGitPushForceMain(t *testing.T) {
 	if exitCode != 0 {
 		t.Errorf("expected exit code 0, got %d", exitCode)
 	}
-	if strings.TrimSpace(output) != "" {
-		t.Errorf("expected no output for git push --force main, got: %s", output)
+	assertAskOutput(t, output, "git push --force to protected branch main")
+}
+
+// assertAskOutput checks a PermissionRequest ASK: no decision, so the dialog
+// comes up, and the guard's reason in the system message and for Claude.
+func assertAskOutput(t *testing.T, output, reason string) {
+	t.Helper()
+	var hookOutput HookOutput
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &hookOutput); err != nil {
+		t.Fatalf("expected valid JSON output for ASK, got: %s", output)
+	}
+	specific := hookOutput.HookSpecificOutput
+	if specific == nil || specific.HookEventName != "PermissionRequest" || specific.Decision != nil {
+		t.Errorf("expected a PermissionRequest output without a decision for ASK, got: %s", output)
+	} else if !strings.Contains(specific.AdditionalContext, "because "+reason) {
+		t.Errorf("expected the reason %q in the context for Claude, got: %q", reason, specific.AdditionalContext)
+	}
+	if !strings.Contains(hookOutput.SystemMessage, "almost-yolo-guard: asking because "+reason) {
+		t.Errorf("expected the reason %q in the system message, got: %q", reason, hookOutput.SystemMessage)
+	}
+}
+
+func TestIntegrationDenyDangerousDelete(t *testing.T) {
+	input := `{"session_id":"test","tool_name":"Bash","tool_input":{"command":"rm -rf ~"},"cwd":"/tmp/project"}`
+	output, _ := runBinary(t, input)
+
+	var hookOutput HookOutput
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &hookOutput); err != nil {
+		t.Fatalf("expected valid JSON output for rm -rf ~, got: %s", output)
+	}
+	decision := hookOutput.HookSpecificOutput.Decision
+	if decision.Behavior != "deny" {
+		t.Errorf("expected behavior 'deny', got %q", decision.Behavior)
+	}
+	if !strings.Contains(decision.Message, "rm targeting dangerous path") {
+		t.Errorf("deny message should explain the reason, got %q", decision.Message)
+	}
+}
+
+func TestIntegrationPreToolUse(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		tool     string
+		input    string
+		decision string // "" = no output
+	}{
+		{"safe command", "bypassPermissions", "Bash", `{"command":"go test ./..."}`, "allow"},
+		{"protected branch", "bypassPermissions", "Bash", `{"command":"git push --force origin main"}`, "ask"},
+		{"dangerous delete", "bypassPermissions", "Bash", `{"command":"rm -rf /"}`, "deny"},
+		{"system file", "bypassPermissions", "Write", `{"file_path":"/etc/hosts","content":"x"}`, "ask"},
+		{"skipped tool", "bypassPermissions", "Read", `{"file_path":"/tmp/project/main.go"}`, ""},
+
+		// Other modes leave allows and asks to PermissionRequest
+		{"default safe command", "default", "Bash", `{"command":"go test ./..."}`, ""},
+		{"default protected branch", "default", "Bash", `{"command":"git push --force origin main"}`, ""},
+		{"default dangerous delete", "default", "Bash", `{"command":"rm -rf /"}`, "deny"},
+		{"no mode system file", "", "Write", `{"file_path":"/etc/hosts","content":"x"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := fmt.Sprintf(`{"session_id":"test","hook_event_name":"PreToolUse","permission_mode":%q,"tool_name":%q,"tool_input":%s,"cwd":"/tmp/project"}`, tt.mode, tt.tool, tt.input)
+			output, exitCode := runBinary(t, input)
+			if exitCode != 0 {
+				t.Errorf("expected exit code 0, got %d", exitCode)
+			}
+			if tt.decision == "" {
+				if strings.TrimSpace(output) != "" {
+					t.Errorf("expected no output, got: %s", output)
+				}
+				return
+			}
+
+			var hookOutput HookOutput
+			if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &hookOutput); err != nil {
+				t.Fatalf("expected valid JSON output, got: %s", output)
+			}
+			got := hookOutput.HookSpecificOutput
+			if got.HookEventName != "PreToolUse" || got.Decision != nil {
+				t.Errorf("expected PreToolUse output shape, got %+v", got)
+			}
+			if got.PermissionDecision != tt.decision {
+				t.Errorf("expected permissionDecision %q, got %q", tt.decision, got.PermissionDecision)
+			}
+			if got.PermissionDecisionReason == "" {
+				t.Error("expected a permissionDecisionReason")
+			}
+			if tt.decision == "ask" && !strings.Contains(got.AdditionalContext, "did not auto-approve") {
+				t.Errorf("expected context for Claude on ask, got %q", got.AdditionalContext)
+			}
+		})
+	}
+}
+
+func TestIntegrationSessionApproval(t *testing.T) {
+	home := t.TempDir()
+	transcript := filepath.Join(home, ".claude", "projects", "tmp-project", "s1.jsonl")
+	os.MkdirAll(filepath.Dir(transcript), 0755)
+	os.WriteFile(transcript, []byte(`{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"git push --force origin main"}}]}}`+"\n"), 0644)
+	call := `{"session_id":"s1","tool_use_id":"toolu_1","transcript_path":"` + transcript + `","tool_name":"Bash","tool_input":{"command":"git push --force origin main"},"cwd":"/tmp/project"}`
+	approved := `{"session_id":"s1","tool_use_id":"toolu_1","transcript_path":"` + transcript + `","hook_event_name":"PostToolUse","tool_name":"Bash","tool_input":{"command":"git push --force origin main"},"tool_response":{"stdout":"","interrupted":false},"cwd":"/tmp/project"}`
+
+	// An outcome the transcript doesn't back up, as piped in by hand, is
+	// logged but not remembered
+	forged := strings.Replace(strings.Replace(approved, `"s1"`, `"s3"`, 1), `"toolu_1"`, `"toolu_9"`, 1)
+	runBinaryInHome(t, home, strings.Replace(call, `"s1"`, `"s3"`, 1))
+	runBinaryInHome(t, home, forged)
+	output, _ := runBinaryInHome(t, home, strings.Replace(call, `"s1"`, `"s3"`, 1))
+	assertAskOutput(t, output, "git push --force to protected branch main")
+
+	output, _ = runBinaryInHome(t, home, call)
+	assertAskOutput(t, output, "git push --force to protected branch main")
+
+	// The user approved it and the push ran
+	if output, _ := runBinaryInHome(t, home, approved); strings.TrimSpace(output) != "" {
+		t.Errorf("expected no output for PostToolUse, got: %s", output)
+	}
+
+	output, _ = runBinaryInHome(t, home, call)
+	if !strings.Contains(output, `"behavior":"allow"`) {
+		t.Errorf("expected the approved command to be allowed, got: %s", output)
+	}
+
+	// Other sessions still ask
+	output, _ = runBinaryInHome(t, home, strings.Replace(call, `"s1"`, `"s2"`, 1))
+	assertAskOutput(t, output, "git push --force to protected branch main")
+
+	// Deny-class calls are never remembered
+	rm := `{"session_id":"s1","tool_name":"Bash","tool_input":{"command":"rm -rf /"},"cwd":"/tmp/project"}`
+	os.MkdirAll(filepath.Join(home, ".config", "almost-yolo-guard"), 0755)
+	os.WriteFile(filepath.Join(home, ".config", "almost-yolo-guard", "policy.json"), []byte(`{"categories":{"dangerous_delete":"ask"}}`), 0644)
+	runBinaryInHome(t, home, rm)
+	runBinaryInHome(t, home, strings.Replace(strings.Replace(rm, `"tool_name"`, `"hook_event_name":"PostToolUse","tool_name"`, 1), `"cwd"`, `"tool_response":{},"cwd"`, 1))
+	output, _ = runBinaryInHome(t, home, rm)
+	assertAskOutput(t, output, "rm targeting dangerous path")
+}
+
+func TestIntegrationPreToolUseDenyOutcome(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, ".config", "almost-yolo-guard")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "policy.json"), []byte(`{"pre_tool_use":"deny"}`), 0644)
+
+	// Under bypassPermissions the ASK runs without a dialog
+	call := `{"session_id":"s1","hook_event_name":"PreToolUse","permission_mode":"bypassPermissions","tool_name":"Bash","tool_input":{"command":"git push --force origin main"},"cwd":"/tmp/project"}`
+	ran := `{"session_id":"s1","hook_event_name":"PostToolUse","permission_mode":"bypassPermissions","tool_name":"Bash","tool_input":{"command":"git push --force origin main"},"tool_response":{"stdout":""},"cwd":"/tmp/project"}`
+	if output, _ := runBinaryInHome(t, home, call); strings.TrimSpace(output) != "" {
+		t.Errorf("expected no output in deny mode, got: %s", output)
+	}
+	runBinaryInHome(t, home, ran)
+
+	data, _ := os.ReadFile(filepath.Join(dir, "decisions.log"))
+	if log := string(data); !strings.Contains(log, "PASSTHROUGH") || strings.Contains(log, "approved by user") {
+		t.Errorf("expected a passthrough and no approved outcome, got:\n%s", log)
+	}
+}
+
+func TestIntegrationPermissionModes(t *testing.T) {
+	home := t.TempDir()
+	os.MkdirAll(filepath.Join(home, ".config", "almost-yolo-guard"), 0755)
+	os.WriteFile(filepath.Join(home, ".config", "almost-yolo-guard", "policy.json"),
+		[]byte(`{"pre_tool_use":"ask","permission_modes":{"bypassPermissions":{"verdicts":{"ask":"deny"}}}}`), 0644)
+
+	tests := []struct {
+		name     string
+		mode     string
+		tool     string
+		input    string
+		decision string // "" = no output
+	}{
+		{"plan read", "plan", "Bash", `{"command":"git status"}`, "allow"},
+		{"plan mutating", "plan", "Bash", `{"command":"touch notes.txt"}`, "deny"},
+		{"default mutating", "default", "Bash", `{"command":"touch notes.txt"}`, "allow"},
+		{"acceptEdits project write", "acceptEdits", "Write", `{"file_path":"/tmp/project/main.go","content":"x"}`, ""},
+		{"acceptEdits system write", "acceptEdits", "Write", `{"file_path":"/etc/hosts","content":"x"}`, ""},
+		{"acceptEdits settings", "acceptEdits", "Write", `{"file_path":"/tmp/project/.claude/settings.json","content":"{}"}`, "ask"},
+		{"bypass ask denied", "bypassPermissions", "Bash", `{"command":"git push --force origin main"}`, "deny"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := fmt.Sprintf(`{"session_id":"test","hook_event_name":"PreToolUse","permission_mode":%q,"tool_name":%q,"tool_input":%s,"cwd":"/tmp/project"}`, tt.mode, tt.tool, tt.input)
+			output, _ := runBinaryInHome(t, home, input)
+			if tt.decision == "" {
+				if strings.TrimSpace(output) != "" {
+					t.Errorf("expected no output, got: %s", output)
+				}
+				return
+			}
+			var hookOutput HookOutput
+			if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &hookOutput); err != nil {
+				t.Fatalf("expected valid JSON output, got: %s", output)
+			}
+			if got := hookOutput.HookSpecificOutput.PermissionDecision; got != tt.decision {
+				t.Errorf("expected permissionDecision %q, got %q (%s)", tt.decision, got, output)
+			}
+		})
+	}
+}
+
+func TestIntegrationCircuitBreaker(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, ".config", "almost-yolo-guard")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "policy.json"),
+		[]byte(`{"daemon_failure":{"action":"allow_if_safe","tools":{"mcp__*":"deny"}}}`), 0644)
+	breaker := fmt.Sprintf(`{"failures":3,"last_error":"timeout","open_until":%q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	os.WriteFile(filepath.Join(dir, "circuit-breaker.json"), []byte(breaker), 0644)
+
+	tests := []struct {
+		name     string
+		tool     string
+		input    string
+		decision string // "" = the dialog
+	}{
+		{"write outside project leaned safe", "Write", `{"file_path":"/tmp/notes.txt","content":"x"}`, "allow"},
+		{"unknown command asks", "Bash", `{"command":"some-unknown-tool --flag"}`, ""},
+		{"tool override", "mcp__github__create_issue", `{"title":"x"}`, "deny"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := fmt.Sprintf(`{"session_id":"test","tool_name":%q,"tool_input":%s,"cwd":"/tmp/project"}`, tt.tool, tt.input)
+			start := time.Now()
+			output, _ := runBinaryInHome(t, home, input)
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("open breaker still waited for the daemon (%s)", elapsed)
+			}
+			var hookOutput HookOutput
+			if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &hookOutput); err != nil {
+				t.Fatalf("expected valid JSON output, got: %s", output)
+			}
+			if tt.decision == "" {
+				if hookOutput.HookSpecificOutput != nil && hookOutput.HookSpecificOutput.Decision != nil || !strings.Contains(output, "evaluator is unavailable") {
+					t.Errorf("expected the dialog, got: %s", output)
+				}
+				return
+			}
+			if hookOutput.HookSpecificOutput == nil || hookOutput.HookSpecificOutput.Decision == nil {
+				t.Fatalf("expected a decision, got: %s", output)
+			}
+			if got := hookOutput.HookSpecificOutput.Decision.Behavior; got != tt.decision {
+				t.Errorf("expected behavior %q, got %q (%s)", tt.decision, got, output)
+			}
+		})
+	}
+
+	log, _ := os.ReadFile(filepath.Join(dir, "decisions.log"))
+	for _, want := range []string{"source=circuit-breaker (allow_if_safe)", "source=circuit-breaker (deny)"} {
+		if !strings.Contains(string(log), want) {
+			t.Errorf("decision log missing %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestIntegrationAdapters(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		want  string // decision in the adapter's output; "" = no output
+	}{
+		{"generic codex shell", nil, `{"tool":"shell","input":{"command":["bash","-lc","git status"]},"cwd":"/tmp/project"}`, "allow"},
+		{"generic dangerous", nil, `{"tool":"exec_command","input":{"cmd":"rm -rf ~"},"cwd":"/tmp/project"}`, "deny"},
+		{"generic patch outside project", nil, `{"tool":"apply_patch","input":{"input":"*** Begin Patch\n*** Add File: src/a.go\n+package a\n*** Add File: /etc/cron.d/job\n+* * * * * root true\n*** End Patch"},"cwd":"/tmp/project"}`, "ask"},
+		{"generic skipped read", nil, `{"tool":"read_file","input":{"absolute_path":"/tmp/project/a.go"},"cwd":"/tmp/project"}`, ""},
+		{"generic shell outside project", nil, `{"tool":"run_shell_command","input":{"command":"rm -rf .ssh","directory":"/Users/victor"},"cwd":"/Users/victor/projects/myapp"}`, "ask"},
+		{"gemini shell", nil, `{"session_id":"g","hook_event_name":"BeforeTool","tool_name":"run_shell_command","tool_input":{"command":"ls -la"},"cwd":"/tmp/project"}`, "allow"},
+		{"gemini write outside", nil, `{"session_id":"g","hook_event_name":"BeforeTool","tool_name":"write_file","tool_input":{"file_path":"/etc/hosts","content":"x"},"cwd":"/tmp/project"}`, "ask"},
+		{"gemini other event", nil, `{"session_id":"g","hook_event_name":"SessionStart","cwd":"/tmp/project"}`, ""},
+		{"flag", []string{"--adapter", "generic"}, `{"tool":"Bash","input":{"command":"ls"},"cwd":"/tmp/project"}`, "allow"},
+		{"unknown adapter", []string{"--adapter=cursor"}, `{"tool":"Bash","input":{"command":"ls"},"cwd":"/tmp/project"}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, exitCode := runBinaryInHome(t, t.TempDir(), tt.input, tt.args...)
+			if exitCode != 0 {
+				t.Errorf("expected exit code 0, got %d", exitCode)
+			}
+			if tt.want == "" {
+				if strings.TrimSpace(output) != "" {
+					t.Errorf("expected no output, got: %s", output)
+				}
+				return
+			}
+			var decision struct {
+				Decision string `json:"decision"`
+				Reason   string `json:"reason"`
+			}
+			if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &decision); err != nil {
+				t.Fatalf("expected JSON output, got: %s", output)
+			}
+			if decision.Decision != tt.want {
+				t.Errorf("expected decision %q, got %q (%s)", tt.want, decision.Decision, output)
+			}
+		})
 	}
 }
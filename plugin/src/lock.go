@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Hook processes run concurrently, and several of them read, change and
+// write back the same small state files. withFileLock serializes that with a
+// lock file created exclusively next to the state file, which works on every
+// platform the guard is built for.
+
+const (
+	fileLockWait  = 2 * time.Second
+	fileLockStale = 10 * time.Second // a lock this old was left by a crashed process
+)
+
+// withFileLock runs fn holding the lock for path. If the lock can't be taken
+// in time, fn runs anyway rather than hold up the tool call.
+func withFileLock(path string, fn func()) {
+	lock := path + ".lock"
+	os.MkdirAll(filepath.Dir(path), 0755)
+	deadline := time.Now().Add(fileLockWait)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			defer os.Remove(lock)
+			break
+		}
+		if !os.IsExist(err) || time.Now().After(deadline) {
+			break
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > fileLockStale {
+			os.Remove(lock)
+			continue
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fn()
+}
@@ -8,6 +8,26 @@ import (
 )
 
 func logDecision(toolName, toolInput, workDir, decision, source, reason string) {
+	writeLogEntry(fmt.Sprintf("%s | tool=%s | dir=%s | source=%s | input=%s | reason=%s",
+		decision, toolName, workDir, source, truncateLogInput(toolInput), reason))
+}
+
+// logRewrite records a call allowed with a rewritten input, keeping both the
+// original and the rewritten input.
+func logRewrite(toolName, toolInput, rewritten, workDir, source, rewrite, reason string) {
+	writeLogEntry(fmt.Sprintf("ALLOW | tool=%s | dir=%s | source=%s | input=%s | rewritten=%s | reason=rewrite %s instead of: %s",
+		toolName, workDir, source, truncateLogInput(toolInput), truncateLogInput(rewritten), rewrite, reason))
+}
+
+// truncateLogInput shortens a tool input for logging.
+func truncateLogInput(toolInput string) string {
+	if len(toolInput) > 200 {
+		return toolInput[:200] + "..."
+	}
+	return toolInput
+}
+
+func writeLogEntry(entry string) {
 	logDir := filepath.Join(os.Getenv("HOME"), ".config", "almost-yolo-guard")
 	os.MkdirAll(logDir, 0755)
 
@@ -18,13 +38,15 @@ func logDecision(toolName, toolInput, workDir, decision, source, reason string)
 	}
 	defer f.Close()
 
-	// Truncate long tool inputs for logging
-	if len(toolInput) > 200 {
-		toolInput = toolInput[:200] + "..."
-	}
-
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	logEntry := fmt.Sprintf("[%s] %s | tool=%s | dir=%s | source=%s | input=%s | reason=%s\n",
-		timestamp, decision, toolName, workDir, source, toolInput, reason)
-	f.WriteString(logEntry)
+	f.WriteString("[" + timestamp + "] " + entry + "\n")
+}
+
+// logOutcome records how a decided call went. The time is measured from the
+// decision to the outcome, so for an ASK it includes the time the dialog was
+// open, not just the tool's run.
+func logOutcome(toolName, toolInput, workDir, sessionID, fingerprint string, decision pendingDecision, outcome toolOutcome, sinceDecision time.Duration) {
+	writeLogEntry(fmt.Sprintf("OUTCOME | tool=%s | dir=%s | session=%s | request=%s | decision=%s | source=%s | %s | since_decision=%s | input=%s",
+		toolName, workDir, sessionID, fingerprint, outcomeDecision(decision), decision.Source,
+		formatOutcome(outcome), sinceDecision.Round(time.Millisecond), truncateLogInput(toolInput)))
 }
@@ -24,5 +24,13 @@ func main() {
 		runDaemon()
 		return
 	}
-	runClient()
+	if len(os.Args) > 1 && os.Args[1] == "session" {
+		runSession(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "doctor" {
+		runDoctor()
+		return
+	}
+	runClient(os.Args[1:])
 }
@@ -131,7 +131,7 @@ func TestShouldSkipEvaluation(t *testing.T) {
 	}
 
 	for _, tool := range skipTools {
-		if !shouldSkipEvaluation(tool) {
+		if !shouldSkipEvaluation(tool, nil) {
 			t.Errorf("Expected %s to be skipped, but it wasn't", tool)
 		}
 	}
@@ -145,7 +145,7 @@ func TestShouldSkipEvaluation(t *testing.T) {
 	}
 
 	for _, tool := range evalTools {
-		if shouldSkipEvaluation(tool) {
+		if shouldSkipEvaluation(tool, nil) {
 			t.Errorf("Expected %s to require evaluation, but it was skipped", tool)
 		}
 	}
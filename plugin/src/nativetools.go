@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// Other agent CLIs name their tools differently (Codex's shell and
+// apply_patch, Gemini CLI's run_shell_command and write_file). Adapters
+// translate those calls into Claude Code's tools, so the Bash, Write and Edit
+// rules apply unchanged. A patch touching several files becomes several
+// calls, and the strictest verdict wins.
+
+// toolCall is one tool call in Claude Code's terms.
+type toolCall struct {
+	ToolName  string
+	ToolInput json.RawMessage
+}
+
+// translateNativeTool returns the Claude Code calls for another agent's tool
+// call. ok is false for tools it doesn't know, which are evaluated under
+// their own name. workDir stays the project: a shell call's own directory
+// becomes a leading cd, so running outside the project is judged like any
+// other cd out of it.
+func translateNativeTool(toolName string, toolInput json.RawMessage, workDir string) (calls []toolCall, ok bool) {
+	switch toolName {
+	case "shell", "local_shell", "exec_command", "run_shell_command":
+		command, argv, dir := nativeShellCommand(toolInput, workDir)
+		if len(argv) == 2 && argv[0] == "apply_patch" {
+			calls := patchCalls(argv[1], dir)
+			return calls, len(calls) > 0
+		}
+		if dir != workDir {
+			command = "cd " + joinShellWords([]string{dir}) + " && " + command
+		}
+		return []toolCall{{"Bash", mustJSON(map[string]string{"command": command})}}, true
+	case "apply_patch":
+		var input struct {
+			Input string `json:"input"`
+			Patch string `json:"patch"`
+		}
+		json.Unmarshal(toolInput, &input)
+		if input.Input == "" {
+			input.Input = input.Patch
+		}
+		calls := patchCalls(input.Input, workDir)
+		return calls, len(calls) > 0
+	case "write_file":
+		return []toolCall{{"Write", toolInput}}, true
+	case "replace", "edit_file":
+		return []toolCall{{"Edit", toolInput}}, true
+	case "read_file":
+		var input struct {
+			FilePath     string `json:"file_path"`
+			AbsolutePath string `json:"absolute_path"`
+		}
+		json.Unmarshal(toolInput, &input)
+		if input.FilePath == "" {
+			input.FilePath = input.AbsolutePath
+		}
+		return []toolCall{{"Read", mustJSON(map[string]string{"file_path": input.FilePath})}}, true
+	case "read_many_files":
+		// Paths and globs are relative to the project; each one is a read
+		var input struct {
+			Paths   []string `json:"paths"`
+			Include []string `json:"include"`
+		}
+		json.Unmarshal(toolInput, &input)
+		var calls []toolCall
+		for _, p := range append(input.Paths, input.Include...) {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(workDir, p)
+			}
+			calls = append(calls, toolCall{"Read", mustJSON(map[string]string{"file_path": p})})
+		}
+		return calls, len(calls) > 0
+	case "glob", "list_directory":
+		return []toolCall{{"Glob", toolInput}}, true
+	case "search_file_content", "grep_search":
+		return []toolCall{{"Grep", toolInput}}, true
+	case "web_fetch":
+		return []toolCall{{"WebFetch", toolInput}}, true
+	case "google_web_search", "web_search":
+		return []toolCall{{"WebSearch", toolInput}}, true
+	}
+	return nil, false
+}
+
+// nativeShellCommand reads a shell call: a command string (Gemini CLI,
+// Codex's exec_command), an argv array (Codex's shell) or an exec action
+// (Codex's local_shell), and the directory it runs in. argv is set for
+// array commands.
+func nativeShellCommand(toolInput json.RawMessage, workDir string) (command string, argv []string, dir string) {
+	var input struct {
+		Command   json.RawMessage `json:"command"`
+		Cmd       string          `json:"cmd"`
+		Workdir   string          `json:"workdir"`
+		Directory string          `json:"directory"`
+		Action    struct {
+			Command          json.RawMessage `json:"command"`
+			WorkingDirectory string          `json:"working_directory"`
+		} `json:"action"`
+	}
+	json.Unmarshal(toolInput, &input)
+	if input.Command == nil {
+		input.Command = input.Action.Command
+	}
+
+	dir = workDir
+	for _, d := range []string{input.Workdir, input.Directory, input.Action.WorkingDirectory} {
+		if d == "" {
+			continue
+		}
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(workDir, d)
+		}
+		dir = d
+	}
+
+	command = input.Cmd
+	if json.Unmarshal(input.Command, &argv) == nil {
+		command = argvCommand(argv)
+	} else {
+		json.Unmarshal(input.Command, &command)
+	}
+	return command, argv, dir
+}
+
+// argvCommand turns an argv into a command line. The script of a
+// `bash -lc SCRIPT` call is the command itself.
+func argvCommand(argv []string) string {
+	if len(argv) == 3 && (argv[1] == "-c" || argv[1] == "-lc") {
+		switch filepath.Base(argv[0]) {
+		case "sh", "bash", "zsh", "dash":
+			return argv[2]
+		}
+	}
+	return joinShellWords(argv)
+}
+
+// patchCalls turns an apply_patch patch into one call per file: Write for
+// added files, Edit for updated ones, and Bash rm/mv for deletes and moves.
+func patchCalls(patch, workDir string) []toolCall {
+	type patchFile struct {
+		op, path, moveTo string
+		old, new         []string
+	}
+	var files []*patchFile
+	var current *patchFile
+	for _, line := range strings.Split(patch, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "*** Add File: "):
+			current = &patchFile{op: "add", path: strings.TrimPrefix(line, "*** Add File: ")}
+			files = append(files, current)
+		case strings.HasPrefix(line, "*** Update File: "):
+			current = &patchFile{op: "update", path: strings.TrimPrefix(line, "*** Update File: ")}
+			files = append(files, current)
+		case strings.HasPrefix(line, "*** Delete File: "):
+			current = &patchFile{op: "delete", path: strings.TrimPrefix(line, "*** Delete File: ")}
+			files = append(files, current)
+		case strings.HasPrefix(line, "*** Move to: ") && current != nil:
+			current.moveTo = strings.TrimPrefix(line, "*** Move to: ")
+		case strings.HasPrefix(line, "***") || strings.HasPrefix(line, "@@") || current == nil:
+			// Begin/End Patch, End of File, hunk headers
+		case strings.HasPrefix(line, "+"):
+			current.new = append(current.new, line[1:])
+		case strings.HasPrefix(line, "-"):
+			current.old = append(current.old, line[1:])
+		case strings.HasPrefix(line, " "):
+			current.old = append(current.old, line[1:])
+			current.new = append(current.new, line[1:])
+		}
+	}
+
+	abs := func(p string) string {
+		p = strings.TrimSpace(p)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(workDir, p)
+		}
+		return p
+	}
+	var calls []toolCall
+	for _, f := range files {
+		path := abs(f.path)
+		switch f.op {
+		case "add":
+			calls = append(calls, toolCall{"Write", mustJSON(map[string]string{
+				"file_path": path, "content": strings.Join(f.new, "\n"),
+			})})
+		case "delete":
+			calls = append(calls, toolCall{"Bash", mustJSON(map[string]string{
+				"command": "rm -- " + joinShellWords([]string{path}),
+			})})
+		case "update":
+			if f.moveTo != "" {
+				target := abs(f.moveTo)
+				calls = append(calls, toolCall{"Bash", mustJSON(map[string]string{
+					"command": "mv -- " + joinShellWords([]string{path, target}),
+				})})
+				path = target
+			}
+			calls = append(calls, toolCall{"Edit", mustJSON(map[string]string{
+				"file_path": path, "old_string": strings.Join(f.old, "\n"), "new_string": strings.Join(f.new, "\n"),
+			})})
+		}
+	}
+	return calls
+}
+
+func mustJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// evaluateCalls runs the rules on each call of a translated tool call. Any
+// deny denies, then any ask asks, then anything uncertain goes to the
+// evaluator. The notes of every call are kept.
+func evaluateCalls(calls []toolCall, workDir string) (Verdict, string) {
+	if len(calls) == 0 {
+		return VerdictUncertain, "no tool calls"
+	}
+	if len(calls) == 1 {
+		return EvaluateRules(calls[0].ToolName, calls[0].ToolInput, workDir)
+	}
+	rank := map[Verdict]int{VerdictAllow: 0, VerdictUncertain: 1, VerdictAsk: 2, VerdictDeny: 3}
+	notes := map[string]bool{}
+	verdict, reasons := VerdictAllow, []string{}
+	for _, call := range calls {
+		v, r := EvaluateRules(call.ToolName, call.ToolInput, workDir)
+		for n := range ruleNotes {
+			notes[n] = true
+		}
+		switch {
+		case rank[v] > rank[verdict]:
+			verdict, reasons = v, []string{r}
+		case v == verdict:
+			reasons = append(reasons, r)
+		}
+	}
+	ruleNotes = notes
+	return verdict, strings.Join(reasons, "; ")
+}
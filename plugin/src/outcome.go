@@ -0,0 +1,269 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Every decision is remembered under the call's tool use id (or, for events
+// without one, a fingerprint of the request) until the tool's PostToolUse
+// (or PostToolUseFailure) event arrives. The outcome is then appended to the
+// decision log next to the decision it belongs to, so an ASK followed by an
+// outcome is a call the user approved. Decisions whose tool never ran (a
+// denial, or a rejected ASK) expire unanswered after pendingDecisionTTL.
+// Several dialogs can be open in a session at once, so a new decision never
+// drops another call's.
+//
+// Anyone can pipe a PostToolUse event into the guard, so an outcome only
+// makes an ASK's approval stick when Claude Code's transcript confirms it
+// (see approvedCallRan).
+
+const (
+	pendingDecisionTTL        = time.Hour
+	pendingDecisionMaxEntries = 200
+)
+
+type pendingDecision struct {
+	Decision   string    `json:"decision"`
+	Source     string    `json:"source"`
+	Approval   string    `json:"approval,omitempty"` // approvalKey of an ASK that can be remembered
+	Session    string    `json:"session,omitempty"`
+	Request    string    `json:"request,omitempty"`     // requestFingerprint of the call
+	ToolUseID  string    `json:"tool_use_id,omitempty"` // the call the decision answered
+	Transcript string    `json:"transcript,omitempty"`  // the session transcript that records it
+	Time       time.Time `json:"time"`
+}
+
+func pendingDecisionsPath() string {
+	return filepath.Join(configDir(), "pending-decisions.json")
+}
+
+// requestFingerprint identifies a tool call within a session. The input is
+// re-encoded so that key order and whitespace don't matter.
+func requestFingerprint(sessionID, toolName string, toolInput json.RawMessage) string {
+	input := []byte(toolInput)
+	var decoded interface{}
+	if json.Unmarshal(toolInput, &decoded) == nil {
+		if data, err := json.Marshal(decoded); err == nil {
+			input = data
+		}
+	}
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(sessionID), []byte(toolName), input} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func loadPendingDecisions() map[string]pendingDecision {
+	pending := map[string]pendingDecision{}
+	data, err := os.ReadFile(pendingDecisionsPath())
+	if err != nil {
+		return pending
+	}
+	json.Unmarshal(data, &pending)
+	return pending
+}
+
+func savePendingDecisions(pending map[string]pendingDecision) {
+	keys := make([]string, 0, len(pending))
+	for key, entry := range pending {
+		if time.Since(entry.Time) >= pendingDecisionTTL {
+			delete(pending, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) > pendingDecisionMaxEntries {
+		sort.Slice(keys, func(i, j int) bool { return pending[keys[i]].Time.Before(pending[keys[j]].Time) })
+		for _, key := range keys[:len(keys)-pendingDecisionMaxEntries] {
+			delete(pending, key)
+		}
+	}
+	data, err := json.Marshal(pending)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(configDir(), 0755)
+	tmp := pendingDecisionsPath() + ".tmp"
+	if os.WriteFile(tmp, data, 0644) == nil {
+		os.Rename(tmp, pendingDecisionsPath())
+	}
+}
+
+// findPendingDecision returns the key of the pending decision for a call.
+// An entry recorded under the call's tool use id wins; otherwise the newest
+// entry for the same request that doesn't belong to another tool use.
+func findPendingDecision(pending map[string]pendingDecision, toolUseID, fingerprint string) (string, bool) {
+	if _, ok := pending[toolUseID]; ok && toolUseID != "" {
+		return toolUseID, true
+	}
+	found := ""
+	for key, entry := range pending {
+		if entry.Request != fingerprint || (toolUseID != "" && entry.ToolUseID != "") {
+			continue
+		}
+		if found == "" || entry.Time.After(pending[found].Time) {
+			found = key
+		}
+	}
+	return found, found != ""
+}
+
+// recordPendingDecision remembers a decision on a call, made with toolInput,
+// until its outcome arrives. A later event for the same call
+// (PermissionRequest after PreToolUse) replaces it.
+func recordPendingDecision(hookInput *HookInput, toolInput json.RawMessage, decision, source, approval string) {
+	fingerprint := requestFingerprint(hookInput.SessionID, hookInput.ToolName, toolInput)
+	withFileLock(pendingDecisionsPath(), func() {
+		pending := loadPendingDecisions()
+		toolUseID := hookInput.ToolUseID
+		key := toolUseID
+		if previous, ok := findPendingDecision(pending, toolUseID, fingerprint); ok {
+			if toolUseID == "" {
+				toolUseID, key = pending[previous].ToolUseID, previous
+			}
+			delete(pending, previous)
+		}
+		if key == "" {
+			key = fingerprint
+		}
+		pending[key] = pendingDecision{
+			Decision:   decision,
+			Source:     source,
+			Approval:   approval,
+			Session:    hookInput.SessionID,
+			Request:    fingerprint,
+			ToolUseID:  toolUseID,
+			Transcript: hookInput.TranscriptPath,
+			Time:       time.Now(),
+		}
+		savePendingDecisions(pending)
+	})
+}
+
+// takePendingDecision returns and forgets the decision for a call.
+func takePendingDecision(toolUseID, fingerprint string) (entry pendingDecision, ok bool) {
+	withFileLock(pendingDecisionsPath(), func() {
+		pending := loadPendingDecisions()
+		key, found := findPendingDecision(pending, toolUseID, fingerprint)
+		if !found || time.Since(pending[key].Time) >= pendingDecisionTTL {
+			return
+		}
+		entry, ok = pending[key], true
+		delete(pending, key)
+		savePendingDecisions(pending)
+	})
+	return entry, ok
+}
+
+// toolOutcome is what the PostToolUse input says about how the call went.
+type toolOutcome struct {
+	Status   string // "ok", "failed" or "interrupted"
+	ExitCode *int
+	Error    string
+}
+
+// parseToolOutcome reads the outcome from a tool response. Tool responses
+// have no common shape, so only the fields tools are known to use are read.
+func parseToolOutcome(hookInput *HookInput) toolOutcome {
+	outcome := toolOutcome{Status: "ok", Error: hookInput.Error}
+	if hookInput.event() == EventPostToolUseFailure {
+		outcome.Status = "failed"
+	}
+	if hookInput.IsInterrupt {
+		outcome.Status = "interrupted"
+	}
+
+	var response map[string]interface{}
+	if json.Unmarshal(hookInput.ToolResponse, &response) != nil {
+		return outcome
+	}
+	for _, key := range []string{"exit_code", "exitCode", "returnCode"} {
+		if n, ok := response[key].(float64); ok {
+			code := int(n)
+			outcome.ExitCode = &code
+			if code != 0 && outcome.Status == "ok" {
+				outcome.Status = "failed"
+			}
+			break
+		}
+	}
+	if isError, _ := response["is_error"].(bool); isError && outcome.Status == "ok" {
+		outcome.Status = "failed"
+	}
+	if message, ok := response["error"].(string); ok && message != "" && outcome.Error == "" {
+		outcome.Error = message
+		if outcome.Status == "ok" {
+			outcome.Status = "failed"
+		}
+	}
+	if interrupted, _ := response["interrupted"].(bool); interrupted {
+		outcome.Status = "interrupted"
+	}
+	return outcome
+}
+
+// recordOutcome correlates a PostToolUse event with its decision and logs
+// the outcome. Calls the guard never decided on (skipped tools, calls
+// answered by the permission mode) aren't logged. An approved ASK is
+// remembered for the rest of the session, once approvedCallRan confirms it.
+func recordOutcome(hookInput *HookInput) {
+	fingerprint := requestFingerprint(hookInput.SessionID, hookInput.ToolName, hookInput.ToolInput)
+	decision, ok := takePendingDecision(hookInput.ToolUseID, fingerprint)
+	if !ok {
+		return
+	}
+	outcome := parseToolOutcome(hookInput)
+	logOutcome(hookInput.ToolName, string(hookInput.ToolInput), hookInput.WorkingDir, hookInput.SessionID,
+		fingerprint, decision, outcome, time.Since(decision.Time))
+
+	if decision.Decision == VerdictAsk.String() && decision.Approval != "" && approvedCallRan(decision, hookInput) {
+		rememberApproval(hookInput.SessionID, decision.Approval, currentPolicy().SessionApprovals.ttl())
+	}
+}
+
+// approvedCallRan reports whether a PostToolUse event is Claude Code's own
+// report of the call an ASK answered: both name the same tool use, and the
+// session transcript holds that tool use, with the same tool and input and
+// no rejection. Events without a tool use id can't be checked, so they
+// never make an approval stick.
+func approvedCallRan(decision pendingDecision, hookInput *HookInput) bool {
+	if decision.ToolUseID == "" || decision.ToolUseID != hookInput.ToolUseID ||
+		decision.Transcript == "" || decision.Transcript != hookInput.TranscriptPath {
+		return false
+	}
+	return transcriptHasToolUse(decision.Transcript, decision.ToolUseID, hookInput.ToolName, hookInput.ToolInput)
+}
+
+// outcomeDecision describes the decision an outcome belongs to. The tool
+// only runs after an ASK if the user approved it.
+func outcomeDecision(decision pendingDecision) string {
+	if decision.Decision == VerdictAsk.String() {
+		return "ASK (approved by user)"
+	}
+	return decision.Decision
+}
+
+func formatOutcome(outcome toolOutcome) string {
+	parts := []string{"status=" + outcome.Status}
+	if outcome.ExitCode != nil {
+		parts = append(parts, fmt.Sprintf("exit=%d", *outcome.ExitCode))
+	}
+	if outcome.Error != "" {
+		message := strings.Join(strings.Fields(outcome.Error), " ")
+		if len(message) > 200 {
+			message = message[:200] + "..."
+		}
+		parts = append(parts, "error="+message)
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+	a := requestFingerprint("s1", "Bash", json.RawMessage(`{"command":"ls","description":"list"}`))
+	b := requestFingerprint("s1", "Bash", json.RawMessage(`{ "description": "list", "command": "ls" }`))
+	if a != b {
+		t.Errorf("fingerprint depends on key order: %s != %s", a, b)
+	}
+	if c := requestFingerprint("s2", "Bash", json.RawMessage(`{"command":"ls","description":"list"}`)); c == a {
+		t.Errorf("fingerprint ignores the session")
+	}
+}
+
+func TestParseToolOutcome(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"success", `{"hook_event_name":"PostToolUse","tool_response":{"stdout":"ok","stderr":"","interrupted":false}}`, "status=ok"},
+		{"exit code", `{"hook_event_name":"PostToolUse","tool_response":{"exit_code":2}}`, "status=failed | exit=2"},
+		{"response error", `{"hook_event_name":"PostToolUse","tool_response":{"is_error":true,"error":"file not found"}}`, "status=failed | error=file not found"},
+		{"interrupted", `{"hook_event_name":"PostToolUse","tool_response":{"interrupted":true}}`, "status=interrupted"},
+		{"failure event", `{"hook_event_name":"PostToolUseFailure","error":"Exit code 1\nnpm ERR!"}`, "status=failed | error=Exit code 1 npm ERR!"},
+		{"failure interrupt", `{"hook_event_name":"PostToolUseFailure","error":"aborted","is_interrupt":true}`, "status=interrupted | error=aborted"},
+		{"string response", `{"hook_event_name":"PostToolUse","tool_response":"done"}`, "status=ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hookInput HookInput
+			if err := json.Unmarshal([]byte(tt.input), &hookInput); err != nil {
+				t.Fatal(err)
+			}
+			if got := formatOutcome(parseToolOutcome(&hookInput)); got != tt.want {
+				t.Errorf("parseToolOutcome(%s) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordOutcome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	input := json.RawMessage(`{"command":"kubectl apply -f k8s/dev/"}`)
+	call := &HookInput{SessionID: "s1", ToolName: "Bash"}
+	recordPendingDecision(call, json.RawMessage(`{"command":"ls"}`), "ALLOW", "rules", "")
+	recordPendingDecision(call, input, "ASK", "rules", "")
+
+	recordOutcome(&HookInput{
+		SessionID:     "s1",
+		HookEventName: EventPostToolUse,
+		ToolName:      "Bash",
+		ToolInput:     input,
+		WorkingDir:    "/home/dev/project",
+		ToolResponse:  json.RawMessage(`{"stdout":"deployment.apps/app configured","interrupted":false}`),
+	})
+	// Never decided on, so not logged
+	recordOutcome(&HookInput{SessionID: "s1", HookEventName: EventPostToolUse, ToolName: "Read", ToolInput: json.RawMessage(`{}`)})
+
+	data, err := os.ReadFile(filepath.Join(home, ".config", "almost-yolo-guard", "decisions.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	if strings.Count(log, "OUTCOME") != 1 {
+		t.Fatalf("expected one outcome entry, got:\n%s", log)
+	}
+	for _, want := range []string{"decision=ASK (approved by user)", "session=s1", "status=ok", "tool=Bash"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("outcome entry missing %q:\n%s", want, log)
+		}
+	}
+
+	// The decision is consumed; a repeated event isn't logged twice
+	if _, ok := takePendingDecision("", requestFingerprint("s1", "Bash", input)); ok {
+		t.Errorf("pending decision not consumed")
+	}
+	if _, ok := takePendingDecision("", requestFingerprint("s1", "Bash", json.RawMessage(`{"command":"ls"}`))); !ok {
+		t.Errorf("unrelated pending decision dropped")
+	}
+}
+
+func TestPendingDecisionsConcurrent(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recordPendingDecision(&HookInput{SessionID: "s1", ToolName: "Bash"}, json.RawMessage(fmt.Sprintf(`{"command":"echo %d"}`, i)), "ALLOW", "rules", "")
+		}(i)
+	}
+	wg.Wait()
+
+	if pending := loadPendingDecisions(); len(pending) != 20 {
+		t.Errorf("expected 20 pending decisions, got %d", len(pending))
+	}
+}
+
+func TestPendingAsksKeyedByToolUse(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	asked := json.RawMessage(`{"command":"kubectl delete ns app"}`)
+	askedAgain := json.RawMessage(`{"command":"kubectl delete ns web"}`)
+	recordPendingDecision(&HookInput{SessionID: "s1", ToolName: "Bash", ToolUseID: "toolu_1"}, asked, "ASK", "rules", "key")
+	recordPendingDecision(&HookInput{SessionID: "s1", ToolName: "Bash", ToolUseID: "toolu_2"}, askedAgain, "ASK", "rules", "key")
+	recordPendingDecision(&HookInput{SessionID: "s1", ToolName: "Bash", ToolUseID: "toolu_3"}, json.RawMessage(`{"command":"ls"}`), "ALLOW", "rules", "")
+
+	// A PermissionRequest without an id replaces the PreToolUse decision
+	recordPendingDecision(&HookInput{SessionID: "s1", ToolName: "Bash"}, asked, "ASK", "evaluator", "key")
+	if pending := loadPendingDecisions(); len(pending) != 3 {
+		t.Errorf("expected 3 pending decisions, got %d", len(pending))
+	}
+
+	// Another call's open dialog survives the session moving on
+	entry, ok := takePendingDecision("toolu_1", requestFingerprint("s1", "Bash", asked))
+	if !ok || entry.Source != "evaluator" || entry.ToolUseID != "toolu_1" {
+		t.Errorf("pending ASK = %+v, %v", entry, ok)
+	}
+	if _, ok := takePendingDecision("toolu_2", requestFingerprint("s1", "Bash", askedAgain)); !ok {
+		t.Errorf("other pending ASK dropped")
+	}
+	if _, ok := takePendingDecision("toolu_4", requestFingerprint("s1", "Bash", json.RawMessage(`{"command":"ls"}`))); ok {
+		t.Errorf("decision matched another tool use")
+	}
+}
+
+func TestPendingDecisionExpires(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	asked := json.RawMessage(`{"command":"kubectl delete ns app"}`)
+	fingerprint := requestFingerprint("s1", "Bash", asked)
+	savePendingDecisions(map[string]pendingDecision{
+		"toolu_1": {Decision: "ASK", Session: "s1", Request: fingerprint, ToolUseID: "toolu_1", Time: time.Now().Add(-pendingDecisionTTL)},
+	})
+	if _, ok := takePendingDecision("toolu_1", fingerprint); ok {
+		t.Errorf("unanswered ASK outlived its TTL")
+	}
+}
+
+func TestApprovalNeedsTranscript(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDE_CONFIG_DIR", "")
+
+	input := json.RawMessage(`{"command":"git push --force origin main"}`)
+	transcript := filepath.Join(home, ".claude", "projects", "p", "s1.jsonl")
+	os.MkdirAll(filepath.Dir(transcript), 0755)
+	toolUse := `{"type":"assistant","message":{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"git push --force origin main"}}]}}`
+	rejected := `{"type":"user","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","is_error":true,"content":"The user doesn't want to proceed"}]}}`
+
+	tests := []struct {
+		name       string
+		transcript string
+		lines      []string
+		event      HookInput
+		want       bool
+	}{
+		{"confirmed", transcript, []string{toolUse}, HookInput{ToolUseID: "toolu_1"}, true},
+		{"no tool use id", transcript, []string{toolUse}, HookInput{}, false},
+		{"other tool use", transcript, []string{toolUse}, HookInput{ToolUseID: "toolu_2"}, false},
+		{"not in transcript", transcript, nil, HookInput{ToolUseID: "toolu_1"}, false},
+		{"rejected", transcript, []string{toolUse, rejected}, HookInput{ToolUseID: "toolu_1"}, false},
+		{"transcript elsewhere", filepath.Join(home, "project", "s1.jsonl"), []string{toolUse}, HookInput{ToolUseID: "toolu_1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.MkdirAll(filepath.Dir(tt.transcript), 0755)
+			os.WriteFile(tt.transcript, []byte(strings.Join(tt.lines, "\n")+"\n"), 0644)
+			session := "s-" + strings.ReplaceAll(tt.name, " ", "-")
+
+			recordPendingDecision(&HookInput{SessionID: session, ToolName: "Bash", ToolUseID: "toolu_1", TranscriptPath: tt.transcript},
+				input, "ASK", "rules", "key")
+			event := tt.event
+			event.SessionID, event.HookEventName, event.ToolName, event.ToolInput = session, EventPostToolUse, "Bash", input
+			event.TranscriptPath = tt.transcript
+			recordOutcome(&event)
+
+			if got := recallApproval(session, "key"); got != tt.want {
+				t.Errorf("approval remembered = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
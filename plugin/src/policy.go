@@ -0,0 +1,559 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Policy is the user-editable configuration loaded from policy.json in
+// configDir(). Every field is optional; anything left out keeps the default.
+type Policy struct {
+	// SkipTools decides which tools bypass evaluation entirely. Entries are
+	// merged over defaultSkipTools, so a policy only lists what it changes.
+	SkipTools map[string]SkipRule `json:"skip_tools,omitempty"`
+
+	// ProductionPatterns are case-insensitive glob patterns (path.Match syntax)
+	// for workspace, stack, stage and environment names that refer to
+	// production. A non-empty list replaces the defaults.
+	ProductionPatterns []string `json:"production_patterns,omitempty"`
+
+	// AwsAskProfiles are glob patterns for AWS profiles whose every command
+	// asks, reads included.
+	AwsAskProfiles []string `json:"aws_ask_profiles,omitempty"`
+
+	// GcloudAskProjects are glob patterns for Google Cloud projects whose
+	// every command asks.
+	GcloudAskProjects []string `json:"gcloud_ask_projects,omitempty"`
+
+	// KubeContexts pick a kubectl/helm profile per context and namespace.
+	// User rules are checked first, then defaultKubeContexts.
+	KubeContexts []KubeContextRule `json:"kube_contexts,omitempty"`
+
+	// ProtectedBranches are glob patterns for branches that force pushes and
+	// deletes ask for. A non-empty list replaces the defaults.
+	ProtectedBranches []string `json:"protected_branches,omitempty"`
+
+	// ProductionHosts and DevHosts are glob patterns for ssh, scp and rsync
+	// hosts, matched against the name as written and its ssh config
+	// HostName. Production hosts ask for anything not allowed outright; dev
+	// hosts hand asks to the evaluator instead.
+	ProductionHosts []string `json:"production_hosts,omitempty"`
+	DevHosts        []string `json:"dev_hosts,omitempty"`
+
+	// Categories decide whether each category of dangerous call asks or is
+	// denied outright. Entries are merged over defaultCategories.
+	Categories map[string]string `json:"categories,omitempty"`
+
+	// PreToolUse decides which verdicts a PreToolUse hook answers, which is
+	// what still runs under bypassPermissions. Defaults to PreToolUseAuto.
+	PreToolUse string `json:"pre_tool_use,omitempty"`
+
+	// Rewrites turns on input rewrites by name (see inputRewrites): a call
+	// that would ask is replaced by its safer equivalent and allowed. All
+	// rewrites are off unless listed.
+	Rewrites map[string]bool `json:"rewrites,omitempty"`
+
+	// SessionApprovals controls how approved ASKs are remembered for the
+	// rest of a session.
+	SessionApprovals SessionApprovalPolicy `json:"session_approvals,omitempty"`
+
+	// PermissionModes adjust decisions per Claude Code permission mode.
+	// Entries are merged over defaultPermissionModes field by field.
+	PermissionModes map[string]PermissionModeRule `json:"permission_modes,omitempty"`
+
+	// DaemonFailure decides calls the evaluator can't answer, and when the
+	// client stops trying it for a while.
+	DaemonFailure DaemonFailurePolicy `json:"daemon_failure,omitempty"`
+}
+
+// DaemonFailurePolicy configures daemon failures (see failureAction). Actions
+// are FailureAsk, FailureDeny or FailureAllowIfSafe.
+type DaemonFailurePolicy struct {
+	Action           string            `json:"action,omitempty"`            // for every call, unless a more specific entry applies
+	Tools            map[string]string `json:"tools,omitempty"`             // tool name or glob pattern → action
+	Profiles         map[string]string `json:"profiles,omitempty"`          // "production" or "dev" target → action
+	BreakerThreshold int               `json:"breaker_threshold,omitempty"` // consecutive failures that open the breaker
+	BreakerCoolDown  string            `json:"breaker_cooldown,omitempty"`  // Go duration the daemon is skipped for
+}
+
+// SessionApprovalPolicy configures session approvals. Approvals are on by
+// default, last defaultSessionApprovalTTL and match calls exactly.
+type SessionApprovalPolicy struct {
+	Enabled   *bool    `json:"enabled,omitempty"`
+	TTL       string   `json:"ttl,omitempty"`       // Go duration, e.g. "8h"
+	Normalize []string `json:"normalize,omitempty"` // see knownNormalizations
+}
+
+const defaultSessionApprovalTTL = 8 * time.Hour
+
+func (s SessionApprovalPolicy) enabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// ttl returns how long an approval lasts. validate has checked the value.
+func (s SessionApprovalPolicy) ttl() time.Duration {
+	if d, err := time.ParseDuration(s.TTL); err == nil && d > 0 {
+		return d
+	}
+	return defaultSessionApprovalTTL
+}
+
+// PreToolUse modes for Policy.PreToolUse.
+const (
+	PreToolUseAuto = "auto" // ask under bypassPermissions, deny in every other mode
+	PreToolUseAsk  = "ask"  // allow, ask (forcing the dialog) and deny
+	PreToolUseDeny = "deny" // deny only; the rest is left to the permission mode
+	PreToolUseOff  = "off"  // PreToolUse events pass through untouched
+)
+
+// preToolUseMode resolves the pre_tool_use setting for a permission mode.
+// Auto answers everything only under bypassPermissions, where no dialog
+// comes up otherwise; elsewhere PermissionRequest answers allows and asks.
+func (p *Policy) preToolUseMode(permissionMode string) string {
+	if p.PreToolUse != PreToolUseAuto && p.PreToolUse != "" {
+		return p.PreToolUse
+	}
+	if permissionMode == PermissionModeBypass {
+		return PreToolUseAsk
+	}
+	return PreToolUseDeny
+}
+
+// Rule categories that policy can switch between asking and denying.
+const (
+	CategoryDangerousDelete = "dangerous_delete" // rm of /, ~ and system roots
+	CategoryPipeToShell     = "pipe_to_shell"    // curl ... | sh
+	CategorySystemWrite     = "system_write"     // writes to system paths and shell profiles
+	CategorySelfProtection  = "self_protection"  // changes to the guard or Claude's permission settings
+	CategoryProtectedBranch = "protected_branch" // force pushes and deletes of protected branches
+	CategoryEvaluator       = "evaluator"        // DENY answers from the evaluator
+)
+
+// Category verdicts for Policy.Categories.
+const (
+	CategoryAsk  = "ask"
+	CategoryDeny = "deny"
+)
+
+var defaultCategories = map[string]string{
+	CategoryDangerousDelete: CategoryDeny,
+	CategoryPipeToShell:     CategoryAsk,
+	CategorySystemWrite:     CategoryAsk,
+	CategorySelfProtection:  CategoryAsk,
+	CategoryProtectedBranch: CategoryAsk,
+	CategoryEvaluator:       CategoryDeny,
+}
+
+// Skip modes for SkipRule.Mode.
+const (
+	SkipModeSkip         = "skip"          // never evaluate
+	SkipModeEvaluate     = "evaluate"      // always evaluate
+	SkipModeEvaluateWhen = "evaluate_when" // evaluate only if a condition matches
+)
+
+// SkipRule controls whether a tool bypasses evaluation. In policy.json it can
+// be written as a bare mode string ("skip") or as an object with conditions.
+type SkipRule struct {
+	Mode string           `json:"mode"`
+	When []FieldCondition `json:"when,omitempty"`
+}
+
+func (r *SkipRule) UnmarshalJSON(data []byte) error {
+	var mode string
+	if err := json.Unmarshal(data, &mode); err == nil {
+		r.Mode = mode
+		return nil
+	}
+	type plain SkipRule
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// FieldCondition is a predicate on a tool input field. Field is a dotted path
+// into the input object; exactly one of Equals, Contains or Matches applies.
+type FieldCondition struct {
+	Field    string `json:"field"`
+	Equals   string `json:"equals,omitempty"`
+	Contains string `json:"contains,omitempty"`
+	Matches  string `json:"matches,omitempty"` // regular expression
+}
+
+// defaultSkipTools contains tools that don't need security evaluation.
+// These are either read-only, user-facing, or internal tracking tools.
+var defaultSkipTools = map[string]SkipRule{
+	// Plan mode - separate UX flow for plan approval
+	"ExitPlanMode":  {Mode: SkipModeSkip},
+	"EnterPlanMode": {Mode: SkipModeSkip},
+
+	// User interaction - just prompts the user
+	"AskUserQuestion": {Mode: SkipModeSkip},
+
+	// Task tracking - internal state management
+	"TaskCreate": {Mode: SkipModeSkip},
+	"TaskUpdate": {Mode: SkipModeSkip},
+	"TaskList":   {Mode: SkipModeSkip},
+	"TaskGet":    {Mode: SkipModeSkip},
+	"TaskStop":   {Mode: SkipModeSkip},
+	"TaskOutput": {Mode: SkipModeSkip},
+
+	// Read-only tools - no side effects
+	"Read":      {Mode: SkipModeSkip},
+	"Glob":      {Mode: SkipModeSkip},
+	"Grep":      {Mode: SkipModeSkip},
+	"WebFetch":  {Mode: SkipModeSkip},
+	"WebSearch": {Mode: SkipModeSkip},
+
+	// Subagent/skill invocation - spawns isolated work
+	"Task":  {Mode: SkipModeSkip},
+	"Skill": {Mode: SkipModeSkip},
+}
+
+var defaultProtectedBranches = []string{"main", "master"}
+
+var defaultProductionPatterns = []string{
+	"prod", "prod-*", "prod_*", "*-prod", "*_prod", "*-prod-*",
+	"production", "production-*", "*-production", "prd", "*-prd", "live",
+}
+
+// defaultPolicy returns the built-in policy used when no policy file exists.
+func defaultPolicy() *Policy {
+	p := &Policy{
+		SkipTools:          map[string]SkipRule{},
+		ProductionPatterns: append([]string(nil), defaultProductionPatterns...),
+		KubeContexts:       append([]KubeContextRule(nil), defaultKubeContexts...),
+		ProtectedBranches:  append([]string(nil), defaultProtectedBranches...),
+		Categories:         map[string]string{},
+		PreToolUse:         PreToolUseAuto,
+		PermissionModes:    map[string]PermissionModeRule{},
+	}
+	for mode, rule := range defaultPermissionModes {
+		p.PermissionModes[mode] = rule
+	}
+	for name, rule := range defaultSkipTools {
+		p.SkipTools[name] = rule
+	}
+	for category, verdict := range defaultCategories {
+		p.Categories[category] = verdict
+	}
+	return p
+}
+
+// activePolicy is the policy used by the rule engine and the client.
+// runClient replaces it with the user's policy file at startup.
+var activePolicy = defaultPolicy()
+
+func currentPolicy() *Policy {
+	return activePolicy
+}
+
+func defaultPolicyPath() string {
+	return filepath.Join(configDir(), "policy.json")
+}
+
+// loadPolicy reads a policy file and merges it over the defaults.
+// A missing file is not an error; the default policy is returned.
+func loadPolicy(path string) (*Policy, error) {
+	policy := defaultPolicy()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, err
+	}
+
+	var user Policy
+	if err := json.Unmarshal(data, &user); err != nil {
+		return policy, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := user.validate(); err != nil {
+		return policy, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for name, rule := range user.SkipTools {
+		policy.SkipTools[name] = rule
+	}
+	if len(user.ProductionPatterns) > 0 {
+		policy.ProductionPatterns = user.ProductionPatterns
+	}
+	policy.AwsAskProfiles = user.AwsAskProfiles
+	policy.GcloudAskProjects = user.GcloudAskProjects
+	policy.KubeContexts = append(user.KubeContexts, policy.KubeContexts...)
+	if len(user.ProtectedBranches) > 0 {
+		policy.ProtectedBranches = user.ProtectedBranches
+	}
+	policy.ProductionHosts = user.ProductionHosts
+	policy.DevHosts = user.DevHosts
+	for category, verdict := range user.Categories {
+		policy.Categories[category] = verdict
+	}
+	if user.PreToolUse != "" {
+		policy.PreToolUse = user.PreToolUse
+	}
+	policy.Rewrites = user.Rewrites
+	policy.SessionApprovals = user.SessionApprovals
+	policy.DaemonFailure = user.DaemonFailure
+	for mode, user := range user.PermissionModes {
+		rule := policy.PermissionModes[mode]
+		if user.PassthroughTools != nil {
+			rule.PassthroughTools = user.PassthroughTools
+		}
+		if user.DenyMutating != nil {
+			rule.DenyMutating = user.DenyMutating
+		}
+		if user.Verdicts != nil {
+			verdicts := map[string]string{}
+			for verdict, action := range rule.Verdicts {
+				verdicts[verdict] = action
+			}
+			for verdict, action := range user.Verdicts {
+				verdicts[verdict] = action
+			}
+			rule.Verdicts = verdicts
+		}
+		policy.PermissionModes[mode] = rule
+	}
+	return policy, nil
+}
+
+func (p *Policy) validate() error {
+	if err := validatePatterns("production_patterns", p.ProductionPatterns); err != nil {
+		return err
+	}
+	if err := validatePatterns("aws_ask_profiles", p.AwsAskProfiles); err != nil {
+		return err
+	}
+	if err := validatePatterns("gcloud_ask_projects", p.GcloudAskProjects); err != nil {
+		return err
+	}
+	if err := validatePatterns("protected_branches", p.ProtectedBranches); err != nil {
+		return err
+	}
+	if err := validatePatterns("production_hosts", p.ProductionHosts); err != nil {
+		return err
+	}
+	if err := validatePatterns("dev_hosts", p.DevHosts); err != nil {
+		return err
+	}
+	switch p.PreToolUse {
+	case "", PreToolUseAuto, PreToolUseAsk, PreToolUseDeny, PreToolUseOff:
+	default:
+		return fmt.Errorf("pre_tool_use: unknown mode %q", p.PreToolUse)
+	}
+	for name := range p.Rewrites {
+		if !isKnownRewrite(name) {
+			return fmt.Errorf("rewrites: unknown rewrite %q", name)
+		}
+	}
+	if ttl := p.SessionApprovals.TTL; ttl != "" {
+		if d, err := time.ParseDuration(ttl); err != nil || d <= 0 {
+			return fmt.Errorf("session_approvals.ttl: bad duration %q", ttl)
+		}
+	}
+	for _, name := range p.SessionApprovals.Normalize {
+		if !knownNormalizations[name] {
+			return fmt.Errorf("session_approvals.normalize: unknown normalization %q", name)
+		}
+	}
+	if err := p.DaemonFailure.validate(); err != nil {
+		return err
+	}
+	for mode, rule := range p.PermissionModes {
+		if !knownPermissionModes[mode] {
+			return fmt.Errorf("permission_modes: unknown mode %q", mode)
+		}
+		for verdict, action := range rule.Verdicts {
+			switch verdict {
+			case "allow", "ask", "uncertain", "deny":
+			default:
+				return fmt.Errorf("permission_modes.%s.verdicts: unknown verdict %q", mode, verdict)
+			}
+			if !knownModeActions[action] {
+				return fmt.Errorf("permission_modes.%s.verdicts.%s: unknown action %q", mode, verdict, action)
+			}
+			if verdict == "deny" && action != ModeActionDeny && action != ModeActionKeep {
+				return fmt.Errorf("permission_modes.%s.verdicts.deny: a deny can't be relaxed to %q", mode, action)
+			}
+		}
+	}
+	for category, verdict := range p.Categories {
+		if _, ok := defaultCategories[category]; !ok {
+			return fmt.Errorf("categories: unknown category %q", category)
+		}
+		if verdict != CategoryAsk && verdict != CategoryDeny {
+			return fmt.Errorf("categories.%s: unknown verdict %q", category, verdict)
+		}
+	}
+	for i, rule := range p.KubeContexts {
+		switch rule.Profile {
+		case KubeProfilePermissive, KubeProfileDefault, KubeProfileStrict:
+		default:
+			return fmt.Errorf("kube_contexts[%d]: unknown profile %q", i, rule.Profile)
+		}
+		if err := validatePatterns(fmt.Sprintf("kube_contexts[%d]", i), []string{rule.Context, rule.Namespace}); err != nil {
+			return err
+		}
+	}
+	for name, rule := range p.SkipTools {
+		switch rule.Mode {
+		case SkipModeSkip, SkipModeEvaluate:
+		case SkipModeEvaluateWhen:
+			if len(rule.When) == 0 {
+				return fmt.Errorf("skip_tools.%s: evaluate_when needs at least one condition", name)
+			}
+			for i, cond := range rule.When {
+				key := fmt.Sprintf("skip_tools.%s.when[%d]", name, i)
+				if cond.Field == "" {
+					return fmt.Errorf("%s: field is required", key)
+				}
+				set := 0
+				for _, matcher := range []string{cond.Equals, cond.Contains, cond.Matches} {
+					if matcher != "" {
+						set++
+					}
+				}
+				if set != 1 {
+					return fmt.Errorf("%s: exactly one of equals, contains or matches is required", key)
+				}
+				if cond.Matches != "" {
+					if _, err := regexp.Compile(cond.Matches); err != nil {
+						return fmt.Errorf("%s: bad pattern %q: %w", key, cond.Matches, err)
+					}
+				}
+			}
+		default:
+			return fmt.Errorf("skip_tools.%s: unknown mode %q", name, rule.Mode)
+		}
+	}
+	return nil
+}
+
+func validatePatterns(key string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s: bad pattern %q: %w", key, pattern, err)
+		}
+	}
+	return nil
+}
+
+// shouldSkipEvaluation reports whether a tool call bypasses evaluation.
+// Tools without a skip rule are evaluated.
+func (p *Policy) shouldSkipEvaluation(toolName string, toolInput json.RawMessage) bool {
+	rule, ok := p.SkipTools[toolName]
+	if !ok {
+		return false
+	}
+
+	switch rule.Mode {
+	case SkipModeSkip:
+		return true
+	case SkipModeEvaluateWhen:
+		// Skip unless one of the conditions flags this call
+		for _, cond := range rule.When {
+			if cond.match(toolInput) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// match reports whether the condition holds for the given tool input.
+// Missing fields never match.
+func (c FieldCondition) match(toolInput json.RawMessage) bool {
+	value, ok := lookupField(toolInput, c.Field)
+	if !ok {
+		return false
+	}
+
+	switch {
+	case c.Equals != "":
+		return value == c.Equals
+	case c.Contains != "":
+		return strings.Contains(value, c.Contains)
+	case c.Matches != "":
+		re, err := regexp.Compile(c.Matches)
+		return err == nil && re.MatchString(value)
+	}
+	return false
+}
+
+// lookupField resolves a dotted path in a JSON object and returns the value
+// as a string. Non-string values are returned in their JSON encoding.
+func lookupField(toolInput json.RawMessage, path string) (string, bool) {
+	var current interface{}
+	if err := json.Unmarshal(toolInput, &current); err != nil {
+		return "", false
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if current, ok = obj[key]; !ok {
+			return "", false
+		}
+	}
+
+	if s, ok := current.(string); ok {
+		return s, true
+	}
+	encoded, err := json.Marshal(current)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// categoryVerdict returns VerdictDeny if the policy denies a category and
+// VerdictAsk otherwise.
+func (p *Policy) categoryVerdict(category string) Verdict {
+	if p.Categories[category] == CategoryDeny {
+		return VerdictDeny
+	}
+	return VerdictAsk
+}
+
+// permissionMode returns the rule for a Claude Code permission mode. Unknown
+// and unset modes have no rule.
+func (p *Policy) permissionMode(mode string) PermissionModeRule {
+	return p.PermissionModes[mode]
+}
+
+// askOrDeny is the verdict for a dangerous call of the given category under
+// the active policy. The category is noted for the current evaluation.
+func askOrDeny(category string) Verdict {
+	note(noteCategory, category)
+	return currentPolicy().categoryVerdict(category)
+}
+
+// isProduction reports whether a workspace, stack, stage or environment name
+// matches one of the production patterns.
+func (p *Policy) isProduction(name string) bool {
+	return matchesAnyPattern(name, p.ProductionPatterns)
+}
+
+// matchesAnyPattern reports whether name matches one of the glob patterns,
+// ignoring case.
+func matchesAnyPattern(name string, patterns []string) bool {
+	if name == "" {
+		return false
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package main
+
+// Popular packages per registry, used to spot typosquats: a name one edit
+// away from one of these (and not itself on the list) is suspicious. The
+// lists hold the most downloaded packages; they don't need to be complete,
+// only to cover the names worth imitating. The neighbour lists hold
+// well-known packages that happen to be one edit away from a popular one.
+
+var popularNpmPackages = []string{
+	"react", "react-dom", "react-router", "react-router-dom", "react-redux", "redux",
+	"next", "vue", "vue-router", "vuex", "pinia", "nuxt", "svelte", "angular",
+	"@angular/core", "@angular/cli", "preact", "solid-js", "lit", "jquery",
+	"lodash", "lodash-es", "underscore", "ramda", "immer", "immutable",
+	"axios", "node-fetch", "cross-fetch", "got", "superagent", "request", "ky",
+	"express", "koa", "fastify", "hapi", "restify", "body-parser", "cors",
+	"cookie-parser", "helmet", "morgan", "multer", "compression", "socket.io",
+	"ws", "graphql", "apollo-server", "@apollo/client", "mongoose", "mongodb",
+	"mysql", "mysql2", "pg", "sqlite3", "sequelize", "typeorm", "prisma",
+	"@prisma/client", "knex", "redis", "ioredis", "typescript", "ts-node",
+	"tslib", "@types/node", "@types/react", "@types/express", "@types/jest",
+	"babel-core", "@babel/core", "@babel/preset-env", "@babel/preset-react",
+	"@babel/runtime", "webpack", "webpack-cli", "webpack-dev-server", "rollup",
+	"vite", "esbuild", "parcel", "turbo", "nx", "lerna", "gulp", "grunt",
+	"eslint", "prettier", "stylelint", "jest", "mocha", "chai", "sinon",
+	"vitest", "cypress", "playwright", "@playwright/test", "puppeteer",
+	"supertest", "nyc", "nodemon", "pm2", "concurrently", "cross-env",
+	"dotenv", "commander", "yargs", "minimist", "inquirer", "chalk", "colors",
+	"debug", "winston", "pino", "bunyan", "moment", "dayjs", "date-fns",
+	"luxon", "uuid", "nanoid", "classnames", "clsx", "styled-components",
+	"@emotion/react", "tailwindcss", "postcss", "autoprefixer", "sass", "less",
+	"bootstrap", "@mui/material", "antd", "rxjs", "async", "bluebird",
+	"glob", "rimraf", "mkdirp", "fs-extra", "chokidar", "semver", "yaml",
+	"js-yaml", "ajv", "joi", "yup", "zod", "validator", "jsonwebtoken",
+	"bcrypt", "bcryptjs", "passport", "crypto-js", "node-forge", "sharp",
+	"jsdom", "cheerio", "marked", "handlebars", "ejs", "pug", "mustache",
+	"electron", "electron-builder", "aws-sdk", "@aws-sdk/client-s3",
+	"firebase", "firebase-admin", "stripe", "twilio", "nodemailer",
+	"openai", "@anthropic-ai/sdk", "langchain", "husky", "lint-staged",
+	"npm", "yarn", "pnpm", "core-js", "regenerator-runtime", "qs", "form-data",
+}
+
+var npmPackageNeighbours = []string{
+	"color",  // colors
+	"jsdoc",  // jsdom
+	"tslint", // eslint
+}
+
+var popularPypiPackages = []string{
+	"requests", "urllib3", "certifi", "idna", "charset-normalizer", "httpx",
+	"aiohttp", "httplib2", "six", "setuptools", "wheel", "pip", "virtualenv",
+	"packaging", "python-dateutil", "pytz", "tzdata", "pyyaml", "toml",
+	"tomli", "simplejson", "ujson", "orjson", "numpy", "pandas", "scipy",
+	"matplotlib", "seaborn", "plotly", "scikit-learn", "statsmodels",
+	"tensorflow", "keras", "torch", "torchvision", "transformers", "datasets",
+	"tokenizers", "huggingface-hub", "jax", "xgboost", "lightgbm", "opencv-python",
+	"pillow", "imageio", "nltk", "spacy", "gensim", "openai", "anthropic",
+	"langchain", "tiktoken", "django", "djangorestframework", "flask",
+	"fastapi", "starlette", "uvicorn", "gunicorn", "werkzeug", "jinja2",
+	"markupsafe", "itsdangerous", "click", "typer", "rich", "colorama",
+	"tqdm", "pydantic", "attrs", "marshmallow", "sqlalchemy", "alembic",
+	"psycopg2", "psycopg2-binary", "pymysql", "mysqlclient", "pymongo",
+	"redis", "celery", "kombu", "boto3", "botocore", "s3transfer", "awscli",
+	"google-cloud-storage", "google-api-python-client", "azure-storage-blob",
+	"cryptography", "pyopenssl", "pycryptodome", "bcrypt", "paramiko",
+	"pyjwt", "oauthlib", "requests-oauthlib", "rsa", "pyasn1", "cffi",
+	"pycparser", "lxml", "beautifulsoup4", "html5lib", "scrapy", "selenium",
+	"playwright", "pytest", "pytest-cov", "pytest-mock", "coverage", "tox",
+	"nox", "mock", "hypothesis", "black", "flake8", "pylint", "mypy", "isort",
+	"ruff", "autopep8", "yapf", "pre-commit", "sphinx", "mkdocs", "jupyter",
+	"notebook", "jupyterlab", "ipython", "ipykernel", "pyzmq", "tornado",
+	"twisted", "gevent", "greenlet", "protobuf", "grpcio", "thrift",
+	"docker", "kubernetes", "ansible", "fabric", "invoke", "psutil",
+	"python-dotenv", "decorator", "wrapt", "filelock", "platformdirs",
+	"networkx", "sympy", "pyarrow", "polars", "dask", "numba", "cython",
+	"regex", "chardet", "markdown", "pygments", "docutils", "openpyxl",
+	"xlrd", "pyserial", "pexpect", "sh", "poetry", "pipenv", "uv",
+}
+
+var pypiPackageNeighbours = []string{
+	"boto",    // boto3
+	"pyaml",   // pyyaml
+	"pymssql", // pymysql
+	"scapy",   // scipy
+}
+
+var popularCrates = []string{
+	"serde", "serde_json", "serde_derive", "serde_yaml", "toml", "tokio",
+	"futures", "async-trait", "async-std", "reqwest", "hyper", "axum",
+	"actix-web", "warp", "rocket", "tower", "tonic", "prost", "clap",
+	"structopt", "anyhow", "thiserror", "log", "env_logger", "tracing",
+	"tracing-subscriber", "rand", "regex", "lazy_static", "once_cell",
+	"chrono", "time", "uuid", "itertools", "rayon", "crossbeam", "parking_lot",
+	"bytes", "byteorder", "base64", "hex", "sha2", "md5", "ring", "rustls",
+	"openssl", "native-tls", "libc", "nix", "winapi", "windows", "bitflags",
+	"cfg-if", "syn", "quote", "proc-macro2", "num", "num-traits", "nom",
+	"pest", "url", "http", "mime", "diesel", "sqlx", "rusqlite", "redis",
+	"mongodb", "image", "walkdir", "glob", "tempfile", "dirs", "indicatif",
+	"colored", "termcolor", "crossterm", "ratatui", "criterion", "proptest",
+	"wasm-bindgen", "js-sys", "web-sys", "bevy", "egui", "tauri",
+	"cargo-edit", "cargo-watch", "ripgrep", "bat", "fd-find", "exa",
+}
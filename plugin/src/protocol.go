@@ -7,6 +7,7 @@ const (
 	VerdictAllow     Verdict = iota // Deterministically safe
 	VerdictAsk                      // Deterministically requires user approval
 	VerdictUncertain                // Cannot determine — needs Claude evaluation
+	VerdictDeny                     // Refused outright; Claude is told why
 )
 
 func (v Verdict) String() string {
@@ -15,20 +16,54 @@ func (v Verdict) String() string {
 		return "ALLOW"
 	case VerdictAsk:
 		return "ASK"
+	case VerdictDeny:
+		return "DENY"
 	default:
 		return "UNCERTAIN"
 	}
 }
 
+// parseVerdict reads a daemon decision. Anything unrecognized is an ASK.
+func parseVerdict(decision string) Verdict {
+	switch decision {
+	case "ALLOW":
+		return VerdictAllow
+	case "DENY":
+		return VerdictDeny
+	default:
+		return VerdictAsk
+	}
+}
+
+// Request types for EvalRequest.Type. The session approval requests only
+// use SessionID, Key and TTL.
+const (
+	RequestEvaluate = ""         // evaluate a tool call
+	RequestRemember = "remember" // remember an approved call for the session
+	RequestRecall   = "recall"   // ALLOW if the call was approved in the session
+	RequestForget   = "forget"   // drop a session's approvals, or all of them
+)
+
 // EvalRequest is sent from client to daemon via Unix socket.
 type EvalRequest struct {
+	Type      string `json:"type,omitempty"`
 	ToolName  string `json:"tool_name"`
 	ToolInput string `json:"tool_input"`
 	WorkDir   string `json:"work_dir"`
+
+	// TranscriptPath is the session transcript; the evaluator reads the
+	// user's latest messages from it.
+	TranscriptPath string `json:"transcript_path,omitempty"`
+
+	SessionID string `json:"session_id,omitempty"`
+	Key       string `json:"key,omitempty"`         // see approvalKey
+	TTL       int64  `json:"ttl_seconds,omitempty"` // how long a remembered approval lasts
 }
 
 // EvalResponse is sent from daemon to client via Unix socket.
 type EvalResponse struct {
-	Decision string `json:"decision"` // "ALLOW" or "ASK"
+	Decision string `json:"decision"` // "ALLOW", "ASK" or "DENY"
 	Reason   string `json:"reason"`
+	Excerpt  string `json:"excerpt,omitempty"` // the transcript excerpt in the prompt, if any
+	Failed   bool   `json:"failed,omitempty"`  // the evaluator couldn't answer; Reason says why
 }
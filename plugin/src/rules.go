@@ -4,12 +4,32 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 )
 
 // EvaluateRules applies deterministic rules to decide if a tool call is safe.
-// Returns VerdictAllow, VerdictAsk, or VerdictUncertain.
+// Returns VerdictAllow, VerdictAsk, VerdictUncertain, or VerdictDeny.
 func EvaluateRules(toolName string, toolInput json.RawMessage, workDir string) (Verdict, string) {
+	ruleNotes = map[string]bool{}
+
+	// The guard's own files and Claude's permission settings come first,
+	// unless the other rules deny the call outright (rm -rf ~ does both)
+	if verdict, reason, hit := evaluateSelfProtection(toolName, toolInput, workDir); hit {
+		if v, r := evaluateTool(toolName, toolInput, workDir); v == VerdictDeny {
+			return v, r
+		}
+		return verdict, reason
+	}
+	verdict, reason := evaluateTool(toolName, toolInput, workDir)
+	if verdict == VerdictUncertain && len(notedValues(noteUncertain)) == 0 {
+		note(noteUncertain, uncertainOther)
+	}
+	return verdict, reason
+}
+
+func evaluateTool(toolName string, toolInput json.RawMessage, workDir string) (Verdict, string) {
 	switch toolName {
 	case "Bash":
 		return evaluateBash(toolInput, workDir)
@@ -20,6 +40,120 @@ func EvaluateRules(toolName string, toolInput json.RawMessage, workDir string) (
 	}
 }
 
+// --- Evaluation notes ---
+//
+// Rules note what they saw while evaluating a call: the categories of
+// dangerous calls (askOrDeny), production or dev targets, why a call was
+// uncertain, and what the verdict read from outside the call itself. The
+// client reads them after EvaluateRules; cached verdicts replay the notes
+// they were computed with.
+
+// Note kinds.
+const (
+	noteCategory  = "category"  // a Category* constant
+	noteProfile   = "profile"   // profileProduction or profileDev
+	noteUncertain = "uncertain" // uncertainUnknown or uncertainOther
+	noteInput     = "input"     // a hash of a file, kube context or workspace the verdict depends on
+)
+
+// Target profiles for noteProfile.
+const (
+	profileProduction = "production"
+	profileDev        = "dev"
+)
+
+// Uncertainty classes for noteUncertain.
+const (
+	uncertainUnknown = "unknown" // a command the rules don't know
+	uncertainIntent  = "intent"  // understood and harmless; only the user's intent is in question
+	uncertainOther   = "other"   // anything else the rules couldn't settle
+)
+
+// ruleNotes holds "kind:value" notes since EvaluateRules started.
+var ruleNotes = map[string]bool{}
+
+func note(kind, value string) {
+	ruleNotes[kind+":"+value] = true
+}
+
+// noteInputs records something the verdict read from outside the call, such
+// as a script's content or the current kube context.
+func noteInputs(parts ...string) {
+	note(noteInput, contentKey(parts...)[:16])
+}
+
+func noted(kind, value string) bool {
+	return ruleNotes[kind+":"+value]
+}
+
+// notedValues returns the values noted for a kind, sorted.
+func notedValues(kind string) []string {
+	var values []string
+	for n := range ruleNotes {
+		if value, ok := strings.CutPrefix(n, kind+":"); ok {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// ruleNoteList returns every note of the last evaluation, sorted.
+func ruleNoteList() []string {
+	notes := make([]string, 0, len(ruleNotes))
+	for n := range ruleNotes {
+		notes = append(notes, n)
+	}
+	sort.Strings(notes)
+	return notes
+}
+
+// ruleCategories returns the categories flagged by the last evaluation.
+func ruleCategories() []string {
+	return notedValues(noteCategory)
+}
+
+// ruleProfile returns the riskiest target profile the last evaluation saw,
+// or "".
+func ruleProfile() string {
+	switch {
+	case noted(noteProfile, profileProduction):
+		return profileProduction
+	case noted(noteProfile, profileDev):
+		return profileDev
+	}
+	return ""
+}
+
+// intentNotes counts uncertainIntent notes, so evaluateCommand can tell
+// which segments were left to the evaluator for intent alone.
+var intentNotes int
+
+// uncertainOnIntent leaves a call the rules understood and found harmless
+// to the evaluator, which knows what the user asked for.
+func uncertainOnIntent(reason string) (Verdict, string) {
+	note(noteUncertain, uncertainIntent)
+	intentNotes++
+	return VerdictUncertain, reason
+}
+
+// ruleLeanedSafe reports whether the last evaluation was uncertain only
+// because of the user's intent: everything else was allowed, no command was
+// unknown, and nothing risky or production-bound was seen.
+func ruleLeanedSafe() bool {
+	return noted(noteUncertain, uncertainIntent) && !noted(noteUncertain, uncertainUnknown) &&
+		!noted(noteUncertain, uncertainOther) && len(ruleCategories()) == 0 && ruleProfile() != profileProduction
+}
+
+// ruleEngineTools are the tools EvaluateRules has dedicated rules for.
+var ruleEngineTools = map[string]bool{
+	"Bash": true, "Write": true, "Edit": true, "NotebookEdit": true,
+}
+
+func isRuleEngineTool(toolName string) bool {
+	return ruleEngineTools[toolName]
+}
+
 // --- Bash evaluation ---
 
 func evaluateBash(toolInput json.RawMessage, workDir string) (Verdict, string) {
@@ -39,43 +173,155 @@ func evaluateBash(toolInput json.RawMessage, workDir string) (Verdict, string) {
 }
 
 func evaluateCommand(command, workDir string) (Verdict, string) {
-	segments := splitCompoundCommand(command)
+	if currentLine == nil {
+		currentLine = &lineContext{written: map[string]string{}, functions: map[string]string{}, vars: map[string]string{}}
+		defer func() { currentLine = nil }()
+	}
+
+	command, heredocs := extractHeredocs(command)
+	command = stripShellComments(command)
+	segments := splitCommandSegments(command)
+	for name, body := range shellFunctions(command) {
+		currentLine.functions[name] = body
+	}
+
+	// A nested line (sh -c, a wrapper, a function) reads whatever its
+	// caller's segment was piped
+	inheritedPipe := currentLine.piped
+	defer func() { currentLine.piped = inheritedPipe }()
 
 	worstVerdict := VerdictAllow
-	var worstReason string
+	var worstReason, allowReason string
+	seenIntent := intentNotes
+	merge := func(verdict Verdict, reason string) {
+		intent := intentNotes != seenIntent
+		seenIntent = intentNotes
+		if verdict == VerdictAllow && allowReason == "" {
+			allowReason = reason
+		}
+		if verdict == VerdictUncertain {
+			switch {
+			case strings.HasPrefix(reason, "unknown command: "):
+				note(noteUncertain, uncertainUnknown)
+			case !intent:
+				note(noteUncertain, uncertainOther)
+			}
+		}
+		if verdict > worstVerdict {
+			worstVerdict = verdict
+			worstReason = reason
+		}
+	}
 
-	for i, seg := range segments {
-		seg = strings.TrimSpace(seg)
-		if seg == "" {
+	project, dirStack := workDir, []string(nil)
+	for _, seg := range segments {
+		text := strings.TrimSpace(seg.text)
+		if text == "" {
 			continue
 		}
 
 		// Check for pipe-to-shell pattern (curl ... | bash)
-		if i > 0 && isPipeToShell(seg) {
-			return VerdictAsk, "pipe to shell interpreter: " + seg
+		if seg.piped && isPipeToShell(text) {
+			return askOrDeny(CategoryPipeToShell), "pipe to shell interpreter: " + text
 		}
 
-		verdict, reason := evaluateSegment(seg, workDir)
-		if verdict > worstVerdict {
-			worstVerdict = verdict
-			worstReason = reason
+		// Heredoc bodies feed the segment that declared them, in order
+		var stdin []string
+		for range heredocOperator.FindAllString(text, -1) {
+			if len(heredocs) > 0 {
+				stdin = append(stdin, heredocs[0])
+				heredocs = heredocs[1:]
+			}
+		}
+
+		currentLine.piped = inheritedPipe
+		for _, inner := range commandSubstitutions(text) {
+			merge(evaluateCommand(inner, workDir))
+		}
+
+		text = unwrapShellSyntax(text)
+		if text == "" {
+			continue
+		}
+
+		// cd moves the rest of the line; leaving the project is for the evaluator
+		switch extractBaseCommand(text) {
+		case "cd", "pushd", "popd":
+			if project == "" {
+				// No project to leave, as on a remote host
+				merge(VerdictAllow, "changes directory")
+				continue
+			}
+			dir, ok := changeDir(text, workDir, project, &dirStack)
+			if !ok {
+				merge(VerdictUncertain, "changes directory: "+text)
+				continue
+			}
+			workDir = dir
+			merge(VerdictAllow, "changes directory within project")
+			continue
 		}
+
+		// Variables assigned earlier on the line are judged by their values
+		text = substituteLineVars(text)
+		currentLine.piped = seg.piped || inheritedPipe
+		merge(evaluateSegmentWithInput(text, strings.Join(stdin, "\n"), workDir))
+		currentLine.recordAssignment(text, workDir)
+		currentLine.recordWrites(text, workDir)
+		currentLine.segments++
 	}
 
+	// An allowed line is explained by its first command
+	if worstVerdict == VerdictAllow {
+		return worstVerdict, allowReason
+	}
 	return worstVerdict, worstReason
 }
 
-// splitCompoundCommand splits on &&, ||, ;, and | while respecting quotes.
+// commandSegment is one simple command of a compound command line.
+type commandSegment struct {
+	text  string
+	piped bool // receives the previous segment's output through |
+}
+
+// splitCompoundCommand splits on &&, ||, ;, | and newlines while respecting quotes.
 func splitCompoundCommand(command string) []string {
-	var segments []string
+	var out []string
+	for _, seg := range splitCommandSegments(command) {
+		out = append(out, seg.text)
+	}
+	return out
+}
+
+func splitCommandSegments(command string) []commandSegment {
+	var segments []commandSegment
 	var current strings.Builder
 	inSingleQuote := false
 	inDoubleQuote := false
+	inBacktick := false
+	substDepth := 0 // open $( ... ) command substitutions
+	piped := false
+
+	flush := func(nextPiped bool) {
+		segments = append(segments, commandSegment{text: current.String(), piped: piped})
+		current.Reset()
+		piped = nextPiped
+	}
 
 	runes := []rune(command)
 	for i := 0; i < len(runes); i++ {
 		ch := runes[i]
 
+		// Backslash escapes the next character; backslash-newline continues the line
+		if ch == '\\' && !inSingleQuote && i+1 < len(runes) {
+			if runes[i+1] != '\n' {
+				current.WriteRune(ch)
+				current.WriteRune(runes[i+1])
+			}
+			i++
+			continue
+		}
+
 		if ch == '\'' && !inDoubleQuote {
 			inSingleQuote = !inSingleQuote
 			current.WriteRune(ch)
@@ -87,35 +333,48 @@ func splitCompoundCommand(command string) []string {
 			continue
 		}
 
-		if inSingleQuote || inDoubleQuote {
+		// Command substitutions run as a unit: don't split inside them
+		if !inSingleQuote {
+			switch {
+			case ch == '$' && i+1 < len(runes) && runes[i+1] == '(':
+				substDepth++
+				current.WriteString("$(")
+				i++
+				continue
+			case ch == '(' && substDepth > 0:
+				substDepth++
+			case ch == ')' && substDepth > 0:
+				substDepth--
+			case ch == '`':
+				inBacktick = !inBacktick
+			}
+		}
+
+		if inSingleQuote || inDoubleQuote || inBacktick || substDepth > 0 {
 			current.WriteRune(ch)
 			continue
 		}
 
 		// Split on && (skip second &)
 		if ch == '&' && i+1 < len(runes) && runes[i+1] == '&' {
-			segments = append(segments, current.String())
-			current.Reset()
+			flush(false)
 			i++
 			continue
 		}
 		// Split on || (skip second |)
 		if ch == '|' && i+1 < len(runes) && runes[i+1] == '|' {
-			segments = append(segments, current.String())
-			current.Reset()
+			flush(false)
 			i++
 			continue
 		}
 		// Split on single |
 		if ch == '|' {
-			segments = append(segments, current.String())
-			current.Reset()
+			flush(true)
 			continue
 		}
-		// Split on ;
-		if ch == ';' {
-			segments = append(segments, current.String())
-			current.Reset()
+		// Split on ; and newlines
+		if ch == ';' || ch == '\n' {
+			flush(false)
 			continue
 		}
 
@@ -123,12 +382,57 @@ func splitCompoundCommand(command string) []string {
 	}
 
 	if current.Len() > 0 {
-		segments = append(segments, current.String())
+		flush(false)
 	}
 
 	return segments
 }
 
+// heredocOperator matches <<WORD, <<-WORD and quoted delimiters, but not <<<.
+var heredocOperator = regexp.MustCompile(`(?:^|[^<])<<-?[ \t]*(?:'([A-Za-z_][A-Za-z0-9_]*)'|"([A-Za-z_][A-Za-z0-9_]*)"|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// extractHeredocs removes heredoc bodies from a command, returning the command
+// lines and the bodies in the order their operators appear. An operator whose
+// delimiter never appears is left alone, so nothing is hidden from evaluation.
+func extractHeredocs(command string) (string, []string) {
+	if !strings.Contains(command, "<<") {
+		return command, nil
+	}
+
+	lines := strings.Split(command, "\n")
+	var kept, bodies []string
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		kept = append(kept, line)
+
+		for _, m := range heredocOperator.FindAllStringSubmatch(line, -1) {
+			delim := m[1] + m[2] + m[3]
+			stripTabs := strings.Contains(m[0], "<<-")
+
+			end := -1
+			for j := i + 1; j < len(lines); j++ {
+				candidate := lines[j]
+				if stripTabs {
+					candidate = strings.TrimLeft(candidate, "\t")
+				}
+				if candidate == delim {
+					end = j
+					break
+				}
+			}
+			if end < 0 {
+				continue
+			}
+
+			bodies = append(bodies, strings.Join(lines[i+1:end], "\n"))
+			lines = append(lines[:i+1], lines[end+1:]...)
+		}
+	}
+
+	return strings.Join(kept, "\n"), bodies
+}
+
 func isPipeToShell(segment string) bool {
 	cmd := extractBaseCommand(strings.TrimSpace(segment))
 	switch cmd {
@@ -139,9 +443,107 @@ func isPipeToShell(segment string) bool {
 	return false
 }
 
+// shellWords splits a simple command into words the way the shell would,
+// honoring single quotes, double quotes and backslash escapes. Quotes are
+// removed; no expansion is performed.
+func shellWords(segment string) []string {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	inSingleQuote := false
+	inDoubleQuote := false
+
+	runes := []rune(segment)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+
+		// A command substitution is part of the word, spaces and all
+		if !inSingleQuote && (ch == '`' || ch == '$' && i+1 < len(runes) && runes[i+1] == '(') {
+			end := substitutionEnd(runes, i)
+			current.WriteString(string(runes[i:end]))
+			i, inWord = end-1, true
+			continue
+		}
+
+		switch {
+		case inSingleQuote:
+			if ch == '\'' {
+				inSingleQuote = false
+			} else {
+				current.WriteRune(ch)
+			}
+		case inDoubleQuote:
+			if ch == '"' {
+				inDoubleQuote = false
+			} else if ch == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`+"`", runes[i+1]) {
+				i++
+				current.WriteRune(runes[i])
+			} else {
+				current.WriteRune(ch)
+			}
+		case ch == '\'':
+			inSingleQuote, inWord = true, true
+		case ch == '"':
+			inDoubleQuote, inWord = true, true
+		case ch == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inWord = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(ch)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words
+}
+
+// substitutionEnd returns the index just past the $( ... ) or backtick
+// substitution starting at start.
+func substitutionEnd(runes []rune, start int) int {
+	if runes[start] == '`' {
+		for i := start + 1; i < len(runes); i++ {
+			if runes[i] == '`' {
+				return i + 1
+			}
+		}
+		return len(runes)
+	}
+	depth := 0
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(runes)
+}
+
 // extractBaseCommand gets the first command word, stripping env var prefixes and paths.
 func extractBaseCommand(segment string) string {
-	words := strings.Fields(segment)
+	word := commandWord(segment)
+	if word == "" {
+		return ""
+	}
+	return filepath.Base(word)
+}
+
+// commandWord returns the command word as written, after env var prefixes.
+func commandWord(segment string) string {
+	words := shellWords(segment)
 	if len(words) == 0 {
 		return ""
 	}
@@ -167,18 +569,18 @@ func extractBaseCommand(segment string) string {
 	if words[0] == "env" {
 		for i := 1; i < len(words); i++ {
 			if !strings.Contains(words[i], "=") {
-				return filepath.Base(words[i])
+				return words[i]
 			}
 		}
 		return ""
 	}
 
-	return filepath.Base(words[0])
+	return words[0]
 }
 
 // extractArgs returns everything after the base command.
 func extractArgs(segment string) []string {
-	words := strings.Fields(segment)
+	words := shellWords(segment)
 	if len(words) == 0 {
 		return nil
 	}
@@ -214,7 +616,45 @@ func extractArgs(segment string) []string {
 	return nil
 }
 
+// extractEnvAssignments returns the VAR=value assignments that prefix a
+// command, including those passed through 'env'.
+func extractEnvAssignments(segment string) map[string]string {
+	env := map[string]string{}
+	words := shellWords(segment)
+	for i, w := range words {
+		if i == 0 && w == "env" {
+			continue
+		}
+		eq := strings.Index(w, "=")
+		if eq <= 0 || strings.HasPrefix(w, "-") || strings.HasPrefix(w, "/") || strings.HasPrefix(w, ".") {
+			break
+		}
+		env[w[:eq]] = w[eq+1:]
+	}
+	return env
+}
+
+// envValue looks a variable up in the command's own assignments first, then
+// in the environment the hook runs in (inherited from Claude Code). A
+// command on a remote host doesn't see the local environment.
+func envValue(env map[string]string, key string) string {
+	if v, ok := env[key]; ok {
+		return v
+	}
+	if remoteShell {
+		return ""
+	}
+	dependOnEnv(key)
+	return os.Getenv(key)
+}
+
 func evaluateSegment(segment string, workDir string) (Verdict, string) {
+	return evaluateSegmentWithInput(segment, "", workDir)
+}
+
+// evaluateSegmentWithInput evaluates a simple command whose standard input
+// includes the given heredoc text (empty if none).
+func evaluateSegmentWithInput(segment, stdin, workDir string) (Verdict, string) {
 	segment = strings.TrimSpace(segment)
 	if segment == "" {
 		return VerdictAllow, ""
@@ -222,11 +662,42 @@ func evaluateSegment(segment string, workDir string) (Verdict, string) {
 
 	baseCmd := extractBaseCommand(segment)
 	if baseCmd == "" {
+		if words := shellWords(segment); len(words) > 0 && strings.Contains(words[0], "=") && !strings.HasPrefix(words[0], "-") {
+			return VerdictAllow, "variable assignment"
+		}
 		return VerdictUncertain, "could not extract command"
 	}
 
+	// Every segment, however deeply nested in shells, wrappers, functions,
+	// recipes or remote commands, is checked against the guard's own files
+	// and processes. As in EvaluateRules, only a deny overrides it
+	piped := stdin != "" || currentLine != nil && currentLine.piped
+	verdict, reason := evaluateSegmentRules(segment, baseCmd, stdin, piped, workDir)
+	if remoteShell {
+		verdict, reason = remoteSegmentVerdict(segment, baseCmd, verdict, reason)
+	}
+	if protectReason, hit := segmentTargetsGuard(segment, piped, workDir); hit && verdict != VerdictDeny {
+		return askOrDeny(CategorySelfProtection), "guard self-protection: " + protectReason
+	}
+	return verdict, reason
+}
+
+// evaluateSegmentRules applies the command rules to a simple command.
+func evaluateSegmentRules(segment, baseCmd, stdin string, piped bool, workDir string) (Verdict, string) {
 	args := extractArgs(segment)
 
+	if currentLine != nil {
+		if body, ok := currentLine.functions[baseCmd]; ok {
+			return evaluateFunctionCall(baseCmd, body, segment, stdin, args, workDir)
+		}
+	}
+	if baseCmd == "trap" {
+		return evaluateTrap(args, workDir)
+	}
+	if shellBuiltins[baseCmd] {
+		return VerdictAllow, "shell builtin: " + baseCmd
+	}
+
 	// Always ask
 	if isAlwaysAsk(baseCmd) {
 		return VerdictAsk, "dangerous command: " + baseCmd
@@ -242,7 +713,7 @@ func evaluateSegment(segment string, workDir string) (Verdict, string) {
 	case "git":
 		return evaluateGit(args)
 	case "kubectl":
-		return evaluateKubectl(args)
+		return evaluateKubectl(args, extractEnvAssignments(segment))
 	case "rm":
 		return evaluateRm(args, workDir)
 	case "chmod":
@@ -252,11 +723,32 @@ func evaluateSegment(segment string, workDir string) (Verdict, string) {
 	case "gh":
 		return evaluateGh(args)
 	case "gcloud":
-		return evaluateGcloud(args)
+		return evaluateGcloud(args, extractEnvAssignments(segment), workDir)
+	case "gsutil":
+		return evaluateGsutil(args, workDir)
 	case "bq":
-		return evaluateBq(args)
+		return evaluateBq(args, stdin, workDir)
+	case "psql":
+		return evaluatePsql(args, extractEnvAssignments(segment), stdin, workDir)
+	case "mysql", "mariadb":
+		return evaluateMysql(baseCmd, args, extractEnvAssignments(segment), stdin, workDir)
+	case "sqlite3", "sqlite":
+		return evaluateSqlite(baseCmd, args, stdin, workDir)
+	case "duckdb":
+		return evaluateDuckdb(args, stdin, workDir)
+	case "clickhouse-client":
+		return evaluateClickhouse(baseCmd, args, stdin, workDir)
+	case "clickhouse":
+		if len(args) > 0 && args[0] == "client" {
+			return evaluateClickhouse("clickhouse client", args[1:], stdin, workDir)
+		}
+		return VerdictUncertain, "clickhouse " + strings.Join(firstN(args, 1), " ")
+	case "mongosh", "mongo":
+		return evaluateMongosh(baseCmd, args, stdin, workDir)
+	case "redis-cli":
+		return evaluateRedisCli(args, stdin, workDir)
 	case "aws":
-		return evaluateAws(args)
+		return evaluateAws(args, extractEnvAssignments(segment), workDir)
 	case "sed":
 		return evaluateSed(args)
 	case "curl", "wget":
@@ -267,27 +759,62 @@ func evaluateSegment(segment string, workDir string) (Verdict, string) {
 	case "cp", "mv", "mkdir", "touch":
 		return evaluateFileCmd(baseCmd, args, workDir)
 	case "ssh":
-		return evaluateSSH(args)
+		return evaluateSSH(args, stdin, workDir)
 	case "scp":
-		return VerdictUncertain, "scp (remote file transfer)"
+		return evaluateSCP(args, workDir)
+	case "rsync":
+		return evaluateRsync(args, workDir)
 	case "docker", "podman":
-		return evaluateDocker(args)
-	case "docker-compose":
-		return evaluateDockerCompose(args)
+		return evaluateDocker(baseCmd, args, extractEnvAssignments(segment), workDir)
+	case "docker-compose", "podman-compose":
+		return evaluateDockerCompose(baseCmd, args, extractEnvAssignments(segment), workDir)
 	case "npm", "yarn", "pnpm":
-		return evaluateNodePkgMgr(baseCmd, args)
+		return evaluateNodePkgMgr(baseCmd, args, extractEnvAssignments(segment), workDir)
 	case "npx":
 		return VerdictUncertain, "npx downloads and runs code"
 	case "pip", "pip3":
-		return evaluatePip(baseCmd, args)
-	case "python", "python3", "node", "deno", "bun", "ruby", "swift":
-		return evaluateRuntime(baseCmd, args)
+		return evaluatePip(baseCmd, args, extractEnvAssignments(segment))
+	case "bun":
+		if len(args) > 1 && args[0] == "run" && hasPackageScript(workDir, args[1]) {
+			return evaluatePackageScript("bun run", args[1], workDir)
+		}
+		if len(args) > 0 && (args[0] == "install" || args[0] == "i" || args[0] == "add") {
+			return evaluateNodeInstall(baseCmd, args[0], args[1:], extractEnvAssignments(segment))
+		}
+		return evaluateRuntime(baseCmd, args, stdin, extractEnvAssignments(segment), workDir)
+	case "python", "python3", "node", "deno", "ruby", "swift":
+		return evaluateRuntime(baseCmd, args, stdin, extractEnvAssignments(segment), workDir)
+	case "bash", "sh", "zsh", "dash", "ksh":
+		return evaluateShell(baseCmd, args, stdin, workDir)
+	case "source", ".":
+		if len(args) == 0 {
+			return VerdictUncertain, baseCmd + " (no file)"
+		}
+		return evaluateScriptFile(baseCmd, args[0], workDir)
+	case "exec", "command", "builtin":
+		if words, _ := splitRedirects(args); len(words) == 0 {
+			return VerdictAllow, baseCmd + " (redirections only)"
+		}
+		if baseCmd == "command" && (args[0] == "-v" || args[0] == "-V") {
+			return VerdictAllow, "command lookup"
+		}
+		if strings.HasPrefix(args[0], "-") {
+			return VerdictUncertain, baseCmd + " " + args[0]
+		}
+		// These run the command itself, never a function of the same name
+		if currentLine != nil {
+			if body, ok := currentLine.functions[args[0]]; ok {
+				delete(currentLine.functions, args[0])
+				defer func() { currentLine.functions[args[0]] = body }()
+			}
+		}
+		return evaluateCommand(strings.Join(args, " "), workDir)
 	case "go":
 		return evaluateGo(args)
 	case "cargo":
 		return evaluateCargo(args)
 	case "helm":
-		return evaluateHelm(args)
+		return evaluateHelm(args, extractEnvAssignments(segment))
 	case "find":
 		return evaluateFind(args)
 	case "tee":
@@ -307,6 +834,43 @@ func evaluateSegment(segment string, workDir string) (Verdict, string) {
 		return evaluateTimeout(args, workDir)
 	case "brew", "apt", "apt-get", "yum", "pacman":
 		return evaluatePackageManager(baseCmd, args)
+	case "make", "gmake":
+		return evaluateMake(args, workDir)
+	case "just":
+		return evaluateJust(args, workDir)
+	case "task":
+		return evaluateTaskfile(args, workDir)
+	case "mise":
+		return evaluateMise(args, workDir)
+	case "cmake":
+		return evaluateCmake(args, workDir)
+	case "bazel", "bazelisk":
+		return evaluateBazel(baseCmd, args)
+	case "terraform", "tofu":
+		return evaluateTerraform(baseCmd, args, extractEnvAssignments(segment), workDir)
+	case "pulumi":
+		return evaluatePulumi(args)
+	case "cdk", "cdktf":
+		return evaluateCdk(baseCmd, args)
+	case "ansible-playbook":
+		return evaluateAnsiblePlaybook(args)
+	case "serverless", "sls":
+		return evaluateServerless(baseCmd, args)
+	case guardBinaryName:
+		if isGuardStatusQuery(args, piped) {
+			return VerdictAllow, "guard status query"
+		}
+		return askOrDeny(CategorySelfProtection), "guard self-protection: controls the guard: " + segment
+	}
+
+	// Tools installed by the project, as run from package scripts
+	if localPackageBinary(baseCmd, workDir) {
+		return VerdictAllow, "project dependency: " + baseCmd
+	}
+
+	// A command given by path is a script or program from that path
+	if word := commandWord(segment); strings.Contains(word, "/") {
+		return evaluateScriptFile("", word, workDir)
 	}
 
 	// Unknown command
@@ -342,10 +906,8 @@ var alwaysSafeCommands = map[string]bool{
 	"open": true, "pbcopy": true, "pbpaste": true,
 	// Terminal
 	"tmux": true, "screen": true,
-	// Build tools (project-defined targets)
-	"make": true, "cmake": true, "bazel": true,
 	// Dev utilities
-	"sleep": true, "seq": true,
+	"sleep": true, "seq": true, "mktemp": true,
 	"pre-commit": true, "prettier": true, "eslint": true, "golangci-lint": true,
 	"tsc": true, "jest": true, "pytest": true, "phpunit": true,
 	// K8s tools
@@ -374,131 +936,6 @@ func isAlwaysAsk(cmd string) bool {
 
 // --- Special command handlers ---
 
-func evaluateGit(args []string) (Verdict, string) {
-	if len(args) == 0 {
-		return VerdictAllow, "git (no subcommand)"
-	}
-
-	subCmd := args[0]
-
-	safeSubcmds := map[string]bool{
-		"status": true, "diff": true, "log": true, "show": true,
-		"branch": true, "fetch": true, "stash": true, "add": true,
-		"commit": true, "pull": true, "clone": true, "checkout": true,
-		"rebase": true, "merge": true, "cherry-pick": true, "tag": true,
-		"remote": true, "reflog": true, "rev-parse": true, "ls-files": true,
-		"config": true, "init": true, "worktree": true, "bisect": true,
-		"blame": true, "shortlog": true, "describe": true, "clean": true,
-		"reset": true, "switch": true, "restore": true,
-	}
-
-	if safeSubcmds[subCmd] {
-		return VerdictAllow, "git " + subCmd
-	}
-
-	if subCmd == "push" {
-		return evaluateGitPush(args[1:])
-	}
-
-	return VerdictUncertain, "git " + subCmd
-}
-
-func evaluateGitPush(args []string) (Verdict, string) {
-	isForce := false
-	isDelete := false
-	var positionalArgs []string
-
-	for _, arg := range args {
-		switch {
-		case arg == "--force" || arg == "-f" || arg == "--force-with-lease":
-			isForce = true
-		case arg == "--delete" || arg == "-d":
-			isDelete = true
-		case strings.HasPrefix(arg, "-"):
-			// other flags
-		default:
-			positionalArgs = append(positionalArgs, arg)
-		}
-	}
-
-	// No force, no delete → always safe
-	if !isForce && !isDelete {
-		return VerdictAllow, "git push (no force)"
-	}
-
-	// Check if any positional arg refers to main/master
-	for _, arg := range positionalArgs {
-		lower := strings.ToLower(arg)
-		if lower == "main" || lower == "master" ||
-			strings.HasSuffix(lower, ":main") || strings.HasSuffix(lower, ":master") {
-			if isForce {
-				return VerdictAsk, "git push --force to " + arg
-			}
-			if isDelete {
-				return VerdictAsk, "git push --delete " + arg
-			}
-		}
-	}
-
-	// Force/delete with explicit non-main branch
-	if len(positionalArgs) >= 2 {
-		return VerdictAllow, "git push to non-main branch"
-	}
-
-	// Force push without explicit branch → uncertain (could be on main)
-	if isForce {
-		return VerdictUncertain, "git push --force without explicit branch"
-	}
-
-	return VerdictUncertain, "git push --delete without explicit target"
-}
-
-func evaluateKubectl(args []string) (Verdict, string) {
-	if len(args) == 0 {
-		return VerdictAllow, "kubectl (no subcommand)"
-	}
-
-	subCmd := args[0]
-
-	safeSubcmds := map[string]bool{
-		"get": true, "describe": true, "logs": true, "top": true,
-		"explain": true, "api-resources": true, "api-versions": true,
-		"config": true, "cluster-info": true, "version": true,
-		"auth": true, "port-forward": true,
-	}
-
-	if safeSubcmds[subCmd] {
-		return VerdictAllow, "kubectl " + subCmd
-	}
-
-	if subCmd == "delete" {
-		return evaluateKubectlDelete(args[1:])
-	}
-
-	writeSubcmds := map[string]bool{
-		"apply": true, "create": true, "replace": true, "patch": true,
-		"edit": true, "scale": true, "rollout": true, "exec": true,
-		"cp": true, "run": true, "expose": true,
-		"set": true, "label": true, "annotate": true, "taint": true,
-		"cordon": true, "uncordon": true, "drain": true,
-	}
-
-	if writeSubcmds[subCmd] {
-		return VerdictAsk, "kubectl " + subCmd
-	}
-
-	return VerdictUncertain, "kubectl " + subCmd
-}
-
-func evaluateKubectlDelete(args []string) (Verdict, string) {
-	for _, arg := range args {
-		if arg == "pod" || arg == "pods" || arg == "po" {
-			return VerdictAllow, "kubectl delete pod"
-		}
-	}
-	return VerdictAsk, "kubectl delete (non-pod resource)"
-}
-
 func evaluateRm(args []string, workDir string) (Verdict, string) {
 	hasRecursive := false
 	var targets []string
@@ -518,9 +955,9 @@ func evaluateRm(args []string, workDir string) (Verdict, string) {
 	}
 
 	for _, target := range targets {
-		absTarget := target
-		if !filepath.IsAbs(target) {
-			absTarget = filepath.Join(workDir, target)
+		absTarget := expandHome(target)
+		if !filepath.IsAbs(absTarget) {
+			absTarget = filepath.Join(workDir, absTarget)
 		}
 		absTarget = filepath.Clean(absTarget)
 
@@ -532,7 +969,7 @@ func evaluateRm(args []string, workDir string) (Verdict, string) {
 		}
 		for _, dp := range dangerousPaths {
 			if absTarget == dp {
-				return VerdictAsk, "rm targeting dangerous path: " + target
+				return askOrDeny(CategoryDangerousDelete), "rm targeting dangerous path: " + target
 			}
 		}
 
@@ -542,7 +979,7 @@ func evaluateRm(args []string, workDir string) (Verdict, string) {
 		}
 
 		// Recursive rm outside project
-		if hasRecursive && workDir != "" && !isWithinDir(absTarget, workDir) {
+		if hasRecursive && !isWithinDir(absTarget, workDir) {
 			return VerdictAsk, "rm -r outside project: " + target
 		}
 	}
@@ -631,65 +1068,6 @@ func evaluateGhRepo(args []string) (Verdict, string) {
 	return VerdictUncertain, "gh repo " + args[0]
 }
 
-func evaluateGcloud(args []string) (Verdict, string) {
-	if len(args) == 0 {
-		return VerdictUncertain, "gcloud (no args)"
-	}
-
-	// Check for config subcommand
-	if args[0] == "config" {
-		if len(args) > 1 && (args[1] == "list" || args[1] == "get-value") {
-			return VerdictAllow, "gcloud config read"
-		}
-		return VerdictUncertain, "gcloud config"
-	}
-
-	// Scan args for known safe/dangerous verbs
-	for _, arg := range args {
-		switch arg {
-		case "list", "describe", "info", "get-iam-policy":
-			return VerdictAllow, "gcloud read operation"
-		case "create", "delete", "update", "deploy", "ssh", "set-iam-policy", "add-iam-policy-binding", "remove-iam-policy-binding":
-			return VerdictAsk, "gcloud write operation: " + arg
-		}
-	}
-
-	return VerdictUncertain, "gcloud command"
-}
-
-func evaluateBq(args []string) (Verdict, string) {
-	for _, arg := range args {
-		switch arg {
-		case "ls", "show", "head":
-			return VerdictAllow, "bq read operation"
-		case "query":
-			fullCmd := strings.ToUpper(strings.Join(args, " "))
-			writeKeywords := []string{"INSERT", "UPDATE", "DELETE", "DROP", "CREATE", "ALTER", "TRUNCATE"}
-			for _, kw := range writeKeywords {
-				if strings.Contains(fullCmd, kw) {
-					return VerdictAsk, "bq write query: " + kw
-				}
-			}
-			return VerdictAllow, "bq query (SELECT)"
-		}
-	}
-	return VerdictUncertain, "bq command"
-}
-
-func evaluateAws(args []string) (Verdict, string) {
-	for _, arg := range args {
-		if strings.HasPrefix(arg, "describe-") || strings.HasPrefix(arg, "list-") || strings.HasPrefix(arg, "get-") {
-			return VerdictAllow, "aws read operation"
-		}
-		if strings.HasPrefix(arg, "create-") || strings.HasPrefix(arg, "delete-") ||
-			strings.HasPrefix(arg, "update-") || strings.HasPrefix(arg, "put-") ||
-			strings.HasPrefix(arg, "run-") {
-			return VerdictAsk, "aws write operation: " + arg
-		}
-	}
-	return VerdictUncertain, "aws command"
-}
-
 func evaluateSed(args []string) (Verdict, string) {
 	for _, arg := range args {
 		if arg == "-i" || arg == "--in-place" || (strings.HasPrefix(arg, "-") && strings.Contains(arg, "i") && !strings.HasPrefix(arg, "--")) {
@@ -724,139 +1102,398 @@ func evaluateFileOp(toolName string, toolInput json.RawMessage, workDir string)
 	}
 
 	filePath = filepath.Clean(filePath)
+	verdict, reason := evaluateFilePath(toolName, filePath, workDir)
+
+	// A cell that runs commands can't relax the path's ASK or DENY
+	if toolName == "NotebookEdit" {
+		cellVerdict, cellReason := evaluateNotebookSource(input["new_source"], workDir)
+		if cellVerdict == VerdictDeny || (cellVerdict != VerdictAllow && verdict != VerdictAsk && verdict != VerdictDeny) {
+			return cellVerdict, cellReason
+		}
+	}
+	return verdict, reason
+}
 
+func evaluateFilePath(toolName, filePath, workDir string) (Verdict, string) {
 	if workDir != "" && isWithinDir(filePath, workDir) {
 		return VerdictAllow, toolName + " within project"
 	}
 
 	if isSystemPath(filePath) {
-		return VerdictAsk, toolName + " targeting system path: " + filePath
+		return askOrDeny(CategorySystemWrite), toolName + " targeting system path: " + filePath
 	}
 
-	return VerdictUncertain, toolName + " outside project: " + filePath
+	return uncertainOnIntent(toolName + " outside project: " + filePath)
 }
 
-func evaluateFileCmd(cmd string, args []string, workDir string) (Verdict, string) {
-	for _, arg := range args {
-		if strings.HasPrefix(arg, "-") {
-			continue
+// evaluateNotebookSource inspects a notebook cell for code that runs shell
+// commands once executed: ! lines, %%bash/%%sh/%%!/%%script cells,
+// %system/%sx/%sc, %run and %alias magics and get_ipython().system calls.
+// Extracted commands go through evaluateCommand and the worst verdict is
+// returned; the Python code around them is checked for process calls only.
+// Magics not known to be safe are uncertain.
+func evaluateNotebookSource(raw json.RawMessage, workDir string) (Verdict, string) {
+	if raw == nil {
+		return VerdictAllow, ""
+	}
+	var source string
+	if err := json.Unmarshal(raw, &source); err != nil {
+		return VerdictUncertain, "failed to parse new_source"
+	}
+
+	commands, code, uncertain := extractNotebookCommands(source)
+	if uncertain != "" {
+		return VerdictUncertain, "NotebookEdit cell uses " + uncertain
+	}
+
+	worstVerdict := VerdictAllow
+	var worstReason string
+	for _, cmd := range commands {
+		verdict, reason := evaluateCommand(cmd, workDir)
+		if verdict > worstVerdict {
+			worstVerdict = verdict
+			worstReason = "NotebookEdit cell runs shell: " + reason
 		}
-		absPath := arg
-		if !filepath.IsAbs(arg) {
-			absPath = filepath.Join(workDir, arg)
+	}
+	if worstVerdict < VerdictUncertain {
+		if findings := processFindings(analyzeInlineCode(langPython, code, workDir)); len(findings) > 0 {
+			return VerdictUncertain, "NotebookEdit cell code: " + strings.Join(findings, ", ")
 		}
-		if isSystemPath(absPath) {
-			return VerdictAsk, cmd + " targeting system path: " + arg
+	}
+	return worstVerdict, worstReason
+}
+
+// processFindingNotes mark the inline code findings about running other
+// programs, the only ones that matter in a notebook cell: the rest of the
+// code runs in the kernel like any project code.
+var processFindingNotes = []string{"runs commands", "runs processes", "runs programs", "opens programs", "forks"}
+
+// processFindings keeps the findings about running other programs.
+func processFindings(findings []string) []string {
+	var out []string
+	for _, finding := range findings {
+		for _, note := range processFindingNotes {
+			if strings.Contains(finding, note) {
+				out = append(out, finding)
+				break
+			}
 		}
 	}
-	return VerdictAllow, cmd + " (safe)"
+	return out
 }
 
-// --- Path helpers ---
+// extractNotebookCommands returns the shell commands contained in a cell
+// and the Python code around them. Commands after a %cd magic are prefixed
+// with the cd, so they're judged from the directory they'll run in. The
+// third result names a construct that runs commands in a way that cannot be
+// extracted (empty if none).
+func extractNotebookCommands(source string) ([]string, string, string) {
+	lines := strings.Split(source, "\n")
+
+	// Cell magics apply to the whole cell
+	first := strings.TrimSpace(lines[0])
+	if strings.HasPrefix(first, "%%") {
+		fields := strings.Fields(first)
+		switch {
+		case fields[0] == "%%bash", fields[0] == "%%sh", fields[0] == "%%zsh", fields[0] == "%%system",
+			fields[0] == "%%sx", fields[0] == "%%!", fields[0] == "%%script":
+			if fields[0] == "%%script" && (len(fields) < 2 || !isShellInterpreter(fields[1])) {
+				return nil, "", fields[0]
+			}
+			return cellCommandLines(lines[1:]), "", ""
+		case notebookTextCellMagics[fields[0]]:
+			return nil, "", ""
+		case notebookCodeCellMagics[fields[0]]:
+			// The rest of the first line and the cell are Python
+			lines[0] = strings.TrimSpace(strings.TrimPrefix(first, fields[0]))
+		default:
+			return nil, "", fields[0]
+		}
+	}
 
-func isWithinDir(path, dir string) bool {
-	if dir == "" {
-		return false
+	var commands, code []string
+	cdPrefix := ""
+	add := func(command string) {
+		commands = append(commands, cdPrefix+command)
 	}
-	path = filepath.Clean(path)
-	dir = filepath.Clean(dir)
-	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+	aliases := map[string]string{}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		// Assignment forms: files = %sx ls, out = %sc ls
+		if m := notebookMagicAssignment.FindStringSubmatch(trimmed); m != nil {
+			trimmed = m[1]
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "!"):
+			add(strings.TrimPrefix(trimmed, "!"))
+		case isNotebookCd(trimmed):
+			// %cd changes the kernel's directory for every later line
+			args := strings.Fields(trimmed)[1:]
+			if len(args) > 0 && args[0] == "-q" {
+				args = args[1:]
+			}
+			if len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+				return nil, "", "%cd " + args[0]
+			}
+			cdPrefix += strings.TrimSpace("cd "+strings.Join(args, " ")) + " && "
+		case strings.HasPrefix(trimmed, "%popd"):
+			return nil, "", "%popd"
+		case strings.HasPrefix(trimmed, "%"):
+			command, rest, uncertain := notebookLineMagic(trimmed, aliases)
+			switch {
+			case uncertain != "":
+				return nil, "", uncertain
+			case command != "":
+				add(command)
+			case rest != "":
+				code = append(code, rest)
+			}
+		default:
+			// Assignment form: files = !ls
+			if i := strings.Index(trimmed, "= !"); i >= 0 {
+				add(trimmed[i+3:])
+				continue
+			}
+			calls, uncertain := ipythonShellCalls(trimmed)
+			if uncertain != "" {
+				return nil, "", uncertain
+			}
+			for _, call := range calls {
+				add(call)
+			}
+			code = append(code, line)
+		}
+	}
+	return commands, strings.Join(code, "\n"), ""
 }
 
-// --- New command handlers ---
+// notebookMagicAssignment matches a line magic whose result is assigned to
+// variables.
+var notebookMagicAssignment = regexp.MustCompile(`^[A-Za-z_][\w.]*(?:\s*,\s*[A-Za-z_][\w.]*)*\s*=\s*(%[^%=].*)$`)
 
-func evaluateSSH(args []string) (Verdict, string) {
-	// Flags that consume the next argument
-	flagsWithValue := map[string]bool{
-		"-b": true, "-c": true, "-D": true, "-E": true, "-e": true,
-		"-F": true, "-I": true, "-i": true, "-J": true, "-L": true,
-		"-l": true, "-m": true, "-O": true, "-o": true, "-p": true,
-		"-Q": true, "-R": true, "-S": true, "-W": true, "-w": true,
-	}
+// notebookTextCellMagics render their cell as text instead of running it.
+var notebookTextCellMagics = map[string]bool{
+	"%%markdown": true, "%%latex": true, "%%svg": true,
+}
 
-	positionalCount := 0
-	skipNext := false
-	for _, arg := range args {
-		if skipNext {
-			skipNext = false
-			continue
+// notebookCodeCellMagics run their cell as Python, timed or captured.
+var notebookCodeCellMagics = map[string]bool{
+	"%%time": true, "%%timeit": true, "%%capture": true, "%%prun": true,
+}
+
+// notebookCodeLineMagics run the rest of their line as Python.
+var notebookCodeLineMagics = map[string]bool{
+	"%time": true, "%timeit": true, "%prun": true, "%capture": true,
+}
+
+// notebookSafeLineMagics only configure the kernel or show information.
+var notebookSafeLineMagics = map[string]bool{
+	"%matplotlib": true, "%autoreload": true, "%pwd": true, "%who": true, "%whos": true,
+	"%who_ls": true, "%lsmagic": true, "%magic": true, "%pinfo": true, "%pinfo2": true,
+	"%pdoc": true, "%pdef": true, "%psource": true, "%precision": true, "%pprint": true,
+	"%xmode": true, "%history": true, "%hist": true, "%dirs": true, "%dhist": true,
+	"%quickref": true, "%page": true, "%config": true, "%env": true, "%reset": true,
+	"%reset_selective": true, "%colors": true, "%automagic": true, "%doctest_mode": true,
+	"%alias_magic": true, "%unalias": true,
+}
+
+// notebookLineMagic returns the shell command a line magic runs, or the
+// Python code it runs, or names a magic that isn't known to be safe.
+// %alias definitions are remembered in aliases and their commands checked
+// as the alias is defined and each time it is used.
+func notebookLineMagic(line string, aliases map[string]string) (command, code, uncertain string) {
+	name, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	words := strings.Fields(rest)
+
+	switch {
+	case name == "%system" || name == "%sx":
+		return rest, "", ""
+	case name == "%sc":
+		// %sc [-l] var=command
+		for len(words) > 0 && strings.HasPrefix(words[0], "-") {
+			words = words[1:]
 		}
-		if flagsWithValue[arg] {
-			skipNext = true
-			continue
+		command := strings.Join(words, " ")
+		if _, after, found := strings.Cut(command, "="); found && len(words) > 0 && strings.Contains(words[0], "=") {
+			command = after
 		}
-		if strings.HasPrefix(arg, "-") {
-			continue
+		return command, "", ""
+	case name == "%run":
+		// %run [options] file args, or %run -m module args
+		for len(words) > 0 && strings.HasPrefix(words[0], "-") && words[0] != "-m" {
+			words = words[1:]
 		}
-		positionalCount++
-		if positionalCount > 1 {
-			return VerdictUncertain, "ssh with remote command"
+		if len(words) == 0 {
+			return "", "", "%run"
 		}
-	}
-	return VerdictAllow, "ssh (interactive)"
+		return "python " + strings.Join(words, " "), "", ""
+	case name == "%pip" || name == "%conda":
+		return strings.TrimPrefix(name, "%") + " " + rest, "", ""
+	case name == "%alias":
+		if len(words) < 2 {
+			return "", "", ""
+		}
+		body := strings.NewReplacer("%s", "", "%l", "").Replace(strings.Join(words[1:], " "))
+		aliases[words[0]] = body
+		return body, "", ""
+	case aliases[strings.TrimPrefix(name, "%")] != "":
+		return aliases[strings.TrimPrefix(name, "%")] + " " + rest, "", ""
+	case notebookCodeLineMagics[name]:
+		return "", rest, ""
+	case notebookSafeLineMagics[name]:
+		return "", "", ""
+	}
+	return "", "", name
 }
 
-func evaluateDocker(args []string) (Verdict, string) {
-	if len(args) == 0 {
-		return VerdictAllow, "docker (no subcommand)"
+// isNotebookCd reports whether a line is a %cd or %pushd magic, or the cd
+// automagic (not a Python assignment to a variable named cd).
+func isNotebookCd(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
 	}
-
-	subCmd := args[0]
-
-	// docker compose is equivalent to docker-compose
-	if subCmd == "compose" {
-		return evaluateDockerCompose(args[1:])
+	switch fields[0] {
+	case "%cd", "%pushd":
+		return true
+	case "cd":
+		return len(fields) == 1 || !strings.ContainsAny(fields[1][:1], "=+-*/%&|^<>([")
 	}
+	return false
+}
 
-	safeSubcmds := map[string]bool{
-		"ps": true, "logs": true, "images": true, "inspect": true,
-		"stats": true, "top": true, "history": true, "info": true,
-		"version": true, "build": true, "pull": true, "tag": true,
-		"login": true, "logout": true, "search": true,
-		"events": true, "diff": true, "port": true, "wait": true,
-		"cp": true, "create": true, "start": true,
+// ipythonShellCalls returns the commands run by get_ipython().system(...) and
+// .getoutput(...) on a line. Any other use of the IPython shell, or a
+// command that isn't a string literal, is returned as uncertain.
+func ipythonShellCalls(line string) ([]string, string) {
+	if !strings.Contains(line, "get_ipython") {
+		return nil, ""
+	}
+	tokens := codeTokens(langPython, line)
+	var commands []string
+	for i, t := range tokens {
+		if t.kind != tokName || t.text != "get_ipython" {
+			continue
+		}
+		if i+4 >= len(tokens) || tokens[i+1].text != "(" || tokens[i+2].text != ")" || tokens[i+4].text != "(" {
+			return nil, "get_ipython()"
+		}
+		method := tokens[i+3].text
+		if method != ".system" && method != ".getoutput" {
+			return nil, "get_ipython()" + method
+		}
+		args := callArgs(tokens, i+4)
+		if len(args) != 1 {
+			return nil, "get_ipython()" + method
+		}
+		command, ok := literalArg(args[0])
+		if !ok {
+			return nil, "get_ipython()" + method + " with a computed command"
+		}
+		commands = append(commands, command)
 	}
+	return commands, ""
+}
 
-	if safeSubcmds[subCmd] {
-		return VerdictAllow, "docker " + subCmd
+// cellCommandLines splits a shell cell into its lines, joining lines that
+// end in a backslash.
+func cellCommandLines(lines []string) []string {
+	var commands []string
+	current := ""
+	for _, line := range lines {
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		if command := strings.TrimSpace(current + line); command != "" {
+			commands = append(commands, command)
+		}
+		current = ""
 	}
-
-	return VerdictUncertain, "docker " + subCmd
+	if command := strings.TrimSpace(current); command != "" {
+		commands = append(commands, command)
+	}
+	return commands
 }
 
-func evaluateDockerCompose(args []string) (Verdict, string) {
-	if len(args) == 0 {
-		return VerdictAllow, "docker-compose (no subcommand)"
+func isShellInterpreter(name string) bool {
+	switch filepath.Base(name) {
+	case "bash", "sh", "zsh":
+		return true
 	}
+	return false
+}
 
-	subCmd := args[0]
-
-	safeSubcmds := map[string]bool{
-		"up": true, "build": true, "pull": true, "start": true,
-		"ps": true, "logs": true, "config": true, "images": true,
-		"top": true, "version": true, "ls": true, "port": true,
-		"create": true, "events": true,
+func evaluateFileCmd(cmd string, args []string, workDir string) (Verdict, string) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		absPath := arg
+		if !filepath.IsAbs(arg) {
+			absPath = filepath.Join(workDir, arg)
+		}
+		if isSystemPath(absPath) {
+			return askOrDeny(CategorySystemWrite), cmd + " targeting system path: " + arg
+		}
 	}
+	return VerdictAllow, cmd + " (safe)"
+}
 
-	if safeSubcmds[subCmd] {
-		return VerdictAllow, "docker-compose " + subCmd
-	}
+// --- Path helpers ---
 
-	return VerdictUncertain, "docker-compose " + subCmd
+func isWithinDir(path, dir string) bool {
+	if dir == "" {
+		return false
+	}
+	path = filepath.Clean(path)
+	dir = filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
 }
 
-func evaluateNodePkgMgr(cmd string, args []string) (Verdict, string) {
+// --- New command handlers ---
+
+func evaluateNodePkgMgr(cmd string, args []string, env map[string]string, workDir string) (Verdict, string) {
+	// Leading options: npm --prefix dir, yarn --cwd dir, pnpm -C dir
+	dir := workDir
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		if flag == "--prefix" || flag == "--cwd" || flag == "-C" || flag == "--dir" {
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			dir = resolvePath(value, workDir)
+		}
+	}
+	args = args[i:]
 	if len(args) == 0 {
 		return VerdictAllow, cmd + " (no subcommand)"
 	}
 
 	subCmd := args[0]
 
+	switch subCmd {
+	case "run", "run-script", "rum", "urn":
+		pos := positionalArgs(args[1:], nil)
+		if len(pos) == 0 {
+			return VerdictAllow, cmd + " run (lists scripts)"
+		}
+		return evaluatePackageScript(cmd+" run", pos[0], dir)
+	case "test", "t", "tst", "start", "stop", "restart":
+		script := subCmd
+		if subCmd == "t" || subCmd == "tst" {
+			script = "test"
+		}
+		return evaluatePackageScript(cmd, script, dir)
+	case "install", "i", "add":
+		return evaluateNodeInstall(cmd, subCmd, args[1:], env)
+	}
+
 	safeSubcmds := map[string]bool{
-		"install": true, "i": true, "ci": true, "add": true,
-		"remove": true, "uninstall": true, "rm": true,
-		"test": true, "t": true, "run": true, "start": true,
-		"build": true, "dev": true, "lint": true, "format": true,
+		"ci": true, "remove": true, "uninstall": true, "rm": true,
 		"update": true, "upgrade": true, "outdated": true,
 		"list": true, "ls": true, "info": true, "view": true,
 		"init": true, "create": true, "exec": true,
@@ -874,19 +1511,26 @@ func evaluateNodePkgMgr(cmd string, args []string) (Verdict, string) {
 		return VerdictAsk, cmd + " publish"
 	}
 
+	// yarn and pnpm run scripts by name
+	if cmd != "npm" && hasPackageScript(dir, subCmd) {
+		return evaluatePackageScript(cmd, subCmd, dir)
+	}
+
 	return VerdictUncertain, cmd + " " + subCmd
 }
 
-func evaluatePip(cmd string, args []string) (Verdict, string) {
+func evaluatePip(cmd string, args []string, env map[string]string) (Verdict, string) {
 	if len(args) == 0 {
 		return VerdictAllow, cmd + " (no subcommand)"
 	}
 
 	subCmd := args[0]
+	if subCmd == "install" {
+		return evaluatePipInstall(cmd, args[1:], env)
+	}
 
 	safeSubcmds := map[string]bool{
-		"install": true, "uninstall": true,
-		"list": true, "show": true, "freeze": true,
+		"uninstall": true, "list": true, "show": true, "freeze": true,
 		"check": true, "config": true, "cache": true,
 		"debug": true, "inspect": true, "download": true,
 		"wheel": true, "hash": true, "search": true,
@@ -900,21 +1544,6 @@ func evaluatePip(cmd string, args []string) (Verdict, string) {
 	return VerdictUncertain, cmd + " " + subCmd
 }
 
-func evaluateRuntime(cmd string, args []string) (Verdict, string) {
-	if len(args) == 0 {
-		return VerdictAllow, cmd + " (REPL)"
-	}
-
-	for _, arg := range args {
-		switch arg {
-		case "-c", "-e", "--eval":
-			return VerdictUncertain, cmd + " with inline code"
-		}
-	}
-
-	return VerdictAllow, cmd + " (script)"
-}
-
 func evaluateGo(args []string) (Verdict, string) {
 	if len(args) == 0 {
 		return VerdictAllow, "go (no subcommand)"
@@ -943,12 +1572,15 @@ func evaluateCargo(args []string) (Verdict, string) {
 	}
 
 	subCmd := args[0]
+	if subCmd == "install" || subCmd == "add" {
+		return evaluateCargoInstall(subCmd, args[1:])
+	}
 
 	safeSubcmds := map[string]bool{
 		"build": true, "test": true, "check": true, "clippy": true,
 		"fmt": true, "doc": true, "clean": true, "update": true,
 		"bench": true, "run": true, "new": true, "init": true,
-		"add": true, "remove": true, "install": true, "search": true,
+		"remove": true, "search": true,
 		"tree": true, "vendor": true, "fix": true, "fetch": true,
 		"metadata": true, "verify-project": true,
 	}
@@ -964,37 +1596,6 @@ func evaluateCargo(args []string) (Verdict, string) {
 	return VerdictUncertain, "cargo " + subCmd
 }
 
-func evaluateHelm(args []string) (Verdict, string) {
-	if len(args) == 0 {
-		return VerdictAllow, "helm (no subcommand)"
-	}
-
-	subCmd := args[0]
-
-	safeSubcmds := map[string]bool{
-		"list": true, "ls": true, "get": true, "status": true,
-		"show": true, "template": true, "lint": true, "version": true,
-		"repo": true, "search": true, "history": true, "env": true,
-		"dependency": true, "plugin": true, "verify": true,
-		"pull": true, "package": true, "create": true,
-	}
-
-	if safeSubcmds[subCmd] {
-		return VerdictAllow, "helm " + subCmd
-	}
-
-	askSubcmds := map[string]bool{
-		"install": true, "upgrade": true, "uninstall": true,
-		"delete": true, "rollback": true, "test": true,
-	}
-
-	if askSubcmds[subCmd] {
-		return VerdictAsk, "helm " + subCmd
-	}
-
-	return VerdictUncertain, "helm " + subCmd
-}
-
 func evaluateFind(args []string) (Verdict, string) {
 	for _, arg := range args {
 		switch arg {
@@ -1017,9 +1618,9 @@ func evaluateTee(args []string, workDir string) (Verdict, string) {
 		absPath = filepath.Clean(absPath)
 
 		if isSystemPath(absPath) {
-			return VerdictAsk, "tee to system path: " + arg
+			return askOrDeny(CategorySystemWrite), "tee to system path: " + arg
 		}
-		if workDir != "" && !isWithinDir(absPath, workDir) {
+		if !isWithinDir(absPath, workDir) {
 			return VerdictUncertain, "tee outside project: " + arg
 		}
 	}
@@ -1048,10 +1649,12 @@ func evaluatePackageManager(cmd string, args []string) (Verdict, string) {
 	}
 
 	subCmd := args[0]
+	if subCmd == "install" || subCmd == "add" || subCmd == "reinstall" {
+		return evaluateSystemInstall(cmd, subCmd, args[1:])
+	}
 
 	safeSubcmds := map[string]bool{
-		"install": true, "add": true, "update": true,
-		"upgrade": true, "search": true, "info": true,
+		"update": true, "upgrade": true, "search": true, "info": true,
 		"show": true, "list": true, "outdated": true,
 		"deps": true, "leaves": true, "uses": true,
 		"doctor": true, "cleanup": true, "autoremove": true,
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- AWS CLI handler ---
+//
+// Commands are parsed as `aws [global options] <service> <operation> [args]`.
+// Services with well-known sharp edges (s3, s3api, sts, iam, ssm,
+// secretsmanager, lambda, kms) have their own rules, and operations that
+// print credentials ask; everything else is classified by the operation's
+// verb prefix. Profiles listed in the policy's aws_ask_profiles always ask.
+
+// awsGlobalValueFlags are global options that take a value.
+var awsGlobalValueFlags = map[string]bool{
+	"--profile": true, "--region": true, "--output": true, "--query": true,
+	"--endpoint-url": true, "--cli-read-timeout": true, "--cli-connect-timeout": true,
+	"--color": true, "--ca-bundle": true, "--cli-binary-format": true,
+}
+
+// awsReadPrefixes and awsWritePrefixes classify operations by verb.
+var awsReadPrefixes = []string{
+	"describe-", "list-", "get-", "head-", "search-", "lookup-", "batch-get-",
+	"test-", "validate-", "estimate-", "preview-", "filter-", "simulate-",
+	"select-",
+}
+
+var awsWritePrefixes = []string{
+	"create-", "delete-", "update-", "put-", "run-", "start-", "stop-", "terminate-",
+	"reboot-", "modify-", "attach-", "detach-", "associate-", "disassociate-",
+	"enable-", "disable-", "register-", "deregister-", "tag-", "untag-", "send-",
+	"add-", "remove-", "set-", "reset-", "revoke-", "authorize-", "restore-",
+	"import-", "copy-", "cancel-", "execute-", "upload-", "rotate-", "batch-write-",
+	"batch-delete-", "purge-", "publish", "invoke", "deploy", "replace-", "release-",
+	"allocate-", "accept-", "reject-", "apply-", "scale-", "force-",
+}
+
+func evaluateAws(args []string, env map[string]string, workDir string) (Verdict, string) {
+	var service, operation string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--version" || arg == "--help" || (arg == "help" && operation == "") {
+			return VerdictAllow, "aws help"
+		}
+		if strings.HasPrefix(arg, "-") {
+			if _, _, inline := splitFlagValue(arg); !inline && awsGlobalValueFlags[arg] {
+				i++
+			}
+			continue
+		}
+		if service == "" {
+			service = arg
+			continue
+		}
+		operation = arg
+		rest = args[i+1:]
+		break
+	}
+	if service == "" {
+		return VerdictUncertain, "aws (no service)"
+	}
+
+	// `aws SERVICE OPERATION help`, but not a value like --user-name help
+	if n := len(rest); n > 0 && rest[n-1] == "help" && (n == 1 || !strings.HasPrefix(rest[n-2], "-")) {
+		return VerdictAllow, "aws help"
+	}
+
+	profile := flagValue(args, "--profile")
+	if profile == "" {
+		profile = envValue(env, "AWS_PROFILE")
+	}
+	if profile != "" && matchesAnyPattern(profile, currentPolicy().AwsAskProfiles) {
+		return VerdictAsk, "aws " + strings.TrimSpace(service+" "+operation) + " [profile " + profile + " always asks]"
+	}
+
+	verdict, reason := evaluateAwsOperation(service, operation, rest, workDir)
+	return verdict, reason + productionNote("profile", profile)
+}
+
+// awsOutfileOperations take a positional outfile the response body is
+// written to.
+var awsOutfileOperations = map[string]bool{
+	"s3api get-object": true, "s3api get-object-torrent": true, "lambda invoke": true,
+	"glacier get-job-output": true, "mediastore-data get-object": true,
+	"kinesis-video-media get-media": true, "kinesis-video-archived-media get-clip": true,
+}
+
+// awsBooleanFlagPrefixes mark options that don't take a value.
+var awsBooleanFlagPrefixes = []string{"--no-", "--dry-run", "--debug", "--with-decryption"}
+
+func evaluateAwsOperation(service, operation string, args []string, workDir string) (Verdict, string) {
+	verdict, reason := evaluateAwsService(service, operation, args, workDir)
+	if awsOutfileOperations[service+" "+operation] {
+		if outfile := awsOutfile(args); outfile != "" && outfile != "-" && outfile != "/dev/stdout" && outfile != "/dev/null" {
+			name := "aws " + service + " " + operation
+			noRemote := func(string) bool { return false }
+			if v, r := copyDirectionVerdict(name, operation, "", outfile, false, noRemote, workDir); v > verdict {
+				return v, r
+			}
+		}
+	}
+	return verdict, reason
+}
+
+// awsOutfile returns the positional outfile of an operation: the argument
+// that is neither an option nor an option's value.
+func awsOutfile(args []string) string {
+	outfile := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			outfile = arg
+			continue
+		}
+		if _, _, inline := splitFlagValue(arg); !inline && !hasAnyPrefix(arg, awsBooleanFlagPrefixes) {
+			i++
+		}
+	}
+	return outfile
+}
+
+// awsCredentialOperations print a token, password or key, however
+// read-only their verb looks.
+var awsCredentialOperations = map[string]bool{
+	"ecr get-login-password": true, "ecr get-authorization-token": true,
+	"ecr-public get-login-password": true, "ecr-public get-authorization-token": true,
+	"codeartifact get-authorization-token": true, "eks get-token": true,
+	"ec2 get-password-data": true, "rds generate-db-auth-token": true,
+	"redshift get-cluster-credentials": true, "redshift get-cluster-credentials-with-iam": true,
+	"sso get-role-credentials": true, "cognito-identity get-credentials-for-identity": true,
+	"lightsail get-instance-access-details": true, "configure export-credentials": true,
+	"sts assume-role": true, "sts get-session-token": true, "sts get-federation-token": true,
+}
+
+// awsConfigPublicKeys are config keys `aws configure get` may print without
+// asking; any other key could be a secret.
+var awsConfigPublicKeys = map[string]bool{
+	"region": true, "output": true, "sso_region": true, "sso_start_url": true,
+	"sso_session": true, "sso_account_id": true, "sso_role_name": true,
+	"role_arn": true, "source_profile": true, "cli_pager": true,
+}
+
+func evaluateAwsService(service, operation string, args []string, workDir string) (Verdict, string) {
+	name := "aws " + strings.TrimSpace(service+" "+operation)
+	if operation == "" {
+		return VerdictUncertain, name + " (no operation)"
+	}
+	if awsCredentialOperations[service+" "+operation] {
+		return VerdictAsk, name + " (prints credentials)"
+	}
+
+	switch service {
+	case "s3":
+		return evaluateAwsS3(operation, args, workDir)
+	case "sts":
+		switch operation {
+		case "get-caller-identity", "get-access-key-info", "decode-authorization-message":
+			return VerdictAllow, name
+		}
+		return VerdictUncertain, name + " (mints credentials)"
+	case "iam":
+		if strings.HasPrefix(operation, "list-") || strings.HasPrefix(operation, "get-") ||
+			strings.HasPrefix(operation, "simulate-") || operation == "generate-credential-report" {
+			return VerdictAllow, name
+		}
+		return VerdictAsk, name + " (IAM change)"
+	case "ssm":
+		switch operation {
+		case "get-parameter", "get-parameters", "get-parameters-by-path", "get-parameter-history":
+			if hasFlag(args, "--with-decryption") {
+				return VerdictAsk, name + " --with-decryption (reads secrets)"
+			}
+			return VerdictAllow, name
+		case "start-session", "send-command":
+			return VerdictAsk, name + " (runs commands on instances)"
+		}
+	case "secretsmanager":
+		switch operation {
+		case "get-secret-value", "batch-get-secret-value":
+			return VerdictAsk, name + " (reads secret value)"
+		}
+	case "kms":
+		switch operation {
+		case "decrypt", "re-encrypt", "generate-data-key":
+			return VerdictAsk, name + " (uses KMS key material)"
+		}
+	case "lambda":
+		if operation == "invoke" || operation == "invoke-async" {
+			return VerdictAsk, name + " (runs deployed function)"
+		}
+	case "configure":
+		if operation == "list" || operation == "list-profiles" {
+			return VerdictAllow, name
+		}
+		if operation == "get" {
+			key := strings.Join(firstN(positionalArgs(args, awsGlobalValueFlags), 1), "")
+			if i := strings.LastIndex(key, "."); i >= 0 {
+				key = key[i+1:]
+			}
+			if awsConfigPublicKeys[key] {
+				return VerdictAllow, name + " " + key
+			}
+			return VerdictAsk, name + " " + key + " (may print a credential)"
+		}
+		return VerdictUncertain, name + " (changes local AWS config)"
+	case "eks":
+		if operation == "update-kubeconfig" {
+			return VerdictUncertain, name + " (changes local kubeconfig)"
+		}
+	case "dynamodb":
+		if operation == "scan" || operation == "query" {
+			return VerdictAllow, name
+		}
+	}
+
+	return awsVerbVerdict(name, operation)
+}
+
+// awsVerbVerdict classifies an operation by its verb prefix.
+func awsVerbVerdict(name, operation string) (Verdict, string) {
+	if operation == "wait" {
+		return VerdictAllow, name
+	}
+	for _, prefix := range awsReadPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return VerdictAllow, name
+		}
+	}
+	for _, prefix := range awsWritePrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return VerdictAsk, name
+		}
+	}
+	return VerdictUncertain, name
+}
+
+// awsS3ValueFlags are `aws s3` options that take a value.
+var awsS3ValueFlags = map[string]bool{
+	"--exclude": true, "--include": true, "--acl": true, "--storage-class": true,
+	"--sse": true, "--sse-kms-key-id": true, "--sse-c": true, "--sse-c-key": true,
+	"--grants": true, "--content-type": true, "--cache-control": true,
+	"--content-disposition": true, "--content-encoding": true, "--content-language": true,
+	"--metadata": true, "--metadata-directive": true, "--expires": true,
+	"--page-size": true, "--source-region": true, "--region": true, "--profile": true,
+	"--website-redirect": true, "--expected-size": true, "--request-payer": true,
+	"--expires-in": true, "--index-document": true, "--error-document": true,
+	"--output": true, "--query": true, "--endpoint-url": true,
+}
+
+func evaluateAwsS3(operation string, args []string, workDir string) (Verdict, string) {
+	name := "aws s3 " + operation
+	pos := positionalArgs(args, awsS3ValueFlags)
+
+	switch operation {
+	case "ls":
+		return VerdictAllow, name
+	case "presign":
+		return VerdictUncertain, name + " (shares object by URL)"
+	case "rm", "mb", "rb", "website":
+		return VerdictAsk, name + " " + strings.Join(firstN(pos, 1), " ")
+	case "cp", "mv", "sync":
+		if len(pos) < 2 {
+			return VerdictUncertain, name
+		}
+		return copyDirectionVerdict(name, operation, pos[0], pos[len(pos)-1], hasFlag(args, "--delete"), isS3URI, workDir)
+	}
+	return awsVerbVerdict(name, operation)
+}
+
+func isS3URI(s string) bool {
+	return strings.HasPrefix(s, "s3://")
+}
+
+// copyDirectionVerdict classifies cp, mv and sync/rsync between local paths
+// and object storage. Uploads and anything that deletes remote objects ask;
+// downloads into the project are allowed, and downloads elsewhere are
+// checked like any other local write.
+func copyDirectionVerdict(name, operation, src, dst string, deletes bool, isRemote func(string) bool, workDir string) (Verdict, string) {
+	switch {
+	case isRemote(dst):
+		if deletes {
+			return VerdictAsk, name + " removes objects from " + dst
+		}
+		return VerdictAsk, name + " uploads to " + dst
+	case operation == "mv" && isRemote(src):
+		return VerdictAsk, name + " deletes source " + src
+	}
+
+	local := resolvePath(expandHome(dst), workDir)
+	switch {
+	case isSystemPath(local):
+		return askOrDeny(CategorySystemWrite), name + " downloads to system path: " + dst
+	case deletes:
+		return VerdictUncertain, name + " removes local files"
+	case isWithinDir(local, workDir):
+		return VerdictAllow, name + " (download into project)"
+	}
+	return uncertainOnIntent(name + " downloads outside project: " + dst)
+}
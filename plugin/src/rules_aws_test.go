@@ -0,0 +1,112 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluateAws(t *testing.T) {
+	workDir := "/Users/victor/projects/app"
+
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		{"s3 ls", "aws s3 ls s3://bucket/prefix/", VerdictAllow},
+		{"s3 download", "aws s3 cp s3://bucket/file.txt ./file.txt", VerdictAllow},
+		{"s3 download to ssh dir", "aws s3 cp s3://bucket/key ~/.ssh/authorized_keys", VerdictAsk},
+		{"s3 download outside project", "aws s3 cp s3://bucket/file.txt /opt/file.txt", VerdictUncertain},
+		{"s3 sync down", "aws s3 sync s3://bucket/dir ./dir", VerdictAllow},
+		{"s3 upload", "aws s3 cp ./build s3://bucket/site --recursive", VerdictAsk},
+		{"s3 upload with exclude", "aws s3 sync --exclude '*.tmp' ./dist s3://bucket", VerdictAsk},
+		{"s3 sync delete", "aws s3 sync ./dist s3://bucket --delete", VerdictAsk},
+		{"s3 sync delete local", "aws s3 sync s3://bucket ./dist --delete", VerdictUncertain},
+		{"s3 mv from bucket", "aws s3 mv s3://bucket/a ./a", VerdictAsk},
+		{"s3 rm", "aws s3 rm s3://bucket --recursive", VerdictAsk},
+		{"s3 rb", "aws s3 rb s3://bucket --force", VerdictAsk},
+		{"s3 mb", "aws s3 mb s3://new-bucket", VerdictAsk},
+		{"s3api get", "aws s3api get-bucket-policy --bucket b", VerdictAllow},
+		{"s3api head", "aws s3api head-object --bucket b --key k", VerdictAllow},
+		{"s3api get-object into project", "aws s3api get-object --bucket b --key k out.json", VerdictAllow},
+		{"s3api get-object to bashrc", "aws s3api get-object --bucket b --key k ~/.bashrc", VerdictAsk},
+		{"s3api get-object outside project", "aws s3api get-object --bucket b --key k /tmp/x/out.json", VerdictUncertain},
+		{"s3api get-object outfile first", "aws s3api get-object ~/.bashrc --bucket b --key k", VerdictAsk},
+		{"s3api get-object stdout", "aws s3api get-object --bucket b --key k /dev/stdout", VerdictAllow},
+		{"lambda invoke outfile system path", "aws lambda invoke --function-name f /etc/cron.d/job", VerdictAsk},
+		{"s3api put", "aws s3api put-bucket-policy --bucket b --policy file://p.json", VerdictAsk},
+		{"s3api delete", "aws s3api delete-objects --bucket b --delete file://d.json", VerdictAsk},
+		{"sts identity", "aws sts get-caller-identity", VerdictAllow},
+		{"sts assume role", "aws sts assume-role --role-arn arn:aws:iam::1:role/admin --role-session-name x", VerdictAsk},
+		{"sts session token", "aws sts get-session-token --duration-seconds 3600", VerdictAsk},
+		{"sts federation token", "aws sts get-federation-token --name dev", VerdictAsk},
+		{"iam list", "aws iam list-roles", VerdictAllow},
+		{"iam attach", "aws iam attach-role-policy --role-name r --policy-arn p", VerdictAsk},
+		{"iam unknown verb", "aws iam upload-ssh-public-key --user-name u", VerdictAsk},
+		{"ssm get parameter", "aws ssm get-parameter --name /app/db-host", VerdictAllow},
+		{"ssm get decrypted", "aws ssm get-parameter --name /app/db-pass --with-decryption", VerdictAsk},
+		{"ssm send command", "aws ssm send-command --document-name AWS-RunShellScript", VerdictAsk},
+		{"secret value", "aws secretsmanager get-secret-value --secret-id prod/db", VerdictAsk},
+		{"secret list", "aws secretsmanager list-secrets", VerdictAllow},
+		{"lambda invoke", "aws lambda invoke --function-name f out.json", VerdictAsk},
+		{"lambda list", "aws lambda list-functions", VerdictAllow},
+		{"query value is not the verb", "aws ec2 run-instances --query get-x", VerdictAsk},
+		{"query value before service", "aws --query delete-x --region us-east-1 ec2 describe-instances", VerdictAllow},
+		{"global flags first", "aws --profile dev --region eu-west-1 ec2 describe-vpcs", VerdictAllow},
+		{"wait", "aws ec2 wait instance-running --instance-ids i-1", VerdictAllow},
+		{"help", "aws ec2 help", VerdictAllow},
+		{"operation help", "aws iam delete-user help", VerdictAllow},
+		{"help as a value", "aws iam delete-user --user-name help", VerdictAsk},
+		{"version", "aws --version", VerdictAllow},
+		{"configure set", "aws configure set region us-east-1", VerdictUncertain},
+		{"configure get region", "aws configure get region", VerdictAllow},
+		{"configure get profile region", "aws configure get profile.dev.region --profile dev", VerdictAllow},
+		{"configure get secret key", "aws configure get aws_secret_access_key", VerdictAsk},
+		{"configure get profile secret", "aws configure get profile.prod.aws_session_token", VerdictAsk},
+		{"configure export credentials", "aws configure export-credentials --format env", VerdictAsk},
+		{"ecr login password", "aws ecr get-login-password --region us-east-1", VerdictAsk},
+		{"ecr authorization token", "aws ecr get-authorization-token", VerdictAsk},
+		{"codeartifact token", "aws codeartifact get-authorization-token --domain d", VerdictAsk},
+		{"eks token", "aws eks get-token --cluster-name c", VerdictAsk},
+		{"ec2 password data", "aws ec2 get-password-data --instance-id i-1", VerdictAsk},
+		{"ec2 get console output", "aws ec2 get-console-output --instance-id i-1", VerdictAllow},
+		{"unknown verb", "aws ec2 frobnicate", VerdictUncertain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestAwsProfilePolicy(t *testing.T) {
+	saved := activePolicy
+	defer func() { activePolicy = saved }()
+	activePolicy = defaultPolicy()
+	activePolicy.AwsAskProfiles = []string{"*-prod", "prod"}
+
+	tests := []struct {
+		name     string
+		command  string
+		want     Verdict
+		contains string
+	}{
+		{"read under ask profile", "aws s3 ls --profile acme-prod", VerdictAsk, "always asks"},
+		{"env profile", "AWS_PROFILE=prod aws ec2 describe-instances", VerdictAsk, "always asks"},
+		{"other profile", "aws s3 ls --profile dev", VerdictAllow, "aws s3 ls"},
+		{"production note", "aws s3 ls --profile production", VerdictAllow, "[production profile: production]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, "/tmp")
+			if got != tt.want || !strings.Contains(reason, tt.contains) {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v containing %q", tt.command, got, reason, tt.want, tt.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// --- Database CLI handlers ---
+//
+// Statements are collected from flags (-c, -e, --query, ...), script files
+// inside the project, heredocs and `< file` redirects, then classified with
+// the shared SQL classifier. Reads are allowed. Writes ask when they go to a
+// remote server and are left to the evaluator on a local one. Destructive
+// statements always ask, and anything that can't be classified (interactive
+// sessions, scripts outside the project, client escapes) is uncertain.
+
+// maxScriptSize bounds how much of a script file is read for classification.
+const maxScriptSize = 1 << 20
+
+// dbTarget describes where a database command connects.
+type dbTarget struct {
+	host  string
+	local bool
+}
+
+func (t dbTarget) String() string {
+	if t.host == "" {
+		return "local"
+	}
+	return t.host
+}
+
+// classifyDBHost decides whether a host is local: only the default
+// connection (no host), localhost, loopback addresses and unix socket paths
+// are. Anything else, including a host left in an unexpanded variable
+// ($DB_HOST) or a bare service name that could resolve anywhere, is remote.
+func classifyDBHost(host string) dbTarget {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return dbTarget{local: true}
+	}
+	if strings.ContainsAny(host, "$`") {
+		return dbTarget{host: host}
+	}
+	if strings.HasPrefix(host, "/") {
+		return dbTarget{host: host, local: true}
+	}
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	name = strings.Trim(name, "[]")
+
+	local := false
+	switch {
+	case name == "localhost" || strings.HasSuffix(name, ".localhost"):
+		local = true
+	case net.ParseIP(name) != nil:
+		local = net.ParseIP(name).IsLoopback()
+	}
+	return dbTarget{host: host, local: local}
+}
+
+// hostFromURL extracts the host from a connection URI such as
+// postgres://user@db.example.com:5432/app.
+func hostFromURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if i := strings.LastIndex(u.Host, "@"); i >= 0 {
+		return u.Host[i+1:]
+	}
+	return u.Host
+}
+
+// splitRedirects removes redirection tokens from args and returns the file
+// fed to stdin with '<', if any. Heredoc operators are dropped too; their
+// bodies arrive separately.
+func splitRedirects(args []string) ([]string, string) {
+	var out []string
+	stdinFile := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "<":
+			if i+1 < len(args) {
+				stdinFile = args[i+1]
+				i++
+			}
+		case arg == "<<" || arg == "<<-" || arg == ">" || arg == ">>" ||
+			arg == "2>" || arg == "2>>" || arg == "&>" || arg == "<<<":
+			if arg == "<<<" && i+1 < len(args) {
+				// Here-string: treat like a one-line heredoc
+				stdinFile = "\x00" + args[i+1]
+			}
+			i++
+		case strings.HasPrefix(arg, "<<<"):
+			stdinFile = "\x00" + arg[3:]
+		case strings.HasPrefix(arg, "<<"):
+		case strings.HasPrefix(arg, "<"):
+			stdinFile = arg[1:]
+		case strings.HasPrefix(arg, ">") || strings.HasPrefix(arg, "2>") || strings.HasPrefix(arg, "&>") ||
+			strings.HasPrefix(arg, "1>"):
+		default:
+			out = append(out, arg)
+		}
+	}
+	return out, stdinFile
+}
+
+// dbScript gathers the statements a database command will run.
+type dbScript struct {
+	texts   []string // inline statements and heredoc bodies
+	files   []string // script files to read
+	problem string   // why the statements can't be known, if set
+}
+
+func (s *dbScript) addStdin(stdin, stdinFile string) {
+	if stdin != "" {
+		s.texts = append(s.texts, stdin)
+	}
+	switch {
+	case strings.HasPrefix(stdinFile, "\x00"):
+		s.texts = append(s.texts, stdinFile[1:])
+	case stdinFile != "":
+		s.files = append(s.files, stdinFile)
+	}
+}
+
+func (s *dbScript) empty() bool {
+	return len(s.texts) == 0 && len(s.files) == 0 && s.problem == ""
+}
+
+// load reads the script files, which must live inside the project.
+func (s *dbScript) load(workDir string) []string {
+	texts := append([]string(nil), s.texts...)
+	for _, file := range s.files {
+		text, err := readProjectScript(file, workDir)
+		if err != nil {
+			s.problem = err.Error()
+			return nil
+		}
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+// readProjectScript reads a script file, refusing files outside the project
+// because their contents can't be vouched for.
+func readProjectScript(file, workDir string) (string, error) {
+	path := resolvePath(file, workDir)
+	if workDir == "" || !isWithinDir(path, workDir) {
+		return "", fmt.Errorf("script outside project: %s", file)
+	}
+	dependOnFile(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read script: %s", file)
+	}
+	if info.Size() > maxScriptSize {
+		return "", fmt.Errorf("script too large to check: %s", file)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read script: %s", file)
+	}
+	noteInputs("db-script", path, string(data))
+	return string(data), nil
+}
+
+// classifyDBScript runs the classifier over every statement source.
+func classifyDBScript(script *dbScript, workDir string, classify func(string) (sqlEffect, string)) (sqlEffect, string) {
+	worst, keyword := sqlRead, ""
+	for _, text := range script.load(workDir) {
+		effect, kw := classify(text)
+		if effect > worst || keyword == "" {
+			worst, keyword = effect, kw
+		}
+	}
+	return worst, keyword
+}
+
+// databaseVerdict turns a classification into a verdict.
+func databaseVerdict(tool string, script *dbScript, target dbTarget, workDir string, classify func(string) (sqlEffect, string)) (Verdict, string) {
+	if script.empty() {
+		return VerdictUncertain, tool + " interactive session (" + target.String() + ")"
+	}
+
+	effect, keyword := classifyDBScript(script, workDir, classify)
+	if script.problem != "" {
+		return VerdictUncertain, tool + ": " + script.problem
+	}
+
+	where := " on " + target.String()
+	switch effect {
+	case sqlRead:
+		return VerdictAllow, tool + " read-only (" + keyword + ")" + where
+	case sqlDestructive:
+		return VerdictAsk, tool + " destructive statement: " + keyword + where
+	case sqlWrite:
+		if target.local {
+			return VerdictUncertain, tool + " write (" + keyword + ")" + where
+		}
+		return VerdictAsk, tool + " write (" + keyword + ") on remote " + target.String()
+	}
+	return VerdictUncertain, tool + " unclassified statement: " + keyword + where
+}
+
+func sqlClassifier(dialect string) func(string) (sqlEffect, string) {
+	return func(text string) (sqlEffect, string) {
+		return classifySQL(text, dialect)
+	}
+}
+
+func evaluatePsql(args []string, env map[string]string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	script := &dbScript{}
+	host := envValue(env, "PGHOST")
+	listOnly := false
+
+	withValue := map[string]bool{
+		"-c": true, "--command": true, "-f": true, "--file": true,
+		"-h": true, "--host": true, "-p": true, "--port": true,
+		"-U": true, "--username": true, "-d": true, "--dbname": true,
+		"-v": true, "--set": true, "--variable": true, "-o": true, "--output": true,
+		"-P": true, "--pset": true, "-F": true, "-R": true, "-L": true, "--log-file": true, "-T": true,
+	}
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		if !inline && withValue[flag] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch flag {
+		case "-c", "--command":
+			script.texts = append(script.texts, value)
+		case "-f", "--file":
+			if value == "-" {
+				continue
+			}
+			script.files = append(script.files, value)
+		case "-h", "--host":
+			host = value
+		case "-d", "--dbname":
+			if h := connInfoHost(value); h != "" {
+				host = h
+			}
+		case "-l", "--list", "-V", "--version", "--help":
+			listOnly = true
+		default:
+			if !strings.HasPrefix(flag, "-") {
+				if h := connInfoHost(flag); h != "" {
+					host = h
+				}
+			}
+		}
+	}
+
+	target := classifyDBHost(host)
+	if listOnly {
+		return VerdictAllow, "psql metadata query on " + target.String()
+	}
+	script.addStdin(stdin, stdinFile)
+	return databaseVerdict("psql", script, target, workDir, sqlClassifier(dialectPostgres))
+}
+
+// connInfoHost extracts the host from a postgres URI or a "host=... dbname=..."
+// connection string. A connection string in an unexpanded variable is
+// returned whole, as an unknown host.
+func connInfoHost(s string) string {
+	if strings.ContainsAny(s, "$`") && !strings.Contains(s, "host=") {
+		return s
+	}
+	if strings.Contains(s, "://") {
+		return hostFromURL(s)
+	}
+	for _, field := range strings.Fields(s) {
+		if strings.HasPrefix(field, "host=") {
+			return strings.TrimPrefix(field, "host=")
+		}
+	}
+	return ""
+}
+
+func evaluateMysql(cmd string, args []string, env map[string]string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	script := &dbScript{}
+	host := envValue(env, "MYSQL_HOST")
+
+	withValue := map[string]bool{
+		"-e": true, "--execute": true, "-h": true, "--host": true,
+		"-u": true, "--user": true, "-P": true, "--port": true,
+		"-D": true, "--database": true, "-S": true, "--socket": true,
+		"--defaults-file": true, "--defaults-extra-file": true, "--protocol": true,
+	}
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		if !inline && withValue[flag] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch {
+		case flag == "-e" || flag == "--execute":
+			script.texts = append(script.texts, value)
+		case strings.HasPrefix(flag, "-e") && !strings.HasPrefix(flag, "--"):
+			script.texts = append(script.texts, flag[2:])
+		case flag == "-h" || flag == "--host":
+			host = value
+		case strings.HasPrefix(flag, "-h") && !strings.HasPrefix(flag, "--"):
+			host = flag[2:]
+		case flag == "-S" || flag == "--socket":
+			host = value
+		case flag == "--version" || flag == "-V" || flag == "--help":
+			return VerdictAllow, cmd + " " + flag
+		}
+	}
+
+	script.addStdin(stdin, stdinFile)
+	return databaseVerdict(cmd, script, classifyDBHost(host), workDir, sqlClassifier(dialectMySQL))
+}
+
+func evaluateSqlite(cmd string, args []string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	script := &dbScript{}
+	readOnly := false
+
+	// Options take one or two leading dashes
+	withValue := map[string]bool{
+		"cmd": true, "init": true, "separator": true, "newline": true,
+		"nullvalue": true, "vfs": true, "lookaside": true, "pagecache": true,
+		"mmap": true, "maxsize": true, "c": true, "s": true,
+	}
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		if !strings.HasPrefix(flag, "-") {
+			positional = append(positional, args[i])
+			continue
+		}
+		name := strings.TrimLeft(flag, "-")
+		if !inline && withValue[name] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "cmd", "c", "s":
+			script.texts = append(script.texts, value)
+		case "init":
+			script.files = append(script.files, value)
+		case "readonly":
+			readOnly = true
+		case "version", "help":
+			return VerdictAllow, cmd + " " + flag
+		}
+	}
+
+	// sqlite3 [options] FILE [SQL...]
+	db := ":memory:"
+	if len(positional) > 0 {
+		db = positional[0]
+	}
+	if len(positional) > 1 {
+		script.texts = append(script.texts, strings.Join(positional[1:], " "))
+	}
+	script.addStdin(stdin, stdinFile)
+	classify := sqlClassifier(dialectSQLite)
+	if readOnly {
+		classify = classifySQLiteReadOnly
+	}
+	return databaseVerdict(cmd, script, dbTarget{host: db, local: true}, workDir, classify)
+}
+
+// classifySQLiteReadOnly classifies SQL run by sqlite3 -readonly, where
+// writes to the database fail. ATTACH and VACUUM INTO still create other
+// database files.
+func classifySQLiteReadOnly(text string) (sqlEffect, string) {
+	worst := sqlRead
+	keyword := ""
+	for _, stmt := range sqlStatements(text, dialectSQLite) {
+		effect, kw := classifyStatement(stmt)
+		if effect == sqlWrite && stmt[0] != "ATTACH" && stmt[0] != "VACUUM" {
+			effect = sqlRead
+		}
+		if effect > worst || keyword == "" {
+			worst = effect
+			keyword = kw
+		}
+	}
+	return worst, keyword
+}
+
+func evaluateClickhouse(cmd string, args []string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	script := &dbScript{}
+	host := ""
+
+	withValue := map[string]bool{
+		"-q": true, "--query": true, "-h": true, "--host": true, "--port": true,
+		"-u": true, "--user": true, "--password": true, "-d": true, "--database": true,
+		"--queries-file": true, "-f": true, "--format": true, "--config-file": true, "-C": true,
+	}
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		if !inline && withValue[flag] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch flag {
+		case "-q", "--query":
+			script.texts = append(script.texts, value)
+		case "--queries-file":
+			script.files = append(script.files, value)
+		case "-h", "--host":
+			host = value
+		case "--version", "-V", "--help":
+			return VerdictAllow, cmd + " " + flag
+		}
+	}
+
+	script.addStdin(stdin, stdinFile)
+	return databaseVerdict(cmd, script, classifyDBHost(host), workDir, sqlClassifier(dialectANSI))
+}
+
+func evaluateMongosh(cmd string, args []string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	script := &dbScript{}
+	host := ""
+
+	withValue := map[string]bool{
+		"--eval": true, "--file": true, "-f": true, "--host": true, "--port": true,
+		"-u": true, "--username": true, "-p": true, "--password": true,
+		"--authenticationDatabase": true, "--authenticationMechanism": true,
+	}
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		if !strings.HasPrefix(flag, "-") {
+			switch {
+			case strings.HasPrefix(args[i], "mongodb://") || strings.HasPrefix(args[i], "mongodb+srv://"):
+				host = hostFromURL(args[i])
+			case strings.HasSuffix(args[i], ".js"):
+				script.files = append(script.files, args[i])
+			}
+			continue
+		}
+		if !inline && withValue[flag] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch flag {
+		case "--eval":
+			script.texts = append(script.texts, value)
+		case "--file", "-f":
+			script.files = append(script.files, value)
+		case "--host":
+			host = value
+			if strings.Contains(value, "://") {
+				host = hostFromURL(value)
+			}
+		case "--version", "--help":
+			return VerdictAllow, cmd + " " + flag
+		}
+	}
+
+	script.addStdin(stdin, stdinFile)
+	return databaseVerdict(cmd, script, classifyDBHost(host), workDir, classifyMongoScript)
+}
+
+func evaluateRedisCli(args []string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	script := &dbScript{}
+	host := ""
+
+	withValue := map[string]bool{
+		"-h": true, "-p": true, "-a": true, "-u": true, "-n": true, "-s": true,
+		"--user": true, "--pass": true, "-r": true, "-i": true, "-d": true,
+		"--rdb": true, "--functions-rdb": true, "--cacert": true, "--cert": true, "--key": true,
+	}
+	var command []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if len(command) > 0 || !strings.HasPrefix(arg, "-") {
+			command = append(command, arg)
+			continue
+		}
+		value := ""
+		if withValue[arg] && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch arg {
+		case "-h":
+			host = value
+		case "-s":
+			host = value
+		case "-u":
+			host = hostFromURL(value)
+		case "--scan", "--bigkeys", "--memkeys", "--hotkeys", "--stat", "--latency",
+			"--latency-history", "--latency-dist", "--rdb", "--version", "--help":
+			return VerdictAllow, "redis-cli " + arg + " on " + classifyDBHost(host).String()
+		case "--pipe":
+			return VerdictAsk, "redis-cli --pipe (bulk writes from stdin)"
+		case "--eval":
+			return VerdictUncertain, "redis-cli --eval (Lua script)"
+		case "--cluster":
+			return VerdictAsk, "redis-cli --cluster (cluster administration)"
+		}
+	}
+
+	if len(command) > 0 {
+		script.texts = append(script.texts, strings.Join(command, " "))
+	}
+	script.addStdin(stdin, stdinFile)
+	return databaseVerdict("redis-cli", script, classifyDBHost(host), workDir, classifyRedisScript)
+}
+
+// classifyRedisScript classifies one redis command per line.
+func classifyRedisScript(text string) (sqlEffect, string) {
+	worst, keyword := sqlRead, ""
+	for _, line := range strings.Split(text, "\n") {
+		words := shellWords(line)
+		if len(words) == 0 {
+			continue
+		}
+		effect, cmd := classifyRedisCommand(words)
+		if effect > worst || keyword == "" {
+			worst, keyword = effect, cmd
+		}
+	}
+	return worst, keyword
+}
+
+func evaluateDuckdb(args []string, stdin, workDir string) (Verdict, string) {
+	// duckdb shares the sqlite3 shell's options and dot commands
+	return evaluateSqlite("duckdb", args, stdin, workDir)
+}
+
+// evaluateBq classifies BigQuery commands. BigQuery is always remote, so
+// write queries ask.
+func evaluateBq(args []string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	withValue := map[string]bool{
+		"--project_id": true, "--location": true, "--dataset_id": true, "--format": true,
+		"--api": true, "--apilog": true, "--max_rows": true, "-n": true,
+		"--destination_table": true, "--parameter": true, "--job_id": true, "--label": true,
+	}
+	pos := positionalArgs(args, withValue)
+	if len(pos) == 0 {
+		return VerdictUncertain, "bq command"
+	}
+
+	subCmd := pos[0]
+	switch subCmd {
+	case "ls", "show", "head", "version", "help", "wait", "get-iam-policy", "info":
+		return VerdictAllow, "bq read operation"
+	case "mk", "rm", "update", "cp", "load", "insert", "set-iam-policy",
+		"add-iam-policy-binding", "remove-iam-policy-binding", "cancel",
+		"truncate", "undelete", "extract":
+		return VerdictAsk, "bq " + subCmd
+	case "query":
+	default:
+		return VerdictUncertain, "bq " + subCmd
+	}
+
+	if hasFlag(args, "--dry_run") {
+		return VerdictAllow, "bq query --dry_run"
+	}
+	if flagValue(args, "--destination_table") != "" {
+		return VerdictAsk, "bq query writes to --destination_table"
+	}
+
+	script := &dbScript{}
+	if len(pos) > 1 {
+		script.texts = append(script.texts, strings.Join(pos[1:], " "))
+	}
+	script.addStdin(stdin, stdinFile)
+	if script.empty() {
+		return VerdictUncertain, "bq query (no query text)"
+	}
+	return databaseVerdict("bq query", script, dbTarget{host: "bigquery"}, workDir, sqlClassifier(dialectANSI))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvaluateDatabaseCLIs(t *testing.T) {
+	workDir := t.TempDir()
+	os.WriteFile(filepath.Join(workDir, "report.sql"), []byte("-- weekly report\nSELECT count(*) FROM orders;\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "mutate.sql"), []byte("/* cleanup */ DELETE FROM orders WHERE id = 1;"), 0644)
+	os.WriteFile(filepath.Join(workDir, "wipe.js"), []byte("db.orders.deleteMany({})"), 0644)
+
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		// ===== psql =====
+		{"psql select local", `psql -h localhost -c "SELECT * FROM users"`, VerdictAllow},
+		{"psql select remote", `psql -h db.example.com -c "SELECT 1"`, VerdictAllow},
+		{"psql created_at column", `psql -c "SELECT created_at, deleted FROM users"`, VerdictAllow},
+		{"psql keyword in string", `psql -c "SELECT * FROM log WHERE msg = 'DROP TABLE users'"`, VerdictAllow},
+		{"psql keyword in comment", "psql -c \"SELECT 1 -- then DELETE everything\"", VerdictAllow},
+		{"psql insert local", `psql -h localhost -c "INSERT INTO t VALUES (1)"`, VerdictUncertain},
+		{"psql insert service name", `psql -h postgres -c "INSERT INTO t VALUES (1)"`, VerdictAsk},
+		{"psql insert localhost", `psql -h localhost -c "INSERT INTO t VALUES (1)"`, VerdictUncertain},
+		{"psql insert variable url", `psql "$DATABASE_URL" -c "INSERT INTO t VALUES (1)"`, VerdictAsk},
+		{"psql insert variable dbname", `psql -d "$DATABASE_URL" -c "INSERT INTO t VALUES (1)"`, VerdictAsk},
+		{"psql select variable url", `psql "$DATABASE_URL" -c "SELECT 1"`, VerdictAllow},
+		{"mysql insert variable host", `mysql -h "$DB_HOST" -e "INSERT INTO t VALUES (1)"`, VerdictAsk},
+		{"psql insert remote", `psql -h db.example.com -c "INSERT INTO t VALUES (1)"`, VerdictAsk},
+		{"psql insert remote uri", `psql postgres://app@db.example.com:5432/app -c "UPDATE t SET a = 1 WHERE id = 2"`, VerdictAsk},
+		{"psql gexec remote", "psql -h db.example.com <<'SQL'\nSELECT 'DROP TABLE ' || tablename FROM pg_tables \\gexec\nSQL", VerdictAsk},
+		{"mysql set global remote", `mysql -h mysql.prod.internal -e "SET GLOBAL read_only = 0"`, VerdictAsk},
+		{"psql insert PGHOST", `PGHOST=10.0.0.5 psql -c "INSERT INTO t VALUES (1)"`, VerdictAsk},
+		{"psql drop database local", `psql -c "DROP DATABASE app"`, VerdictAsk},
+		{"psql truncate", `psql -c "TRUNCATE orders"`, VerdictAsk},
+		{"psql delete without where", `psql -c "DELETE FROM orders"`, VerdictAsk},
+		{"psql second statement", `psql -c "SELECT 1; DROP SCHEMA public CASCADE"`, VerdictAsk},
+		{"psql side effect function", `psql -h db.example.com -c "SELECT pg_terminate_backend(123)"`, VerdictAsk},
+		{"psql explain", `psql -c "EXPLAIN SELECT * FROM t"`, VerdictAllow},
+		{"psql explain analyze delete", `psql -c "EXPLAIN ANALYZE DELETE FROM t"`, VerdictAsk},
+		{"psql meta command", `psql -c "\dt"`, VerdictAllow},
+		{"psql shell escape", `psql -c "\! rm -rf /"`, VerdictUncertain},
+		{"psql list", "psql -l", VerdictAllow},
+		{"psql interactive", "psql -h db.example.com", VerdictUncertain},
+		{"psql file read", "psql -f report.sql", VerdictAllow},
+		{"psql file write remote", "psql -h db.example.com -f mutate.sql", VerdictAsk},
+		{"psql file outside project", "psql -f /tmp/other.sql", VerdictUncertain},
+		{"psql stdin redirect", "psql -h db.example.com < mutate.sql", VerdictAsk},
+		{"psql heredoc read", "psql -h db.example.com <<EOF\nSELECT 1;\nEOF", VerdictAllow},
+		{"psql heredoc write", "psql -h db.example.com <<'SQL'\nUPDATE t SET a = 1 WHERE id = 1;\nSQL", VerdictAsk},
+		{"psql dollar quoted", `psql -c "SELECT \$\$DROP TABLE x\$\$"`, VerdictAllow},
+
+		// ===== mysql =====
+		{"mysql select", `mysql -h 127.0.0.1 -e "SHOW TABLES"`, VerdictAllow},
+		{"mysql update remote", `mysql -h mysql.prod.internal -e "UPDATE t SET a = 1 WHERE id = 1"`, VerdictAsk},
+		{"mysql hash comment", "mysql -e \"SELECT 1 # DROP DATABASE x\"", VerdictAllow},
+		{"mysql execute equals", `mysql --execute="DROP DATABASE app"`, VerdictAsk},
+		{"mariadb stdin", "mariadb -h db.example.com app < report.sql", VerdictAllow},
+
+		// ===== sqlite / duckdb =====
+		{"sqlite select", `sqlite3 app.db "SELECT * FROM users"`, VerdictAllow},
+		{"sqlite dot tables", `sqlite3 app.db .tables`, VerdictAllow},
+		{"sqlite shell", `sqlite3 app.db ".shell rm -rf ~"`, VerdictUncertain},
+		{"sqlite insert", `sqlite3 app.db "INSERT INTO t VALUES (1)"`, VerdictUncertain},
+		{"sqlite readonly", `sqlite3 -readonly app.db "INSERT INTO t VALUES (1)"`, VerdictAllow},
+		{"sqlite readonly attach", `sqlite3 -readonly app.db "ATTACH 'other.db' AS o"`, VerdictUncertain},
+		{"sqlite interactive", "sqlite3 app.db", VerdictUncertain},
+		{"duckdb query", `duckdb -c "SELECT * FROM 'data.parquet'"`, VerdictAllow},
+		{"duckdb copy", `duckdb analytics.db -c "COPY t TO 'out.csv'"`, VerdictUncertain},
+
+		// ===== clickhouse =====
+		{"clickhouse-client select", `clickhouse-client --host ch.example.com -q "SELECT count() FROM events"`, VerdictAllow},
+		{"clickhouse client drop", `clickhouse client --query "DROP DATABASE analytics"`, VerdictAsk},
+		{"clickhouse client insert remote", `clickhouse client -h ch.example.com -q "INSERT INTO events VALUES (1)"`, VerdictAsk},
+
+		// ===== mongosh =====
+		{"mongosh find", `mongosh --eval "db.users.find({}).limit(5)"`, VerdictAllow},
+		{"mongosh insert remote", `mongosh mongodb+srv://cluster0.example.net/app --eval "db.users.insertOne({a: 1})"`, VerdictAsk},
+		{"mongosh insert local", `mongosh mongodb://localhost/app --eval "db.users.insertOne({a: 1})"`, VerdictUncertain},
+		{"mongosh drop", `mongosh --eval "db.dropDatabase()"`, VerdictAsk},
+		{"mongosh aggregate out", `mongosh --eval "db.orders.aggregate([{\$out: 'copy'}])"`, VerdictUncertain},
+		{"mongosh file", "mongosh mongodb://localhost/app wipe.js", VerdictUncertain},
+
+		// ===== redis-cli =====
+		{"redis get", "redis-cli GET session:1", VerdictAllow},
+		{"redis keys remote", "redis-cli -h cache.example.com KEYS 'user:*'", VerdictAllow},
+		{"redis set local", "redis-cli SET a 1", VerdictUncertain},
+		{"redis set remote", "redis-cli -h cache.example.com SET a 1", VerdictAsk},
+		{"redis flushall", "redis-cli FLUSHALL", VerdictAsk},
+		{"redis config set", "redis-cli CONFIG SET dir /tmp", VerdictAsk},
+		{"redis config get", "redis-cli CONFIG GET maxmemory", VerdictAllow},
+		{"redis scan", "redis-cli --scan --pattern 'user:*'", VerdictAllow},
+		{"redis interactive", "redis-cli -h cache.example.com", VerdictUncertain},
+
+		// ===== bq =====
+		{"bq query created_at", `bq query --nouse_legacy_sql 'SELECT created_at FROM ds.t'`, VerdictAllow},
+		{"bq query format flag", `bq query --format json 'SELECT 1'`, VerdictAllow},
+		{"bq query stdin", "bq query < mutate.sql", VerdictAsk},
+		{"bq query dry run", `bq query --dry_run 'DELETE FROM ds.t WHERE true'`, VerdictAllow},
+		{"bq query destination", `bq query --destination_table=ds.copy 'SELECT 1'`, VerdictAsk},
+		{"bq rm", "bq rm -f ds.t", VerdictAsk},
+		{"bq global flags", "bq --project_id=p ls", VerdictAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassifySQL(t *testing.T) {
+	tests := []struct {
+		sql     string
+		dialect string
+		want    sqlEffect
+	}{
+		{"SELECT * FROM t", dialectANSI, sqlRead},
+		{"select * from t", dialectANSI, sqlRead},
+		{"WITH x AS (SELECT 1) SELECT * FROM x", dialectANSI, sqlRead},
+		{"WITH d AS (DELETE FROM t WHERE a = 1 RETURNING *) SELECT * FROM d", dialectPostgres, sqlWrite},
+		{"SELECT * INTO backup FROM t", dialectANSI, sqlWrite},
+		{"SELECT setval('seq', 1)", dialectPostgres, sqlWrite},
+		{"SELECT 'a;DROP DATABASE x'", dialectANSI, sqlRead},
+		{`SELECT "delete" FROM t`, dialectANSI, sqlRead},
+		{"/* DROP DATABASE x */ SELECT 1", dialectANSI, sqlRead},
+		{"# DROP DATABASE x\nSELECT 1", dialectMySQL, sqlRead},
+		{"PRAGMA table_info(users)", dialectSQLite, sqlRead},
+		{"PRAGMA journal_mode = WAL", dialectSQLite, sqlWrite},
+		{"COPY t TO STDOUT", dialectPostgres, sqlRead},
+		{"COPY t FROM '/tmp/x.csv'", dialectPostgres, sqlWrite},
+		{"CREATE TABLE t (a int)", dialectANSI, sqlWrite},
+		{"DROP TABLE t", dialectANSI, sqlWrite},
+		{"DROP SCHEMA s CASCADE", dialectANSI, sqlDestructive},
+		{"UPDATE t SET a = 1", dialectANSI, sqlDestructive},
+		{"FROBNICATE t", dialectANSI, sqlUnknown},
+		{"\\dt\n\\i other.sql", dialectPostgres, sqlUnknown},
+		{"SELECT 'DROP TABLE ' || tablename FROM pg_tables \\gexec", dialectPostgres, sqlWrite},
+		{"SELECT count(*) FROM t \\gx", dialectPostgres, sqlRead},
+		{"SELECT * FROM t \\g |sh", dialectPostgres, sqlUnknown},
+		{"SELECT 1\n\\g", dialectPostgres, sqlRead},
+		{"SET PASSWORD FOR root = 'x'", dialectMySQL, sqlWrite},
+		{"SET GLOBAL read_only = 0", dialectMySQL, sqlWrite},
+		{"SELECT * FROM t WHERE id = 1 FOR UPDATE", dialectPostgres, sqlRead},
+		{"SELECT * FROM t FOR NO KEY UPDATE", dialectPostgres, sqlRead},
+	}
+
+	for _, tt := range tests {
+		if got, kw := classifySQL(tt.sql, tt.dialect); got != tt.want {
+			t.Errorf("classifySQL(%q) = %v (%s), want %v", tt.sql, got, kw, tt.want)
+		}
+	}
+}
+
+func TestClassifyDBHost(t *testing.T) {
+	tests := []struct {
+		host  string
+		local bool
+	}{
+		{"", true},
+		{"localhost", true},
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"localhost:5432", true},
+		{"/var/run/postgresql", true},
+		{"postgres", false},
+		{"db.local", false},
+		{"$DB_HOST", false},
+		{"${PGHOST}", false},
+		{"10.0.0.5", false},
+		{"db.example.com", false},
+		{"prod-db.internal.corp:5432", false},
+	}
+
+	for _, tt := range tests {
+		if got := classifyDBHost(tt.host); got.local != tt.local {
+			t.Errorf("classifyDBHost(%q).local = %v, want %v", tt.host, got.local, tt.local)
+		}
+	}
+}
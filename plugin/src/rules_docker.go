@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// --- docker / podman handlers ---
+//
+// Creating containers and images is allowed; stopping and removing them is
+// left to the evaluator, since they may not be the agent's. What matters for
+// a new container is what it is given: --privileged, host namespaces, extra
+// capabilities, the Docker socket and bind mounts of /, $HOME or credential
+// directories all break the isolation that makes `docker run` safe. Volumes
+// hold data, so removing them asks. All of this assumes the local daemon: a
+// command sent to another host or context goes to the evaluator.
+
+// dockerGlobalValueFlags are docker/podman options before the subcommand
+// that take a value.
+var dockerGlobalValueFlags = map[string]bool{
+	"--context": true, "-c": true, "-H": true, "--host": true, "--config": true,
+	"-l": true, "--log-level": true, "--connection": true, "--url": true,
+}
+
+// dockerRunValueFlags are `docker run`/`create` options that take a value.
+var dockerRunValueFlags = map[string]bool{
+	"--add-host": true, "--annotation": true, "-a": true, "--attach": true,
+	"--blkio-weight": true, "--blkio-weight-device": true, "--cap-add": true,
+	"--cap-drop": true, "--cgroup-parent": true, "--cgroupns": true, "--cidfile": true,
+	"--cpu-period": true, "--cpu-quota": true, "--cpu-rt-period": true,
+	"--cpu-rt-runtime": true, "-c": true, "--cpu-shares": true, "--cpus": true,
+	"--cpuset-cpus": true, "--cpuset-mems": true, "--detach-keys": true,
+	"--device": true, "--device-cgroup-rule": true, "--device-read-bps": true,
+	"--device-read-iops": true, "--device-write-bps": true, "--device-write-iops": true,
+	"--dns": true, "--dns-option": true, "--dns-opt": true, "--dns-search": true,
+	"--domainname": true, "--entrypoint": true, "-e": true, "--env": true,
+	"--env-file": true, "--expose": true, "--gpus": true, "--group-add": true,
+	"--health-cmd": true, "--health-interval": true, "--health-retries": true,
+	"--health-start-period": true, "--health-start-interval": true,
+	"--health-timeout": true, "-h": true, "--hostname": true, "--ip": true,
+	"--ip6": true, "--ipc": true, "--isolation": true, "--kernel-memory": true,
+	"-l": true, "--label": true, "--label-file": true, "--link": true,
+	"--link-local-ip": true, "--log-driver": true, "--log-opt": true,
+	"--mac-address": true, "-m": true, "--memory": true, "--memory-reservation": true,
+	"--memory-swap": true, "--memory-swappiness": true, "--mount": true,
+	"--name": true, "--network": true, "--net": true, "--network-alias": true,
+	"--net-alias": true, "--oom-score-adj": true, "--pid": true, "--pids-limit": true,
+	"--platform": true, "-p": true, "--publish": true, "--pull": true,
+	"--restart": true, "--runtime": true, "--security-opt": true, "--shm-size": true,
+	"--stop-signal": true, "--stop-timeout": true, "--storage-opt": true,
+	"--sysctl": true, "--tmpfs": true, "--ulimit": true, "-u": true, "--user": true,
+	"--userns": true, "--uts": true, "-v": true, "--volume": true,
+	"--volume-driver": true, "--volumes-from": true, "-w": true, "--workdir": true,
+}
+
+// dockerRunBoolFlags are `docker run`/`create` options without a value. An
+// option in neither table ends the scan: its value could be the image.
+var dockerRunBoolFlags = map[string]bool{
+	"-d": true, "--detach": true, "--disable-content-trust": true, "--help": true,
+	"--init": true, "-i": true, "--interactive": true, "--no-healthcheck": true,
+	"--oom-kill-disable": true, "--privileged": true, "-P": true,
+	"--publish-all": true, "-q": true, "--quiet": true, "--read-only": true,
+	"--rm": true, "--sig-proxy": true, "-t": true, "--tty": true,
+	"--use-api-socket": true,
+}
+
+// dockerDangerousCaps give a container control over the host kernel,
+// network or other processes.
+var dockerDangerousCaps = map[string]bool{
+	"ALL": true, "SYS_ADMIN": true, "SYS_PTRACE": true, "SYS_MODULE": true,
+	"NET_ADMIN": true, "SYS_RAWIO": true, "DAC_READ_SEARCH": true, "SYS_BOOT": true,
+}
+
+// dockerCredentialDirs are home-relative directories holding credentials.
+var dockerCredentialDirs = []string{
+	".ssh", ".aws", ".kube", ".docker", ".gnupg", ".config/gcloud", ".azure",
+	".config/gh", ".netrc", ".npmrc", ".pypirc", ".git-credentials",
+}
+
+func evaluateDocker(cmd string, args []string, env map[string]string, workDir string) (Verdict, string) {
+	i := 0
+	var daemon []string
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		if args[i] == "--version" || args[i] == "-v" {
+			return VerdictAllow, cmd + " --version"
+		}
+		option, value, inline := splitFlagValue(args[i])
+		if !dockerGlobalValueFlags[option] {
+			continue
+		}
+		if !inline && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+		switch option {
+		case "-H", "--host", "--url":
+			daemon = append(daemon, "DOCKER_HOST="+value)
+		case "-c", "--context", "--connection":
+			daemon = append(daemon, "DOCKER_CONTEXT="+value)
+		}
+	}
+	if i >= len(args) {
+		return VerdictAllow, cmd + " (no subcommand)"
+	}
+
+	// The daemon flags decide over the environment
+	for _, assignment := range daemon {
+		key, value, _ := strings.Cut(assignment, "=")
+		env = withEnv(env, key, value)
+	}
+	verdict, reason := evaluateDockerCommand(cmd, args[i], args[i+1:], env, workDir)
+	return dockerDaemonVerdict(cmd, env, verdict, reason)
+}
+
+// withEnv returns a copy of env with key set to value.
+func withEnv(env map[string]string, key, value string) map[string]string {
+	out := map[string]string{key: value}
+	for k, v := range env {
+		if k != key {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// dockerDaemonVerdict leaves a command the rules would allow to the evaluator
+// when it goes to a daemon other than the local default one: a remote host
+// (DOCKER_HOST, -H) or a named context (DOCKER_CONTEXT, --context), whose
+// containers, mounts and ports are somewhere else.
+func dockerDaemonVerdict(cmd string, env map[string]string, verdict Verdict, reason string) (Verdict, string) {
+	if verdict != VerdictAllow {
+		return verdict, reason
+	}
+	hostVar, contextVar := "DOCKER_HOST", "DOCKER_CONTEXT"
+	if cmd == "podman" || strings.HasPrefix(cmd, "podman") {
+		hostVar, contextVar = "CONTAINER_HOST", "CONTAINER_CONNECTION"
+	}
+	if host := envValue(env, hostVar); host != "" && !strings.HasPrefix(host, "unix://") {
+		return VerdictUncertain, reason + " (daemon at " + host + ")"
+	}
+	if context := envValue(env, contextVar); context != "" && context != "default" {
+		return VerdictUncertain, reason + " (context " + context + ")"
+	}
+	return verdict, reason
+}
+
+func evaluateDockerCommand(cmd, subCmd string, rest []string, env map[string]string, workDir string) (Verdict, string) {
+	// docker compose is equivalent to docker-compose
+	if subCmd == "compose" {
+		return evaluateDockerCompose(cmd+" compose", rest, env, workDir)
+	}
+
+	// Management commands: docker container rm, docker image prune, ...
+	switch subCmd {
+	case "container", "image", "volume", "network", "system", "builder", "buildx", "context":
+		if len(rest) > 0 {
+			return evaluateDockerManagement(cmd, subCmd, rest[0], rest[1:], env, workDir)
+		}
+		return VerdictAllow, cmd + " " + subCmd
+	}
+
+	safeSubcmds := map[string]bool{
+		"ps": true, "logs": true, "images": true, "inspect": true,
+		"stats": true, "top": true, "history": true, "info": true,
+		"version": true, "build": true, "pull": true, "tag": true,
+		"login": true, "logout": true, "search": true,
+		"events": true, "diff": true, "port": true, "wait": true,
+		"cp": true, "start": true, "save": true, "load": true,
+	}
+	if safeSubcmds[subCmd] {
+		return VerdictAllow, cmd + " " + subCmd
+	}
+
+	switch subCmd {
+	case "run", "create":
+		return evaluateDockerRun(cmd+" "+subCmd, rest, workDir)
+	case "push":
+		return VerdictAsk, cmd + " push (publishes image)"
+	}
+
+	return VerdictUncertain, cmd + " " + subCmd
+}
+
+func evaluateDockerManagement(cmd, group, action string, args []string, env map[string]string, workDir string) (Verdict, string) {
+	name := cmd + " " + group + " " + action
+	switch action {
+	case "ls", "list", "inspect", "history", "logs", "top", "stats", "port",
+		"diff", "df", "info", "events", "show":
+		return VerdictAllow, name
+	}
+
+	switch group {
+	case "container":
+		switch action {
+		case "run", "create":
+			return evaluateDockerRun(name, args, workDir)
+		case "prune":
+			return VerdictUncertain, name
+		}
+		return evaluateDockerCommand(cmd, action, args, env, workDir)
+	case "image":
+		switch action {
+		case "build", "pull", "tag", "save", "load":
+			return VerdictAllow, name
+		case "prune":
+			return VerdictUncertain, name
+		case "push":
+			return VerdictAsk, name + " (publishes image)"
+		}
+	case "volume":
+		switch action {
+		case "create":
+			return VerdictAllow, name
+		case "rm", "remove", "prune":
+			return VerdictAsk, name + " (deletes volume data)"
+		}
+	case "network":
+		return VerdictAllow, name
+	case "context":
+		if action == "use" {
+			return VerdictUncertain, name + " (points later calls at another daemon)"
+		}
+	case "system":
+		if action == "prune" {
+			if hasFlag(args, "--volumes") {
+				return VerdictAsk, name + " --volumes (deletes volume data)"
+			}
+			return VerdictUncertain, name
+		}
+	case "builder", "buildx":
+		if action == "prune" {
+			return VerdictAllow, name + " (build cache)"
+		}
+		if action == "build" || action == "bake" {
+			if hasFlag(args, "--push") {
+				return VerdictAsk, name + " --push (publishes image)"
+			}
+			return VerdictAllow, name
+		}
+	}
+	return VerdictUncertain, name
+}
+
+// evaluateDockerRun checks what a new container gets access to. Only the
+// options before the image are inspected; the rest is the container's own
+// command.
+func evaluateDockerRun(name string, args []string, workDir string) (Verdict, string) {
+	verdict := VerdictAllow
+	var findings []string
+	flag := func(v Verdict, finding string) {
+		findings = append(findings, finding)
+		if v == VerdictAsk || verdict == VerdictAllow {
+			verdict = v
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			break // image
+		}
+		option, value, inline, ok := dockerRunOption(arg)
+		if !ok {
+			flag(VerdictUncertain, "unknown option "+arg)
+			break
+		}
+		if !inline && dockerRunValueFlags[option] {
+			if i+1 >= len(args) {
+				break
+			}
+			i++
+			value = args[i]
+		}
+
+		switch option {
+		case "--privileged":
+			if !inline || value == "true" {
+				flag(VerdictAsk, "--privileged")
+			}
+		case "--use-api-socket":
+			flag(VerdictAsk, "the container runtime socket")
+		case "--pid", "--ipc", "--userns", "--uts", "--cgroupns":
+			if value == "host" {
+				flag(VerdictAsk, option+"=host")
+			}
+		case "--network", "--net":
+			if value == "host" {
+				flag(VerdictUncertain, option+"=host")
+			}
+		case "-p", "--publish":
+			if !strings.HasPrefix(value, "127.0.0.1:") && !strings.HasPrefix(value, "localhost:") {
+				flag(VerdictUncertain, "publishes port "+value)
+			}
+		case "-P", "--publish-all":
+			flag(VerdictUncertain, "publishes all ports")
+		case "--cap-add":
+			cap := strings.TrimPrefix(strings.ToUpper(value), "CAP_")
+			if dockerDangerousCaps[cap] {
+				flag(VerdictAsk, "--cap-add "+value)
+			} else {
+				flag(VerdictUncertain, "--cap-add "+value)
+			}
+		case "--security-opt":
+			if strings.HasSuffix(value, "unconfined") {
+				flag(VerdictAsk, "--security-opt "+value)
+			}
+		case "--device", "--device-cgroup-rule":
+			flag(VerdictUncertain, option+" "+value)
+		case "-v", "--volume":
+			if v, risk := bindMountRisk(strings.SplitN(value, ":", 2)[0], workDir); risk != "" {
+				flag(v, "mounts "+risk)
+			}
+		case "--mount":
+			if v, risk := bindMountRisk(mountSource(value), workDir); risk != "" {
+				flag(v, "mounts "+risk)
+			}
+		case "--env-file":
+			if v, risk := envFileRisk(value, workDir); risk != "" {
+				flag(v, "reads environment from "+risk)
+			}
+		}
+	}
+
+	if len(findings) == 0 {
+		return VerdictAllow, name + " (isolated container)"
+	}
+	return verdict, name + ": " + strings.Join(findings, ", ")
+}
+
+// dockerRunOption splits a docker run option into its name and inline value.
+// Short options can be grouped (-it) and take their value attached
+// (-v/:/host). ok is false for options docker run doesn't have.
+func dockerRunOption(arg string) (option, value string, inline, ok bool) {
+	if strings.HasPrefix(arg, "--") || len(arg) == 2 {
+		option, value, inline = splitFlagValue(arg)
+		return option, value, inline, dockerRunValueFlags[option] || dockerRunBoolFlags[option]
+	}
+	for j := 1; j < len(arg); j++ {
+		option = "-" + arg[j:j+1]
+		if dockerRunValueFlags[option] {
+			value = strings.TrimPrefix(arg[j+1:], "=")
+			return option, value, j+1 < len(arg), true
+		}
+		if !dockerRunBoolFlags[option] {
+			return arg, "", false, false
+		}
+	}
+	return option, "", false, true
+}
+
+// mountSource extracts the source of a --mount type=bind,source=...,target=...
+func mountSource(spec string) string {
+	for _, field := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(field, "=")
+		if key == "source" || key == "src" {
+			return value
+		}
+	}
+	return ""
+}
+
+// bindMountRisk describes why mounting a host path into a container is
+// risky, or returns "" if it isn't. Named volumes are never a risk. System
+// paths and anything else outside the project are uncertain rather than ask:
+// read-only mounts like /etc/localtime are common.
+func bindMountRisk(source, workDir string) (Verdict, string) {
+	if source == "" {
+		return VerdictAllow, ""
+	}
+	source = strings.ReplaceAll(source, "$(pwd)", workDir)
+	source = strings.ReplaceAll(source, "${PWD}", workDir)
+	source = strings.ReplaceAll(source, "$PWD", workDir)
+	source = expandHome(source)
+	if strings.ContainsAny(source, "$`") {
+		return VerdictUncertain, "computed path " + source
+	}
+	if dockerVolumeName.MatchString(source) {
+		return VerdictAllow, "" // named volume
+	}
+	path := resolvePath(source, workDir)
+
+	if strings.HasSuffix(path, "docker.sock") || strings.HasSuffix(path, "podman.sock") {
+		return VerdictAsk, "the container runtime socket " + source
+	}
+	if path == "/" {
+		return VerdictAsk, "the host root filesystem"
+	}
+	if exposed := homeExposure(path, source); exposed != "" {
+		return VerdictAsk, exposed
+	}
+	if isSystemPath(path) {
+		return VerdictUncertain, "system path " + source
+	}
+	if workDir == "" || !isWithinDir(path, workDir) {
+		return VerdictUncertain, "path outside project " + source
+	}
+	return VerdictAllow, ""
+}
+
+// composeRunOnlyFlags are `docker compose run` options without a value that
+// docker run doesn't have.
+var composeRunOnlyFlags = map[string]bool{
+	"--service-ports": true, "--use-aliases": true, "--no-deps": true, "-T": true,
+	"--no-TTY": true, "--remove-orphans": true, "--build": true, "--quiet-pull": true,
+	"--quiet-build": true,
+}
+
+// composeRunOptions turns `docker compose run` options into their docker run
+// equivalents, so evaluateDockerRun can check them.
+func composeRunOptions(args []string) []string {
+	var out []string
+	for _, arg := range args {
+		option, value, inline := splitFlagValue(arg)
+		switch {
+		case composeRunOnlyFlags[option]:
+			continue
+		case option == "--env-from-file" && inline:
+			arg = "--env-file=" + value
+		case option == "--env-from-file":
+			arg = "--env-file"
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+// dockerVolumeName matches a named volume, as opposed to a host path.
+var dockerVolumeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// homeExposure describes a path that holds the home directory or a
+// credential directory in it, or returns "".
+func homeExposure(path, source string) string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return ""
+	}
+	if path == home || isWithinDir(home, path) {
+		return "the home directory via " + source
+	}
+	for _, dir := range dockerCredentialDirs {
+		if isWithinDir(path, filepath.Join(home, dir)) {
+			return "credentials in " + source
+		}
+	}
+	return ""
+}
+
+// envFileRisk describes why passing a file's variables into a container is
+// risky, or returns "". Credential files ask; other files outside the
+// project go to the evaluator.
+func envFileRisk(file, workDir string) (Verdict, string) {
+	path := resolvePath(expandHome(file), workDir)
+	if isWithinDir(path, workDir) {
+		return VerdictAllow, ""
+	}
+	if v, risk := bindMountRisk(path, workDir); risk != "" && v == VerdictAsk {
+		return v, risk
+	}
+	return VerdictUncertain, "file outside project " + file
+}
+
+func evaluateDockerCompose(name string, args []string, env map[string]string, workDir string) (Verdict, string) {
+	verdict, reason := evaluateComposeCommand(name, args, env, workDir)
+	return dockerDaemonVerdict(name, env, verdict, reason)
+}
+
+func evaluateComposeCommand(name string, args []string, env map[string]string, workDir string) (Verdict, string) {
+	var files []string
+	dir := workDir
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		option, value, inline := splitFlagValue(args[i])
+		switch option {
+		case "-f", "--file", "--project-directory", "-p", "--project-name", "--profile", "--env-file":
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			switch option {
+			case "-f", "--file":
+				files = append(files, value)
+			case "--project-directory":
+				dir = resolvePath(value, workDir)
+			case "--env-file":
+				if v, risk := envFileRisk(value, workDir); risk != "" {
+					return v, name + ": reads environment from " + risk
+				}
+			}
+		}
+	}
+
+	pos := positionalArgs(args, map[string]bool{
+		"-f": true, "--file": true, "--project-directory": true, "-p": true,
+		"--project-name": true, "--profile": true, "--env-file": true,
+	})
+	if len(pos) == 0 {
+		return VerdictAllow, name + " (no subcommand)"
+	}
+
+	subCmd := pos[0]
+
+	safeSubcmds := map[string]bool{
+		"build": true, "pull": true, "start": true,
+		"ps": true, "logs": true, "config": true, "images": true,
+		"top": true, "version": true, "ls": true, "port": true,
+		"events": true,
+	}
+	if safeSubcmds[subCmd] {
+		return VerdictAllow, name + " " + subCmd
+	}
+
+	switch subCmd {
+	case "up", "create", "run":
+		if len(files) == 0 {
+			files = composeEnvFiles(env)
+		}
+		verdict, reason := evaluateComposeFiles(name+" "+subCmd, files, dir)
+		if subCmd == "run" && i < len(args) {
+			// The run's own options add to what the service gets
+			runVerdict, runReason := evaluateDockerRun(name+" run", composeRunOptions(args[i+1:]), dir)
+			if runVerdict == VerdictAsk || runVerdict != VerdictAllow && verdict == VerdictAllow {
+				return runVerdict, runReason
+			}
+		}
+		return verdict, reason
+	case "down", "rm":
+		if hasFlag(args, "-v", "--volumes") {
+			return VerdictAsk, name + " " + subCmd + " -v (deletes volumes)"
+		}
+		return VerdictUncertain, name + " " + subCmd
+	case "push":
+		return VerdictAsk, name + " push (publishes images)"
+	}
+
+	return VerdictUncertain, name + " " + subCmd
+}
+
+// composeFileNames are the default compose files, in lookup order.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yml", "docker-compose.yaml"}
+
+// composeOverrideNames are the override files compose merges into the
+// default file, in lookup order.
+var composeOverrideNames = []string{"compose.override.yaml", "compose.override.yml", "docker-compose.override.yml", "docker-compose.override.yaml"}
+
+// composeEnvFiles returns the files COMPOSE_FILE lists, separated by
+// COMPOSE_PATH_SEPARATOR (the OS path list separator by default).
+func composeEnvFiles(env map[string]string) []string {
+	list := envValue(env, "COMPOSE_FILE")
+	if list == "" {
+		return nil
+	}
+	separator := envValue(env, "COMPOSE_PATH_SEPARATOR")
+	if separator == "" {
+		separator = string(os.PathListSeparator)
+	}
+	var files []string
+	for _, file := range strings.Split(list, separator) {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// defaultComposeFiles returns the compose file compose picks in dir and
+// its override file, if they exist.
+func defaultComposeFiles(dir string) []string {
+	var files []string
+	for _, names := range [][]string{composeFileNames, composeOverrideNames} {
+		for _, candidate := range names {
+			dependOnFile(filepath.Join(dir, candidate))
+			if _, err := os.Stat(filepath.Join(dir, candidate)); err == nil {
+				files = append(files, candidate)
+				break
+			}
+		}
+	}
+	return files
+}
+
+// evaluateComposeFiles checks the services a compose command starts for the
+// same risks as docker run. With no files given, the default file and its
+// override are checked; if there is none, compose fails by itself. A file
+// the command names but that can't be read goes to the evaluator.
+func evaluateComposeFiles(name string, files []string, dir string) (Verdict, string) {
+	if len(files) == 0 {
+		files = defaultComposeFiles(dir)
+	}
+
+	verdict := VerdictAllow
+	var findings []string
+	for _, file := range files {
+		path := resolvePath(file, dir)
+		if dir == "" || !isWithinDir(path, dir) {
+			return VerdictUncertain, name + ": compose file outside project: " + file
+		}
+		v, found, ok := scanComposeFile(path, dir)
+		if !ok {
+			return VerdictUncertain, name + ": cannot read compose file " + file
+		}
+		if v == VerdictAsk || verdict == VerdictAllow {
+			verdict = v
+		}
+		findings = append(findings, found...)
+	}
+
+	if len(findings) == 0 {
+		return VerdictAllow, name
+	}
+	return verdict, name + ": " + strings.Join(findings, ", ")
+}
+
+// scanComposeFile looks for risky service settings with a line scan. ok is
+// false if the file can't be read.
+func scanComposeFile(path, dir string) (Verdict, []string, bool) {
+	dependOnFile(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return VerdictAllow, nil, false
+	}
+	defer f.Close()
+
+	verdict := VerdictAllow
+	var findings []string
+	flag := func(v Verdict, finding string) {
+		findings = append(findings, finding)
+		if v == VerdictAsk || verdict == VerdictAllow {
+			verdict = v
+		}
+	}
+
+	flagCap := func(item string) {
+		if dockerDangerousCaps[strings.TrimPrefix(strings.ToUpper(item), "CAP_")] {
+			flag(VerdictAsk, "cap_add "+item)
+		} else {
+			flag(VerdictUncertain, "cap_add "+item)
+		}
+	}
+
+	inCapAdd := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, hasColon := strings.Cut(line, ":")
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		if strings.HasPrefix(line, "- ") {
+			item := strings.Trim(strings.TrimSpace(line[2:]), `"'`)
+			if inCapAdd {
+				flagCap(item)
+				continue
+			}
+			// Short volume syntax: - ./src:/dst[:ro]
+			if src, _, ok := strings.Cut(item, ":"); ok {
+				if v, risk := bindMountRisk(src, dir); risk != "" {
+					flag(v, "mounts "+risk)
+				}
+			}
+			continue
+		}
+		inCapAdd = false
+		if !hasColon {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "privileged":
+			if value == "true" {
+				flag(VerdictAsk, "privileged")
+			}
+		case "pid", "ipc", "userns_mode", "uts", "cgroup":
+			if value == "host" {
+				flag(VerdictAsk, strings.TrimSpace(key)+": host")
+			}
+		case "network_mode":
+			if value == "host" {
+				flag(VerdictUncertain, "network_mode: host")
+			}
+		case "cap_add":
+			// Block list follows, or a flow list: cap_add: [SYS_ADMIN]
+			inCapAdd = value == ""
+			for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if cap := strings.Trim(strings.TrimSpace(item), `"'`); cap != "" {
+					flagCap(cap)
+				}
+			}
+		case "source":
+			if v, risk := bindMountRisk(value, dir); risk != "" {
+				flag(v, "mounts "+risk)
+			}
+		}
+	}
+	return verdict, findings, scanner.Err() == nil
+}
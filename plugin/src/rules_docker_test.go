@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEvaluateDocker(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := filepath.Join(home, "projects", "app")
+	os.MkdirAll(filepath.Join(workDir, "risky"), 0755)
+	os.WriteFile(filepath.Join(workDir, "compose.yaml"), []byte(`services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+    volumes:
+      - ./site:/usr/share/nginx/html:ro
+      - data:/var/lib/data
+    environment:
+      - MODE=dev:local
+volumes:
+  data:
+`), 0644)
+	os.WriteFile(filepath.Join(workDir, "risky", "compose.yml"), []byte(`services:
+  agent:
+    image: agent
+    privileged: true
+    volumes:
+      - /var/run/docker.sock:/var/run/docker.sock
+`), 0644)
+	os.WriteFile(filepath.Join(workDir, "caps.yml"), []byte(`services:
+  net:
+    image: tools
+    cap_add: [NET_ADMIN]
+    network_mode: host
+`), 0644)
+
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		// ===== run / create =====
+		{"plain run", "docker run --rm alpine echo hi", VerdictAllow},
+		{"run with project mount", "docker run --rm -v $(pwd):/src -w /src golang go test ./...", VerdictAllow},
+		{"run with named volume", "docker run -d -v pgdata:/var/lib/postgresql/data postgres", VerdictAllow},
+		{"run command is not inspected", "docker run --rm alpine rm -rf / --privileged", VerdictAllow},
+		{"privileged", "docker run --privileged alpine", VerdictAsk},
+		{"pid host", "docker run --pid=host alpine ps", VerdictAsk},
+		{"net host", "docker run --net=host nginx", VerdictUncertain},
+		{"cap sys admin", "docker run --cap-add SYS_ADMIN alpine", VerdictAsk},
+		{"cap chown", "docker run --cap-add=CHOWN alpine", VerdictUncertain},
+		{"docker socket", "docker run -v /var/run/docker.sock:/var/run/docker.sock alpine", VerdictAsk},
+		{"root mount", "docker run -v /:/host alpine", VerdictAsk},
+		{"home mount", "docker run -v ~:/home/me alpine", VerdictAsk},
+		{"ssh mount", "docker run -v $HOME/.ssh:/root/.ssh:ro alpine", VerdictAsk},
+		{"aws mount long syntax", "docker run --mount type=bind,source=" + home + "/.aws,target=/root/.aws alpine", VerdictAsk},
+		{"system path mount", "docker run -v /etc/localtime:/etc/localtime:ro alpine", VerdictUncertain},
+		{"computed mount", `docker run -v "$(echo /)":/h alpine`, VerdictUncertain},
+		{"variable mount", "docker run -v ${X}:/h alpine", VerdictUncertain},
+		{"parent dir mount", "docker run -v ..:/h alpine", VerdictUncertain},
+		{"home parent mount", "docker run -v " + filepath.Dir(home) + ":/h alpine", VerdictAsk},
+		{"outside mount", "docker run -v /srv/cache:/cache alpine", VerdictUncertain},
+		{"seccomp unconfined", "docker run --security-opt seccomp=unconfined alpine", VerdictAsk},
+		{"container run", "docker container run --privileged alpine", VerdictAsk},
+		{"create", "docker create --name x -v /:/host alpine", VerdictAsk},
+		{"podman run", "podman run --rm -it fedora bash", VerdictAllow},
+		{"global flag first", "docker --log-level debug run --rm alpine", VerdictAllow},
+		{"default context", "docker --context default run --rm alpine", VerdictAllow},
+		{"named context", "docker --context prod run --rm alpine", VerdictUncertain},
+		{"context ls", "docker context ls", VerdictAllow},
+		{"context use", "docker context use prod", VerdictUncertain},
+		{"remote host flag", "docker -H tcp://build.example.com:2376 run --rm alpine", VerdictUncertain},
+		{"remote host env", "DOCKER_HOST=ssh://build.example.com docker ps", VerdictUncertain},
+		{"local socket host", "docker -H unix:///var/run/docker.sock ps", VerdictAllow},
+		{"remote host keeps ask", "docker -H tcp://build.example.com:2376 run --privileged alpine", VerdictAsk},
+		{"credentials env file", "docker run --env-file ~/.aws/credentials alpine", VerdictAsk},
+		{"project env file", "docker run --env-file .env alpine", VerdictAllow},
+		{"env file outside project", "docker run --env-file /tmp/app.env alpine", VerdictUncertain},
+		{"value flag before privileged", "docker run --cpu-shares 512 --privileged alpine", VerdictAsk},
+		{"value flag before root mount", "docker run --pids-limit 100 -v /:/host alpine", VerdictAsk},
+		{"attached short value", "docker run -v/:/host alpine", VerdictAsk},
+		{"grouped short flags", "docker run -it --rm alpine sh", VerdictAllow},
+		{"grouped short flags with value", "docker run -itv/:/host alpine", VerdictAsk},
+		{"unknown option", "docker run --frobnicate alpine", VerdictUncertain},
+		{"unknown option keeps ask", "docker run --privileged --frobnicate alpine", VerdictAsk},
+		{"published port", "docker run -p 8080:80 nginx", VerdictUncertain},
+		{"loopback port", "docker run -p 127.0.0.1:8080:80 nginx", VerdictAllow},
+		{"api socket", "docker run --use-api-socket alpine", VerdictAsk},
+
+		// ===== removal =====
+		{"rm force", "docker rm -f web", VerdictUncertain},
+		{"rm force volumes", "docker rm -fv web", VerdictUncertain},
+		{"rmi", "docker rmi -f myimage:dev", VerdictUncertain},
+		{"image rm", "docker image rm myimage", VerdictUncertain},
+		{"volume rm", "docker volume rm pgdata", VerdictAsk},
+		{"volume prune", "docker volume prune -f", VerdictAsk},
+		{"system prune", "docker system prune -a", VerdictUncertain},
+		{"system prune volumes", "docker system prune -a --volumes", VerdictAsk},
+		{"builder prune", "docker builder prune -f", VerdictAllow},
+		{"push", "docker push registry/app:1.0", VerdictAsk},
+
+		// ===== compose =====
+		{"compose up safe file", "docker compose up -d", VerdictAllow},
+		{"compose up risky file", "docker compose -f risky/compose.yml up", VerdictAsk},
+		{"compose caps", "docker-compose -f caps.yml up -d", VerdictAsk},
+		{"compose outside project", "docker compose -f /tmp/compose.yml up", VerdictUncertain},
+		{"compose missing file", "docker compose -f missing.yml up", VerdictUncertain},
+		{"compose file from env", "COMPOSE_FILE=compose.yaml:risky/compose.yml docker compose up", VerdictAsk},
+		{"compose file env separator", "COMPOSE_PATH_SEPARATOR=, COMPOSE_FILE=compose.yaml,caps.yml docker compose up", VerdictAsk},
+		{"compose credentials env file", "docker compose --env-file ~/.aws/credentials up", VerdictAsk},
+		{"compose remote context", "docker --context prod compose up -d", VerdictUncertain},
+		{"compose run safe", "docker compose run --rm --no-deps web go test ./...", VerdictAllow},
+		{"compose run host root", "docker compose run --rm -v /:/h web", VerdictAsk},
+		{"compose run privileged", "docker compose run --privileged web", VerdictAsk},
+		{"compose run cap", "docker compose run --cap-add SYS_ADMIN web", VerdictAsk},
+		{"compose run outside mount", "docker compose run -v /srv/cache:/cache web", VerdictUncertain},
+		{"compose down", "docker compose down", VerdictUncertain},
+		{"compose down volumes", "docker compose down -v", VerdictAsk},
+		{"compose down volumes long", "docker-compose down --volumes --remove-orphans", VerdictAsk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateComposeOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := filepath.Join(home, "app")
+	os.MkdirAll(workDir, 0755)
+	os.WriteFile(filepath.Join(workDir, "compose.yaml"), []byte("services:\n  web:\n    image: nginx\n"), 0644)
+
+	if got, reason := evaluateCommand("docker compose up", workDir); got != VerdictAllow {
+		t.Fatalf("without override: %v (%s), want ALLOW", got, reason)
+	}
+
+	os.WriteFile(filepath.Join(workDir, "docker-compose.override.yml"), []byte(`services:
+  web:
+    privileged: true
+    volumes:
+      - /:/host
+`), 0644)
+	if got, reason := evaluateCommand("docker compose up", workDir); got != VerdictAsk {
+		t.Errorf("with override: %v (%s), want ASK", got, reason)
+	}
+}
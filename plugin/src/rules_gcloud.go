@@ -0,0 +1,218 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- gcloud / gsutil handlers ---
+//
+// gcloud commands are parsed as a path of groups followed by a verb
+// (`gcloud compute instances delete vm`), with flags separated out so a
+// --filter value can't be mistaken for the verb. Projects listed in the
+// policy's gcloud_ask_projects always ask.
+
+// gcloudValueFlags are common flags that take a value.
+var gcloudValueFlags = map[string]bool{
+	"--project": true, "--account": true, "--configuration": true, "--format": true,
+	"--filter": true, "--flatten": true, "--limit": true, "--page-size": true,
+	"--sort-by": true, "--verbosity": true, "--impersonate-service-account": true,
+	"--billing-project": true, "--region": true, "--zone": true, "--trace-token": true,
+	"--access-token-file": true, "--location": true, "--secret": true, "--member": true,
+	"--role": true, "--image": true, "--source": true, "--cluster": true,
+}
+
+var gcloudReadVerbs = map[string]bool{
+	"list": true, "describe": true, "get-iam-policy": true, "get-value": true,
+	"info": true, "version": true, "help": true, "read": true, "tail": true,
+	"search": true, "lookup": true, "list-grantable-roles": true, "get-ancestors": true,
+	"get-server-config": true, "print-settings": true, "cat": true, "ls": true,
+	"du": true, "hash": true, "check": true, "validate": true, "list-tags": true,
+	"list-files": true, "wait": true,
+}
+
+var gcloudWriteVerbs = map[string]bool{
+	"create": true, "delete": true, "update": true, "deploy": true, "ssh": true,
+	"scp": true, "set-iam-policy": true, "add-iam-policy-binding": true,
+	"remove-iam-policy-binding": true, "patch": true, "import": true, "export": true,
+	"resize": true, "reset": true, "start": true, "stop": true, "restart": true,
+	"submit": true, "call": true, "rollback": true, "promote": true, "enable": true,
+	"disable": true, "attach": true, "detach": true, "move": true, "rename": true,
+	"undelete": true, "restore": true, "execute": true, "cancel": true,
+	"suspend": true, "resume": true, "publish": true, "upload": true, "replace": true,
+	"apply": true, "connect": true, "rotate": true, "destroy": true, "add": true,
+	"remove": true, "clone": true, "failover": true, "upgrade": true, "access": true,
+	"set-traffic": true, "update-traffic": true, "acknowledge": true, "pull": true,
+	"cp": true, "mv": true, "rsync": true, "rm": true, "sign-url": true,
+}
+
+// gcloudPath splits gcloud arguments into the command path (groups and
+// verb) and everything else.
+func gcloudPath(args []string) (path []string, rest []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			if _, _, inline := splitFlagValue(arg); !inline && gcloudValueFlags[arg] {
+				i++
+			}
+			continue
+		}
+		path = append(path, arg)
+		if gcloudReadVerbs[arg] || gcloudWriteVerbs[arg] || isGcloudVerbLike(arg) {
+			return path, args[i+1:]
+		}
+	}
+	return path, nil
+}
+
+// isGcloudVerbLike matches the verb families gcloud uses beyond the fixed
+// lists (list-*, get-*, set-*, add-*, remove-*, print-*).
+func isGcloudVerbLike(arg string) bool {
+	for _, prefix := range []string{"list-", "get-", "set-", "add-", "remove-", "print-", "describe-", "update-", "delete-", "create-"} {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateGcloud(args []string, env map[string]string, workDir string) (Verdict, string) {
+	if len(args) == 0 {
+		return VerdictUncertain, "gcloud (no args)"
+	}
+	if hasFlag(args, "--version", "--help", "-h") {
+		return VerdictAllow, "gcloud help"
+	}
+
+	path, rest := gcloudPath(args)
+	if len(path) == 0 {
+		return VerdictUncertain, "gcloud (no command)"
+	}
+
+	project := flagValue(args, "--project")
+	if project == "" {
+		project = envValue(env, "CLOUDSDK_CORE_PROJECT")
+	}
+	name := "gcloud " + strings.Join(path, " ")
+	if project != "" && matchesAnyPattern(project, currentPolicy().GcloudAskProjects) {
+		return VerdictAsk, name + " [project " + project + " always asks]"
+	}
+
+	verdict, reason := evaluateGcloudCommand(path, rest, args, workDir)
+	return verdict, reason + productionNote("project", project)
+}
+
+func evaluateGcloudCommand(path, rest, args []string, workDir string) (Verdict, string) {
+	name := "gcloud " + strings.Join(path, " ")
+	verb := path[len(path)-1]
+	groups := strings.Join(stripReleaseTrack(path[:len(path)-1]), " ")
+
+	switch {
+	case groups == "storage" || strings.HasPrefix(groups, "storage "):
+		return evaluateGcloudStorage(name, verb, rest, args, workDir)
+	case groups == "secrets versions" && verb == "access":
+		return VerdictAsk, name + " (reads secret value)"
+	case groups == "auth" || groups == "auth application-default":
+		switch verb {
+		case "list", "describe":
+			return VerdictAllow, name
+		case "print-access-token", "print-identity-token":
+			return VerdictAsk, name + " (exposes credentials)"
+		}
+		return VerdictUncertain, name + " (changes credentials)"
+	case groups == "config" || strings.HasPrefix(groups, "config "):
+		if gcloudReadVerbs[verb] {
+			return VerdictAllow, name
+		}
+		return VerdictUncertain, name + " (changes local gcloud config)"
+	case groups == "container clusters" && verb == "get-credentials":
+		return VerdictUncertain, name + " (changes local kubeconfig)"
+	case groups == "kms" && (verb == "decrypt" || verb == "asymmetric-decrypt"):
+		return VerdictAsk, name + " (uses KMS key material)"
+	}
+
+	switch {
+	case gcloudReadVerbs[verb], strings.HasPrefix(verb, "list-"), strings.HasPrefix(verb, "describe-"),
+		strings.HasPrefix(verb, "get-"):
+		return VerdictAllow, name
+	case gcloudWriteVerbs[verb], isGcloudVerbLike(verb):
+		return VerdictAsk, name
+	}
+	return VerdictUncertain, name
+}
+
+func stripReleaseTrack(groups []string) []string {
+	if len(groups) > 0 && (groups[0] == "alpha" || groups[0] == "beta") {
+		return groups[1:]
+	}
+	return groups
+}
+
+func isGCSURI(s string) bool {
+	return strings.HasPrefix(s, "gs://")
+}
+
+func evaluateGcloudStorage(name, verb string, rest, args []string, workDir string) (Verdict, string) {
+	pos := positionalArgs(rest, gcloudValueFlags)
+	switch verb {
+	case "ls", "cat", "du", "hash", "list", "describe":
+		return VerdictAllow, name
+	case "sign-url":
+		return VerdictUncertain, name + " (shares object by URL)"
+	case "rm", "delete", "create", "update", "restore":
+		return VerdictAsk, name + " " + strings.Join(firstN(pos, 1), " ")
+	case "cp", "mv", "rsync":
+		if len(pos) < 2 {
+			return VerdictUncertain, name
+		}
+		deletes := hasFlag(args, "--delete-unmatched-destination-objects")
+		return copyDirectionVerdict(name, verb, pos[0], pos[len(pos)-1], deletes, isGCSURI, workDir)
+	}
+	return VerdictAsk, name
+}
+
+// gsutilValueFlags are gsutil top-level and subcommand options with values.
+var gsutilValueFlags = map[string]bool{
+	"-o": true, "-h": true, "-u": true, "-p": true, "-x": true, "-z": true, "-L": true,
+}
+
+func evaluateGsutil(args []string, workDir string) (Verdict, string) {
+	pos := positionalArgs(args, gsutilValueFlags)
+	if len(pos) == 0 {
+		return VerdictUncertain, "gsutil (no command)"
+	}
+
+	subCmd := pos[0]
+	name := "gsutil " + subCmd
+	switch subCmd {
+	case "ls", "cat", "du", "stat", "hash", "version", "help":
+		return VerdictAllow, name
+	case "signurl":
+		return VerdictUncertain, name + " (shares object by URL)"
+	case "rm", "rb", "mb", "setmeta", "defacl", "defstorageclass", "rewrite", "retention", "compose":
+		return VerdictAsk, name + " " + strings.Join(firstN(pos[1:], 1), " ")
+	case "acl", "iam", "lifecycle", "cors", "versioning", "web", "logging", "label", "notification", "kms":
+		if len(pos) > 1 && pos[1] == "get" {
+			return VerdictAllow, name + " get"
+		}
+		return VerdictAsk, name + " " + strings.Join(firstN(pos[1:], 1), " ")
+	case "cp", "mv", "rsync":
+		if len(pos) < 3 {
+			return VerdictUncertain, name
+		}
+		// gsutil rsync -d deletes destination objects missing from the source
+		deletes := subCmd == "rsync" && hasShortFlag(args, 'd')
+		return copyDirectionVerdict(name, subCmd, pos[1], pos[len(pos)-1], deletes, isGCSURI, workDir)
+	}
+	return VerdictUncertain, name
+}
+
+// hasShortFlag reports whether a single-letter flag appears on its own or in
+// a combined group like -rd.
+func hasShortFlag(args []string, flag rune) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.ContainsRune(arg[1:], flag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluateGcloud(t *testing.T) {
+	workDir := "/Users/victor/projects/app"
+
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		{"filter value is not the verb", "gcloud compute instances list --filter=name:delete", VerdictAllow},
+		{"filter value spaced", "gcloud compute instances list --filter delete", VerdictAllow},
+		{"flags before path", "gcloud --project my-proj --format=json compute instances list", VerdictAllow},
+		{"resource named like verb", "gcloud compute instances describe delete", VerdictAllow},
+		{"run services list", "gcloud run services list", VerdictAllow},
+		{"run deploy", "gcloud run deploy api --image gcr.io/p/api", VerdictAsk},
+		{"beta track", "gcloud beta compute instances delete vm", VerdictAsk},
+		{"iam binding", "gcloud projects add-iam-policy-binding p --member user:a --role roles/owner", VerdictAsk},
+		{"logging read", "gcloud logging read 'severity>=ERROR' --limit 10", VerdictAllow},
+		{"secret access", "gcloud secrets versions access latest --secret db-pass", VerdictAsk},
+		{"secret list", "gcloud secrets list", VerdictAllow},
+		{"print access token", "gcloud auth print-access-token", VerdictAsk},
+		{"adc print token", "gcloud auth application-default print-access-token", VerdictAsk},
+		{"auth list", "gcloud auth list", VerdictAllow},
+		{"auth login", "gcloud auth login", VerdictUncertain},
+		{"config get", "gcloud config get-value project", VerdictAllow},
+		{"config set", "gcloud config set project other", VerdictUncertain},
+		{"get credentials", "gcloud container clusters get-credentials c --region r", VerdictUncertain},
+		{"version", "gcloud --version", VerdictAllow},
+
+		// ===== gcloud storage =====
+		{"storage ls", "gcloud storage ls gs://bucket", VerdictAllow},
+		{"storage cat", "gcloud storage cat gs://bucket/a.txt", VerdictAllow},
+		{"storage download", "gcloud storage cp gs://bucket/a.txt .", VerdictAllow},
+		{"storage upload", "gcloud storage cp -r ./dist gs://bucket/site", VerdictAsk},
+		{"storage rm", "gcloud storage rm -r gs://bucket/dir", VerdictAsk},
+		{"storage rsync delete", "gcloud storage rsync ./dist gs://bucket --delete-unmatched-destination-objects", VerdictAsk},
+		{"storage buckets delete", "gcloud storage buckets delete gs://bucket", VerdictAsk},
+
+		// ===== gsutil =====
+		{"gsutil ls", "gsutil ls -l gs://bucket", VerdictAllow},
+		{"gsutil download", "gsutil -m cp -r gs://bucket/dir .", VerdictAllow},
+		{"gsutil download to bashrc", "gsutil cp gs://bucket/rc ~/.bashrc", VerdictAsk},
+		{"gsutil upload", "gsutil cp file.txt gs://bucket/", VerdictAsk},
+		{"gsutil rm", "gsutil rm -r gs://bucket/dir", VerdictAsk},
+		{"gsutil rsync delete", "gsutil -m rsync -rd ./dist gs://bucket", VerdictAsk},
+		{"gsutil rsync delete local", "gsutil rsync -d gs://bucket ./dist", VerdictUncertain},
+		{"gsutil acl get", "gsutil acl get gs://bucket", VerdictAllow},
+		{"gsutil acl ch", "gsutil acl ch -u AllUsers:R gs://bucket/a", VerdictAsk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestGcloudProjectPolicy(t *testing.T) {
+	saved := activePolicy
+	defer func() { activePolicy = saved }()
+	activePolicy = defaultPolicy()
+	activePolicy.GcloudAskProjects = []string{"acme-prod*"}
+
+	tests := []struct {
+		name     string
+		command  string
+		want     Verdict
+		contains string
+	}{
+		{"read in ask project", "gcloud compute instances list --project acme-prod-1", VerdictAsk, "always asks"},
+		{"env project", "CLOUDSDK_CORE_PROJECT=acme-prod gcloud sql instances list", VerdictAsk, "always asks"},
+		{"other project", "gcloud compute instances list --project=acme-dev", VerdictAllow, "gcloud compute instances list"},
+		{"production note", "gcloud run deploy api --project prod", VerdictAsk, "[production project: prod]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, "/tmp")
+			if got != tt.want || !strings.Contains(reason, tt.contains) {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v containing %q", tt.command, got, reason, tt.want, tt.contains)
+			}
+		})
+	}
+}
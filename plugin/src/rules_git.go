@@ -0,0 +1,396 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- git handler ---
+//
+// Everyday git is allowed, including force pushes to feature branches.
+// Pushes are parsed refspec by refspec so that every way of force-updating or
+// deleting a protected branch (+main, :main, HEAD:refs/heads/main, --mirror)
+// asks. History rewriting, reflog and object pruning ask because they destroy
+// recovery points, and config keys that make git run programs ask because
+// they turn a later harmless command into arbitrary code execution.
+
+func evaluateGit(args []string) (Verdict, string) {
+	// Global options: git -C dir -c key=value --no-pager <subcommand>
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		option, value, inline := splitFlagValue(args[i])
+		switch option {
+		case "-C", "-c", "--git-dir", "--work-tree", "--namespace", "--exec-path":
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			if option == "-c" {
+				key, val, _ := strings.Cut(value, "=")
+				if reason := gitConfigRunsCode(key, val); reason != "" {
+					return VerdictAsk, "git -c " + key + " (" + reason + ")"
+				}
+			}
+		case "--version", "--help":
+			return VerdictAllow, "git " + option
+		}
+	}
+	args = args[i:]
+	if len(args) == 0 {
+		return VerdictAllow, "git (no subcommand)"
+	}
+
+	subCmd := args[0]
+	rest := args[1:]
+
+	safeSubcmds := map[string]bool{
+		"status": true, "diff": true, "log": true, "show": true,
+		"branch": true, "fetch": true, "stash": true, "add": true,
+		"commit": true, "pull": true, "clone": true, "checkout": true,
+		"rebase": true, "merge": true, "cherry-pick": true, "tag": true,
+		"remote": true, "rev-parse": true, "ls-files": true,
+		"init": true, "worktree": true, "bisect": true,
+		"blame": true, "shortlog": true, "describe": true, "clean": true,
+		"reset": true, "switch": true, "restore": true, "mv": true, "rm": true,
+		"grep": true, "ls-remote": true, "ls-tree": true, "cat-file": true,
+		"rev-list": true, "show-ref": true, "for-each-ref": true, "revert": true,
+		"range-diff": true, "format-patch": true, "apply": true, "am": true,
+		"notes": true, "sparse-checkout": true, "check-ignore": true,
+		"merge-base": true, "name-rev": true, "whatchanged": true,
+	}
+	if safeSubcmds[subCmd] {
+		return VerdictAllow, "git " + subCmd
+	}
+
+	switch subCmd {
+	case "push":
+		return evaluateGitPush(rest)
+	case "config":
+		return evaluateGitConfig(rest)
+	case "filter-branch", "filter-repo":
+		return VerdictAsk, "git " + subCmd + " (rewrites history)"
+	case "update-ref":
+		if hasFlag(rest, "-d", "--stdin") {
+			return VerdictAsk, "git update-ref " + rest[0] + " (rewrites refs directly)"
+		}
+		return VerdictUncertain, "git update-ref"
+	case "reflog":
+		if len(rest) > 0 && (rest[0] == "expire" || rest[0] == "delete") {
+			return VerdictAsk, "git reflog " + rest[0] + " (removes recovery points)"
+		}
+		return VerdictAllow, "git reflog"
+	case "gc":
+		if hasFlag(rest, "--prune") && (flagValue(rest, "--prune") == "now" || flagValue(rest, "--prune") == "all") {
+			return VerdictAsk, "git gc --prune=" + flagValue(rest, "--prune") + " (deletes unreachable objects)"
+		}
+		return VerdictAllow, "git gc"
+	case "prune":
+		if hasFlag(rest, "-n", "--dry-run") {
+			return VerdictAllow, "git prune --dry-run"
+		}
+		return VerdictAsk, "git prune (deletes unreachable objects)"
+	case "submodule":
+		action := ""
+		for _, arg := range rest {
+			if !strings.HasPrefix(arg, "-") {
+				action = arg
+				break
+			}
+		}
+		switch action {
+		case "foreach":
+			return VerdictUncertain, "git submodule foreach runs a command"
+		case "update":
+			return VerdictUncertain, "git submodule update (checks out and can run submodule.<name>.update commands)"
+		}
+		return VerdictAllow, "git submodule"
+	}
+
+	return VerdictUncertain, "git " + subCmd
+}
+
+// gitPushRef is one ref update of a push.
+type gitPushRef struct {
+	src, dst string
+	force    bool
+	delete   bool
+}
+
+// parseRefspec parses [+]<src>[:<dst>]. A refspec without a colon pushes src
+// to the branch of the same name; an empty src deletes dst.
+func parseRefspec(spec string, force, deleteAll bool) gitPushRef {
+	ref := gitPushRef{force: force}
+	if strings.HasPrefix(spec, "+") {
+		ref.force = true
+		spec = spec[1:]
+	}
+	if deleteAll {
+		ref.dst = spec
+		ref.delete = true
+		return ref
+	}
+	if src, dst, ok := strings.Cut(spec, ":"); ok {
+		ref.src, ref.dst = src, dst
+		ref.delete = src == ""
+		if dst == "" {
+			ref.dst = src // "src:" pushes to the matching name
+		}
+		return ref
+	}
+	ref.src, ref.dst = spec, spec
+	return ref
+}
+
+// branchName reduces a ref to a branch name, or "" for tags and other refs.
+func branchName(ref string) string {
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return strings.TrimPrefix(ref, "refs/heads/")
+	case strings.HasPrefix(ref, "refs/"):
+		return ""
+	}
+	return ref
+}
+
+func isProtectedBranch(name string) bool {
+	return matchesAnyPattern(name, currentPolicy().ProtectedBranches)
+}
+
+// globMatchesProtectedBranch reports whether a refspec glob like * or
+// release/* can match a protected branch. A refspec * also matches slashes.
+func globMatchesProtectedBranch(glob string) bool {
+	prefix, suffix, ok := strings.Cut(strings.ToLower(glob), "*")
+	if !ok {
+		return false
+	}
+	for _, pattern := range currentPolicy().ProtectedBranches {
+		pattern = strings.ToLower(pattern)
+		if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+			if literal := pattern[:i]; strings.HasPrefix(literal, prefix) || strings.HasPrefix(prefix, literal) {
+				return true
+			}
+			continue
+		}
+		if len(pattern) >= len(prefix)+len(suffix) && strings.HasPrefix(pattern, prefix) && strings.HasSuffix(pattern, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateGitPush(args []string) (Verdict, string) {
+	isForce, isDelete, mirror, all, prune := false, false, false, false, false
+	forceFlag := "" // as written, for reasons
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--force" || arg == "--force-with-lease" || strings.HasPrefix(arg, "--force-with-lease="):
+			isForce = true
+			forceFlag, _, _ = strings.Cut(arg, "=")
+		case arg == "--delete":
+			isDelete = true
+		case arg == "--mirror":
+			mirror = true
+		case arg == "--all" || arg == "--branches":
+			all = true
+		case arg == "--prune":
+			prune = true
+		case arg == "--dry-run" || arg == "-n":
+			return VerdictAllow, "git push --dry-run"
+		case arg == "-o" || arg == "--push-option" || arg == "--repo" || arg == "--receive-pack" || arg == "--exec":
+			i++
+		case strings.HasPrefix(arg, "--"):
+			// other long flags
+		case strings.HasPrefix(arg, "-"):
+			// short flags, possibly combined (-fu)
+			if strings.Contains(arg, "f") && !isForce {
+				isForce, forceFlag = true, "-f"
+			}
+			isDelete = isDelete || strings.Contains(arg, "d")
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	if mirror {
+		return VerdictAsk, "git push --mirror (overwrites and deletes every remote ref)"
+	}
+	if all && (isForce || prune) {
+		return askOrDeny(CategoryProtectedBranch), "git push --all with force or prune (includes protected branches)"
+	}
+	if prune {
+		return VerdictUncertain, "git push --prune deletes remote branches"
+	}
+
+	var refspecs []string
+	if len(positional) > 1 {
+		refspecs = positional[1:]
+	}
+
+	anyForce := isForce
+	for _, spec := range refspecs {
+		ref := parseRefspec(spec, isForce, isDelete)
+		if !ref.force && !ref.delete {
+			continue
+		}
+		anyForce = true
+		// A + refspec forces on its own; otherwise name the flag used
+		push := "git push " + forceFlag
+		if !isForce {
+			push = "git push " + spec
+		}
+		if ref.dst == "HEAD" {
+			return VerdictUncertain, push + " to HEAD (current branch unknown)"
+		}
+		branch := branchName(ref.dst)
+		if branch != "" && (isProtectedBranch(branch) || globMatchesProtectedBranch(branch)) {
+			if ref.delete {
+				return askOrDeny(CategoryProtectedBranch), "git push deletes protected branch " + branch
+			}
+			reason := push + " to protected branch " + branch
+			if isForce && spec != branch {
+				reason += " (" + spec + ")"
+			}
+			return askOrDeny(CategoryProtectedBranch), reason
+		}
+	}
+
+	// No force, no delete → always safe
+	if !anyForce && !isDelete {
+		return VerdictAllow, "git push (no force)"
+	}
+
+	// Force/delete with explicit non-protected refspecs
+	if len(refspecs) > 0 {
+		return VerdictAllow, "git push to non-protected branch"
+	}
+
+	// Force push without explicit branch → uncertain (could be on main)
+	if isForce {
+		return VerdictUncertain, "git push " + forceFlag + " without explicit branch"
+	}
+
+	return VerdictUncertain, "git push --delete without explicit target"
+}
+
+// gitConfigReadFlags only read configuration.
+var gitConfigReadFlags = map[string]bool{
+	"--get": true, "--get-all": true, "--get-regexp": true, "--get-urlmatch": true,
+	"--list": true, "-l": true, "--show-origin": true, "--show-scope": true,
+	"--get-color": true, "--get-colorbool": true,
+}
+
+func evaluateGitConfig(args []string) (Verdict, string) {
+	scope := "local"
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case gitConfigReadFlags[arg]:
+			return VerdictAllow, "git config " + arg
+		case arg == "-e" || arg == "--edit":
+			return gitConfigEdit(arg)
+		case arg == "--global" || arg == "--system":
+			scope = strings.TrimPrefix(arg, "--")
+		case arg == "-f" || arg == "--file" || arg == "--blob" || arg == "--type" || arg == "--default":
+			i++
+		case strings.HasPrefix(arg, "-"):
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	// git config get/list (new style) and git config <key> read
+	if len(positional) > 0 && (positional[0] == "get" || positional[0] == "list") {
+		return VerdictAllow, "git config " + positional[0]
+	}
+	if len(positional) > 0 && positional[0] == "edit" {
+		return gitConfigEdit("edit")
+	}
+	if len(positional) > 0 && (positional[0] == "set" || positional[0] == "unset") {
+		positional = positional[1:]
+	}
+	if len(positional) < 2 && !hasFlag(args, "--unset", "--unset-all", "--add", "--replace-all") {
+		return VerdictAllow, "git config read"
+	}
+	if len(positional) == 0 {
+		return VerdictUncertain, "git config"
+	}
+
+	key := positional[0]
+	value := strings.Join(positional[1:], " ")
+	if reason := gitConfigRunsCode(key, value); reason != "" {
+		return VerdictAsk, "git config " + key + " (" + reason + ")"
+	}
+	if scope != "local" {
+		return VerdictUncertain, "git config --" + scope + " " + key
+	}
+	return VerdictAllow, "git config " + key
+}
+
+// gitConfigEdit rates git config --edit, which runs $GIT_EDITOR on the
+// config file: the editor can set any key, including those that run programs.
+func gitConfigEdit(arg string) (Verdict, string) {
+	return VerdictAsk, "git config " + arg + " (runs the editor on the config, which can set any key)"
+}
+
+// gitConfigRunsCode reports why setting a config key makes git run a
+// program, or "" if it doesn't.
+func gitConfigRunsCode(key, value string) string {
+	key = strings.ToLower(key)
+	section, rest, _ := strings.Cut(key, ".")
+	name := rest
+	if i := strings.LastIndex(rest, "."); i >= 0 {
+		name = rest[i+1:]
+	}
+
+	switch key {
+	case "core.hookspath", "core.sshcommand", "core.pager", "core.editor",
+		"core.fsmonitor", "core.gitproxy", "core.askpass", "sequence.editor",
+		"diff.external", "credential.helper", "gpg.program", "uploadpack.packobjectshook",
+		"pager.log", "pager.diff", "pager.show", "web.browser",
+		"instaweb.httpd", "sendemail.smtpserver":
+		return "runs a program"
+	}
+
+	switch section {
+	case "alias":
+		if strings.HasPrefix(strings.TrimSpace(value), "!") {
+			return "shell alias"
+		}
+	case "filter":
+		if name == "clean" || name == "smudge" || name == "process" {
+			return "filter driver runs a program"
+		}
+	case "diff", "merge":
+		if name == "textconv" || name == "command" || name == "driver" {
+			return "external driver runs a program"
+		}
+	case "difftool", "mergetool":
+		if name == "cmd" || name == "path" {
+			return "external tool runs a program"
+		}
+	case "submodule":
+		if name == "update" && strings.HasPrefix(strings.TrimSpace(value), "!") {
+			return "submodule update runs a command"
+		}
+	case "gpg", "credential", "pager":
+		if name == "program" || name == "helper" || section == "pager" {
+			return "runs a program"
+		}
+	case "include", "includeif":
+		if name == "path" {
+			return "includes another config file"
+		}
+	case "remote":
+		if name == "uploadpack" || name == "receivepack" {
+			return "runs a program on fetch/push"
+		}
+	case "protocol":
+		if value == "always" {
+			return "enables a transport protocol"
+		}
+	}
+	return ""
+}
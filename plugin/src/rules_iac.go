@@ -0,0 +1,307 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- Infrastructure-as-code handlers ---
+//
+// Planning and inspection are local or read-only and allowed. Anything that
+// changes real infrastructure or state asks. Names of workspaces, stacks and
+// stages that match the policy's production patterns are called out in the
+// reason so the user sees what the change is aimed at.
+
+// productionNote returns a reason suffix naming the production target, if any.
+// Org-qualified names (acme/prod) are matched on their last segment too.
+func productionNote(kind string, names ...string) string {
+	for _, name := range names {
+		if currentPolicy().isProduction(name) || currentPolicy().isProduction(name[strings.LastIndex(name, "/")+1:]) {
+			note(noteProfile, profileProduction)
+			return " [production " + kind + ": " + name + "]"
+		}
+	}
+	return ""
+}
+
+// splitFlagValue splits --flag=value into its parts.
+func splitFlagValue(arg string) (string, string, bool) {
+	if i := strings.Index(arg, "="); i > 0 && strings.HasPrefix(arg, "-") {
+		return arg[:i], arg[i+1:], true
+	}
+	return arg, "", false
+}
+
+// flagValue returns the value of the first matching flag, written either as
+// "--flag value" or "--flag=value".
+func flagValue(args []string, names ...string) string {
+	for i, arg := range args {
+		flag, value, hasValue := splitFlagValue(arg)
+		for _, name := range names {
+			if flag != name {
+				continue
+			}
+			if hasValue {
+				return value
+			}
+			if i+1 < len(args) {
+				return args[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+func hasFlag(args []string, names ...string) bool {
+	for _, arg := range args {
+		flag, _, _ := splitFlagValue(arg)
+		for _, name := range names {
+			if flag == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// positionalArgs returns the non-flag arguments. Flags listed in withValue
+// consume the following argument unless written as --flag=value.
+func positionalArgs(args []string, withValue map[string]bool) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			if _, _, inline := splitFlagValue(arg); !inline && withValue[arg] {
+				i++
+			}
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
+
+func evaluateTerraform(cmd string, args []string, env map[string]string, workDir string) (Verdict, string) {
+	dir := workDir
+	i := 0
+	for ; i < len(args) && strings.HasPrefix(args[i], "-"); i++ {
+		if flag, value, ok := splitFlagValue(args[i]); ok && flag == "-chdir" {
+			dir = resolvePath(value, workDir)
+		}
+		if args[i] == "-version" || args[i] == "-help" || args[i] == "--version" || args[i] == "--help" {
+			return VerdictAllow, cmd + " " + args[i]
+		}
+	}
+	if i >= len(args) {
+		return VerdictAllow, cmd + " (no subcommand)"
+	}
+
+	subCmd := args[i]
+	rest := args[i+1:]
+	note := productionNote("workspace", terraformWorkspace(env, dir))
+
+	safeSubcmds := map[string]bool{
+		"plan": true, "validate": true, "fmt": true, "show": true,
+		"graph": true, "output": true, "init": true, "version": true,
+		"providers": true, "get": true, "console": true, "modules": true,
+		"metadata": true,
+	}
+	if subCmd == "init" && hasFlag(rest, "-migrate-state", "--migrate-state", "-force-copy", "--force-copy") {
+		return VerdictAsk, cmd + " init moving state to a new backend" + note
+	}
+	if safeSubcmds[subCmd] {
+		return VerdictAllow, cmd + " " + subCmd + note
+	}
+
+	switch subCmd {
+	case "workspace", "env":
+		if len(rest) > 0 && rest[0] == "delete" {
+			return VerdictAsk, cmd + " workspace delete" + productionNote("workspace", lastArg(rest))
+		}
+		return VerdictAllow, cmd + " workspace"
+	case "state":
+		if len(rest) > 0 && (rest[0] == "list" || rest[0] == "show" || rest[0] == "pull") {
+			return VerdictAllow, cmd + " state " + rest[0] + note
+		}
+		return VerdictAsk, strings.TrimSpace(cmd+" state "+strings.Join(firstN(rest, 1), " ")) + note
+	case "apply", "destroy", "import", "taint", "untaint", "refresh",
+		"force-unlock", "test", "login", "logout":
+		reason := cmd + " " + subCmd
+		if hasFlag(rest, "-auto-approve", "--auto-approve") {
+			reason += " with -auto-approve (no confirmation)"
+		}
+		return VerdictAsk, reason + note
+	}
+
+	return VerdictUncertain, cmd + " " + subCmd
+}
+
+// terraformWorkspace returns the selected workspace: TF_WORKSPACE wins, then
+// the .terraform/environment file written by `terraform workspace select`.
+func terraformWorkspace(env map[string]string, dir string) string {
+	if ws := envValue(env, "TF_WORKSPACE"); ws != "" {
+		return ws
+	}
+	dependOnFile(filepath.Join(dir, ".terraform", "environment"))
+	data, err := os.ReadFile(filepath.Join(dir, ".terraform", "environment"))
+	if err != nil {
+		return ""
+	}
+	noteInputs("terraform-workspace", string(data))
+	return strings.TrimSpace(string(data))
+}
+
+func evaluatePulumi(args []string) (Verdict, string) {
+	pos := positionalArgs(args, map[string]bool{
+		"--stack": true, "-s": true, "--cwd": true, "-C": true,
+		"--config-file": true, "--color": true, "--target": true, "-t": true,
+	})
+	if len(pos) == 0 {
+		return VerdictAllow, "pulumi (no subcommand)"
+	}
+
+	subCmd := pos[0]
+	note := productionNote("stack", flagValue(args, "--stack", "-s"))
+
+	switch subCmd {
+	case "preview", "pre", "version", "whoami", "about", "logs", "plugin",
+		"login", "new", "convert", "schema", "gen-completion", "watch-logs":
+		return VerdictAllow, "pulumi " + subCmd + note
+	case "config":
+		if len(pos) > 1 && (pos[1] == "rm" || pos[1] == "rm-all") {
+			return VerdictUncertain, "pulumi config " + pos[1] + note
+		}
+		return VerdictAllow, "pulumi config" + note
+	case "stack":
+		if len(pos) > 1 {
+			switch pos[1] {
+			case "rm", "import", "change-secrets-provider", "rename":
+				return VerdictAsk, "pulumi stack " + pos[1] + productionNote("stack", lastArg(pos))
+			}
+		}
+		return VerdictAllow, "pulumi stack" + note
+	case "up", "update", "destroy", "refresh", "import", "cancel", "state", "down", "watch":
+		reason := "pulumi " + subCmd
+		if hasFlag(args, "--yes", "-y", "--skip-preview", "-f") {
+			reason += " without preview confirmation"
+		}
+		return VerdictAsk, reason + note
+	}
+
+	return VerdictUncertain, "pulumi " + subCmd
+}
+
+func evaluateCdk(cmd string, args []string) (Verdict, string) {
+	pos := positionalArgs(args, map[string]bool{
+		"--app": true, "-a": true, "--context": true, "-c": true,
+		"--profile": true, "--output": true, "-o": true, "--require-approval": true,
+		"--role-arn": true, "-r": true, "--parameters": true, "--plugin": true,
+	})
+	if len(pos) == 0 {
+		return VerdictAllow, cmd + " (no subcommand)"
+	}
+
+	subCmd := pos[0]
+	stacks := pos[1:]
+	note := productionNote("stack", stacks...)
+
+	safeSubcmds := map[string]bool{
+		"synth": true, "synthesize": true, "diff": true, "ls": true, "list": true,
+		"doctor": true, "context": true, "docs": true, "acknowledge": true,
+		"notices": true, "init": true, "metadata": true, "get": true,
+		"output": true, "outputs": true, "provider": true, "convert": true,
+		"version": true, "help": true,
+	}
+	if safeSubcmds[subCmd] {
+		return VerdictAllow, cmd + " " + subCmd + note
+	}
+
+	switch subCmd {
+	case "deploy", "destroy", "bootstrap", "import", "watch", "migrate",
+		"gc", "rollback", "apply", "refactor":
+		reason := cmd + " " + subCmd
+		if flagValue(args, "--require-approval") == "never" || hasFlag(args, "--auto-approve", "--force", "-f") {
+			reason += " without approval prompt"
+		}
+		return VerdictAsk, reason + note
+	}
+
+	return VerdictUncertain, cmd + " " + subCmd
+}
+
+func evaluateAnsiblePlaybook(args []string) (Verdict, string) {
+	note := productionNote("inventory",
+		inventoryName(flagValue(args, "-i", "--inventory", "--inventory-file")),
+		flagValue(args, "-l", "--limit"))
+
+	for _, arg := range args {
+		switch arg {
+		case "--syntax-check", "--list-tasks", "--list-hosts", "--list-tags", "--version", "-h", "--help":
+			return VerdictAllow, "ansible-playbook " + arg + note
+		}
+	}
+	if hasFlag(args, "--check", "-C") {
+		return VerdictAllow, "ansible-playbook --check (dry run)" + note
+	}
+
+	return VerdictAsk, "ansible-playbook (applies changes to hosts)" + note
+}
+
+// inventoryName reduces an inventory path like inventories/prod/hosts.ini to
+// the name that identifies the environment.
+func inventoryName(inventory string) string {
+	if inventory == "" {
+		return ""
+	}
+	inventory = strings.TrimSuffix(inventory, "/")
+	base := filepath.Base(inventory)
+	switch strings.TrimSuffix(base, filepath.Ext(base)) {
+	case "hosts", "inventory", "main":
+		return filepath.Base(filepath.Dir(inventory))
+	}
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+func evaluateServerless(cmd string, args []string) (Verdict, string) {
+	pos := positionalArgs(args, map[string]bool{
+		"--stage": true, "-s": true, "--region": true, "-r": true,
+		"--function": true, "-f": true, "--config": true, "-c": true,
+		"--aws-profile": true, "--data": true, "-d": true, "--path": true, "-p": true,
+	})
+	if len(pos) == 0 {
+		return VerdictAllow, cmd + " (no subcommand)"
+	}
+
+	subCmd := pos[0]
+	note := productionNote("stage", flagValue(args, "--stage", "-s"))
+
+	switch subCmd {
+	case "package", "print", "info", "logs", "metrics", "doctor", "offline",
+		"create", "install", "plugin", "config", "login", "dev", "help", "version":
+		return VerdictAllow, cmd + " " + subCmd + note
+	case "invoke":
+		if len(pos) > 1 && pos[1] == "local" {
+			return VerdictAllow, cmd + " invoke local" + note
+		}
+		return VerdictAsk, cmd + " invoke (runs deployed function)" + note
+	case "deploy", "remove", "rollback":
+		return VerdictAsk, cmd + " " + strings.Join(firstN(pos, 2), " ") + note
+	}
+
+	return VerdictUncertain, cmd + " " + subCmd
+}
+
+func firstN(s []string, n int) []string {
+	if len(s) < n {
+		return s
+	}
+	return s[:n]
+}
+
+func lastArg(s []string) string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[len(s)-1]
+}
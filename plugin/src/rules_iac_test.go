@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateIaC(t *testing.T) {
+	workDir := "/Users/victor/projects/infra"
+
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		// ===== terraform / tofu =====
+		{"tf plan", "terraform plan -out=tfplan", VerdictAllow},
+		{"tf validate", "terraform validate", VerdictAllow},
+		{"tf fmt", "terraform fmt -recursive", VerdictAllow},
+		{"tf show", "terraform show tfplan", VerdictAllow},
+		{"tf output", "terraform output -json", VerdictAllow},
+		{"tf init chdir", "terraform -chdir=envs/dev init", VerdictAllow},
+		{"tf state list", "terraform state list", VerdictAllow},
+		{"tf workspace select", "terraform workspace select dev", VerdictAllow},
+		{"tf apply", "terraform apply tfplan", VerdictAsk},
+		{"tf apply auto-approve", "terraform apply -auto-approve", VerdictAsk},
+		{"tf destroy", "terraform destroy", VerdictAsk},
+		{"tf import", "terraform import aws_s3_bucket.b my-bucket", VerdictAsk},
+		{"tf state rm", "terraform state rm aws_s3_bucket.b", VerdictAsk},
+		{"tf state mv", "terraform state mv a b", VerdictAsk},
+		{"tf taint", "terraform taint aws_instance.web", VerdictAsk},
+		{"tf refresh", "terraform refresh", VerdictAsk},
+		{"tf init migrate state", "terraform init -migrate-state", VerdictAsk},
+		{"tf init force copy", "terraform init -reconfigure -force-copy", VerdictAsk},
+		{"tf workspace delete", "terraform workspace delete staging", VerdictAsk},
+		{"tofu plan", "tofu plan", VerdictAllow},
+		{"tofu apply", "tofu apply", VerdictAsk},
+
+		// ===== pulumi =====
+		{"pulumi preview", "pulumi preview --stack dev", VerdictAllow},
+		{"pulumi stack ls", "pulumi stack ls", VerdictAllow},
+		{"pulumi stack output", "pulumi stack output url", VerdictAllow},
+		{"pulumi up", "pulumi up --stack dev", VerdictAsk},
+		{"pulumi up yes", "pulumi up --yes", VerdictAsk},
+		{"pulumi destroy", "pulumi destroy -s prod", VerdictAsk},
+		{"pulumi watch", "pulumi watch --stack dev", VerdictAsk},
+		{"pulumi stack rm", "pulumi stack rm dev", VerdictAsk},
+
+		// ===== cdk / cdktf =====
+		{"cdk synth", "cdk synth", VerdictAllow},
+		{"cdk diff", "cdk diff MyStack", VerdictAllow},
+		{"cdk deploy", "cdk deploy MyStack", VerdictAsk},
+		{"cdk deploy no approval", "cdk deploy --require-approval never", VerdictAsk},
+		{"cdk destroy", "cdk destroy --force", VerdictAsk},
+		{"cdk bootstrap", "cdk bootstrap", VerdictAsk},
+		{"cdktf synth", "cdktf synth", VerdictAllow},
+		{"cdktf deploy", "cdktf deploy --auto-approve", VerdictAsk},
+
+		// ===== ansible =====
+		{"ansible check", "ansible-playbook -i inventories/prod site.yml --check", VerdictAllow},
+		{"ansible syntax", "ansible-playbook site.yml --syntax-check", VerdictAllow},
+		{"ansible list hosts", "ansible-playbook site.yml --list-hosts", VerdictAllow},
+		{"ansible run", "ansible-playbook -i hosts.ini site.yml", VerdictAsk},
+
+		// ===== serverless =====
+		{"sls package", "serverless package --stage dev", VerdictAllow},
+		{"sls invoke local", "sls invoke local -f hello", VerdictAllow},
+		{"sls deploy", "serverless deploy --stage dev", VerdictAsk},
+		{"sls remove", "sls remove", VerdictAsk},
+		{"sls invoke", "sls invoke -f hello", VerdictAsk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestIaCProductionReasons(t *testing.T) {
+	workDir := t.TempDir()
+	os.MkdirAll(filepath.Join(workDir, ".terraform"), 0755)
+	os.WriteFile(filepath.Join(workDir, ".terraform", "environment"), []byte("prod\n"), 0644)
+
+	tests := []struct {
+		name     string
+		command  string
+		want     Verdict
+		contains string
+	}{
+		{"tf workspace file", "terraform apply", VerdictAsk, "production workspace: prod"},
+		{"tf plan in prod still allowed", "terraform plan", VerdictAllow, "production workspace: prod"},
+		{"TF_WORKSPACE", "TF_WORKSPACE=eu-production terraform destroy", VerdictAsk, "production workspace: eu-production"},
+		{"pulumi org stack", "pulumi up -s acme/prod", VerdictAsk, "production stack: acme/prod"},
+		{"pulumi prod stack", "pulumi up --stack=prod", VerdictAsk, "production stack: prod"},
+		{"cdk stack", "cdk deploy api-prod", VerdictAsk, "production stack: api-prod"},
+		{"sls stage", "sls deploy --stage production", VerdictAsk, "production stage: production"},
+		{"ansible inventory", "ansible-playbook -i inventories/prod/hosts.ini site.yml", VerdictAsk, "production inventory: prod"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want || !strings.Contains(reason, tt.contains) {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v containing %q", tt.command, got, reason, tt.want, tt.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- kubectl / helm handlers ---
+//
+// Decisions depend on where the command lands. The effective context comes
+// from --context/--kube-context, then the current-context of the kubeconfig
+// (--kubeconfig, KUBECONFIG or ~/.kube/config); the namespace from -n, then
+// the context's default namespace. The first matching kube_contexts rule in
+// the policy picks a profile:
+//
+//	permissive - local clusters: changes are allowed, cluster-wide deletes uncertain
+//	default    - reads and pod deletes allowed, other changes ask
+//	strict     - production: every change asks, including pod deletes
+
+// Kubernetes policy profiles.
+const (
+	KubeProfilePermissive = "permissive"
+	KubeProfileDefault    = "default"
+	KubeProfileStrict     = "strict"
+)
+
+// KubeContextRule assigns a profile to contexts and namespaces matching glob
+// patterns. An empty pattern matches everything.
+type KubeContextRule struct {
+	Context   string `json:"context,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Profile   string `json:"profile"`
+}
+
+// defaultKubeContexts treats well-known local cluster names as permissive.
+// They are checked after the user's rules.
+var defaultKubeContexts = []KubeContextRule{
+	{Context: "kind-*", Profile: KubeProfilePermissive},
+	{Context: "minikube", Profile: KubeProfilePermissive},
+	{Context: "k3d-*", Profile: KubeProfilePermissive},
+	{Context: "docker-desktop", Profile: KubeProfilePermissive},
+	{Context: "docker-for-desktop", Profile: KubeProfilePermissive},
+	{Context: "rancher-desktop", Profile: KubeProfilePermissive},
+	{Context: "orbstack", Profile: KubeProfilePermissive},
+	{Context: "colima", Profile: KubeProfilePermissive},
+}
+
+func (r KubeContextRule) matches(context, namespace string) bool {
+	if r.Context != "" && !matchesAnyPattern(context, []string{r.Context}) {
+		return false
+	}
+	if r.Namespace != "" && !matchesAnyPattern(namespace, []string{r.Namespace}) {
+		return false
+	}
+	return true
+}
+
+// kubeTarget is the resolved cluster context and namespace of a command.
+type kubeTarget struct {
+	context   string
+	namespace string
+	profile   string
+}
+
+func (t kubeTarget) String() string {
+	context := t.context
+	if context == "" {
+		context = "unknown context"
+	}
+	return "context " + context + ", namespace " + t.namespace + ", " + t.profile
+}
+
+// kubeProfile picks the profile for a context and namespace: the first
+// matching policy rule, then strict for production-looking context names,
+// then default.
+func kubeProfile(context, namespace string) string {
+	if isProductionContext(context) {
+		note(noteProfile, profileProduction)
+	}
+	for _, rule := range currentPolicy().KubeContexts {
+		if rule.matches(context, namespace) {
+			if rule.Profile == KubeProfilePermissive {
+				note(noteProfile, profileDev)
+			}
+			return rule.Profile
+		}
+	}
+	if isProductionContext(context) {
+		return KubeProfileStrict
+	}
+	return KubeProfileDefault
+}
+
+// isProductionContext checks the context name and each of its parts, since
+// managed clusters use names like gke_acme-prod_us-east1_main or
+// arn:aws:eks:us-east-1:123:cluster/prod.
+func isProductionContext(context string) bool {
+	policy := currentPolicy()
+	if policy.isProduction(context) {
+		return true
+	}
+	for _, part := range strings.FieldsFunc(context, func(r rune) bool {
+		return r == '_' || r == '/' || r == ':' || r == '@'
+	}) {
+		if policy.isProduction(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveKubeTarget works out the context and namespace a command uses.
+func resolveKubeTarget(args []string, env map[string]string, contextFlag string) kubeTarget {
+	context := flagValue(args, contextFlag)
+	namespace := kubeNamespaceFlag(args)
+
+	config := kubeconfigInfo(flagValue(args, "--kubeconfig"), env)
+	if context == "" {
+		context = config.currentContext
+	}
+	if namespace == "" {
+		namespace = config.namespaces[context]
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+	noteInputs("kube", context, namespace)
+
+	return kubeTarget{
+		context:   context,
+		namespace: namespace,
+		profile:   kubeProfile(context, namespace),
+	}
+}
+
+// kubeNamespaceFlag returns the namespace given with -n/--namespace, or "*"
+// for --all-namespaces.
+func kubeNamespaceFlag(args []string) string {
+	for i, arg := range args {
+		switch {
+		case arg == "-A" || arg == "--all-namespaces":
+			return "*"
+		case (arg == "-n" || arg == "--namespace") && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--namespace="):
+			return strings.TrimPrefix(arg, "--namespace=")
+		case strings.HasPrefix(arg, "-n") && len(arg) > 2 && !strings.HasPrefix(arg, "--"):
+			return strings.TrimPrefix(arg[2:], "=")
+		}
+	}
+	return ""
+}
+
+// kubeconfig holds the parts of a kubeconfig the guard cares about.
+type kubeconfig struct {
+	currentContext string
+	namespaces     map[string]string // context name -> default namespace
+}
+
+// kubeconfigInfo reads the current context and per-context namespaces from
+// the kubeconfig files in effect. Like kubectl, the first file that sets
+// current-context wins when KUBECONFIG lists several. The local files say
+// nothing about a remote host's.
+func kubeconfigInfo(flagPath string, env map[string]string) kubeconfig {
+	var paths []string
+	switch {
+	case remoteShell:
+	case flagPath != "":
+		paths = []string{expandHome(flagPath)}
+	case envValue(env, "KUBECONFIG") != "":
+		paths = filepath.SplitList(envValue(env, "KUBECONFIG"))
+	default:
+		if home, err := os.UserHomeDir(); err == nil {
+			paths = []string{filepath.Join(home, ".kube", "config")}
+		}
+	}
+
+	config := kubeconfig{namespaces: map[string]string{}}
+	for _, path := range paths {
+		current, namespaces := parseKubeconfig(expandHome(path))
+		if config.currentContext == "" {
+			config.currentContext = current
+		}
+		for name, ns := range namespaces {
+			if _, ok := config.namespaces[name]; !ok {
+				config.namespaces[name] = ns
+			}
+		}
+	}
+	return config
+}
+
+// parseKubeconfig extracts current-context and each context's namespace with
+// a line scan; kubeconfigs are machine-written and regular enough for this.
+func parseKubeconfig(path string) (string, map[string]string) {
+	namespaces := map[string]string{}
+	dependOnFile(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return "", namespaces
+	}
+	defer f.Close()
+
+	current := ""
+	inContexts := false
+	var name, namespace string
+	flush := func() {
+		if name != "" {
+			namespaces[name] = namespace
+		}
+		name, namespace = "", ""
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Top-level keys start a new section
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			if inContexts {
+				flush()
+			}
+			inContexts = strings.HasPrefix(line, "contexts:")
+			if value, ok := yamlValue(line, "current-context"); ok {
+				current = value
+			}
+			continue
+		}
+		if !inContexts {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") {
+			flush()
+			trimmed = strings.TrimSpace(trimmed[2:])
+		}
+		if value, ok := yamlValue(trimmed, "name"); ok {
+			name = value
+		}
+		if value, ok := yamlValue(trimmed, "namespace"); ok {
+			namespace = value
+		}
+	}
+	if inContexts {
+		flush()
+	}
+	return current, namespaces
+}
+
+// yamlValue returns the scalar value of a "key: value" line.
+func yamlValue(line, key string) (string, bool) {
+	if !strings.HasPrefix(line, key+":") {
+		return "", false
+	}
+	value := strings.TrimSpace(strings.TrimPrefix(line, key+":"))
+	return strings.Trim(value, `"'`), true
+}
+
+// kubectlValueFlags are kubectl options that take a value.
+var kubectlValueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "--context": true, "--kubeconfig": true,
+	"--cluster": true, "--user": true, "-s": true, "--server": true, "--token": true,
+	"-l": true, "--selector": true, "-o": true, "--output": true, "-f": true,
+	"--filename": true, "-c": true, "--container": true, "--field-selector": true,
+	"--as": true, "--as-group": true, "--request-timeout": true, "-L": true,
+	"--label-columns": true, "--sort-by": true, "--template": true, "-k": true,
+	"--kustomize": true, "--since": true, "--tail": true, "--replicas": true,
+	"--image": true, "--timeout": true, "--grace-period": true,
+}
+
+// kubeClusterScopedKinds are resources whose deletion affects a whole
+// cluster or many workloads at once.
+var kubeClusterScopedKinds = map[string]bool{
+	"namespace": true, "namespaces": true, "ns": true, "node": true, "nodes": true,
+	"no": true, "crd": true, "crds": true, "customresourcedefinition": true,
+	"customresourcedefinitions": true, "pv": true, "persistentvolume": true,
+	"persistentvolumes": true, "clusterrole": true, "clusterroles": true,
+	"clusterrolebinding": true, "clusterrolebindings": true,
+}
+
+func evaluateKubectl(args []string, env map[string]string) (Verdict, string) {
+	pos := positionalArgs(args, kubectlValueFlags)
+	if len(pos) == 0 {
+		return VerdictAllow, "kubectl (no subcommand)"
+	}
+
+	subCmd := pos[0]
+
+	safeSubcmds := map[string]bool{
+		"get": true, "describe": true, "logs": true, "top": true,
+		"explain": true, "api-resources": true, "api-versions": true,
+		"config": true, "cluster-info": true, "version": true,
+		"auth": true, "port-forward": true, "diff": true, "events": true,
+		"wait": true, "completion": true, "kustomize": true,
+	}
+	if safeSubcmds[subCmd] {
+		return VerdictAllow, "kubectl " + subCmd
+	}
+
+	target := resolveKubeTarget(args, env, "--context")
+	if hasKubectlDryRun(args) {
+		return VerdictAllow, "kubectl " + subCmd + " --dry-run (" + target.String() + ")"
+	}
+
+	if subCmd == "delete" {
+		return evaluateKubectlDelete(args[indexOf(args, "delete")+1:], target)
+	}
+
+	writeSubcmds := map[string]bool{
+		"apply": true, "create": true, "replace": true, "patch": true,
+		"edit": true, "scale": true, "rollout": true, "exec": true,
+		"cp": true, "run": true, "expose": true, "autoscale": true,
+		"set": true, "label": true, "annotate": true, "taint": true,
+		"cordon": true, "uncordon": true, "drain": true, "attach": true, "debug": true,
+	}
+	if !writeSubcmds[subCmd] {
+		return VerdictUncertain, "kubectl " + subCmd
+	}
+
+	reason := "kubectl " + subCmd + " (" + target.String() + ")"
+	if target.profile == KubeProfilePermissive {
+		if subCmd == "drain" || subCmd == "cordon" || subCmd == "taint" {
+			return VerdictUncertain, reason
+		}
+		return VerdictAllow, reason
+	}
+	return VerdictAsk, reason
+}
+
+// hasKubectlDryRun reports a client or server dry run, which never persists.
+func hasKubectlDryRun(args []string) bool {
+	for _, arg := range args {
+		if arg == "--dry-run" || arg == "--dry-run=client" || arg == "--dry-run=server" {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateKubectlDelete(args []string, target kubeTarget) (Verdict, string) {
+	resources := positionalArgs(args, kubectlValueFlags)
+
+	// The first positional names the kind(s): "pod", "pod/name", "deploy,svc"
+	pods, clusterScoped := false, false
+	if len(resources) > 0 {
+		kinds := strings.Split(strings.ToLower(strings.SplitN(resources[0], "/", 2)[0]), ",")
+		pods = len(kinds) == 1 && (kinds[0] == "pod" || kinds[0] == "pods" || kinds[0] == "po")
+		for _, kind := range kinds {
+			clusterScoped = clusterScoped || kubeClusterScopedKinds[kind]
+		}
+	}
+
+	switch target.profile {
+	case KubeProfilePermissive:
+		if clusterScoped {
+			return VerdictUncertain, "kubectl delete cluster-scoped resource (" + target.String() + ")"
+		}
+		return VerdictAllow, "kubectl delete (" + target.String() + ")"
+	case KubeProfileStrict:
+		return VerdictAsk, "kubectl delete (" + target.String() + ")"
+	}
+
+	if pods {
+		return VerdictAllow, "kubectl delete pod"
+	}
+	return VerdictAsk, "kubectl delete (non-pod resource, " + target.String() + ")"
+}
+
+// helmValueFlags are helm options that take a value.
+var helmValueFlags = map[string]bool{
+	"-n": true, "--namespace": true, "--kube-context": true, "--kubeconfig": true,
+	"-f": true, "--values": true, "--set": true, "--set-string": true, "--set-file": true,
+	"--version": true, "--timeout": true, "-o": true, "--output": true, "--repo": true,
+	"--description": true, "--post-renderer": true,
+}
+
+func evaluateHelm(args []string, env map[string]string) (Verdict, string) {
+	pos := positionalArgs(args, helmValueFlags)
+	if len(pos) == 0 {
+		return VerdictAllow, "helm (no subcommand)"
+	}
+
+	subCmd := pos[0]
+
+	safeSubcmds := map[string]bool{
+		"list": true, "ls": true, "get": true, "status": true,
+		"show": true, "template": true, "lint": true, "version": true,
+		"repo": true, "search": true, "history": true, "env": true,
+		"dependency": true, "plugin": true, "verify": true,
+		"pull": true, "package": true, "create": true,
+	}
+	if safeSubcmds[subCmd] {
+		return VerdictAllow, "helm " + subCmd
+	}
+
+	askSubcmds := map[string]bool{
+		"install": true, "upgrade": true, "uninstall": true,
+		"delete": true, "rollback": true, "test": true,
+	}
+	if !askSubcmds[subCmd] {
+		return VerdictUncertain, "helm " + subCmd
+	}
+
+	target := resolveKubeTarget(args, env, "--kube-context")
+	reason := "helm " + subCmd + " (" + target.String() + ")"
+	if hasFlag(args, "--dry-run") {
+		return VerdictAllow, reason + " --dry-run"
+	}
+	if target.profile == KubeProfilePermissive {
+		return VerdictAllow, reason
+	}
+	return VerdictAsk, reason
+}
+
+func indexOf(args []string, value string) int {
+	for i, arg := range args {
+		if arg == value {
+			return i
+		}
+	}
+	return -1
+}
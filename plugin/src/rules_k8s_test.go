@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+clusters:
+- cluster:
+    server: https://127.0.0.1:6443
+  name: kind-dev
+contexts:
+- context:
+    cluster: kind-dev
+    user: kind-dev
+  name: kind-dev
+- context:
+    cluster: gke_acme-prod_us-east1_main
+    namespace: payments
+    user: gke
+  name: gke_acme-prod_us-east1_main
+- context:
+    cluster: staging
+    user: staging
+  name: staging
+current-context: kind-dev
+kind: Config
+users:
+- name: kind-dev
+`
+
+func TestEvaluateKubeContexts(t *testing.T) {
+	dir := t.TempDir()
+	kubeconfig := filepath.Join(dir, "config")
+	os.WriteFile(kubeconfig, []byte(testKubeconfig), 0644)
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	tests := []struct {
+		name     string
+		command  string
+		want     Verdict
+		contains string
+	}{
+		// current-context is kind-dev: permissive
+		{"kind delete deployment", "kubectl delete deployment api", VerdictAllow, "context kind-dev"},
+		{"kind apply", "kubectl apply -f k8s/", VerdictAllow, "permissive"},
+		{"kind delete namespace", "kubectl delete ns test", VerdictUncertain, "cluster-scoped"},
+		{"kind drain", "kubectl drain node-1", VerdictUncertain, "drain"},
+		{"kind helm install", "helm install api ./chart", VerdictAllow, "permissive"},
+
+		// --context overrides current-context
+		{"prod delete pod", "kubectl --context gke_acme-prod_us-east1_main delete pod api-1", VerdictAsk, "strict"},
+		{"prod namespace from context", "kubectl --context=gke_acme-prod_us-east1_main rollout restart deploy/api", VerdictAsk, "namespace payments"},
+		{"prod get", "kubectl --context gke_acme-prod_us-east1_main get pods", VerdictAllow, "kubectl get"},
+		{"prod helm upgrade", "helm upgrade api ./chart --kube-context gke_acme-prod_us-east1_main", VerdictAsk, "strict"},
+		{"staging delete pod", "kubectl delete pod api-1 --context staging", VerdictAllow, "delete pod"},
+		{"staging delete deploy", "kubectl delete deploy api --context staging -n web", VerdictAsk, "namespace web"},
+
+		// flags before the subcommand
+		{"namespace flag first", "kubectl -n kube-system --context staging get pods", VerdictAllow, "kubectl get"},
+		{"dry run", "kubectl --context staging apply -f x.yaml --dry-run=server", VerdictAllow, "dry-run"},
+		{"helm dry run", "helm upgrade api ./chart --kube-context staging --dry-run", VerdictAllow, "dry-run"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, "/tmp")
+			if got != tt.want || !strings.Contains(reason, tt.contains) {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v containing %q", tt.command, got, reason, tt.want, tt.contains)
+			}
+		})
+	}
+}
+
+func TestKubeContextPolicy(t *testing.T) {
+	saved := activePolicy
+	defer func() { activePolicy = saved }()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	os.WriteFile(path, []byte(`{"kube_contexts": [
+		{"context": "staging", "namespace": "sandbox-*", "profile": "permissive"},
+		{"namespace": "kube-system", "profile": "strict"},
+		{"context": "kind-*", "profile": "default"}
+	]}`), 0644)
+	policy, err := loadPolicy(path)
+	if err != nil {
+		t.Fatalf("loadPolicy: %v", err)
+	}
+	activePolicy = policy
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+
+	tests := []struct {
+		name    string
+		command string
+		want    Verdict
+	}{
+		{"sandbox namespace", "kubectl --context staging -n sandbox-1 delete deploy api", VerdictAllow},
+		{"other namespace", "kubectl --context staging -n web delete deploy api", VerdictAsk},
+		{"kube-system pod", "kubectl --context staging -n kube-system delete pod coredns-1", VerdictAsk},
+		{"user rule overrides local default", "kubectl --context kind-dev delete deploy api", VerdictAsk},
+		{"default still applies", "kubectl --context minikube delete deploy api", VerdictAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, "/tmp")
+			if got != tt.want {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+			}
+		})
+	}
+
+	os.WriteFile(path, []byte(`{"kube_contexts": [{"context": "x", "profile": "yolo"}]}`), 0644)
+	if _, err := loadPolicy(path); err == nil {
+		t.Error("loadPolicy accepted an unknown profile")
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// --- permission mode handlers ---
+//
+// Claude Code's permission mode (default, plan, acceptEdits,
+// bypassPermissions) comes with every hook input. The policy's
+// permission_modes rules adjust decisions per mode: tools to leave to Claude
+// Code, denying Bash commands that change anything (plan mode), and a
+// verdict → action matrix.
+
+// Claude Code permission modes.
+const (
+	PermissionModeDefault     = "default"
+	PermissionModePlan        = "plan"
+	PermissionModeAcceptEdits = "acceptEdits"
+	PermissionModeBypass      = "bypassPermissions"
+)
+
+var knownPermissionModes = map[string]bool{
+	PermissionModeDefault: true, PermissionModePlan: true,
+	PermissionModeAcceptEdits: true, PermissionModeBypass: true,
+}
+
+// Actions for PermissionModeRule.Verdicts.
+const (
+	ModeActionKeep        = "keep"        // the verdict stands (the default)
+	ModeActionAllow       = "allow"       // allow the call
+	ModeActionAsk         = "ask"         // ask the user
+	ModeActionDeny        = "deny"        // refuse the call
+	ModeActionPassthrough = "passthrough" // leave the call to Claude Code
+)
+
+var knownModeActions = map[string]bool{
+	ModeActionKeep: true, ModeActionAllow: true, ModeActionAsk: true,
+	ModeActionDeny: true, ModeActionPassthrough: true,
+}
+
+// PermissionModeRule says how one permission mode changes decisions.
+type PermissionModeRule struct {
+	// PassthroughTools are left to Claude Code without evaluation, unless
+	// they touch the guard's own files or Claude's permission settings.
+	PassthroughTools []string `json:"passthrough_tools,omitempty"`
+
+	// DenyMutating denies Bash commands that aren't read-only.
+	DenyMutating *bool `json:"deny_mutating,omitempty"`
+
+	// Verdicts maps a verdict (allow, ask, uncertain, deny) to an action.
+	// Uncertain is mapped before the evaluator is asked, the others on the
+	// final verdict. A deny can't be relaxed.
+	Verdicts map[string]string `json:"verdicts,omitempty"`
+}
+
+var defaultPermissionModes = map[string]PermissionModeRule{
+	PermissionModePlan:        {DenyMutating: boolPtr(true)},
+	PermissionModeAcceptEdits: {PassthroughTools: []string{"Write", "Edit", "NotebookEdit"}},
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+// passesThrough reports whether the mode leaves this tool to Claude Code.
+func (r PermissionModeRule) passesThrough(toolName string) bool {
+	for _, name := range r.PassthroughTools {
+		if name == toolName {
+			return true
+		}
+	}
+	return false
+}
+
+func (r PermissionModeRule) deniesMutating() bool {
+	return r.DenyMutating != nil && *r.DenyMutating
+}
+
+// action returns what the mode does with a verdict.
+func (r PermissionModeRule) action(verdict Verdict) string {
+	if action := r.Verdicts[strings.ToLower(verdict.String())]; action != "" {
+		return action
+	}
+	return ModeActionKeep
+}
+
+// mutatingBash returns the first part of a Bash call that isn't read-only,
+// or "" if the whole command only reads.
+func mutatingBash(toolInput json.RawMessage) string {
+	var input struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal(toolInput, &input); err != nil {
+		return "unparseable command"
+	}
+	return mutatingCommand(strings.TrimSpace(input.Command))
+}
+
+func mutatingCommand(command string) string {
+	command, _ = extractHeredocs(command)
+	command = stripShellComments(command)
+	for _, seg := range splitCommandSegments(command) {
+		text := strings.TrimSpace(seg.text)
+		if text == "" {
+			continue
+		}
+		for _, inner := range commandSubstitutions(text) {
+			if part := mutatingCommand(inner); part != "" {
+				return part
+			}
+		}
+		if hasOutputRedirect(text) {
+			return text
+		}
+		text = unwrapShellSyntax(text)
+		if text != "" && !isReadOnlySegment(extractBaseCommand(text), extractArgs(text)) {
+			return text
+		}
+	}
+	return ""
+}
+
+// readOnlyCommands never change anything, whatever their arguments. Tools
+// with an option or operand that writes or runs a program (rg --pre, yq -i,
+// date -s, uniq IN OUT) are checked in isReadOnlySegment instead.
+var readOnlyCommands = map[string]bool{
+	"cat": true, "head": true, "tail": true, "less": true, "more": true,
+	"file": true, "stat": true, "wc": true, "od": true, "strings": true,
+	"ls": true, "locate": true, "du": true, "df": true,
+	"grep": true, "ag": true, "ack": true,
+	"cut": true, "tr": true,
+	"diff": true, "comm": true, "jq": true,
+	"whoami": true, "id": true, "groups": true, "uname": true,
+	"uptime": true, "which": true, "type": true, "where": true,
+	"env": true, "printenv": true, "echo": true, "printf": true, "pwd": true,
+	"realpath": true, "dirname": true, "basename": true, "true": true, "false": true,
+	"test": true, "[": true, "cd": true, "pushd": true, "popd": true,
+	"ps": true, "pgrep": true, "lsof": true,
+}
+
+// readOnlySubcommands are the read-only subcommands of tools that can also
+// write.
+var readOnlySubcommands = map[string]map[string]bool{
+	"git": {
+		"status": true, "diff": true, "log": true, "show": true, "blame": true,
+		"ls-files": true, "ls-tree": true, "rev-parse": true, "describe": true,
+		"grep": true, "shortlog": true, "cat-file": true, "merge-base": true,
+	},
+	"go":      {"list": true, "doc": true, "version": true, "env": true, "vet": true},
+	"kubectl": {"get": true, "describe": true, "logs": true, "top": true, "explain": true, "api-resources": true, "version": true},
+	"gh":      {"status": true},
+}
+
+func isReadOnlySegment(base string, args []string) bool {
+	switch {
+	case base == "" || readOnlyCommands[base]: // bare assignments only set variables
+		return true
+	case base == "sed":
+		return isReadOnlySed(args)
+	case base == "sort":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-o") || strings.HasPrefix(arg, "--output") || strings.HasPrefix(arg, "--compress-program") {
+				return false
+			}
+		}
+		return true
+	case base == "uniq":
+		// uniq IN OUT writes OUT
+		return len(positionalArgs(args, map[string]bool{
+			"-f": true, "-s": true, "-w": true, "--skip-fields": true, "--skip-chars": true, "--check-chars": true,
+		})) < 2
+	case base == "xxd":
+		// xxd -r rebuilds a binary, and xxd IN OUT writes OUT
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-r") {
+				return false
+			}
+		}
+		return len(positionalArgs(args, map[string]bool{
+			"-c": true, "-cols": true, "-g": true, "-groupsize": true, "-l": true, "-len": true,
+			"-o": true, "-offset": true, "-s": true, "-seek": true, "-n": true, "-name": true,
+		})) < 2
+	case base == "tree":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-o") || strings.HasPrefix(arg, "--output") {
+				return false
+			}
+		}
+		return true
+	case base == "rg":
+		for _, arg := range args {
+			if arg == "--pre" || strings.HasPrefix(arg, "--pre=") {
+				return false
+			}
+		}
+		return true
+	case base == "yq":
+		for _, arg := range args {
+			if arg == "-i" || arg == "--inplace" || strings.HasPrefix(arg, "--inplace=") {
+				return false
+			}
+		}
+		return true
+	case base == "date":
+		// date -s and date MMDDhhmm set the clock; +FORMAT only prints
+		for i := 0; i < len(args); i++ {
+			switch arg := args[i]; {
+			case arg == "-d" || arg == "--date" || arg == "-r" || arg == "--reference" || arg == "-f" || arg == "--file":
+				i++
+			case arg == "-s" || strings.HasPrefix(arg, "--set"):
+				return false
+			case strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "s"):
+				return false
+			case !strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "+"):
+				return false
+			}
+		}
+		return true
+	case base == "hostname":
+		// hostname NAME and hostname -F FILE set the name
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") || arg == "-F" || strings.HasPrefix(arg, "--file") || arg == "-b" || arg == "--boot" {
+				return false
+			}
+		}
+		return true
+	case base == "git" && !isReadOnlyGit(args):
+		return false
+	case base == "go" && hasFlag(args, "-w", "-u"):
+		// go env -w and -u write the go env file
+		return false
+	case base == "find":
+		for _, arg := range args {
+			switch arg {
+			case "-delete", "-exec", "-execdir", "-ok", "-okdir", "-fprint", "-fprint0", "-fprintf", "-fls":
+				return false
+			}
+		}
+		return true
+	case base == "gh" && len(args) >= 2:
+		// gh pr view, gh issue list, ...
+		return args[1] == "view" || args[1] == "list" || args[1] == "diff" || args[1] == "checks"
+	}
+
+	subcommands, ok := readOnlySubcommands[base]
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if strings.HasPrefix(arg, "-") {
+			if base == "git" && arg == "-C" {
+				i++
+			}
+			continue
+		}
+		return subcommands[arg]
+	}
+	return false
+}
+
+// isReadOnlyGit rejects git options that write or run programs whatever the
+// subcommand: -c and --config-env can set hooks, pagers and diff drivers,
+// diff --output writes a file, and grep -O opens matches in a program.
+func isReadOnlyGit(args []string) bool {
+	for _, arg := range args {
+		switch {
+		case strings.HasPrefix(arg, "-O") || strings.HasPrefix(arg, "--open-files-in-pager"):
+			return false
+		case arg == "-c" || strings.HasPrefix(arg, "-c") && len(arg) > 2 && !strings.HasPrefix(arg, "--"),
+			strings.HasPrefix(arg, "--config-env"),
+			arg == "--output" || strings.HasPrefix(arg, "--output="),
+			arg == "--ext-diff", arg == "--textconv", strings.HasPrefix(arg, "--exec"):
+			return false
+		}
+	}
+	return true
+}
+
+// isReadOnlySed checks sed's options and scripts: in-place edits, script
+// files it can't see, and the w, W and e commands (or s///w and s///e)
+// that write files and run commands.
+func isReadOnlySed(args []string) bool {
+	var scripts []string
+	explicit := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if !explicit && i+1 < len(args) {
+				scripts = append(scripts, args[i+1])
+			}
+			i = len(args)
+		case strings.HasPrefix(arg, "--in-place"), strings.HasPrefix(arg, "--file"):
+			return false
+		case arg == "--expression" && i+1 < len(args):
+			i++
+			scripts, explicit = append(scripts, args[i]), true
+		case strings.HasPrefix(arg, "--expression="):
+			scripts, explicit = append(scripts, strings.TrimPrefix(arg, "--expression=")), true
+		case arg == "--line-length":
+			i++
+		case strings.HasPrefix(arg, "--"):
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// A cluster of short options; e, f and l take a value
+			for j := 1; j < len(arg); j++ {
+				switch arg[j] {
+				case 'i', 'f':
+					return false
+				case 'e', 'l':
+					value := arg[j+1:]
+					if value == "" && i+1 < len(args) {
+						i++
+						value = args[i]
+					}
+					if arg[j] == 'e' {
+						scripts, explicit = append(scripts, value), true
+					}
+					j = len(arg)
+				}
+			}
+		case !explicit && len(scripts) == 0:
+			scripts = append(scripts, arg)
+		}
+	}
+	for _, script := range scripts {
+		if sedScriptWrites(script) {
+			return false
+		}
+	}
+	return true
+}
+
+// sedScriptWrites reports whether a sed script has a command that writes a
+// file or runs one. A script it can't follow counts as writing.
+func sedScriptWrites(script string) bool {
+	i := 0
+	// skipDelimited moves past text up to an unescaped delim, returning
+	// false if there is none
+	skipDelimited := func(delim byte) bool {
+		for ; i < len(script); i++ {
+			switch script[i] {
+			case '\\':
+				i++
+			case delim:
+				i++
+				return true
+			}
+		}
+		return false
+	}
+	// skipTo moves up to the first of the stop bytes
+	skipTo := func(stops string) {
+		for i < len(script) && !strings.ContainsRune(stops, rune(script[i])) {
+			i++
+		}
+	}
+	// skipAddresses moves past separators and addresses: line numbers, $,
+	// /regex/, \cregexc, ranges and steps
+	skipAddresses := func() bool {
+		for i < len(script) {
+			switch c := script[i]; {
+			case strings.IndexByte(" \t\n;!,~+$", c) >= 0 || c >= '0' && c <= '9':
+				i++
+			case c == '/':
+				i++
+				if !skipDelimited('/') {
+					return false
+				}
+				for i < len(script) && (script[i] == 'I' || script[i] == 'M') {
+					i++
+				}
+			case c == '\\' && i+1 < len(script):
+				i += 2
+				if !skipDelimited(script[i-1]) {
+					return false
+				}
+			default:
+				return true
+			}
+		}
+		return true
+	}
+
+	for {
+		if !skipAddresses() {
+			return true
+		}
+		if i >= len(script) {
+			return false
+		}
+		c := script[i]
+		i++
+		switch c {
+		case 'w', 'W', 'e':
+			return true
+		case 's', 'y':
+			if i >= len(script) {
+				return true
+			}
+			delim := script[i]
+			i++
+			if !skipDelimited(delim) || !skipDelimited(delim) {
+				return true
+			}
+			if c == 's' {
+				flags := i
+				skipTo(";\n}")
+				if strings.ContainsAny(script[flags:i], "wWe") {
+					return true
+				}
+			}
+		case 'a', 'i', 'c', 'r', 'R', '#':
+			// Text, a file to read or a comment, up to the end of the line
+			skipTo("\n")
+		case 'b', 't', 'T', ':':
+			skipTo(";\n")
+		case '{', '}', '=', 'd', 'D', 'g', 'G', 'h', 'H', 'l', 'n', 'N', 'p', 'P', 'q', 'Q', 'x', 'z', 'F':
+		default:
+			return true
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMutatingCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		mutating bool
+	}{
+		{"ls -la", false},
+		{"git status && git log --oneline -5", false},
+		{"git -C web diff main", false},
+		{"cat go.mod | grep require", false},
+		{"find . -name '*.go' | xargs wc -l", true},
+		{"find . -name '*.tmp' -delete", true},
+		{"sed -n 1,20p main.go", false},
+		{"sed -i s/a/b/ main.go", true},
+		{"sort -o out.txt in.txt", true},
+		{"kubectl get pods -n app", false},
+		{"kubectl apply -f k8s/", true},
+		{"gh pr view 12", false},
+		{"gh pr merge 12", true},
+		{"echo hi > notes.txt", true},
+		{"ls foo 2>/dev/null", false},
+		{"cat x 2>/dev/null || true", false},
+		{"grep -q main go.mod > /dev/null", false},
+		{"echo hi >/dev/null.txt", true},
+		{"echo $(rm -rf build)", true},
+		{"cd src && go list ./...", false},
+		{"go test ./...", true},
+		{"touch main.go", true},
+		{"git commit -m 'wip'", true},
+		{"cat <<'EOF'\n> not a redirect\nEOF", false},
+
+		// Options that write or run programs
+		{"git -c core.pager=evil log", true},
+		{"git -c alias.x=!rm status", true},
+		{"git diff --output=patch.diff", true},
+		{"git -C web log --oneline", false},
+		{"go env GOPATH", false},
+		{"go env -w GOFLAGS=-mod=mod", true},
+		{"rg --pre ./decode TODO", true},
+		{"rg --pre=./decode TODO", true},
+		{"sort --compress-program=./zip big.txt", true},
+		{"yq '.a' config.yaml", false},
+		{"yq -i '.a = 1' config.yaml", true},
+		{"date +%Y-%m-%d", false},
+		{"date -d yesterday +%F", false},
+		{"date -s '2020-01-01'", true},
+		{"date 010112002020", true},
+		{"hostname", false},
+		{"hostname -f", false},
+		{"hostname evil", true},
+
+		// sed scripts that write files or run commands
+		{"sed -n '/start/,/end/p' log.txt", false},
+		{"sed 's/a/b/g;s|x|y|' main.go", false},
+		{"sed -e 's/a/b/' -e '/^$/d' main.go", false},
+		{"sed 'w /tmp/out' main.go", true},
+		{"sed -n '1,5w out.txt' main.go", true},
+		{"sed 's/a/b/w out.txt' main.go", true},
+		{"sed 's/.*/rm -rf build/e' main.go", true},
+		{"sed '1e touch x' main.go", true},
+		{"sed -ne 's/a/b/p;W out' main.go", true},
+		{"sed 'b end; w out' main.go", true},
+		{"sed 's/w/e/' main.go", false},
+		{"sed -f edit.sed main.go", true},
+		{"sed -E 's/(a|b)/c/' main.go", false},
+
+		// Process substitutions and output operands
+		{"cat <(rm x)", true},
+		{"diff <(ls a) <(ls b)", false},
+		{"ls | tee >(rm x)", true},
+		{"uniq -c in.txt", false},
+		{"uniq -f 1 in.txt", false},
+		{"uniq in.txt out.txt", true},
+		{"tree -L 2", false},
+		{"tree -o out.txt", true},
+		{"xxd main.go", false},
+		{"xxd -l 64 main.go", false},
+		{"xxd -r dump bin", true},
+		{"xxd main.go dump", true},
+		{"git grep -n TODO", false},
+		{"git grep -Ovim TODO", true},
+		{"git grep --open-files-in-pager=vim TODO", true},
+	}
+	for _, tt := range tests {
+		input, _ := json.Marshal(map[string]string{"command": tt.command})
+		if got := mutatingBash(input) != ""; got != tt.mutating {
+			t.Errorf("mutatingBash(%q) = %v, want %v", tt.command, got, tt.mutating)
+		}
+	}
+}
+
+func TestModeAction(t *testing.T) {
+	rule := PermissionModeRule{Verdicts: map[string]string{"ask": "deny", "uncertain": "passthrough"}}
+	if v, ok := modeAction(rule, VerdictAsk); v != VerdictDeny || !ok {
+		t.Errorf("ask → %v, %v, want DENY", v, ok)
+	}
+	if v, ok := modeAction(rule, VerdictAllow); v != VerdictAllow || !ok {
+		t.Errorf("allow → %v, %v, want ALLOW", v, ok)
+	}
+	if _, ok := modeAction(rule, VerdictUncertain); ok {
+		t.Error("uncertain should pass through")
+	}
+}
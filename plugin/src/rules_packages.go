@@ -0,0 +1,402 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- package install handlers ---
+//
+// Installing a package runs its install scripts (npm lifecycle scripts,
+// setup.py, build.rs) right away, so the specs of npm/yarn/pnpm/bun, pip,
+// cargo and system package manager installs are checked before they're
+// allowed. Packages from the default registry are fine unless the name is
+// one edit away from a popular package; VCS, URL and tarball sources and
+// other registries go to the evaluator, and anything fetched over plain
+// HTTP asks. Installing what the project's lockfile or requirements file
+// pins is allowed as before.
+
+// installCheck collects the worst finding over an install's specs and
+// options.
+type installCheck struct {
+	verdict Verdict
+	reason  string
+}
+
+func (c *installCheck) flag(verdict Verdict, reason string) {
+	if verdict > c.verdict || c.reason == "" {
+		c.verdict, c.reason = verdict, reason
+	}
+}
+
+// source flags a package fetched from somewhere other than the registry.
+func (c *installCheck) source(kind, location string) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "git+http://") {
+		c.flag(VerdictAsk, "installs over plain HTTP: "+location)
+		return
+	}
+	c.flag(VerdictUncertain, "installs from "+kind+": "+location)
+}
+
+// registry flags a registry or index other than the default one.
+func (c *installCheck) registry(url string, defaults ...string) {
+	for _, d := range defaults {
+		if strings.TrimSuffix(url, "/") == strings.TrimSuffix(d, "/") {
+			return
+		}
+	}
+	if strings.HasPrefix(url, "http://") {
+		c.flag(VerdictAsk, "plain HTTP registry: "+url)
+		return
+	}
+	c.flag(VerdictUncertain, "non-default registry: "+url)
+}
+
+// envRegistries checks registries set in the command's own environment
+// (PIP_INDEX_URL=... pip install x). Names are matched without case, as npm
+// does; a value may list several URLs.
+func (c *installCheck) envRegistries(env map[string]string, names []string, defaults ...string) {
+	for _, name := range names {
+		for key, value := range env {
+			scoped := strings.HasPrefix(name, "npm_config_") && strings.HasPrefix(strings.ToLower(key), "npm_config_@") &&
+				strings.HasSuffix(strings.ToLower(key), ":registry")
+			if !strings.EqualFold(key, name) && !(scoped && name == "npm_config_registry") {
+				continue
+			}
+			for _, url := range strings.Fields(value) {
+				c.registry(url, defaults...)
+			}
+		}
+	}
+}
+
+// npmRegistryEnv and pipIndexEnv name the variables that replace the
+// default registry.
+var (
+	npmRegistryEnv = []string{"npm_config_registry", "YARN_REGISTRY", "YARN_NPM_REGISTRY_SERVER", "BUN_CONFIG_REGISTRY"}
+	pipIndexEnv    = []string{"PIP_INDEX_URL", "PIP_EXTRA_INDEX_URL", "PIP_FIND_LINKS", "UV_INDEX_URL", "UV_EXTRA_INDEX_URL", "UV_DEFAULT_INDEX", "UV_INDEX"}
+)
+
+// name flags a package named like a popular one.
+func (c *installCheck) name(name string, popular, neighbours []string, normalize func(string) string) {
+	if lookalike := typosquatOf(name, popular, neighbours, normalize); lookalike != "" {
+		c.flag(VerdictAsk, name+" is one edit away from "+lookalike+" (typosquat?)")
+	}
+}
+
+func (c *installCheck) result(what string) (Verdict, string) {
+	if c.reason == "" {
+		return VerdictAllow, what
+	}
+	return c.verdict, what + ": " + c.reason
+}
+
+// typosquatOf returns the popular package name is one edit away from, or ""
+// if it is popular or a well-known neighbour itself, or not close to any.
+// Short names are skipped: too many of them are one edit apart. So are names
+// that only differ by a version suffix (boto and boto3).
+func typosquatOf(name string, popular, neighbours []string, normalize func(string) string) string {
+	name = normalize(name)
+	if len(name) < 4 {
+		return ""
+	}
+	for _, n := range neighbours {
+		if normalize(n) == name {
+			return ""
+		}
+	}
+	lookalike := ""
+	for _, p := range popular {
+		p = normalize(p)
+		if p == name {
+			return ""
+		}
+		if lookalike == "" && len(p) >= 5 && editDistance(name, p) == 1 && !sameBaseName(name, p) {
+			lookalike = p
+		}
+	}
+	return lookalike
+}
+
+// sameBaseName reports whether two package names are the same once a
+// trailing version number is dropped.
+func sameBaseName(a, b string) bool {
+	a, b = strings.TrimRight(a, "0123456789"), strings.TrimRight(b, "0123456789")
+	return a != "" && a == b
+}
+
+// editDistance is the optimal string alignment distance: insertions,
+// deletions, substitutions and transpositions of adjacent characters.
+func editDistance(a, b string) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// isLocalSpec reports whether a spec names a file or directory.
+func isLocalSpec(spec string) bool {
+	return strings.HasPrefix(spec, ".") || strings.HasPrefix(spec, "/") || strings.HasPrefix(spec, "~") ||
+		strings.HasPrefix(spec, "file:") || strings.HasPrefix(spec, "link:")
+}
+
+// --- npm, yarn, pnpm, bun ---
+
+// npmInstallValueFlags are install options that take a value.
+var npmInstallValueFlags = map[string]bool{
+	"--registry": true, "--tag": true, "-w": true, "--workspace": true, "--omit": true,
+	"--include": true, "--install-strategy": true, "--cache": true, "--save-prefix": true,
+	"--filter": true, "-F": true, "--network-concurrency": true, "--modules-folder": true,
+}
+
+const npmDefaultRegistry = "https://registry.npmjs.org/"
+
+func evaluateNodeInstall(cmd, subCmd string, args []string, env map[string]string) (Verdict, string) {
+	var check installCheck
+	check.envRegistries(env, npmRegistryEnv, npmDefaultRegistry, "https://registry.yarnpkg.com/")
+	var specs []string
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		switch {
+		case flag == "--registry" || strings.HasSuffix(flag, ":registry"):
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			check.registry(value, npmDefaultRegistry, "https://registry.yarnpkg.com/")
+		case npmInstallValueFlags[flag]:
+			if !inline {
+				i++
+			}
+		case strings.HasPrefix(flag, "-"):
+		default:
+			specs = append(specs, args[i])
+		}
+	}
+
+	for _, spec := range specs {
+		name, kind := npmSpec(spec)
+		switch kind {
+		case "":
+			check.name(name, popularNpmPackages, npmPackageNeighbours, strings.ToLower)
+		case "local":
+		default:
+			check.source(kind, spec)
+		}
+	}
+	return check.result(cmd + " " + subCmd)
+}
+
+// npmSpec returns the package name of an install spec, or the kind of
+// source it comes from instead of the registry ("git", "url", "local").
+func npmSpec(spec string) (string, string) {
+	switch {
+	case isLocalSpec(spec) || strings.HasSuffix(spec, ".tgz") && !strings.Contains(spec, "://"):
+		return "", "local"
+	case strings.HasPrefix(spec, "git+") || strings.HasPrefix(spec, "git://") ||
+		strings.HasPrefix(spec, "github:") || strings.HasPrefix(spec, "gitlab:") ||
+		strings.HasPrefix(spec, "bitbucket:") || strings.HasPrefix(spec, "gist:"):
+		return "", "git"
+	case strings.Contains(spec, "://"):
+		return "", "url"
+	}
+
+	// alias@npm:real@version installs real
+	if i := strings.Index(spec, "@npm:"); i > 0 {
+		spec = spec[i+len("@npm:"):]
+	}
+	name := spec
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		name = spec[:i]
+	}
+	if strings.Contains(name, "/") && !strings.HasPrefix(name, "@") {
+		return "", "git" // user/repo is a GitHub shorthand
+	}
+	return name, ""
+}
+
+// --- pip ---
+
+// pipInstallValueFlags are pip install options that take a value.
+var pipInstallValueFlags = map[string]bool{
+	"-c": true, "--constraint": true, "-t": true, "--target": true, "--prefix": true,
+	"--root": true, "--platform": true, "--python-version": true, "--implementation": true,
+	"--abi": true, "--src": true, "--upgrade-strategy": true, "--progress-bar": true,
+	"--log": true, "--cache-dir": true, "--proxy": true, "--retries": true, "--timeout": true,
+	"--exists-action": true, "-C": true, "--config-settings": true, "--global-option": true,
+	"--report": true, "--only-binary": true, "--no-binary": true, "--python": true,
+}
+
+const pypiDefaultIndex = "https://pypi.org/simple"
+
+func evaluatePipInstall(cmd string, args []string, env map[string]string) (Verdict, string) {
+	var check installCheck
+	check.envRegistries(env, pipIndexEnv, pypiDefaultIndex, "https://pypi.python.org/simple")
+	var specs []string
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		takeValue := func() string {
+			if !inline && i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return value
+		}
+		switch {
+		case flag == "-r" || flag == "--requirement" || flag == "-c" || flag == "--constraint":
+			// A local file is pinned by the project; a URL is whatever it serves
+			if file := takeValue(); strings.Contains(file, "://") {
+				check.source("URL", file)
+			}
+		case flag == "-e" || flag == "--editable":
+			specs = append(specs, takeValue())
+		case flag == "-i" || flag == "--index-url" || flag == "--extra-index-url":
+			check.registry(takeValue(), pypiDefaultIndex, "https://pypi.python.org/simple")
+		case flag == "-f" || flag == "--find-links":
+			if links := takeValue(); strings.Contains(links, "://") {
+				check.registry(links)
+			}
+		case flag == "--trusted-host":
+			check.flag(VerdictUncertain, "trusts host without TLS: "+takeValue())
+		case pipInstallValueFlags[flag]:
+			takeValue()
+		case strings.HasPrefix(flag, "-"):
+		case args[i] == "@" && len(specs) > 0 && i+1 < len(args):
+			// name @ url, unquoted
+			i++
+			specs[len(specs)-1] += " @ " + args[i]
+		default:
+			specs = append(specs, args[i])
+		}
+	}
+
+	for _, spec := range specs {
+		name, kind, location := pipSpec(spec)
+		switch kind {
+		case "":
+			check.name(name, popularPypiPackages, pypiPackageNeighbours, normalizePypiName)
+		case "local":
+		default:
+			check.source(kind, location)
+		}
+	}
+	return check.result(cmd + " install")
+}
+
+// pipSpec returns the project name of a requirement, or the kind and
+// location of the source it comes from instead of the index.
+func pipSpec(spec string) (name, kind, location string) {
+	location = spec
+	if i := strings.Index(spec, "@"); i > 0 && strings.Contains(spec[i:], "://") {
+		location = strings.TrimSpace(spec[i+1:]) // name @ url
+	}
+	switch {
+	case strings.HasPrefix(location, "git+") || strings.HasPrefix(location, "hg+") ||
+		strings.HasPrefix(location, "svn+") || strings.HasPrefix(location, "bzr+"):
+		return "", "VCS", location
+	case strings.Contains(location, "://"):
+		return "", "URL", location
+	case isLocalSpec(spec) || strings.HasSuffix(spec, ".whl") || strings.HasSuffix(spec, ".tar.gz") ||
+		strings.HasSuffix(spec, ".zip") || strings.Contains(spec, "/"):
+		return "", "local", spec
+	}
+	end := strings.IndexAny(spec, "[<>=!~;@ ")
+	if end < 0 {
+		end = len(spec)
+	}
+	return spec[:end], "", ""
+}
+
+// normalizePypiName applies PEP 503 normalization.
+func normalizePypiName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
+
+// --- cargo ---
+
+// cargoInstallValueFlags are cargo install/add options that take a value.
+var cargoInstallValueFlags = map[string]bool{
+	"--version": true, "--vers": true, "--branch": true, "--tag": true, "--rev": true,
+	"--path": true, "--root": true, "--features": true, "-F": true, "--target": true,
+	"--target-dir": true, "--profile": true, "-j": true, "--jobs": true, "--bin": true,
+	"--example": true, "--config": true, "-Z": true, "--rename": true, "-p": true,
+	"--package": true, "--manifest-path": true,
+}
+
+func evaluateCargoInstall(subCmd string, args []string) (Verdict, string) {
+	var check installCheck
+	var crates []string
+	fromRegistry := true // --git and --path name crates in that source
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		takeValue := func() string {
+			if !inline && i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return value
+		}
+		switch {
+		case flag == "--git":
+			check.source("git", takeValue())
+			fromRegistry = false
+		case flag == "--path":
+			takeValue()
+			fromRegistry = false
+		case flag == "--registry":
+			check.registry(takeValue())
+		case flag == "--index":
+			check.registry(takeValue(), "sparse+https://index.crates.io/", "https://github.com/rust-lang/crates.io-index")
+		case cargoInstallValueFlags[flag]:
+			takeValue()
+		case strings.HasPrefix(flag, "-"):
+		default:
+			crates = append(crates, args[i])
+		}
+	}
+
+	if !fromRegistry {
+		crates = nil
+	}
+	for _, crate := range crates {
+		name, _, _ := strings.Cut(crate, "@")
+		check.name(name, popularCrates, nil, func(s string) string {
+			return strings.ReplaceAll(strings.ToLower(s), "_", "-")
+		})
+	}
+	return check.result("cargo " + subCmd)
+}
+
+// --- system package managers ---
+
+func evaluateSystemInstall(cmd, subCmd string, args []string) (Verdict, string) {
+	var check installCheck
+	for _, arg := range positionalArgs(args, nil) {
+		switch {
+		case strings.Contains(arg, "://"):
+			check.source("URL", arg)
+		case cmd == "brew" && strings.HasSuffix(arg, ".rb"):
+			check.flag(VerdictUncertain, "installs a formula file: "+arg)
+		case cmd == "brew" && strings.Count(arg, "/") == 2 && !strings.HasPrefix(arg, "homebrew/"):
+			check.flag(VerdictUncertain, "installs from third-party tap: "+arg)
+		}
+	}
+	return check.result(cmd + " " + subCmd)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluatePackageInstalls(t *testing.T) {
+	workDir := "/home/dev/project"
+
+	tests := []struct {
+		name     string
+		command  string
+		want     Verdict
+		contains string
+	}{
+		// Lockfiles, requirements and registry packages
+		{"npm ci", "npm ci", VerdictAllow, "npm ci"},
+		{"npm install lockfile", "npm install", VerdictAllow, "npm install"},
+		{"npm install package", "npm install express", VerdictAllow, "npm install"},
+		{"npm install scoped version", "npm i -D @types/node@20 typescript@^5", VerdictAllow, "npm i"},
+		{"npm install unknown name", "npm install left-pad", VerdictAllow, "npm install"},
+		{"yarn add", "yarn add react react-dom", VerdictAllow, "yarn add"},
+		{"pnpm add filter", "pnpm add --filter web zod", VerdictAllow, "pnpm add"},
+		{"bun add", "bun add hono", VerdictAllow, "bun add"},
+		{"npm local tarball", "npm install ./vendor/lib-1.0.0.tgz", VerdictAllow, "npm install"},
+		{"pip requirements", "pip install -r requirements.txt", VerdictAllow, "pip install"},
+		{"pip constraints", "pip install -c constraints.txt -r requirements.txt", VerdictAllow, "pip install"},
+		{"pip requirements url", "pip install -r https://example.com/requirements.txt", VerdictUncertain, "installs from URL"},
+		{"pip constraint url", "pip install --constraint=https://example.com/c.txt flask", VerdictUncertain, "installs from URL"},
+		{"pip requirements http", "pip install -r http://example.com/requirements.txt", VerdictAsk, "plain HTTP"},
+		{"pip package", "pip install 'requests>=2.31' flask[async]", VerdictAllow, "pip install"},
+		{"pip editable local", "pip install -e .", VerdictAllow, "pip install"},
+		{"python -m pip", "python3 -m pip install --upgrade pip", VerdictAllow, "pip install"},
+		{"cargo add", "cargo add serde --features derive", VerdictAllow, "cargo add"},
+		{"cargo install", "cargo install ripgrep@14.1.0", VerdictAllow, "cargo install"},
+		{"cargo install path", "cargo install --path .", VerdictAllow, "cargo install"},
+		{"brew install", "brew install jq", VerdictAllow, "brew install"},
+		{"apt install", "apt-get install -y curl", VerdictAllow, "apt-get install"},
+		{"go mod download", "go mod download", VerdictAllow, "go mod"},
+
+		// Other sources
+		{"npm git", "npm install git+https://github.com/someone/lib.git", VerdictUncertain, "installs from git"},
+		{"npm github shorthand", "npm i someone/lib", VerdictUncertain, "installs from git"},
+		{"npm tarball url", "yarn add https://example.com/lib-1.0.0.tgz", VerdictUncertain, "installs from url"},
+		{"npm registry", "npm install lib --registry=https://npm.example.com", VerdictUncertain, "non-default registry"},
+		{"npm default registry", "npm install lib --registry https://registry.npmjs.org/", VerdictAllow, "npm install"},
+		{"npm http registry", "npm install lib --registry http://mirror.example.com", VerdictAsk, "plain HTTP registry"},
+		{"pip vcs", "pip install git+https://github.com/someone/tool", VerdictUncertain, "installs from VCS"},
+		{"pip direct reference", "pip install 'tool @ https://example.com/tool-1.0.tar.gz'", VerdictUncertain, "installs from URL"},
+		{"pip editable vcs", "pip3 install -e git+https://github.com/someone/tool#egg=tool", VerdictUncertain, "installs from VCS"},
+		{"pip index url", "pip install --index-url https://pypi.example.com/simple tool", VerdictUncertain, "non-default registry"},
+		{"pip extra index", "pip install --extra-index-url http://mirror.example.com/simple tool", VerdictAsk, "plain HTTP registry"},
+		{"pip index env", "PIP_INDEX_URL=https://evil.example/simple pip install requests", VerdictUncertain, "non-default registry"},
+		{"pip default index env", "PIP_INDEX_URL=https://pypi.org/simple pip install requests", VerdictAllow, "pip install"},
+		{"pip extra index env", "PIP_EXTRA_INDEX_URL=http://mirror.example.com/simple python3 -m pip install tool", VerdictAsk, "plain HTTP registry"},
+		{"npm registry env", "npm_config_registry=http://evil.example npm install lib", VerdictAsk, "plain HTTP registry"},
+		{"npm registry env upper", "NPM_CONFIG_REGISTRY=https://npm.example.com npm install lib", VerdictUncertain, "non-default registry"},
+		{"npm scoped registry env", "npm_config_@corp:registry=https://npm.example.com npm install @corp/lib", VerdictUncertain, "non-default registry"},
+		{"pip trusted host", "pip install --trusted-host mirror.example.com tool", VerdictUncertain, "without TLS"},
+		{"cargo git", "cargo install --git https://github.com/someone/tool", VerdictUncertain, "installs from git"},
+		{"cargo registry", "cargo add tool --registry corp", VerdictUncertain, "non-default registry"},
+		{"brew tap formula", "brew install someone/tools/thing", VerdictUncertain, "third-party tap"},
+		{"brew formula url", "brew install https://example.com/thing.rb", VerdictUncertain, "installs from URL"},
+		{"yum rpm url", "yum install http://example.com/pkg.rpm", VerdictAsk, "plain HTTP"},
+
+		// Typosquats
+		{"npm typo", "npm install expresss", VerdictAsk, "one edit away from express"},
+		{"npm transposition", "npm i lodahs", VerdictAsk, "one edit away from lodash"},
+		{"yarn typo", "yarn add reqeust", VerdictAsk, "one edit away from request"},
+		{"pip typo", "pip install requets", VerdictAsk, "one edit away from requests"},
+		{"pip typo with version", "pip install 'numpyy==1.26'", VerdictAsk, "one edit away from numpy"},
+		{"pip normalized name", "pip install Python_Dateutil", VerdictAllow, "pip install"},
+		{"cargo typo", "cargo add serd", VerdictAsk, "one edit away from serde"},
+		{"pip well-known neighbour", "pip install boto", VerdictAllow, "pip install"},
+		{"pip other neighbour", "pip install pyaml scapy", VerdictAllow, "pip install"},
+		{"npm well-known neighbour", "npm install -D jsdoc tslint", VerdictAllow, "npm install"},
+		{"pip version suffix", "pip install psycopg", VerdictAllow, "pip install"},
+		{"pip typo of a versioned name", "pip install botp3", VerdictAsk, "one edit away from boto3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want || !strings.Contains(reason, tt.contains) {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v containing %q", tt.command, got, reason, tt.want, tt.contains)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"lodash", "lodash", 0},
+		{"lodash", "lodahs", 1},
+		{"express", "expresss", 1},
+		{"react", "preact", 1},
+		{"requests", "request", 1},
+		{"numpy", "pandas", 6},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
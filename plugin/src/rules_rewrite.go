@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// --- input rewrite handlers ---
+//
+// Some calls that would ask have a safer equivalent. A rewrite turns the
+// command into that equivalent, and the client allows the result through the
+// hook's updatedInput instead of showing the dialog. Every rewrite is opt-in
+// through the policy's rewrites map. Only plain single commands are rewritten
+// (no variables, globs, substitutions or redirects, so requoting is exact),
+// and the result must pass the rules on its own.
+
+// Rewrite names for Policy.Rewrites.
+const (
+	RewriteForceWithLease       = "force_with_lease"       // git push --force → --force-with-lease
+	RewriteRmToTrash            = "rm_to_trash"            // rm -r → mv into a temporary directory
+	RewriteKubectlDryRun        = "kubectl_dry_run"        // kubectl apply → --dry-run=server, once
+	RewriteDockerDropPrivileged = "docker_drop_privileged" // docker run --privileged → without it
+)
+
+type inputRewrite struct {
+	name    string
+	rewrite func(words []string, workDir string) (string, bool)
+	once    bool // a first step: applied once per session and command
+}
+
+// inputRewrites are tried in order; the first one that applies wins.
+var inputRewrites = []inputRewrite{
+	{name: RewriteForceWithLease, rewrite: rewriteForceWithLease},
+	{name: RewriteRmToTrash, rewrite: rewriteRmToTrash},
+	{name: RewriteKubectlDryRun, rewrite: rewriteKubectlDryRun, once: true},
+	{name: RewriteDockerDropPrivileged, rewrite: rewriteDockerDropPrivileged},
+}
+
+func isKnownRewrite(name string) bool {
+	for _, r := range inputRewrites {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrittenInput is a tool input replaced by a rewrite.
+type rewrittenInput struct {
+	name    string
+	command string
+	input   json.RawMessage
+}
+
+// unsafeToRequote matches commands whose words can't be split and requoted
+// without changing what the shell does.
+var unsafeToRequote = regexp.MustCompile("[$`*?\\[{~<>|;&\\n\\\\]")
+
+// rewriteInput returns a safer equivalent for a call that would ask, if an
+// enabled rewrite applies.
+func rewriteInput(toolName string, toolInput json.RawMessage, sessionID, workDir string) (rewrittenInput, bool) {
+	if toolName != "Bash" {
+		return rewrittenInput{}, false
+	}
+	var input map[string]interface{}
+	if err := json.Unmarshal(toolInput, &input); err != nil {
+		return rewrittenInput{}, false
+	}
+	command, _ := input["command"].(string)
+	command = strings.TrimSpace(command)
+	if command == "" || unsafeToRequote.MatchString(command) {
+		return rewrittenInput{}, false
+	}
+	words := shellWords(command)
+	if len(words) == 0 || strings.Contains(words[0], "=") {
+		return rewrittenInput{}, false
+	}
+
+	policy := currentPolicy()
+	for _, r := range inputRewrites {
+		if !policy.Rewrites[r.name] {
+			continue
+		}
+		rewritten, ok := r.rewrite(words, workDir)
+		if !ok {
+			continue
+		}
+		input["command"] = rewritten
+		data, err := json.Marshal(input)
+		if err != nil {
+			return rewrittenInput{}, false
+		}
+		if probeRules(toolName, data, workDir) != VerdictAllow {
+			continue
+		}
+		if r.once && rewriteSeen(r.name, sessionID, workDir, command) {
+			continue
+		}
+		return rewrittenInput{name: r.name, command: rewritten, input: data}, true
+	}
+	return rewrittenInput{}, false
+}
+
+// probeRules evaluates a candidate input without touching the notes of the
+// call being decided: the client still reads them for the log and the
+// session approval after the probe.
+func probeRules(toolName string, toolInput json.RawMessage, workDir string) Verdict {
+	notes, intents, line, remote := ruleNotes, intentNotes, currentLine, remoteShell
+	defer func() { ruleNotes, intentNotes, currentLine, remoteShell = notes, intents, line, remote }()
+	verdict, _ := EvaluateRules(toolName, toolInput, workDir)
+	return verdict
+}
+
+// rewriteSeen reports whether a once-only rewrite was already applied to
+// this command in this session, and records it if not. The marker lives in
+// the verdict cache.
+func rewriteSeen(name, sessionID, workDir, command string) bool {
+	seen := true
+	withVerdictCache(contentKey("rewrite", name, sessionID, workDir, command), func() (Verdict, string) {
+		seen = false
+		return VerdictAllow, "rewritten by " + name
+	})
+	return seen
+}
+
+// rewriteForceWithLease turns forced git pushes into --force-with-lease, which
+// refuses to drop commits the pusher hasn't fetched. Deletes and mirror or
+// --all pushes are left alone, and so are protected branches: a leased push
+// to one still asks.
+func rewriteForceWithLease(words []string, _ string) (string, bool) {
+	push := gitSubcommandIndex(words)
+	if push < 0 || words[push] != "push" {
+		return "", false
+	}
+
+	out := append([]string(nil), words[:push+1]...)
+	changed, lease, positional := false, false, 0
+	for _, arg := range words[push+1:] {
+		switch {
+		case arg == "--force" || arg == "-f":
+			arg, changed, lease = "--force-with-lease", true, true
+		case strings.HasPrefix(arg, "--force-with-lease"):
+			lease = true
+		case arg == "--delete" || arg == "-d" || arg == "--mirror" || arg == "--all" || arg == "--prune":
+			return "", false
+		case strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.Contains(arg, "f"):
+			return "", false // combined short flags
+		case !strings.HasPrefix(arg, "-"):
+			positional++
+			if positional > 1 && strings.HasPrefix(arg, ":") {
+				return "", false
+			}
+			if positional > 1 && strings.HasPrefix(arg, "+") {
+				arg, changed = arg[1:], true
+			}
+		}
+		out = append(out, arg)
+	}
+	if !changed {
+		return "", false
+	}
+	if !lease {
+		out = append(out[:push+1], append([]string{"--force-with-lease"}, out[push+1:]...)...)
+	}
+	return joinShellWords(out), true
+}
+
+// gitSubcommandIndex returns the index of the git subcommand, skipping
+// global options, or -1.
+func gitSubcommandIndex(words []string) int {
+	if filepath.Base(words[0]) != "git" {
+		return -1
+	}
+	for i := 1; i < len(words); i++ {
+		switch words[i] {
+		case "-C", "-c", "--git-dir", "--work-tree", "--namespace":
+			i++
+			continue
+		}
+		if !strings.HasPrefix(words[i], "-") {
+			return i
+		}
+	}
+	return -1
+}
+
+// rewriteRmToTrash moves the targets of a recursive rm into a fresh temporary
+// directory instead, so they can be recovered until the system cleans it up.
+// Only targets inside the project are moved; anything else still asks.
+func rewriteRmToTrash(words []string, workDir string) (string, bool) {
+	if filepath.Base(words[0]) != "rm" {
+		return "", false
+	}
+	recursive := false
+	var targets []string
+	options := true
+	for _, arg := range words[1:] {
+		switch {
+		case options && arg == "--":
+			options = false
+		case options && strings.HasPrefix(arg, "-"):
+			if arg == "--recursive" || !strings.HasPrefix(arg, "--") && strings.ContainsAny(arg, "rR") {
+				recursive = true
+			}
+		default:
+			if target := resolvePath(arg, workDir); target == filepath.Clean(workDir) || !isWithinDir(target, workDir) {
+				return "", false
+			}
+			targets = append(targets, arg)
+		}
+	}
+	if !recursive || len(targets) == 0 {
+		return "", false
+	}
+	return "mv -- " + joinShellWords(targets) + ` "$(mktemp -d "${TMPDIR:-/tmp}/almost-yolo-guard-trash.XXXXXX")"`, true
+}
+
+// rewriteKubectlDryRun runs kubectl apply as a server-side dry run first. The
+// same command asks normally the next time.
+func rewriteKubectlDryRun(words []string, _ string) (string, bool) {
+	if filepath.Base(words[0]) != "kubectl" {
+		return "", false
+	}
+	for i := 1; i < len(words); i++ {
+		arg := words[i]
+		if strings.HasPrefix(arg, "--dry-run") {
+			return "", false
+		}
+		if strings.HasPrefix(arg, "-") {
+			if kubectlValueFlags[arg] {
+				i++
+			}
+			continue
+		}
+		if arg != "apply" {
+			return "", false
+		}
+		for _, rest := range words[i+1:] {
+			if strings.HasPrefix(rest, "--dry-run") {
+				return "", false
+			}
+		}
+		out := append(append([]string(nil), words...), "--dry-run=server")
+		return joinShellWords(out), true
+	}
+	return "", false
+}
+
+// rewriteDockerDropPrivileged removes --privileged from docker/podman run and
+// create. The rules must allow what is left.
+func rewriteDockerDropPrivileged(words []string, _ string) (string, bool) {
+	base := filepath.Base(words[0])
+	if (base != "docker" && base != "podman") || len(words) < 3 || (words[1] != "run" && words[1] != "create") {
+		return "", false
+	}
+	out := words[:2:2]
+	dropped := false
+	for i := 2; i < len(words); i++ {
+		arg := words[i]
+		if !strings.HasPrefix(arg, "-") {
+			// The image; the rest is the container's command
+			out = append(out, words[i:]...)
+			break
+		}
+		if arg == "--privileged" || arg == "--privileged=true" {
+			dropped = true
+			continue
+		}
+		out = append(out, arg)
+		if option, _, inline := splitFlagValue(arg); !inline && dockerRunValueFlags[option] && i+1 < len(words) {
+			i++
+			out = append(out, words[i])
+		}
+	}
+	if !dropped {
+		return "", false
+	}
+	return joinShellWords(out), true
+}
+
+var shellSafeWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// joinShellWords quotes words so the shell splits them back the same way.
+func joinShellWords(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		if shellSafeWord.MatchString(w) {
+			quoted[i] = w
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(w, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
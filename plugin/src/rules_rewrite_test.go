@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRewriteInput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // keep once-only markers out of the real cache
+	t.Setenv("KUBECONFIG", "")
+	workDir := "/home/dev/project"
+
+	saved := activePolicy
+	defer func() { activePolicy = saved }()
+	activePolicy = defaultPolicy()
+	activePolicy.Rewrites = map[string]bool{
+		RewriteForceWithLease:       true,
+		RewriteRmToTrash:            true,
+		RewriteKubectlDryRun:        true,
+		RewriteDockerDropPrivileged: true,
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		want    string // "" = no rewrite
+	}{
+		// force_with_lease: a leased push to a protected branch still asks
+		{"force push protected", "git push --force origin main", ""},
+		{"plus refspec protected", "git push origin +HEAD:main", ""},
+		{"already leased", "git push --force-with-lease origin main", ""},
+		{"delete", "git push --delete origin main", ""},
+
+		// rm_to_trash
+		{"rm in project", "rm -rf build/../dist", `mv -- build/../dist "$(mktemp -d "${TMPDIR:-/tmp}/almost-yolo-guard-trash.XXXXXX")"`},
+		{"rm quoted target", "rm -r 'old build/../dist'", `mv -- 'old build/../dist' "$(mktemp -d "${TMPDIR:-/tmp}/almost-yolo-guard-trash.XXXXXX")"`},
+		{"rm outside project", "rm -rf ../old-build", ""},
+		{"rm system path", "rm -rf /var/lib/app", ""},
+		{"rm glob", "rm -rf ../build-*", ""},
+		{"rm home is denied", "rm -rf /home", ""},
+
+		// kubectl_dry_run
+		{"kubectl apply", "kubectl apply -f k8s/", "kubectl apply -f k8s/ --dry-run=server"},
+		{"kubectl apply again", "kubectl apply -f k8s/", ""},
+		{"kubectl delete", "kubectl delete deploy app", ""},
+
+		// docker_drop_privileged
+		{"docker privileged", "docker run --rm --privileged -v ./data:/data alpine ls", "docker run --rm -v ./data:/data alpine ls"},
+		{"docker still unsafe", "docker run --privileged --pid=host alpine", ""},
+
+		// Not rewritten
+		{"compound", "git push --force origin main && echo done", ""},
+		{"variable", "rm -rf $BUILD_DIR", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, _ := json.Marshal(map[string]string{"command": tt.command, "description": "test"})
+			rewritten, ok := rewriteInput("Bash", input, "session-1", workDir)
+			got := ""
+			if ok {
+				var out map[string]string
+				json.Unmarshal(rewritten.input, &out)
+				got = out["command"]
+				if out["description"] != "test" {
+					t.Errorf("rewrite dropped other input fields: %s", rewritten.input)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("rewriteInput(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteKeepsNotes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	workDir := "/home/dev/project"
+
+	saved := activePolicy
+	defer func() { activePolicy = saved }()
+	activePolicy = defaultPolicy()
+	activePolicy.Rewrites = map[string]bool{RewriteRmToTrash: true}
+
+	input, _ := json.Marshal(map[string]string{"command": "rm -rf build/../dist"})
+	if verdict, _ := EvaluateRules("Bash", input, workDir); verdict != VerdictAsk {
+		t.Fatalf("expected ASK before the rewrite, got %s", verdict)
+	}
+	note(noteProfile, profileDev) // as if the call had targeted a dev cluster
+	before := ruleNoteList()
+	if _, ok := rewriteInput("Bash", input, "session-1", workDir); !ok {
+		t.Fatal("expected a rewrite")
+	}
+	if after := ruleNoteList(); strings.Join(after, ",") != strings.Join(before, ",") {
+		t.Errorf("rewrite probe changed the notes: %v -> %v", before, after)
+	}
+}
+
+func TestRewriteForceWithLease(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string // "" = no rewrite
+	}{
+		{"git push --force origin main", "git push --force-with-lease origin main"},
+		{"git push -f origin master", "git push --force-with-lease origin master"},
+		{"git push origin +HEAD:main", "git push --force-with-lease origin HEAD:main"},
+		{"git -C web push --force origin main", "git -C web push --force-with-lease origin main"},
+		{"git push --force-with-lease origin main", ""},
+		{"git push --delete origin main", ""},
+		{"git push origin :main", ""},
+		{"git push -uf origin main", ""},
+	}
+	for _, tt := range tests {
+		got, _ := rewriteForceWithLease(shellWords(tt.command), "/home/dev/project")
+		if got != tt.want {
+			t.Errorf("rewriteForceWithLease(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteOptIn(t *testing.T) {
+	saved := activePolicy
+	defer func() { activePolicy = saved }()
+	activePolicy = defaultPolicy()
+	activePolicy.Rewrites = map[string]bool{RewriteRmToTrash: true}
+
+	input := json.RawMessage(`{"command":"git push --force origin main"}`)
+	if rewritten, ok := rewriteInput("Bash", input, "s", "/home/dev/project"); ok {
+		t.Errorf("force_with_lease is not enabled, got rewrite %q", rewritten.command)
+	}
+}
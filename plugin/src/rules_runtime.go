@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strings"
+)
+
+// --- language runtime handlers ---
+//
+// Inline code (python -c, node -e, ruby -e, deno eval, heredocs fed to an
+// interpreter) is tokenized and checked by analyzeInlineCode. Code that only
+// parses and prints is allowed; anything else goes to the evaluator with
+// the findings in the reason. Script files are read by evaluateScriptFile.
+
+// runtimeValueFlags are interpreter options that take a value.
+var runtimeValueFlags = map[string]bool{
+	"-W": true, "-X": true, "-m": true, // python
+	"-r": true, "--require": true, "--import": true, "--loader": true, "--input-type": true, // node
+	"-I": true, "-C": true, "-E": true, "-F": true, "-0": true, // ruby
+}
+
+// runtimeSafeSubcommands are deno and bun subcommands that only check, test
+// or build the project. Others install or run code from anywhere.
+var runtimeSafeSubcommands = map[string]map[string]bool{
+	"deno": {"fmt": true, "lint": true, "check": true, "test": true, "bench": true, "info": true,
+		"doc": true, "types": true, "coverage": true, "help": true, "repl": true},
+	"bun": {"test": true, "build": true, "help": true, "repl": true, "outdated": true},
+}
+
+// pythonServerModules open a listening socket when run with python -m.
+var pythonServerModules = map[string]bool{
+	"http.server": true, "xmlrpc.server": true, "wsgiref.simple_server": true,
+	"smtpd": true, "aiosmtpd": true, "socketserver": true,
+}
+
+func evaluateRuntime(cmd string, args []string, stdin string, env map[string]string, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	lang := inlineLanguage(cmd)
+
+	var code []string
+	var findings codeFindings
+	script, module := "", false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flag, value, inline := splitFlagValue(arg)
+		switch {
+		case arg == "-c" || arg == "-e" || arg == "--eval" || arg == "-p" || arg == "--print":
+			if cmd == "ruby" && arg == "-p" {
+				continue // ruby -p loops over input lines
+			}
+			if i+1 < len(args) {
+				code = append(code, args[i+1])
+				i++
+			}
+			if lang == langPython {
+				i = len(args) // the rest is sys.argv
+			}
+		case (lang == langPython || lang == langRuby) && len(arg) > 2 && arg[0] == '-' && arg[1] != '-' &&
+			strings.HasSuffix(arg, map[string]string{langPython: "c", langRuby: "e"}[lang]):
+			// combined short flags ending in the code flag: -Bc, -ne, -pe
+			if cmd == "ruby" && strings.HasPrefix(arg, "-i") {
+				findings.add("edits files in place")
+			}
+			if i+1 < len(args) {
+				code = append(code, args[i+1])
+				i++
+			}
+			if lang == langPython {
+				i = len(args)
+			}
+		case arg == "-":
+			i = len(args) // program from stdin; the rest is argv
+		case cmd == "deno" && arg == "eval":
+			if i+1 < len(args) {
+				code = append(code, args[i+1])
+			}
+			i = len(args)
+		case (cmd == "deno" || cmd == "bun") && arg == "run":
+		case (cmd == "deno" || cmd == "bun") && !strings.HasPrefix(arg, "-") && !strings.ContainsAny(arg, "./"):
+			if runtimeSafeSubcommands[cmd][arg] {
+				return VerdictAllow, cmd + " " + arg
+			}
+			return VerdictUncertain, cmd + " " + arg
+		case cmd == "ruby" && strings.HasPrefix(arg, "-r") && len(arg) > 2:
+			rubyRequire(&findings, arg[2:])
+		case cmd == "ruby" && strings.HasPrefix(arg, "-i"):
+			findings.add("edits files in place")
+		case runtimeValueFlags[flag]:
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			switch {
+			case lang == langPython && flag == "-m" && value == "pip":
+				return evaluatePip(cmd+" -m pip", args[i+1:], env)
+			case lang == langPython && flag == "-m" && (pythonServerModules[value] ||
+				value == "pydoc" && hasFlag(args[i+1:], "-p", "-b")):
+				return VerdictUncertain, cmd + " -m " + value + " (opens a listening socket)"
+			case lang == langPython && flag == "-m":
+				module, i = true, len(args) // the rest is the module's argv
+			case cmd == "ruby" && flag == "-r":
+				rubyRequire(&findings, value)
+			case lang == langJS && (flag == "-r" || flag == "--require" || flag == "--import"):
+				findings.add("preloads " + value)
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+		default:
+			if len(code) == 0 {
+				script = arg
+			}
+			i = len(args)
+		}
+	}
+
+	// A heredoc or here-string is the program when no script is named
+	if len(code) == 0 && script == "" && !module {
+		switch {
+		case stdin != "":
+			code = append(code, stdin)
+		case strings.HasPrefix(stdinFile, "\x00"):
+			code = append(code, stdinFile[1:])
+		case stdinFile != "":
+			script = stdinFile
+		}
+	}
+
+	if len(code) == 0 && len(findings.list) > 0 {
+		return VerdictUncertain, cmd + ": " + strings.Join(findings.list, ", ")
+	}
+	if len(code) == 0 {
+		switch {
+		case script != "" && lang != "":
+			return evaluateScriptFile(cmd, script, workDir)
+		case len(args) == 0 && stdinFile == "":
+			return VerdictAllow, cmd + " (REPL)"
+		}
+		return VerdictAllow, cmd + " (script)"
+	}
+	if lang == "" {
+		return VerdictUncertain, cmd + " with inline code"
+	}
+
+	for _, finding := range analyzeInlineCode(lang, strings.Join(code, "\n"), workDir) {
+		findings.add(finding)
+	}
+	if len(findings.list) > 0 {
+		return VerdictUncertain, cmd + " inline code: " + strings.Join(findings.list, ", ")
+	}
+	return VerdictAllow, cmd + " inline code (parses and prints only)"
+}
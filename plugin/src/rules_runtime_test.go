@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluateInlineCode(t *testing.T) {
+	workDir := "/home/dev/project"
+
+	tests := []struct {
+		name     string
+		command  string
+		want     Verdict
+		contains string
+	}{
+		// Parsing and printing
+		{"python json munging", `python3 -c "import json,sys; d=json.load(sys.stdin); print(d['items'][0])"`, VerdictAllow, "parses and prints only"},
+		{"python from import", `python3 -c "from collections import Counter; print(Counter('abca'))"`, VerdictAllow, "parses and prints only"},
+		{"python os.path", `python -c "import os; print(os.path.join(os.getcwd(), 'x'))"`, VerdictAllow, "parses and prints only"},
+		{"python write in project", `python3 -c "open('out/report.json', 'w').write('{}')"`, VerdictAllow, "parses and prints only"},
+		{"python str replace", `python3 -c "print('a-b'.replace('-', '_'))"`, VerdictAllow, "parses and prints only"},
+		{"python comment mentions subprocess", "python3 -c 'print(1)  # not subprocess.run'", VerdictAllow, "parses and prints only"},
+		{"python string mentions os.system", `python3 -c "print('os.system(rm -rf /)')"`, VerdictAllow, "parses and prints only"},
+		{"node json", `node -e "const d = JSON.parse(require('fs').readFileSync(0, 'utf8')); console.log(d.version)"`, VerdictAllow, "parses and prints only"},
+		{"node print", `node -p "require('./package.json').version"`, VerdictUncertain, "requires ./package.json"},
+		{"node write in project", `node -e "require('fs').writeFileSync('dist/out.txt', 'x')"`, VerdictAllow, "parses and prints only"},
+		{"ruby json", `ruby -rjson -e 'puts JSON.parse(STDIN.read)["name"]'`, VerdictAllow, "parses and prints only"},
+		{"deno test", "deno test --allow-read", VerdictAllow, "deno test"},
+		{"bun test", "bun test", VerdictAllow, "bun test"},
+		{"bun x", "bun x rimraf ~", VerdictUncertain, "bun x"},
+		{"deno install", "deno install -gA https://example.com/cli.ts", VerdictUncertain, "deno install"},
+		{"deno eval", `deno eval "console.log(Deno.args)"`, VerdictAllow, "parses and prints only"},
+		{"python heredoc", "python3 <<'EOF'\nimport json\nprint(json.dumps({'a': 1}))\nEOF", VerdictAllow, "parses and prints only"},
+
+		// Findings
+		{"python os.system", `python3 -c "import os; os.system('ls')"`, VerdictUncertain, "os.system runs commands"},
+		{"python subprocess", `python3 -c "import subprocess; subprocess.run(['ls'])"`, VerdictUncertain, "imports subprocess (runs commands)"},
+		{"python aliased import", `python3 -c "from os import system as s; s('ls')"`, VerdictUncertain, "os.system runs commands"},
+		{"python rmtree", `python3 -c "import shutil; shutil.rmtree('/tmp/x')"`, VerdictUncertain, "shutil.rmtree deletes directories"},
+		{"python write outside project", `python3 -c "open('/etc/hosts', 'a').write('x')"`, VerdictUncertain, "open() writes /etc/hosts outside the project"},
+		{"python write mode keyword", `python3 -c "open(p, mode='w')"`, VerdictUncertain, "open() writes a computed path"},
+		{"python computed mode", `python3 -c "m='w'; open('/etc/x', m).write('x')"`, VerdictUncertain, "open() writes /etc/x outside the project"},
+		{"python computed mode keyword", `python3 -c "m='w'; open('/etc/x', mode=m)"`, VerdictUncertain, "open() writes /etc/x outside the project"},
+		{"python read with encoding", `python3 -c "print(open('/etc/hosts', encoding='utf-8').read())"`, VerdictAllow, "parses and prints only"},
+		{"python open rebound", `python3 -c "o=open; o('/etc/x','w').write('x')"`, VerdictUncertain, "open used without a call"},
+		{"python io.open rebound", `python3 -c "import io; w=io.open; w('/etc/x','w')"`, VerdictUncertain, "io.open used without a call"},
+		{"python http.server", "python3 -m http.server 8000", VerdictUncertain, "opens a listening socket"},
+		{"python pydoc server", "python3 -m pydoc -p 8080", VerdictUncertain, "opens a listening socket"},
+		{"python json.tool", "python3 -m json.tool data.json", VerdictAllow, "python3"},
+		{"python eval", `python3 -c "eval(input())"`, VerdictUncertain, "eval"},
+		{"python dunder import", `python3 -c "__import__('os').system('ls')"`, VerdictUncertain, "dynamic import"},
+		{"python f-string call", `python3 -c "import os; print(f'{os.remove(\"x\")}')"`, VerdictUncertain, "os.remove deletes files"},
+		{"python requests", `python3 -c "import requests; print(requests.get('https://x').text)"`, VerdictUncertain, "imports requests (network)"},
+		{"python pathlib unlink", `python3 -c "from pathlib import Path; Path('x').unlink()"`, VerdictUncertain, "unlink() changes files"},
+		{"python heredoc subprocess", "python3 - <<EOF\nimport subprocess\nsubprocess.call('ls')\nEOF", VerdictUncertain, "imports subprocess"},
+		{"node child_process", `node -e "require('child_process').execSync('ls')"`, VerdictUncertain, "requires child_process (runs commands)"},
+		{"node fs.rm", `node -e "require('fs').rmSync('dist', {recursive: true})"`, VerdictUncertain, "rmSync() deletes files"},
+		{"node destructured unlink", `node -e "const {unlinkSync} = require('node:fs'); unlinkSync('x')"`, VerdictUncertain, "unlinkSync() deletes files"},
+		{"node write outside", `node -e "require('fs').writeFileSync('/etc/motd', 'x')"`, VerdictUncertain, "writeFileSync() writes /etc/motd outside the project"},
+		{"node net", `node -e "require('net').connect(22, 'example.com')"`, VerdictUncertain, "requires net (network)"},
+		{"node fetch", `node -e "fetch('https://example.com').then(r => r.text())"`, VerdictUncertain, "fetch network"},
+		{"node eval", `node -e "eval(process.argv[1])"`, VerdictUncertain, "eval"},
+		{"node template literal", "node -e 'console.log(`${require(\"child_process\").execSync(\"id\")}`)'", VerdictUncertain, "child_process"},
+		{"node import", `node --input-type=module -e "import { exec } from 'node:child_process'; exec('ls')"`, VerdictUncertain, "child_process"},
+		{"ruby backticks", "ruby -e 'puts `whoami`'", VerdictUncertain, "runs `whoami`"},
+		{"ruby system", `ruby -e 'system("rm", "-rf", "x")'`, VerdictUncertain, "system runs commands"},
+		{"ruby fileutils", `ruby -rfileutils -e 'FileUtils.rm_rf("build")'`, VerdictUncertain, "FileUtils.rm_rf changes files"},
+		{"ruby net http", `ruby -rnet/http -e 'puts Net::HTTP.get(URI("https://x"))'`, VerdictUncertain, "requires net/http (network)"},
+		{"ruby open pipe", `ruby -e 'open("|ls").read'`, VerdictUncertain, "open runs `ls`"},
+		{"ruby interpolation", `ruby -e 'puts "#{%x(id)}"'`, VerdictUncertain, "runs `id`"},
+		{"ruby in place", `ruby -i -pe 'gsub(/a/, "b")' file.txt`, VerdictUncertain, "edits files in place"},
+		{"python getattr", `python3 -c "import os; getattr(os,'sys'+'tem')('rm -rf ~')"`, VerdictUncertain, "dynamic attribute access"},
+		{"python execvpe", `python3 -c "import os; os.execvpe('sh', ['sh'], {})"`, VerdictUncertain, "os.execvpe runs programs"},
+		{"python sys.modules", `python3 -c "import sys; sys.modules['os'].system('id')"`, VerdictUncertain, "sys.modules dynamic module access"},
+		{"python dunder dict", `python3 -c "import os; os.__dict__['system']('id')"`, VerdictUncertain, "__dict__ dynamic attribute access"},
+		{"python os.open outside", `python3 -c "import os; fd = os.open('/home/u/.bashrc', os.O_WRONLY | os.O_APPEND); os.write(fd, b'x')"`, VerdictUncertain, "os.open() writes /home/u/.bashrc outside the project"},
+		{"python os.open read", `python3 -c "import os; fd = os.open('/etc/hosts', os.O_RDONLY); print(os.read(fd, 10))"`, VerdictAllow, "parses and prints only"},
+		{"python main guard", "python3 - <<'EOF'\nimport json\nif __name__ == '__main__':\n    print(json.dumps(1))\nEOF", VerdictAllow, "parses and prints only"},
+		{"node mainModule require", `node -e "process.mainModule.require('child_process').execSync('id')"`, VerdictUncertain, "child_process"},
+		{"node process index", `node -e "process['binding']('spawn_sync')"`, VerdictUncertain, "process[...] dynamic property access"},
+		{"node openSync append", `node -e "const fs = require('fs'); fs.writeSync(fs.openSync('/home/u/.bashrc', 'a'), 'x')"`, VerdictUncertain, "openSync() writes /home/u/.bashrc outside the project"},
+		{"node openSync read", `node -e "const fs = require('fs'); console.log(fs.openSync('/etc/hosts'))"`, VerdictAllow, "parses and prints only"},
+		{"ruby IO.read pipe", `ruby -e "IO.read('|rm -rf ~')"`, VerdictUncertain, "IO.read runs `rm -rf ~`"},
+		{"ruby send", `ruby -e 'send(:system, "id")'`, VerdictUncertain, "send dynamic call"},
+		{"ruby send without parens", `ruby -e 'Kernel.send :system, "id"'`, VerdictUncertain, "Kernel.send dynamic call"},
+		{"ruby public_send", `ruby -e 'Kernel.public_send(:exec, "id")'`, VerdictUncertain, "dynamic call"},
+		{"node preload", `node -r ./hook.js -e "console.log(1)"`, VerdictUncertain, "preloads ./hook.js"},
+		{"swift inline", `swift -e 'print(1)'`, VerdictUncertain, "with inline code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, workDir)
+			if got != tt.want || !strings.Contains(reason, tt.contains) {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v containing %q", tt.command, got, reason, tt.want, tt.contains)
+			}
+		})
+	}
+}
+
+func TestCodeTokens(t *testing.T) {
+	tokens := codeTokens(langPython, "import os.path as p  # os.system\nx = 'os.remove'\np.join(a).strip()")
+	var names []string
+	for _, tok := range tokens {
+		if tok.kind == tokName {
+			names = append(names, tok.text)
+		}
+	}
+	want := "import os.path as p x p.join a .strip"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("codeTokens names = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,694 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// --- script handlers ---
+//
+// Running a script runs whatever it contains, so scripts in the project are
+// read before they are allowed: shell scripts are evaluated command by
+// command like any other command line, Python, JavaScript and Ruby scripts
+// go through analyzeInlineCode. Verdicts are cached by the script's
+// contents. Scripts outside the project, and scripts that the same command
+// line downloads or writes, can't be read ahead of time and go to the
+// evaluator.
+
+// langShell is the language of shell scripts, evaluated by evaluateCommand.
+const langShell = "shell"
+
+// lineContext is what the earlier segments of the command line being
+// evaluated tell about the later ones. Rules are evaluated one request at a
+// time, like taskStack.
+type lineContext struct {
+	written   map[string]string // absolute path -> "downloaded" or "written"
+	functions map[string]string // shell functions defined on the line -> body
+	vars      map[string]string // variables holding a known directory or literal
+	segments  int               // segments evaluated so far
+	caseDepth int               // open case statements
+	piped     bool              // the segment being evaluated reads piped input
+}
+
+var currentLine *lineContext
+
+// evaluateShell handles bash, sh and friends: -c strings are evaluated as
+// command lines, a script argument as a script file, and commands on
+// standard input (heredoc, here-string or < file) likewise.
+func evaluateShell(cmd string, args []string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-c" || (len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.HasSuffix(arg, "c")):
+			if i+1 >= len(args) {
+				return VerdictUncertain, cmd + " " + arg + " (no command)"
+			}
+			verdict, reason := evaluateCommand(args[i+1], workDir)
+			return verdict, cmd + " -c: " + reason
+		case arg == "-s":
+			i = len(args) // commands from stdin; the rest is argv
+		case arg == "--":
+			if i+1 < len(args) {
+				return evaluateScriptFile(cmd, args[i+1], workDir)
+			}
+		case arg == "-o" || arg == "+o" || arg == "-O" || arg == "+O" || arg == "--rcfile" || arg == "--init-file":
+			i++
+		case strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+"):
+		default:
+			return evaluateScriptFile(cmd, arg, workDir)
+		}
+	}
+
+	code := stdin
+	switch {
+	case code == "" && strings.HasPrefix(stdinFile, "\x00"):
+		code = stdinFile[1:]
+	case code == "" && stdinFile != "":
+		return evaluateScriptFile(cmd, stdinFile, workDir)
+	case code == "":
+		return VerdictAllow, cmd + " (interactive shell)"
+	}
+	verdict, reason := evaluateCommand(code, workDir)
+	if reason == "" {
+		return VerdictAllow, cmd + " (no commands)"
+	}
+	return verdict, cmd + " stdin: " + reason
+}
+
+// evaluateScriptFile reads a script the command runs and evaluates its
+// contents. runner is the interpreter named on the command line, or empty
+// when the script is run by path.
+func evaluateScriptFile(runner, script, workDir string) (Verdict, string) {
+	name := strings.TrimSpace(runner + " " + script)
+	path := resolvePath(expandHome(script), workDir)
+
+	if currentLine != nil && currentLine.written[path] != "" {
+		return VerdictUncertain, name + " (script " + currentLine.written[path] + " by this command)"
+	}
+	if workDir == "" || !isWithinDir(path, workDir) {
+		return VerdictUncertain, name + " (script outside the project)"
+	}
+
+	dependOnFile(path)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		// Only an earlier command on the line could create it
+		if currentLine != nil && currentLine.segments > 0 {
+			return VerdictUncertain, name + " (script not found; created by this command?)"
+		}
+		return VerdictAllow, name + " (script not found)"
+	}
+	if err != nil || info.IsDir() || info.Size() > maxScriptSize {
+		return VerdictUncertain, name + " (cannot read script)"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return VerdictUncertain, name + " (cannot read script)"
+	}
+
+	lang := scriptLanguage(runner, data)
+	if lang == "" {
+		return VerdictUncertain, name + " (not a script)"
+	}
+
+	stackKey := "script\x00" + path
+	if taskStack[stackKey] {
+		return VerdictUncertain, name + " (runs itself)"
+	}
+	if len(taskStack) >= maxTaskDepth {
+		return VerdictUncertain, name + " (scripts nested too deeply)"
+	}
+	taskStack[stackKey] = true
+	defer delete(taskStack, stackKey)
+
+	key := contentKey("script", runner, path, workDir, string(data))
+	return withVerdictCache(key, func() (Verdict, string) {
+		if lang == langShell {
+			return evaluateShellScript(name, string(data), path, workDir)
+		}
+		findings := analyzeInlineCode(lang, string(data), workDir)
+		if len(findings) > 0 {
+			return VerdictUncertain, name + ": " + strings.Join(findings, ", ")
+		}
+		return VerdictAllow, name + " (script parses and prints only)"
+	})
+}
+
+// evaluateShellScript evaluates a shell script's commands, with $0 and
+// BASH_SOURCE standing for the script itself.
+func evaluateShellScript(name, content, path, workDir string) (Verdict, string) {
+	dir := filepath.Dir(path)
+	content = strings.NewReplacer(
+		"${0%/*}", dir, "${BASH_SOURCE[0]%/*}", dir,
+		"${BASH_SOURCE[0]}", path, "${BASH_SOURCE}", path, "$BASH_SOURCE", path,
+		"${0}", path, "$0", path,
+	).Replace(content)
+
+	// The script runs in its own shell: nothing it defines leaks into the
+	// caller's line, and its verdict doesn't depend on the line
+	saved := currentLine
+	currentLine = nil
+	defer func() { currentLine = saved }()
+
+	// As in EvaluateRules, a line that touches the guard decides unless the
+	// rest of the script is denied
+	protectVerdict, protectReason, protectHit := evaluateCommandSelfProtection(content, workDir)
+	verdict, reason := evaluateCommand(content, workDir)
+	if protectHit && verdict != VerdictDeny {
+		return protectVerdict, name + ": " + protectReason
+	}
+	if reason == "" {
+		return VerdictAllow, name + " (no commands)"
+	}
+	return verdict, name + ": " + reason
+}
+
+// scriptLanguage returns the language a script is run as: the runner's, or
+// for scripts run by path the shebang interpreter's. A text file without a
+// shebang is run by the shell.
+func scriptLanguage(runner string, data []byte) string {
+	switch runner {
+	case "bash", "sh", "zsh", "dash", "ksh", "source", ".":
+		return langShell
+	case "":
+	default:
+		return inlineLanguage(runner)
+	}
+
+	if interpreter := shebangInterpreter(string(data)); interpreter != "" {
+		switch interpreter {
+		case "bash", "sh", "zsh", "dash", "ksh":
+			return langShell
+		}
+		return inlineLanguage(interpreter)
+	}
+	if strings.ContainsRune(string(firstBytes(data, 512)), 0) {
+		return "" // a binary
+	}
+	return langShell
+}
+
+// shebangInterpreter returns the program named by a #! line, looking
+// through env, or "".
+func shebangInterpreter(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	if filepath.Base(fields[0]) != "env" {
+		return filepath.Base(fields[0])
+	}
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+			return filepath.Base(field)
+		}
+	}
+	return ""
+}
+
+func firstBytes(data []byte, n int) []byte {
+	if len(data) > n {
+		return data[:n]
+	}
+	return data
+}
+
+// --- shell syntax ---
+
+// shellBuiltins only change the shell's own state.
+var shellBuiltins = map[string]bool{
+	"set": true, "shopt": true, "local": true, "export": true, "readonly": true,
+	"declare": true, "typeset": true, "return": true, "exit": true, "shift": true,
+	"read": true, "unset": true, "wait": true, ":": true, "[[": true, "((": true,
+	"trap": true, "getopts": true, "break": true, "continue": true, "umask": true,
+	"alias": true, "unalias": true,
+}
+
+// evaluateTrap evaluates the command a trap runs. trap -p, trap -l and
+// resetting a signal (trap - INT) run nothing.
+func evaluateTrap(args []string, workDir string) (Verdict, string) {
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) < 2 || args[0] == "-" || args[0] == "" || strings.HasPrefix(args[0], "-") {
+		return VerdictAllow, "shell builtin: trap"
+	}
+	verdict, reason := evaluateCommand(args[0], workDir)
+	return verdict, "trap " + strings.Join(args[1:], " ") + ": " + reason
+}
+
+// shellPrefixWords are reserved words that may precede a command.
+var shellPrefixWords = map[string]bool{
+	"if": true, "then": true, "elif": true, "else": true, "do": true,
+	"while": true, "until": true, "!": true, "{": true,
+}
+
+// shellClosingWords end a compound command; only redirections may follow.
+var shellClosingWords = map[string]bool{
+	"fi": true, "done": true, "esac": true, "}": true, ")": true,
+}
+
+// shellFunctionHeader matches name() and function name, optionally
+// followed by the body's opening brace.
+var shellFunctionHeader = regexp.MustCompile(`^(?:function\s+([A-Za-z_][\w:-]*)\s*(?:\(\s*\))?|([A-Za-z_][\w:-]*)\s*\(\s*\))\s*`)
+
+// unwrapShellSyntax strips the reserved words, function headers and case
+// patterns around a simple command. It returns "" when the segment is only
+// syntax, such as fi, done or a for loop header.
+func unwrapShellSyntax(segment string) string {
+	for {
+		segment = strings.TrimSpace(segment)
+		word := segment
+		if i := strings.IndexAny(segment, " \t"); i >= 0 {
+			word = segment[:i]
+		}
+
+		switch {
+		case segment == "":
+			return ""
+		case shellPrefixWords[word]:
+			segment = segment[len(word):]
+		case shellClosingWords[word]:
+			if word == "esac" && currentLine != nil && currentLine.caseDepth > 0 {
+				currentLine.caseDepth--
+			}
+			return ""
+		case word == "case":
+			if currentLine != nil {
+				currentLine.caseDepth++
+			}
+			return "" // the subject is data
+		case word == "for" || word == "select":
+			return "" // the loop words are data
+		case strings.HasPrefix(word, "(("):
+			return "" // arithmetic
+		case strings.HasPrefix(segment, "("):
+			segment = segment[1:] // subshell
+		case shellFunctionHeader.MatchString(segment) && !strings.Contains(word, "$"):
+			segment = segment[len(shellFunctionHeader.FindString(segment)):]
+		case strings.HasSuffix(word, ")") && !strings.Contains(word, "(") &&
+			(len(word) < len(segment) || currentLine != nil && currentLine.caseDepth > 0):
+			segment = segment[len(word):] // case pattern, alone or followed by its command
+		default:
+			// A subshell closing after the command
+			if strings.HasSuffix(segment, ")") && strings.Count(segment, ")") > strings.Count(segment, "(") {
+				return strings.TrimSuffix(segment, ")")
+			}
+			return segment
+		}
+	}
+}
+
+// shellFunctionDefinition matches a function definition at the start of a
+// line, up to its body.
+var shellFunctionDefinition = regexp.MustCompile(`(?m)^[ \t]*(?:function[ \t]+([A-Za-z_][\w:-]*)(?:[ \t]*\([ \t]*\))?|([A-Za-z_][\w:-]*)[ \t]*\([ \t]*\))\s*`)
+
+// shellFunctions returns the functions a command line or script defines,
+// with their bodies ("" when the body can't be found).
+func shellFunctions(command string) map[string]string {
+	functions := map[string]string{}
+	for _, m := range shellFunctionDefinition.FindAllStringSubmatchIndex(command, -1) {
+		var name string
+		if m[2] >= 0 {
+			name = command[m[2]:m[3]]
+		} else {
+			name = command[m[4]:m[5]]
+		}
+		body := ""
+		if rest := command[m[1]:]; rest != "" && (rest[0] == '{' || rest[0] == '(') {
+			if end := matchingBracket(rest, 0); end > 0 {
+				body = strings.TrimSpace(rest[1:end])
+			}
+		}
+		functions[name] = body
+	}
+	return functions
+}
+
+// functionArgRef matches "$@", "$*", "$1" and their unquoted and braced
+// forms.
+var functionArgRef = regexp.MustCompile(`"\$\{?([1-9@*])\}?"|\$\{?([1-9@*])\}?`)
+
+// evaluateFunctionCall evaluates a call to a function defined on the line:
+// its body with the call's arguments in place of $@ and $1, and, when the
+// function is named after a command, the call as that command too. A
+// function can't vouch for the command it shadows.
+func evaluateFunctionCall(name, body, segment, stdin string, args []string, workDir string) (Verdict, string) {
+	if body == "" {
+		return VerdictUncertain, "shell function " + name + " (body not found)"
+	}
+	stackKey := "function\x00" + name
+	if taskStack[stackKey] {
+		return VerdictUncertain, "shell function " + name + " (calls itself)"
+	}
+	if len(taskStack) >= maxTaskDepth {
+		return VerdictUncertain, "shell function " + name + " (nested too deeply)"
+	}
+	taskStack[stackKey] = true
+	defer delete(taskStack, stackKey)
+
+	expanded := functionArgRef.ReplaceAllStringFunc(body, func(ref string) string {
+		m := functionArgRef.FindStringSubmatch(ref)
+		switch n := m[1] + m[2]; n {
+		case "@", "*":
+			return joinShellWords(args)
+		default:
+			if i := int(n[0] - '1'); i < len(args) {
+				return joinShellWords(args[i : i+1])
+			}
+			return "''"
+		}
+	})
+	verdict, reason := evaluateCommand(expanded, workDir)
+	reason = "shell function " + name + ": " + reason
+
+	delete(currentLine.functions, name)
+	asCommand, commandReason := evaluateSegmentWithInput(segment, stdin, workDir)
+	currentLine.functions[name] = body
+	if asCommand > verdict && !strings.HasPrefix(commandReason, "unknown command: ") {
+		return asCommand, commandReason
+	}
+	return verdict, reason
+}
+
+// commandSubstitutions returns the commands inside $( ... ), backticks and
+// the process substitutions <( ... ) and >( ... ), outside single quotes. Nested substitutions are found when the outer one
+// is evaluated.
+func commandSubstitutions(segment string) []string {
+	var out []string
+	inSingle := false
+	for i := 0; i < len(segment); i++ {
+		ch := segment[i]
+		switch {
+		case ch == '\\':
+			i++
+		case ch == '\'':
+			// Single quotes inside double quotes are literal, but a
+			// substitution there is rare enough not to track
+			inSingle = !inSingle
+		case inSingle:
+		case ch == '$' && strings.HasPrefix(segment[i:], "$(("):
+			// arithmetic expansion
+		case (ch == '$' || ch == '<' || ch == '>') && i+1 < len(segment) && segment[i+1] == '(':
+			end := matchingBracket(segment[i+1:], 0)
+			if end < 0 {
+				out = append(out, segment[i+2:])
+				return out
+			}
+			out = append(out, segment[i+2:i+1+end])
+			i += 1 + end
+		case ch == '`':
+			end := strings.IndexByte(segment[i+1:], '`')
+			if end < 0 {
+				out = append(out, segment[i+1:])
+				return out
+			}
+			out = append(out, segment[i+1:i+1+end])
+			i += 1 + end
+		}
+	}
+	return out
+}
+
+// stripShellComments removes # comments, which start a word outside quotes.
+func stripShellComments(command string) string {
+	if !strings.Contains(command, "#") {
+		return command
+	}
+	var b strings.Builder
+	inSingle, inDouble := false, false
+	for i := 0; i < len(command); i++ {
+		ch := command[i]
+		switch {
+		case ch == '\\' && !inSingle && i+1 < len(command):
+			b.WriteByte(ch)
+			i++
+			ch = command[i]
+		case ch == '\'' && !inDouble:
+			inSingle = !inSingle
+		case ch == '"' && !inSingle:
+			inDouble = !inDouble
+		case ch == '#' && !inSingle && !inDouble && (i == 0 || strings.IndexByte(" \t\n;&|(", command[i-1]) >= 0):
+			end := strings.IndexByte(command[i:], '\n')
+			if end < 0 {
+				return b.String()
+			}
+			i += end
+			ch = '\n'
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// --- directories and written files ---
+
+// dirnameCall matches $(dirname PATH) and `dirname PATH` with a literal path.
+var dirnameCall = regexp.MustCompile("\\$\\(dirname\\s+(?:--\\s+)?\"?([^\"$()`\\s]+)\"?\\)|`dirname\\s+\"?([^\"$()`\\s]+)\"?`")
+
+// cdPwd matches $(cd DIR && pwd), the usual way to make a directory absolute.
+var cdPwd = regexp.MustCompile(`^"?\$\(\s*cd\s+"?([^"$()\s]+)"?\s*(?:&&|;)\s*pwd(?:\s+-P)?\s*\)"?$`)
+
+// resolveDirnames replaces dirname calls on literal paths with their result.
+func resolveDirnames(s string) string {
+	return dirnameCall.ReplaceAllStringFunc(s, func(call string) string {
+		m := dirnameCall.FindStringSubmatch(call)
+		return filepath.Dir(m[1] + m[2])
+	})
+}
+
+// shellVarRef matches $VAR and ${VAR}.
+var shellVarRef = regexp.MustCompile(`\$\{([A-Za-z_]\w*)\}|\$([A-Za-z_]\w*)`)
+
+// expandKnownVars replaces variables recorded on the line with their
+// values. It reports false if anything else is left to expand.
+func expandKnownVars(s string) (string, bool) {
+	known := true
+	s = shellVarRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := shellVarRef.FindStringSubmatch(ref)
+		if currentLine != nil {
+			if value, ok := currentLine.vars[m[1]+m[2]]; ok {
+				return value
+			}
+		}
+		known = false
+		return ref
+	})
+	return s, known && !strings.ContainsAny(s, "$`")
+}
+
+// substituteLineVars replaces references to variables recorded on the line
+// with their values, outside single quotes, so a later segment is judged by
+// what it expands to (D=/; rm -rf $D). Values that would change how the
+// segment parses are left as references.
+func substituteLineVars(s string) string {
+	if currentLine == nil || len(currentLine.vars) == 0 || !strings.Contains(s, "$") {
+		return s
+	}
+	var out strings.Builder
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && !inSingle && i+1 < len(s):
+			out.WriteString(s[i : i+2])
+			i++
+			continue
+		case s[i] == '\'' && !inDouble:
+			inSingle = !inSingle
+		case s[i] == '"' && !inSingle:
+			inDouble = !inDouble
+		case s[i] == '$' && !inSingle:
+			if m := shellVarRef.FindStringSubmatch(s[i:]); m != nil && strings.HasPrefix(s[i:], m[0]) {
+				if value, ok := currentLine.vars[m[1]+m[2]]; ok && !strings.ContainsAny(value, "$`'\"\\;&|<>(){}\n") {
+					out.WriteString(value)
+					i += len(m[0]) - 1
+					continue
+				}
+			}
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+// changeDir returns the directory a cd, pushd or popd moves to, or false
+// if it can't be known or leaves the project.
+func changeDir(segment, workDir, project string, stack *[]string) (string, bool) {
+	if extractBaseCommand(segment) == "popd" {
+		if len(*stack) == 0 {
+			return "", false
+		}
+		dir := (*stack)[len(*stack)-1]
+		*stack = (*stack)[:len(*stack)-1]
+		return dir, true
+	}
+
+	args, _ := splitRedirects(extractArgs(resolveDirnames(segment)))
+	target := ""
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			target = arg
+			break
+		}
+	}
+	target, known := expandKnownVars(target)
+	if target == "" || target == "-" || !known || workDir == "" {
+		return "", false
+	}
+	dir := resolvePath(expandHome(target), workDir)
+	if project == "" || !isWithinDir(dir, project) {
+		return "", false
+	}
+	if extractBaseCommand(segment) == "pushd" {
+		*stack = append(*stack, workDir)
+	}
+	return dir, true
+}
+
+var shellVarName = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// recordAssignment remembers NAME=value when the value is a literal or a
+// directory made absolute with $(cd DIR && pwd).
+func (c *lineContext) recordAssignment(segment, workDir string) {
+	segment = strings.TrimPrefix(strings.TrimPrefix(segment, "export "), "local ")
+	name, value, ok := strings.Cut(strings.TrimSpace(segment), "=")
+	if !ok || !shellVarName.MatchString(name) {
+		return
+	}
+	value = resolveDirnames(value)
+	if m := cdPwd.FindStringSubmatch(value); m != nil {
+		if dir, known := expandKnownVars(m[1]); known {
+			c.vars[name] = resolvePath(dir, workDir)
+			return
+		}
+	}
+	if words := shellWords(value); len(words) == 1 {
+		if literal, known := expandKnownVars(words[0]); known {
+			c.vars[name] = literal
+			return
+		}
+	}
+	delete(c.vars, name)
+}
+
+// recordWrites remembers the files a segment downloads or redirects output
+// to, so a later segment running one of them isn't judged by its current
+// contents.
+func (c *lineContext) recordWrites(segment, workDir string) {
+	how := "written"
+	base := extractBaseCommand(segment)
+	if base == "curl" || base == "wget" {
+		how = "downloaded"
+	}
+
+	var files []string
+	words := shellWords(segment)
+	for i := 0; i < len(words); i++ {
+		word := strings.TrimLeft(words[i], "0123456789&")
+		if !strings.HasPrefix(word, ">") {
+			continue
+		}
+		target := strings.TrimLeft(word, ">|")
+		if target == "" && i+1 < len(words) {
+			i++
+			target = words[i]
+		}
+		if target != "" && !strings.HasPrefix(target, "&") && !strings.HasPrefix(target, "/dev/") {
+			files = append(files, target)
+		}
+	}
+
+	args, _ := splitRedirects(extractArgs(segment))
+	switch base {
+	case "curl":
+		files = append(files, curlOutputs(args)...)
+	case "wget":
+		files = append(files, wgetOutputs(args)...)
+	case "tee":
+		files = append(files, positionalArgs(args, nil)...)
+	}
+
+	for _, file := range files {
+		c.written[resolvePath(expandHome(file), workDir)] = how
+	}
+}
+
+// curlOutputs returns the files curl -o/-O saves to.
+func curlOutputs(args []string) []string {
+	var files []string
+	remoteName := false
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		switch {
+		case flag == "-o" || flag == "--output":
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			files = append(files, value)
+		case strings.HasPrefix(flag, "-o") && !strings.HasPrefix(flag, "--"):
+			files = append(files, flag[2:])
+		case flag == "-O" || flag == "--remote-name" || flag == "--remote-name-all":
+			remoteName = true
+		}
+	}
+	if remoteName {
+		if u := lastURL(args); u != "" {
+			files = append(files, urlFileName(u))
+		}
+	}
+	return files
+}
+
+// wgetOutputs returns the file wget saves to: -O's, or the URL's file name.
+func wgetOutputs(args []string) []string {
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := splitFlagValue(args[i])
+		if flag == "-O" || flag == "--output-document" {
+			if !inline && i+1 < len(args) {
+				value = args[i+1]
+			}
+			if value == "-" {
+				return nil
+			}
+			return []string{value}
+		}
+		if strings.HasPrefix(flag, "-O") && !strings.HasPrefix(flag, "--") {
+			if flag[2:] == "-" {
+				return nil
+			}
+			return []string{flag[2:]}
+		}
+	}
+	if u := lastURL(args); u != "" {
+		return []string{urlFileName(u)}
+	}
+	return nil
+}
+
+func lastURL(args []string) string {
+	for i := len(args) - 1; i >= 0; i-- {
+		if strings.Contains(args[i], "://") {
+			return args[i]
+		}
+	}
+	return ""
+}
+
+// urlFileName is the last path element of a URL, as saved by curl -O and wget.
+func urlFileName(u string) string {
+	u, _, _ = strings.Cut(u, "?")
+	u, _, _ = strings.Cut(u, "#")
+	_, rest, _ := strings.Cut(u, "://")
+	if i := strings.LastIndexByte(rest, '/'); i >= 0 && i+1 < len(rest) {
+		return rest[i+1:]
+	}
+	return "index.html"
+}
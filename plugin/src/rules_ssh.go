@@ -0,0 +1,647 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// --- ssh / scp / rsync handlers ---
+//
+// Remote commands are evaluated like local ones, but with no project and in
+// remote mode: the remote working directory is unknown, so every write asks,
+// and the local kubeconfig, cloud configuration and environment say nothing
+// about the remote host's, so commands that depend on them go to the
+// evaluator. Hosts are profiled by the dev_hosts and production_hosts policy
+// lists, the production patterns, and the HostName the ssh config gives an
+// alias.
+
+// Host profiles. Loopback hosts are this machine: their commands and
+// uploads are rated like local ones.
+const (
+	hostProduction = "production"
+	hostDev        = "dev"
+	hostLoopback   = "loopback"
+)
+
+// loopbackHosts name this machine; they are checked after the production
+// list.
+var loopbackHosts = []string{"localhost", "*.localhost", "127.*", "::1"}
+
+// defaultDevHosts are checked after the user's lists and the production
+// patterns.
+var defaultDevHosts = []string{"*.local"}
+
+var sshValueFlags = map[string]bool{
+	"-B": true, "-b": true, "-c": true, "-D": true, "-E": true, "-e": true,
+	"-F": true, "-I": true, "-i": true, "-J": true, "-L": true,
+	"-l": true, "-m": true, "-O": true, "-o": true, "-p": true,
+	"-P": true, "-Q": true, "-R": true, "-S": true, "-W": true, "-w": true,
+}
+
+var scpValueFlags = map[string]bool{
+	"-c": true, "-D": true, "-F": true, "-i": true, "-J": true, "-l": true,
+	"-o": true, "-P": true, "-S": true, "-X": true,
+}
+
+var rsyncValueFlags = map[string]bool{
+	"-e": true, "--rsh": true, "--rsync-path": true, "-f": true, "--filter": true,
+	"--exclude": true, "--include": true, "--exclude-from": true, "--include-from": true,
+	"--files-from": true, "-T": true, "--temp-dir": true, "--partial-dir": true,
+	"--backup-dir": true, "--link-dest": true, "--compare-dest": true, "--copy-dest": true,
+	"--log-file": true, "--password-file": true, "--port": true, "--timeout": true,
+	"--chmod": true, "--chown": true, "--bwlimit": true, "--max-size": true,
+	"--min-size": true, "--suffix": true, "-B": true, "--block-size": true,
+}
+
+// sshLocalCommandOptions run a command on this machine rather than the remote.
+var sshLocalCommandOptions = []string{"proxycommand", "localcommand", "remotecommand", "permitlocalcommand"}
+
+func evaluateSSH(args []string, stdin, workDir string) (Verdict, string) {
+	args, stdinFile := splitRedirects(args)
+	configFile := defaultSSHConfig()
+
+	// Like ssh, keep reading options after the host up to the first other
+	// word or --
+	dest := ""
+	var remote []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			if dest == "" && i+1 < len(args) {
+				i++
+				dest = args[i]
+			}
+			remote = args[i+1:]
+			i = len(args)
+		case !strings.HasPrefix(arg, "-") && dest != "":
+			remote = args[i:]
+			i = len(args)
+		case sshValueFlags[arg]:
+			if i+1 >= len(args) {
+				break
+			}
+			i++
+			if arg == "-F" {
+				configFile = args[i]
+			}
+			if arg == "-o" && isLocalCommandOption(args[i]) {
+				return VerdictUncertain, "ssh option runs a command: " + args[i]
+			}
+		case strings.HasPrefix(arg, "-o"):
+			if isLocalCommandOption(arg[2:]) {
+				return VerdictUncertain, "ssh option runs a command: " + arg[2:]
+			}
+		case strings.HasPrefix(arg, "-F") && len(arg) > 2:
+			configFile = arg[2:]
+		case strings.HasPrefix(arg, "-"):
+		default:
+			dest = arg
+		}
+	}
+	if dest == "" {
+		return VerdictAllow, "ssh (no host)"
+	}
+
+	// A local file on stdin feeds the remote shell
+	switch {
+	case stdin == "" && strings.HasPrefix(stdinFile, "\x00"):
+		stdin = stdinFile[1:]
+	case stdin == "" && stdinFile != "":
+		dependOnFile(resolvePath(expandHome(stdinFile), workDir))
+		data, err := os.ReadFile(resolvePath(expandHome(stdinFile), workDir))
+		if err != nil || len(data) > maxScriptSize {
+			return VerdictUncertain, "ssh with unreadable input: " + stdinFile
+		}
+		stdin = string(data)
+		noteInputs("ssh-input", stdin)
+	}
+
+	host := sshHost(dest)
+	profile := remoteHostProfile(host, configFile)
+	name := "ssh " + host
+	command := strings.Join(remote, " ")
+	if command == "" && stdin == "" {
+		if profile == hostProduction {
+			return VerdictAsk, name + " (interactive session on production host)"
+		}
+		return VerdictAllow, "ssh (interactive)"
+	}
+
+	// The remote shell sees nothing the local line defines
+	saved, savedRemote := currentLine, remoteShell
+	currentLine, remoteShell = nil, true
+	defer func() { currentLine, remoteShell = saved, savedRemote }()
+
+	var verdict Verdict
+	var reason string
+	switch {
+	case command == "":
+		verdict, reason = evaluateCommand(stdin, "")
+	case stdin != "" && isShellCommand(extractBaseCommand(command)):
+		verdict, reason = evaluateCommand(command, "")
+		if v, r := evaluateCommand(stdin, ""); v > verdict || reason == "" {
+			verdict, reason = v, r
+		}
+	default:
+		verdict, reason = evaluateCommand(command, "")
+	}
+	if reason == "" {
+		verdict, reason = VerdictAllow, "(no commands)"
+	}
+	return remoteVerdict(name, profile, verdict, reason)
+}
+
+// remoteVerdict applies a host profile to the verdict of a remote command:
+// anything short of allowed asks on production, and dev hosts downgrade asks
+// to the evaluator.
+func remoteVerdict(name, profile string, verdict Verdict, reason string) (Verdict, string) {
+	switch {
+	case profile == hostProduction && (verdict == VerdictAsk || verdict == VerdictUncertain):
+		return VerdictAsk, name + " (production host): " + reason
+	case profile == hostDev && verdict == VerdictAsk:
+		return VerdictUncertain, name + " (dev host): " + reason
+	case profile == hostLoopback:
+		return verdict, name + " (this machine): " + reason
+	case profile != "":
+		return verdict, name + " (" + profile + " host): " + reason
+	}
+	return verdict, name + ": " + reason
+}
+
+// remoteShell is set while evaluating a command that runs on another host.
+var remoteShell bool
+
+// remoteContextCommands act on whatever cluster, cloud account or daemon
+// their configuration points to, which on a remote host is not the local one.
+var remoteContextCommands = map[string]bool{
+	"kubectl": true, "helm": true, "gcloud": true, "gsutil": true, "bq": true,
+	"aws": true, "az": true, "terraform": true, "tofu": true, "pulumi": true,
+	"cdk": true, "cdktf": true, "serverless": true, "sls": true,
+	"ansible-playbook": true, "docker": true, "podman": true,
+	"docker-compose": true, "podman-compose": true,
+}
+
+// remoteWriteCommands change files wherever they run.
+var remoteWriteCommands = map[string]bool{
+	"cp": true, "mv": true, "rm": true, "rmdir": true, "mkdir": true, "touch": true,
+	"ln": true, "tee": true, "chmod": true, "chown": true, "chgrp": true,
+	"truncate": true, "install": true, "unlink": true, "shred": true,
+	"zip": true, "gzip": true, "gunzip": true,
+}
+
+// remoteSegmentVerdict applies remote mode to a simple command's verdict:
+// a write is at least ASK, and an allowed command whose target depends on
+// local configuration is uncertain.
+func remoteSegmentVerdict(segment, baseCmd string, verdict Verdict, reason string) (Verdict, string) {
+	if verdict == VerdictAllow || verdict == VerdictUncertain {
+		if what := remoteWrite(segment, baseCmd); what != "" {
+			return VerdictAsk, what + " on a remote host: " + segment
+		}
+	}
+	if verdict == VerdictAllow && remoteContextCommands[baseCmd] {
+		return VerdictUncertain, baseCmd + " on a remote host (target context unknown): " + reason
+	}
+	return verdict, reason
+}
+
+// remoteWrite describes how a command writes files, or returns "".
+func remoteWrite(segment, baseCmd string) string {
+	words := shellWords(segment)
+	for i, word := range words {
+		word = strings.TrimLeft(word, "0123456789&")
+		if !strings.HasPrefix(word, ">") {
+			continue
+		}
+		target := strings.TrimLeft(word, ">|")
+		if target == "" && i+1 < len(words) {
+			target = words[i+1]
+		}
+		if !strings.HasPrefix(target, "&") && !strings.HasPrefix(target, "/dev/") {
+			return "redirects output to " + target
+		}
+	}
+
+	args, _ := splitRedirects(extractArgs(segment))
+	switch baseCmd {
+	case "sed", "perl":
+		for _, arg := range args {
+			if arg == "-i" || strings.HasPrefix(arg, "-i") || strings.HasPrefix(arg, "--in-place") {
+				return baseCmd + " edits files in place"
+			}
+		}
+		return ""
+	case "tar":
+		if len(args) > 0 && (strings.Contains(strings.TrimPrefix(args[0], "-"), "t") || hasFlag(args, "--list")) {
+			return ""
+		}
+		return "tar writes files"
+	case "unzip":
+		if hasFlag(args, "-l", "-v", "-Z") {
+			return ""
+		}
+		return "unzip writes files"
+	}
+	if remoteWriteCommands[baseCmd] {
+		return baseCmd + " writes files"
+	}
+	return ""
+}
+
+func isShellCommand(cmd string) bool {
+	switch cmd {
+	case "bash", "sh", "zsh", "dash", "ksh":
+		return true
+	}
+	return false
+}
+
+func isLocalCommandOption(option string) bool {
+	option = strings.ToLower(strings.TrimSpace(option))
+	for _, name := range sshLocalCommandOptions {
+		if strings.HasPrefix(option, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func evaluateSCP(args []string, workDir string) (Verdict, string) {
+	configFile := defaultSSHConfig()
+	var programs []transferProgram
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case scpValueFlags[arg]:
+			if i+1 < len(args) {
+				i++
+				switch arg {
+				case "-F":
+					configFile = args[i]
+				case "-S":
+					programs = append(programs, transferProgram{"-S", args[i], workDir})
+				case "-o":
+					if isLocalCommandOption(args[i]) {
+						return VerdictUncertain, "scp option runs a command: " + args[i]
+					}
+				}
+			}
+		case strings.HasPrefix(arg, "-o"):
+			if isLocalCommandOption(arg[2:]) {
+				return VerdictUncertain, "scp option runs a command: " + arg[2:]
+			}
+		case strings.HasPrefix(arg, "-S"):
+			programs = append(programs, transferProgram{"-S", arg[2:], workDir})
+		case strings.HasPrefix(arg, "-"):
+		default:
+			paths = append(paths, arg)
+		}
+	}
+	verdict, reason := VerdictAllow, "scp (no transfer)"
+	if len(paths) >= 2 {
+		verdict, reason = evaluateTransfer("scp", paths[:len(paths)-1], paths[len(paths)-1], false, configFile, workDir)
+	}
+	return transferProgramsVerdict("scp", programs, verdict, reason)
+}
+
+func evaluateRsync(args []string, workDir string) (Verdict, string) {
+	deletes, removesSources := false, false
+	var programs []transferProgram
+	var paths []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case rsyncValueFlags[arg]:
+			i++
+			if i >= len(args) {
+				break
+			}
+			switch arg {
+			case "-e", "--rsh":
+				programs = append(programs, transferProgram{arg, args[i], workDir})
+			case "--rsync-path":
+				programs = append(programs, transferProgram{arg, args[i], ""})
+			}
+		case strings.HasPrefix(arg, "--rsh="):
+			programs = append(programs, transferProgram{"--rsh", strings.TrimPrefix(arg, "--rsh="), workDir})
+		case strings.HasPrefix(arg, "--rsync-path="):
+			programs = append(programs, transferProgram{"--rsync-path", strings.TrimPrefix(arg, "--rsync-path="), ""})
+		case strings.HasPrefix(arg, "-e") && !strings.HasPrefix(arg, "--"):
+			programs = append(programs, transferProgram{"-e", arg[2:], workDir})
+		case strings.HasPrefix(arg, "--delete"):
+			deletes = true
+		case arg == "--remove-source-files":
+			removesSources = true
+		case strings.HasPrefix(arg, "-"):
+		default:
+			paths = append(paths, arg)
+		}
+	}
+	verdict, reason := rsyncTransferVerdict(paths, deletes, removesSources, workDir)
+	return transferProgramsVerdict("rsync", programs, verdict, reason)
+}
+
+func rsyncTransferVerdict(paths []string, deletes, removesSources bool, workDir string) (Verdict, string) {
+	if len(paths) == 0 {
+		return VerdictAllow, "rsync (no transfer)"
+	}
+	if len(paths) == 1 {
+		// A single source lists it
+		return VerdictAllow, "rsync (list)"
+	}
+	for _, src := range paths[:len(paths)-1] {
+		if !removesSources {
+			break
+		}
+		if host, _, ok := remoteSpec(src); ok {
+			return remoteVerdict("rsync from "+host, remoteHostProfile(host, defaultSSHConfig()), VerdictAsk, "removes remote source files")
+		}
+		// Local sources are deleted like rm -r would
+		if v, r := evaluateRm([]string{"-r", src}, workDir); v != VerdictAllow {
+			return v, "rsync --remove-source-files: " + r
+		}
+	}
+	return evaluateTransfer("rsync", paths[:len(paths)-1], paths[len(paths)-1], deletes, defaultSSHConfig(), workDir)
+}
+
+// transferProgram is a command scp or rsync runs in place of ssh (scp -S,
+// rsync -e) or on the remote end (rsync --rsync-path, which has no project).
+type transferProgram struct {
+	flag, command, workDir string
+}
+
+// transferProgramsVerdict rates the programs a transfer runs as commands and
+// keeps the worse of their verdicts and the transfer's own.
+func transferProgramsVerdict(cmd string, programs []transferProgram, verdict Verdict, reason string) (Verdict, string) {
+	if len(programs) == 0 {
+		return verdict, reason
+	}
+	saved, savedRemote := currentLine, remoteShell
+	currentLine = nil
+	defer func() { currentLine, remoteShell = saved, savedRemote }()
+
+	for _, program := range programs {
+		if strings.TrimSpace(program.command) == "" {
+			return VerdictUncertain, cmd + " " + program.flag + " with an empty command"
+		}
+		if words := shellWords(program.command); program.workDir != "" && len(words) > 0 && strings.Contains(words[0], "/") {
+			if _, err := os.Stat(resolvePath(expandHome(words[0]), program.workDir)); err != nil {
+				return VerdictUncertain, cmd + " " + program.flag + " program not found: " + words[0]
+			}
+		}
+		remoteShell = program.workDir == ""
+		v, r := evaluateCommand(program.command, program.workDir)
+		if r == "" {
+			v, r = VerdictUncertain, "no command"
+		}
+		if v > verdict {
+			verdict, reason = v, cmd+" "+program.flag+": "+r
+		}
+	}
+	return verdict, reason
+}
+
+// evaluateTransfer rates a copy by direction: uploads depend on the
+// destination host, downloads and local copies on where they write.
+func evaluateTransfer(cmd string, sources []string, dest string, deletes bool, configFile, workDir string) (Verdict, string) {
+	var sourceHosts []string
+	for _, src := range sources {
+		if host, _, ok := remoteSpec(src); ok {
+			sourceHosts = append(sourceHosts, host)
+		}
+	}
+
+	host, remotePath, remoteDest := remoteSpec(dest)
+	if remoteDest && remoteHostProfile(host, configFile) == hostLoopback {
+		// An upload to this machine writes a local path
+		dest, remoteDest = remoteHomePath(remotePath), false
+	}
+	switch {
+	case remoteDest && len(sourceHosts) > 0:
+		for _, h := range append(sourceHosts, host) {
+			if remoteHostProfile(h, configFile) == hostProduction {
+				return VerdictAsk, cmd + " copies between remote hosts, including production host " + h
+			}
+		}
+		return VerdictUncertain, cmd + " copies between remote hosts"
+
+	case remoteDest:
+		profile := remoteHostProfile(host, configFile)
+		name := cmd + " to " + host
+		if isSystemPath(remoteHomePath(remotePath)) {
+			return remoteVerdict(name, profile, VerdictAsk, "writes to system path "+remotePath)
+		}
+		for _, src := range sources {
+			if exposed := homeExposure(resolvePath(expandHome(src), workDir), src); exposed != "" {
+				return VerdictAsk, name + ": uploads " + exposed
+			}
+		}
+		switch {
+		case profile == hostProduction:
+			return VerdictAsk, name + " (production host): uploads to " + dest
+		case deletes:
+			return remoteVerdict(name, profile, VerdictAsk, "deletes remote files in "+dest)
+		case profile == hostDev:
+			return VerdictAllow, name + " (dev host): uploads to " + dest
+		}
+		return VerdictUncertain, name + ": uploads to " + dest
+	}
+
+	local := resolvePath(expandHome(dest), workDir)
+	what := cmd + " copy"
+	if len(sourceHosts) > 0 {
+		what = cmd + " download"
+	}
+	switch {
+	case isSystemPath(local):
+		return askOrDeny(CategorySystemWrite), what + " to system path: " + dest
+	case isWithinDir(local, workDir):
+		return VerdictAllow, what + " into project"
+	case deletes:
+		return VerdictAsk, what + " deletes files outside project: " + dest
+	}
+	return uncertainOnIntent(what + " outside project: " + dest)
+}
+
+// remoteSpec splits an scp/rsync operand into host and path. Remote operands
+// are [user@]host:path, host::module, or scp://, rsync:// and ssh:// URLs; a
+// colon after a slash is part of a local path.
+func remoteSpec(arg string) (host, remotePath string, ok bool) {
+	for _, scheme := range []string{"scp://", "rsync://", "ssh://"} {
+		if rest, found := strings.CutPrefix(arg, scheme); found {
+			host, remotePath, _ = strings.Cut(rest, "/")
+			return sshHost(host), "/" + remotePath, true
+		}
+	}
+
+	colon := strings.Index(arg, ":")
+	if open := strings.Index(arg, "["); open >= 0 && open < colon {
+		// [user@][v6addr]:path
+		end := strings.Index(arg, "]")
+		if end < 0 || !strings.HasPrefix(arg[end+1:], ":") {
+			return "", "", false
+		}
+		colon = end + 1
+	}
+	if colon <= 0 || strings.Contains(arg[:colon], "/") {
+		return "", "", false
+	}
+	return sshHost(arg[:colon]), strings.TrimPrefix(arg[colon+1:], ":"), true
+}
+
+// sshHost strips the user, port and brackets from an ssh destination.
+func sshHost(dest string) string {
+	dest = strings.TrimSuffix(strings.TrimPrefix(dest, "ssh://"), "/")
+	if i := strings.LastIndex(dest, "@"); i >= 0 {
+		dest = dest[i+1:]
+	}
+	if strings.HasPrefix(dest, "[") {
+		if end := strings.Index(dest, "]"); end > 0 {
+			return dest[1:end]
+		}
+	}
+	if strings.Count(dest, ":") == 1 {
+		dest, _, _ = strings.Cut(dest, ":")
+	}
+	return dest
+}
+
+// remoteHomePath maps a remote path onto this machine's home for the system
+// path check: relative remote paths start in the remote user's home.
+func remoteHomePath(p string) string {
+	if p == "" || (!strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "~")) {
+		p = "~/" + p
+	}
+	return filepath.Clean(expandHome(p))
+}
+
+// remoteHostProfile returns hostProduction, hostDev or "" for a host, trying
+// both the name as written and the HostName an ssh config alias points to.
+// The profile is noted for the current evaluation.
+func remoteHostProfile(host, configFile string) string {
+	profile := hostProfile(host, configFile)
+	switch profile {
+	case hostProduction:
+		note(noteProfile, profileProduction)
+	case hostDev:
+		note(noteProfile, profileDev)
+	}
+	return profile
+}
+
+func hostProfile(host, configFile string) string {
+	names := []string{host}
+	if hostname := sshConfigHostName(configFile, host); hostname != "" && hostname != host {
+		names = append(names, hostname)
+	}
+
+	policy := currentPolicy()
+	for _, name := range names {
+		if matchesAnyPattern(name, policy.ProductionHosts) {
+			return hostProduction
+		}
+	}
+	for _, name := range names {
+		if matchesAnyPattern(name, loopbackHosts) {
+			return hostLoopback
+		}
+	}
+	for _, name := range names {
+		if matchesAnyPattern(name, policy.DevHosts) {
+			return hostDev
+		}
+	}
+	for _, name := range names {
+		if isProductionHost(name) {
+			return hostProduction
+		}
+	}
+	for _, name := range names {
+		if matchesAnyPattern(name, defaultDevHosts) {
+			return hostDev
+		}
+	}
+	return ""
+}
+
+// isProductionHost checks the production patterns against a host name and
+// each of its labels, so db1.prod.example.com counts.
+func isProductionHost(host string) bool {
+	policy := currentPolicy()
+	if policy.isProduction(host) {
+		return true
+	}
+	for _, part := range strings.FieldsFunc(host, func(r rune) bool {
+		return r == '.' || r == '_'
+	}) {
+		if policy.isProduction(part) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultSSHConfig() string {
+	return filepath.Join(os.Getenv("HOME"), ".ssh", "config")
+}
+
+// sshConfigHostName returns the HostName an ssh config file gives alias, or
+// "" if none does. Like ssh, the first matching value wins; Match blocks and
+// Include are not followed.
+func sshConfigHostName(configFile, alias string) string {
+	dependOnFile(expandHome(configFile))
+	f, err := os.Open(expandHome(configFile))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	matching := true // options before the first Host apply to every host
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		end := strings.IndexAny(line, " \t=")
+		if end < 0 {
+			continue
+		}
+		key := strings.ToLower(line[:end])
+		value := strings.Trim(strings.TrimLeft(line[end:], " \t="), `"`)
+
+		switch key {
+		case "host":
+			matching = sshHostMatches(value, alias)
+		case "match":
+			matching = false
+		case "hostname":
+			if matching {
+				return strings.ReplaceAll(value, "%h", alias)
+			}
+		}
+	}
+	return ""
+}
+
+// sshHostMatches reports whether alias matches a Host line: any pattern
+// matches and no negated (!) pattern does.
+func sshHostMatches(patterns, alias string) bool {
+	matched := false
+	alias = strings.ToLower(alias)
+	for _, pattern := range strings.Fields(patterns) {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "!"))
+		if ok, _ := path.Match(pattern, alias); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// --- task runner handlers ---
+//
+// make, npm/yarn/pnpm/bun scripts, just, task and mise run whatever the
+// project's task file says, so the invoked target is looked up there and its
+// commands (dependencies and pre/post scripts included) are evaluated like
+// any other command line, in the task file's directory. Verdicts are cached
+// by the task file's contents. A missing task file means the runner fails
+// without doing anything; a target the parser can't find goes to the
+// evaluator.
+
+// maxTaskDepth bounds nested task invocations (a recipe calling $(MAKE),
+// npm run calling make, ...).
+const maxTaskDepth = 8
+
+// taskStack holds the task invocations being evaluated, so a recipe that
+// invokes its own target stops instead of recursing. Rules are evaluated
+// one request at a time.
+var taskStack = map[string]bool{}
+
+// evaluateTask reads a task file and evaluates the commands resolve lists
+// for it. invocation is what follows the runner on the command line.
+func evaluateTask(runner, file, dir string, invocation []string, resolve func(content string) ([]string, string)) (Verdict, string) {
+	name := strings.TrimSpace(runner + " " + strings.Join(invocation, " "))
+	if dir == "" {
+		return VerdictUncertain, name + " (unknown directory)"
+	}
+
+	dependOnFile(file)
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return VerdictAllow, name + " (no " + filepath.Base(file) + ")"
+	}
+	if err != nil || info.Size() > maxScriptSize {
+		return VerdictUncertain, name + " (cannot read " + filepath.Base(file) + ")"
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return VerdictUncertain, name + " (cannot read " + filepath.Base(file) + ")"
+	}
+
+	stackKey := file + "\x00" + strings.Join(invocation, "\x00")
+	if taskStack[stackKey] {
+		return VerdictUncertain, name + " (invokes itself)"
+	}
+	if len(taskStack) >= maxTaskDepth {
+		return VerdictUncertain, name + " (tasks nested too deeply)"
+	}
+	taskStack[stackKey] = true
+	defer delete(taskStack, stackKey)
+
+	// Included makefiles are parsed with the Makefile, and so are part of
+	// the cache key
+	content := string(data)
+	if runner == "make" {
+		var problem string
+		if content, problem = inlineMakeIncludes(content, dir, 0); problem != "" {
+			return VerdictUncertain, name + " (" + problem + ")"
+		}
+	}
+
+	key := contentKey("task", runner, file, dir, strings.Join(invocation, "\x00"), content)
+	return withVerdictCache(key, func() (Verdict, string) {
+		commands, problem := resolve(content)
+		if problem != "" {
+			return VerdictUncertain, name + " (" + problem + ")"
+		}
+		return evaluateTaskCommands(name, commands, dir)
+	})
+}
+
+// evaluateTaskCommands evaluates each command of a task and keeps the worst
+// verdict.
+func evaluateTaskCommands(name string, commands []string, dir string) (Verdict, string) {
+	if len(commands) == 0 {
+		return VerdictAllow, name + " (no commands)"
+	}
+	verdict, reason := VerdictAllow, ""
+	for _, command := range commands {
+		v, r := evaluateCommand(command, dir)
+		if v > verdict || reason == "" {
+			verdict, reason = v, r
+		}
+	}
+	return verdict, name + ": " + reason
+}
+
+// findTaskFile returns the first of names that exists in dir, or the first
+// name if none does.
+func findTaskFile(dir string, names ...string) string {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		dependOnFile(path)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, names[0])
+}
+
+// --- make ---
+
+// makeValueFlags are make options that take a value.
+var makeValueFlags = map[string]bool{
+	"-C": true, "--directory": true, "-f": true, "--file": true, "--makefile": true,
+	"-I": true, "--include-dir": true, "-o": true, "--old-file": true, "--assume-old": true,
+	"-W": true, "--what-if": true, "--new-file": true, "--assume-new": true, "--eval": true,
+}
+
+func evaluateMake(args []string, workDir string) (Verdict, string) {
+	dir, file := workDir, ""
+	var targets, invocation []string
+	overrides := map[string]string{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flag, value, inline := splitFlagValue(arg)
+		switch {
+		case arg == "-n" || arg == "--dry-run" || arg == "--just-print" || arg == "--recon" ||
+			arg == "-q" || arg == "--question" || arg == "-p" || arg == "--print-data-base" ||
+			arg == "-h" || arg == "--help" || arg == "-v" || arg == "--version":
+			return VerdictAllow, "make " + arg
+		case flag == "--eval":
+			return VerdictUncertain, "make --eval"
+		case makeValueFlags[flag]:
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			switch flag {
+			case "-C", "--directory":
+				dir = resolvePath(value, dir)
+			case "-f", "--file", "--makefile":
+				file = value
+			}
+		case arg == "-j" || arg == "-l":
+			// optional numeric argument
+			if i+1 < len(args) && strings.Trim(args[i+1], "0123456789.") == "" {
+				i++
+			}
+		case strings.HasPrefix(arg, "-"):
+		case strings.Contains(arg, "="):
+			name, value, _ := strings.Cut(arg, "=")
+			overrides[strings.TrimRight(name, ":?+")] = value
+			invocation = append(invocation, arg)
+		default:
+			targets = append(targets, arg)
+			invocation = append(invocation, arg)
+		}
+	}
+
+	var path string
+	if file != "" {
+		path = resolvePath(file, dir)
+	} else if dir != "" {
+		path = findTaskFile(dir, "GNUmakefile", "makefile", "Makefile")
+	}
+	return evaluateTask("make", path, dir, invocation, func(content string) ([]string, string) {
+		return parseMakefile(content, dir, overrides).resolve(targets)
+	})
+}
+
+// inlineMakeIncludes replaces include directives with the files they name.
+// Includes it can't follow (computed names, files outside the project, or a
+// missing file make would first have to build) are reported.
+func inlineMakeIncludes(content, dir string, depth int) (string, string) {
+	var out strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(stripMakeComment(line))
+		if strings.HasPrefix(line, "\t") || len(fields) == 0 ||
+			(fields[0] != "include" && fields[0] != "-include" && fields[0] != "sinclude") {
+			out.WriteString(line + "\n")
+			continue
+		}
+		if depth >= maxTaskDepth {
+			return "", "includes nested too deeply"
+		}
+		optional := fields[0] != "include"
+		for _, name := range fields[1:] {
+			if strings.ContainsAny(name, "$*?[") {
+				return "", "includes " + name
+			}
+			path := resolvePath(name, dir)
+			if !isWithinDir(path, dir) {
+				return "", "includes " + name + " from outside the project"
+			}
+			dependOnFile(path)
+			data, err := os.ReadFile(path)
+			if optional && os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return "", "cannot read included " + name
+			}
+			included, problem := inlineMakeIncludes(string(data), dir, depth+1)
+			if problem != "" {
+				return "", problem
+			}
+			out.WriteString(included)
+		}
+	}
+	return out.String(), ""
+}
+
+// --- package.json scripts ---
+
+// evaluatePackageScript evaluates a package.json script with its pre and
+// post scripts.
+func evaluatePackageScript(runner, script, dir string) (Verdict, string) {
+	return evaluateTask(runner, filepath.Join(dir, "package.json"), dir, []string{script}, func(content string) ([]string, string) {
+		scripts, ok := parsePackageScripts(content)
+		if !ok {
+			return nil, "cannot parse package.json"
+		}
+		return scripts.resolve([]string{script})
+	})
+}
+
+// hasPackageScript reports whether dir's package.json defines the script.
+func hasPackageScript(dir, script string) bool {
+	dependOnFile(filepath.Join(dir, "package.json"))
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return false
+	}
+	scripts, ok := parsePackageScripts(string(data))
+	if !ok {
+		return false
+	}
+	_, ok = scripts.tasks[script]
+	return ok
+}
+
+// projectTools are build, test and lint tools that only work on the project
+// they're run in. Other binaries in node_modules/.bin (rimraf, prisma, ...)
+// can delete files or data anywhere.
+var projectTools = map[string]bool{
+	"vite": true, "tsc": true, "vue-tsc": true, "eslint": true, "prettier": true,
+	"stylelint": true, "biome": true, "jest": true, "vitest": true, "mocha": true,
+	"webpack": true, "rollup": true, "esbuild": true, "tsup": true, "parcel": true,
+	"next": true, "nuxt": true, "astro": true, "svelte-kit": true, "svelte-check": true,
+	"tailwindcss": true, "postcss": true, "babel": true, "swc": true,
+}
+
+// localPackageBinary reports whether cmd is a known project tool installed
+// in the project's node_modules/.bin, where package scripts find their tools.
+func localPackageBinary(cmd, workDir string) bool {
+	if workDir == "" || !projectTools[cmd] {
+		return false
+	}
+	dependOnFile(filepath.Join(workDir, "node_modules", ".bin", cmd))
+	info, err := os.Stat(filepath.Join(workDir, "node_modules", ".bin", cmd))
+	return err == nil && !info.IsDir()
+}
+
+// --- just ---
+
+func evaluateJust(args []string, workDir string) (Verdict, string) {
+	dir, file := workDir, ""
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flag, value, inline := splitFlagValue(arg)
+		switch {
+		case arg == "-l" || arg == "--list" || arg == "--summary" || arg == "-s" || arg == "--show" ||
+			arg == "--dump" || arg == "--evaluate" || arg == "--variables" || arg == "-n" || arg == "--dry-run" ||
+			arg == "-h" || arg == "--help" || arg == "-V" || arg == "--version" || arg == "--fmt" || arg == "--groups":
+			return VerdictAllow, "just " + arg
+		case arg == "--choose":
+			return VerdictUncertain, "just --choose"
+		case flag == "-f" || flag == "--justfile" || flag == "-d" || flag == "--working-directory" || flag == "--set":
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			switch flag {
+			case "-f", "--justfile":
+				file = value
+			case "-d", "--working-directory":
+				dir = resolvePath(value, dir)
+			case "--set":
+				i++ // --set NAME VALUE
+			}
+		case strings.HasPrefix(arg, "-"):
+		default:
+			positional = append(positional, arg)
+		}
+	}
+
+	var path string
+	if file != "" {
+		path = resolvePath(file, workDir)
+	} else if dir != "" {
+		path = findTaskFile(dir, "justfile", "Justfile", ".justfile")
+	}
+	return evaluateTask("just", path, dir, positional, func(content string) ([]string, string) {
+		justfile := parseJustfile(content)
+		// After the first recipe, arguments are parameters unless they name
+		// another recipe; NAME=VALUE sets a variable
+		var recipes []string
+		for _, arg := range positional {
+			if strings.Contains(arg, "=") {
+				continue
+			}
+			if _, ok := justfile.tasks[arg]; ok || len(recipes) == 0 {
+				recipes = append(recipes, arg)
+			}
+		}
+		return justfile.resolve(recipes)
+	})
+}
+
+// --- task (Taskfile) ---
+
+func evaluateTaskfile(args []string, workDir string) (Verdict, string) {
+	dir, file := workDir, ""
+	var tasks []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flag, value, inline := splitFlagValue(arg)
+		switch {
+		case arg == "-l" || arg == "--list" || arg == "-a" || arg == "--list-all" || arg == "--summary" ||
+			arg == "-n" || arg == "--dry" || arg == "-h" || arg == "--help" || arg == "--version" || arg == "-i" || arg == "--init":
+			return VerdictAllow, "task " + arg
+		case flag == "-t" || flag == "--taskfile" || flag == "-d" || flag == "--dir" || flag == "-o" || flag == "--output":
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			switch flag {
+			case "-t", "--taskfile":
+				file = value
+			case "-d", "--dir":
+				dir = resolvePath(value, dir)
+			}
+		case arg == "--":
+			i = len(args) // the rest is passed to the task as CLI_ARGS
+		case strings.HasPrefix(arg, "-"):
+		case strings.Contains(arg, "="):
+			// VAR=value assignment
+		default:
+			tasks = append(tasks, arg)
+		}
+	}
+
+	var path string
+	if file != "" {
+		path = resolvePath(file, workDir)
+	} else if dir != "" {
+		path = findTaskFile(dir, "Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml", "Taskfile.dist.yml", "Taskfile.dist.yaml")
+	}
+	return evaluateTask("task", path, dir, tasks, func(content string) ([]string, string) {
+		return parseTaskfile(content).resolve(tasks)
+	})
+}
+
+// --- mise ---
+
+func evaluateMise(args []string, workDir string) (Verdict, string) {
+	pos := positionalArgs(args, map[string]bool{"-C": true, "--cd": true, "-E": true, "--env": true, "-j": true, "--jobs": true})
+	if len(pos) == 0 {
+		return VerdictAllow, "mise (no subcommand)"
+	}
+	dir := workDir
+	if cd := flagValue(args, "-C", "--cd"); cd != "" {
+		dir = resolvePath(cd, workDir)
+	}
+
+	switch pos[0] {
+	case "run", "r":
+		if len(pos) < 2 {
+			return evaluateMiseTask(nil, dir)
+		}
+		return evaluateMiseTask(pos[1:2], dir)
+	case "tasks":
+		if len(pos) > 1 && pos[1] == "run" {
+			return evaluateMiseTask(pos[2:], dir)
+		}
+		return VerdictAllow, "mise tasks"
+	case "ls", "list", "current", "where", "which", "doctor", "env", "version", "--version",
+		"outdated", "ls-remote", "plugins", "settings", "config", "bin-paths", "completion":
+		return VerdictAllow, "mise " + pos[0]
+	case "install", "i", "use", "u", "upgrade", "up", "uninstall", "prune", "trust", "reshim", "activate":
+		return VerdictAllow, "mise " + pos[0] + " (tool versions)"
+	case "exec", "x":
+		return VerdictUncertain, "mise exec"
+	}
+
+	// `mise <task>` runs a task when it isn't a mise command
+	if data, err := os.ReadFile(miseConfigFile(dir)); err == nil {
+		if _, ok := parseMiseToml(string(data)).tasks[pos[0]]; ok {
+			return evaluateMiseTask(pos[:1], dir)
+		}
+	}
+	return VerdictUncertain, "mise " + pos[0]
+}
+
+// miseTaskDirs hold file tasks, scripts that aren't parsed.
+var miseTaskDirs = []string{"mise-tasks", ".mise-tasks", ".mise/tasks", ".config/mise/tasks"}
+
+func miseConfigFile(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	return findTaskFile(dir, "mise.toml", ".mise.toml", "mise.local.toml", ".config/mise.toml", ".config/mise/config.toml")
+}
+
+func evaluateMiseTask(tasks []string, dir string) (Verdict, string) {
+	hasFileTasks := false
+	for _, taskDir := range miseTaskDirs {
+		dependOnFile(filepath.Join(dir, taskDir))
+		if _, err := os.Stat(filepath.Join(dir, taskDir)); dir != "" && err == nil {
+			hasFileTasks = true
+		}
+	}
+	path := miseConfigFile(dir)
+	if _, err := os.Stat(path); os.IsNotExist(err) && hasFileTasks {
+		return VerdictUncertain, "mise run " + strings.Join(tasks, " ") + " (file task)"
+	}
+	return evaluateTask("mise run", path, dir, tasks, func(content string) ([]string, string) {
+		mise := parseMiseToml(content)
+		mise.includes = hasFileTasks
+		return mise.resolve(tasks)
+	})
+}
+
+// --- cmake ---
+
+// cmakeSafeTools are `cmake -E` tools that only read or print.
+var cmakeSafeTools = map[string]bool{
+	"echo": true, "echo_append": true, "cat": true, "capabilities": true, "environment": true,
+	"compare_files": true, "md5sum": true, "sha1sum": true, "sha256sum": true, "sha512sum": true,
+	"sleep": true, "true": true, "false": true,
+}
+
+// evaluateCmake allows configuring and building the project. Install
+// targets, `--install`, `-P` scripts and `-E` tools that change files go to
+// the evaluator: they write wherever the install prefix or the script says.
+func evaluateCmake(args []string, workDir string) (Verdict, string) {
+	building, prefix := false, ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flag, value, inline := splitFlagValue(arg)
+		switch {
+		case arg == "--version" || arg == "--help" || arg == "-h" || strings.HasPrefix(arg, "--help-") || arg == "--system-information":
+			return VerdictAllow, "cmake " + arg
+		case arg == "-E":
+			if i+1 < len(args) && cmakeSafeTools[args[i+1]] {
+				return VerdictAllow, "cmake -E " + args[i+1]
+			}
+			return VerdictUncertain, "cmake -E " + strings.Join(firstN(args[i+1:], 1), " ")
+		case arg == "-P":
+			return VerdictUncertain, "cmake -P runs a script"
+		case arg == "--install":
+			for j := i + 1; j < len(args); j++ {
+				if f, v, in := splitFlagValue(args[j]); f == "--prefix" {
+					if !in && j+1 < len(args) {
+						v = args[j+1]
+					}
+					prefix = v
+				}
+			}
+			if prefix != "" && workDir != "" && isWithinDir(resolvePath(prefix, workDir), workDir) {
+				return VerdictAllow, "cmake --install into project"
+			}
+			return VerdictUncertain, "cmake --install (writes to the install prefix)"
+		case arg == "--build":
+			building = true
+		case building && (flag == "--target" || flag == "-t"):
+			if !inline && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			for _, target := range strings.Split(value, ",") {
+				if strings.HasPrefix(target, "install") {
+					return VerdictUncertain, "cmake --build --target " + target + " (writes to the install prefix)"
+				}
+			}
+		case building && arg == "--":
+			// The rest goes to the native build tool: make install, ninja install
+			for _, native := range args[i+1:] {
+				if strings.HasPrefix(native, "install") {
+					return VerdictUncertain, "cmake --build -- " + native + " (writes to the install prefix)"
+				}
+			}
+			return VerdictAllow, "cmake --build"
+		}
+	}
+	if building {
+		return VerdictAllow, "cmake --build"
+	}
+	return VerdictAllow, "cmake configure"
+}
+
+// --- bazel ---
+
+// bazelSafeCommands build, test or inspect the workspace. Outputs stay in
+// bazel's own output base.
+var bazelSafeCommands = map[string]bool{
+	"build": true, "test": true, "coverage": true, "query": true, "cquery": true, "aquery": true,
+	"info": true, "version": true, "help": true, "fetch": true, "sync": true, "clean": true,
+	"shutdown": true, "analyze-profile": true, "canonicalize-flags": true, "license": true,
+	"mod": true, "print_action": true,
+}
+
+// evaluateBazel allows building and testing. `bazel run` executes the built
+// binary with the user's privileges, and --run_under wraps tests in any
+// command, so both go to the evaluator.
+func evaluateBazel(cmd string, args []string) (Verdict, string) {
+	command := ""
+	for _, arg := range args {
+		if command == "" && strings.HasPrefix(arg, "-") {
+			continue // startup options
+		}
+		if command == "" {
+			command = arg
+			continue
+		}
+		if flag, _, _ := splitFlagValue(arg); flag == "--run_under" {
+			return VerdictUncertain, cmd + " " + command + " --run_under runs a command"
+		}
+	}
+	switch {
+	case command == "":
+		return VerdictAllow, cmd
+	case command == "run":
+		return VerdictUncertain, cmd + " run executes the built target"
+	case bazelSafeCommands[command]:
+		return VerdictAllow, cmd + " " + command
+	}
+	return VerdictUncertain, cmd + " " + command
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMakefile = `# Project tasks
+DIR ?= build
+VERSION := $(shell git describe --tags)
+INSTALLER = $(shell curl -fsSL https://get.example.com | sh)
+
+.PHONY: build clean deploy-prod release
+
+build: gen
+	@echo "building $(VERSION)"
+	go build -o $(DIR)/app .
+
+gen:
+	go generate ./...
+
+clean:
+	-rm -rf $(DIR)
+
+deploy-prod:
+	terraform -chdir=infra apply -auto-approve
+
+release: build deploy-prod
+
+nested:
+	$(MAKE) -C . deploy-prod
+
+loop:
+	$(MAKE) loop
+
+bootstrap:
+	echo $(INSTALLER)
+`
+
+const testPackageJSON = `{
+  "name": "app",
+  "scripts": {
+    "build": "tsc -p .",
+    "dev": "vite",
+    "test": "jest",
+    "pretest": "npm run build",
+    "db:reset": "psql -h db.example.com -c 'DROP TABLE users'",
+    "predeploy": "npm run build",
+    "deploy": "aws s3 sync dist s3://app-site --delete",
+    "check": "make build"
+  }
+}`
+
+const testJustfile = `set dotenv-load
+
+image := "app"
+
+alias b := build
+
+# Build the image
+build:
+    docker build -t {{image}} .
+
+deploy env="staging": build
+    @kubectl --context {{env}} apply -f k8s/
+
+release: (deploy "prod")
+    git push origin --tags
+
+analyze:
+    #!/usr/bin/env python3
+    print("hi")
+`
+
+const testTaskfile = `version: '3'
+
+tasks:
+  default:
+    deps: [lint]
+    cmds:
+      - go build ./...
+  lint: golangci-lint run
+  "db:drop":
+    cmds:
+      - cmd: psql -h db.example.com -c 'DROP DATABASE app'
+        silent: true
+  publish:
+    cmds:
+      - task: default
+      - |
+        npm publish
+  clean:
+    - rm -rf dist
+`
+
+const testMiseToml = `[tools]
+go = "1.22"
+
+[tasks.build]
+run = "go build ./..."
+
+[tasks.deploy]
+depends = ["build"]
+run = [
+  "go test ./...",
+  "gcloud run deploy app --source .",
+]
+
+[tasks]
+fmt = "go fmt ./..."
+wipe = { run = "docker volume rm app-data" }
+`
+
+// writeTaskFixtures creates a project with every kind of task file.
+func writeTaskFixtures(t *testing.T) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir()) // keep the verdict cache out of the real config dir
+	dir := t.TempDir()
+	files := map[string]string{
+		"Makefile":                 testMakefile,
+		"package.json":             testPackageJSON,
+		"justfile":                 testJustfile,
+		"Taskfile.yml":             testTaskfile,
+		"mise.toml":                testMiseToml,
+		"node_modules/.bin/vite":   "#!/bin/sh\n",
+		"node_modules/.bin/rimraf": "#!/bin/sh\n",
+		"node_modules/.bin/prisma": "#!/bin/sh\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestEvaluateTaskRunners(t *testing.T) {
+	dir := writeTaskFixtures(t)
+
+	tests := []struct {
+		name     string
+		command  string
+		want     Verdict
+		contains string
+	}{
+		// make
+		{"make default goal", "make", VerdictAllow, "make"},
+		{"make build with deps", "make build", VerdictAllow, "make build"},
+		{"make clean", "make clean", VerdictAllow, "make clean"},
+		{"make clean override", "make clean DIR=/", VerdictDeny, "make clean DIR=/"},
+		{"make deploy", "make deploy-prod", VerdictAsk, "terraform apply"},
+		{"make dependency", "make release", VerdictAsk, "terraform apply"},
+		{"make recursive", "make nested", VerdictAsk, "terraform apply"},
+		{"make self recursion", "make loop", VerdictUncertain, "invokes itself"},
+		{"make shell function", "make bootstrap", VerdictAsk, "pipe to shell"},
+		{"make unknown target", "make missing", VerdictUncertain, "no task missing"},
+		{"make dry run", "make -n deploy-prod", VerdictAllow, "make -n"},
+		{"make jobs", "make -j 4 build", VerdictAllow, "make build"},
+
+		// package.json scripts
+		{"npm run build", "npm run build", VerdictAllow, "npm run build"},
+		{"npm test with pretest", "npm test", VerdictAllow, "npm test"},
+		{"npm run db reset", "npm run db:reset", VerdictAsk, "DROP"},
+		{"npm run predeploy and deploy", "npm run deploy", VerdictAsk, "s3 sync"},
+		{"npm run local binary", "npm run dev", VerdictAllow, "project dependency: vite"},
+		{"local rimraf", "rimraf ~", VerdictUncertain, "unknown command: rimraf"},
+		{"local prisma", "prisma migrate reset --force", VerdictUncertain, "unknown command: prisma"},
+		{"npm run make target", "npm run check", VerdictAllow, "make build"},
+		{"npm run missing", "npm run nope", VerdictUncertain, "no task nope"},
+		{"npm run list", "npm run", VerdictAllow, "lists scripts"},
+		{"yarn script", "yarn db:reset", VerdictAsk, "DROP"},
+		{"yarn run script", "yarn run build", VerdictAllow, "yarn run build"},
+		{"pnpm script", "pnpm build", VerdictAllow, "pnpm build"},
+		{"pnpm unknown", "pnpm dlx cowsay", VerdictUncertain, "pnpm dlx"},
+		{"bun run script", "bun run deploy", VerdictAsk, "s3 sync"},
+		{"npm prefix", "npm --prefix sub run build", VerdictAllow, "no package.json"},
+
+		// just
+		{"just default", "just", VerdictAllow, "docker build"},
+		{"just alias", "just b", VerdictAllow, "docker build"},
+		{"just with argument", "just deploy prod", VerdictAsk, "kubectl apply"},
+		{"just dependency call", "just release", VerdictAsk, "kubectl apply"},
+		{"just shebang", "just analyze", VerdictUncertain, "python3 script"},
+		{"just list", "just --list", VerdictAllow, "just --list"},
+
+		// task
+		{"task default", "task", VerdictAllow, "task"},
+		{"task shorthand", "task lint", VerdictAllow, "golangci-lint"},
+		{"task quoted name", "task db:drop", VerdictAsk, "DROP"},
+		{"task call and block", "task publish", VerdictAsk, "npm publish"},
+		{"task list form", "task clean", VerdictAllow, "task clean"},
+
+		// mise
+		{"mise run", "mise run build", VerdictAllow, "mise run build"},
+		{"mise depends", "mise run deploy", VerdictAsk, "gcloud run deploy"},
+		{"mise tasks table", "mise run fmt", VerdictAllow, "mise run fmt"},
+		{"mise inline table", "mise r wipe", VerdictAsk, "volume rm"},
+		{"mise shorthand", "mise deploy", VerdictAsk, "gcloud run deploy"},
+		{"mise unknown", "mise implode", VerdictUncertain, "mise implode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := evaluateCommand(tt.command, dir)
+			if got != tt.want || !strings.Contains(reason, tt.contains) {
+				t.Errorf("evaluateCommand(%q) = %v (%s), want %v containing %q", tt.command, got, reason, tt.want, tt.contains)
+			}
+		})
+	}
+}
+
+func TestEvaluateTaskRunnersWithoutTaskFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	for _, command := range []string{"make deploy", "npm run deploy", "just deploy", "task deploy", "mise run deploy"} {
+		if got, reason := evaluateCommand(command, dir); got != VerdictAllow {
+			t.Errorf("evaluateCommand(%q) = %v (%s), want ALLOW", command, got, reason)
+		}
+	}
+}
+
+func TestTaskVerdictCache(t *testing.T) {
+	dir := writeTaskFixtures(t)
+
+	if got, _ := evaluateCommand("make clean", dir); got != VerdictAllow {
+		t.Fatalf("make clean = %v, want ALLOW", got)
+	}
+	if _, err := os.Stat(verdictCachePath()); err != nil {
+		t.Fatalf("verdict cache not written: %v", err)
+	}
+	if len(loadVerdictCache()) == 0 {
+		t.Fatal("verdict cache is empty")
+	}
+
+	// Editing the Makefile changes the key, so the new recipe is evaluated
+	edited := strings.Replace(testMakefile, "-rm -rf $(DIR)", "rm -rf ~", 1)
+	os.WriteFile(filepath.Join(dir, "Makefile"), []byte(edited), 0644)
+	if got, reason := evaluateCommand("make clean", dir); got != VerdictDeny {
+		t.Errorf("make clean after edit = %v (%s), want DENY", got, reason)
+	}
+}
+
+func TestTaskVerdictCacheInputs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(kubeconfig, []byte(testKubeconfig), 0644)
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	write("Makefile", "deploy:\n\t./deploy.sh\n\nnuke:\n\tkubectl delete deployment api\n")
+	write("deploy.sh", "#!/bin/sh\ngo build ./...\n")
+	for _, command := range []string{"make deploy", "make nuke"} {
+		if got, reason := evaluateCommand(command, dir); got != VerdictAllow {
+			t.Fatalf("%s = %v (%s), want ALLOW", command, got, reason)
+		}
+	}
+
+	// The script a recipe calls is an input of the Makefile's entry
+	write("deploy.sh", "#!/bin/sh\nrm -rf /etc\n")
+	if got, reason := evaluateCommand("make deploy", dir); got != VerdictDeny {
+		t.Errorf("make deploy after editing deploy.sh = %v (%s), want DENY", got, reason)
+	}
+
+	// So is the kubeconfig that picked the context
+	switched := strings.Replace(testKubeconfig, "current-context: kind-dev", "current-context: gke_acme-prod_us-east1_main", 1)
+	os.WriteFile(kubeconfig, []byte(switched), 0644)
+	got, reason := evaluateCommand("make nuke", dir)
+	if got != VerdictAsk || strings.Contains(reason, "kind-dev") {
+		t.Errorf("make nuke after switching context = %v (%s), want ASK on the new context", got, reason)
+	}
+
+	// And the environment that named it
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+	if _, reason := evaluateCommand("make nuke", dir); strings.Contains(reason, "gke_acme-prod") {
+		t.Errorf("make nuke after changing KUBECONFIG still names the old context: %s", reason)
+	}
+}
+
+func TestMakeIncludes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("Makefile", "include tasks.mk\n-include local.mk\n\nbuild:\n\tgo build ./...\n")
+	write("tasks.mk", "deploy:\n\tterraform apply -auto-approve\n")
+
+	tests := []struct {
+		command string
+		want    Verdict
+	}{
+		{"make build", VerdictAllow},
+		{"make deploy", VerdictAsk},
+	}
+	for _, tt := range tests {
+		if got, reason := evaluateCommand(tt.command, dir); got != tt.want {
+			t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+		}
+	}
+
+	// The included file is part of the cache key
+	write("local.mk", "build:\n\trm -rf ~\n")
+	if got, reason := evaluateCommand("make build", dir); got != VerdictDeny {
+		t.Errorf("make build after adding local.mk = %v (%s), want DENY", got, reason)
+	}
+
+	for _, include := range []string{"include $(wildcard *.mk)", "include ../shared.mk", "include missing.mk"} {
+		write("Makefile", include+"\n\nbuild:\n\tgo build ./...\n")
+		if got, reason := evaluateCommand("make build", dir); got != VerdictUncertain {
+			t.Errorf("make build with %q = %v (%s), want UNCERTAIN", include, got, reason)
+		}
+	}
+}
+
+func TestEvaluateBuildTools(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		command string
+		want    Verdict
+	}{
+		{"cmake -S . -B build", VerdictAllow},
+		{"cmake --build build -j 8", VerdictAllow},
+		{"cmake --build build --target app", VerdictAllow},
+		{"cmake --build . --target install", VerdictUncertain},
+		{"cmake --build build -t install/strip", VerdictUncertain},
+		{"cmake --build build -- install", VerdictUncertain},
+		{"cmake --install build", VerdictUncertain},
+		{"cmake --install build --prefix dist", VerdictAllow},
+		{"cmake --install build --prefix /usr/local", VerdictUncertain},
+		{"cmake -E echo hi", VerdictAllow},
+		{"cmake -E rm -rf ~", VerdictUncertain},
+		{"cmake -P deploy.cmake", VerdictUncertain},
+		{"bazel build //...", VerdictAllow},
+		{"bazel --output_base=/tmp/b test //app:all", VerdictAllow},
+		{"bazelisk query 'deps(//app)'", VerdictAllow},
+		{"bazel run //tools:deploy", VerdictUncertain},
+		{"bazel test --run_under=/bin/sh //app:test", VerdictUncertain},
+		{"bazel mobile-install //app", VerdictUncertain},
+	}
+	for _, tt := range tests {
+		if got, reason := evaluateCommand(tt.command, dir); got != tt.want {
+			t.Errorf("evaluateCommand(%q) = %v (%s), want %v", tt.command, got, reason, tt.want)
+		}
+	}
+}
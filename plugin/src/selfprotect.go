@@ -0,0 +1,398 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// guardBinaryName is the name the guard runs under, used to recognize
+// attempts to stop or reconfigure it from a shell.
+const guardBinaryName = "almost-yolo-guard"
+
+// evaluateSelfProtection checks whether a tool call targets the guard itself:
+// its config directory (policy, decision log, daemon socket and PID file),
+// the plugin files, or Claude Code's permission settings. Any such call must
+// be approved by the user, regardless of what the other rules would say.
+// The boolean result is false when the call does not touch anything protected.
+func evaluateSelfProtection(toolName string, toolInput json.RawMessage, workDir string) (Verdict, string, bool) {
+	switch toolName {
+	case "Bash":
+		var input struct {
+			Command string `json:"command"`
+		}
+		if err := json.Unmarshal(toolInput, &input); err != nil {
+			return VerdictAllow, "", false
+		}
+		return evaluateCommandSelfProtection(input.Command, workDir)
+	case "Write", "Edit", "NotebookEdit":
+		var input struct {
+			FilePath     string `json:"file_path"`
+			NotebookPath string `json:"notebook_path"`
+		}
+		if err := json.Unmarshal(toolInput, &input); err != nil {
+			return VerdictAllow, "", false
+		}
+		path := input.FilePath
+		if toolName == "NotebookEdit" {
+			path = input.NotebookPath
+		}
+		if path != "" && isProtectedPath(resolvePath(path, workDir), workDir) {
+			return askOrDeny(CategorySelfProtection), "guard self-protection: " + toolName + " targeting " + path, true
+		}
+		return VerdictAllow, "", false
+	default:
+		// MCP and other tools: look for protected paths in any string value
+		var input interface{}
+		if err := json.Unmarshal(toolInput, &input); err != nil {
+			return VerdictAllow, "", false
+		}
+		for _, s := range collectStrings(input) {
+			if mentionsProtectedPath(s, workDir) {
+				return askOrDeny(CategorySelfProtection), "guard self-protection: " + toolName + " references " + s, true
+			}
+		}
+		return VerdictAllow, "", false
+	}
+}
+
+// evaluateCommandSelfProtection applies self-protection to every segment of a
+// shell command. A cd moves the directory later segments resolve against.
+func evaluateCommandSelfProtection(command, workDir string) (Verdict, string, bool) {
+	for _, segment := range splitCommandSegments(command) {
+		seg := strings.TrimSpace(segment.text)
+		if seg == "" {
+			continue
+		}
+		if dir, ok := selfProtectionCd(seg, workDir); ok {
+			workDir = dir
+		}
+		if reason, hit := segmentTargetsGuard(seg, segment.piped, workDir); hit {
+			return askOrDeny(CategorySelfProtection), "guard self-protection: " + reason, true
+		}
+		for _, inner := range commandSubstitutions(seg) {
+			if verdict, reason, hit := evaluateCommandSelfProtection(inner, workDir); hit {
+				return verdict, reason, true
+			}
+		}
+	}
+	return VerdictAllow, "", false
+}
+
+// segmentTargetsGuard reports whether a single shell segment stops the guard,
+// removes the plugin, or modifies a protected file. piped is set when the
+// segment reads the previous one's output.
+func segmentTargetsGuard(segment string, piped bool, workDir string) (string, bool) {
+	baseCmd := extractBaseCommand(segment)
+	args := extractArgs(segment)
+
+	switch baseCmd {
+	case guardBinaryName:
+		if isGuardStatusQuery(args, piped) {
+			return "", false
+		}
+		return "controls the guard: " + segment, true
+	case "kill", "pkill", "killall", "claude":
+		for _, arg := range args {
+			if strings.Contains(arg, guardBinaryName) {
+				return "targets the guard: " + segment, true
+			}
+			// pkill patterns are regular expressions on the process name
+			// or, with -f, the whole command line
+			if baseCmd == "pkill" && !strings.HasPrefix(arg, "-") {
+				if re, err := regexp.Compile(strings.Trim(arg, `"'`)); err == nil && re.MatchString(guardBinaryName+" daemon") {
+					return "targets the guard: " + segment, true
+				}
+			}
+		}
+	}
+
+	mentioned, mentionedParent := false, false
+	for _, word := range strings.Fields(segment)[1:] {
+		if mentionsProtectedPath(word, workDir) {
+			mentioned = true
+			break
+		}
+		if mentionsProtectedParent(word, workDir) {
+			mentionedParent = true
+		}
+	}
+
+	// Removing or moving a parent directory takes the protected files with it
+	switch baseCmd {
+	case "rm", "mv", "chmod", "chown":
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			target := resolvePath(expandHome(strings.Trim(arg, `"'`)), workDir)
+			for _, p := range protectedPaths(workDir) {
+				if isWithinDir(p, target) {
+					return baseCmd + " of directory containing " + p, true
+				}
+			}
+		}
+	}
+
+	// A command that isn't read-only can reach into the directory holding a
+	// protected file (find ~/.claude -delete)
+	if mentionedParent && !mentioned && !selfProtectionReadOnlyCommands[baseCmd] {
+		return "acts on a directory holding protected files: " + segment, true
+	}
+
+	if !mentioned {
+		return "", false
+	}
+
+	if hasOutputRedirect(segment) || !selfProtectionReadOnlyCommands[baseCmd] {
+		return "modifies protected file: " + segment, true
+	}
+	return "", false
+}
+
+// selfProtectionCd returns the directory a cd or pushd segment moves to, if
+// it can be known.
+func selfProtectionCd(segment, workDir string) (string, bool) {
+	segment = unwrapShellSyntax(segment)
+	switch extractBaseCommand(segment) {
+	case "cd", "pushd":
+	default:
+		return "", false
+	}
+	for _, arg := range extractArgs(segment) {
+		if arg == "-" {
+			return "", false
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		target := expandHome(strings.Trim(arg, `"'`))
+		if target == "" || strings.ContainsAny(target, "$`") {
+			return "", false
+		}
+		return resolvePath(target, workDir), true
+	}
+	return expandHome("~"), os.Getenv("HOME") != ""
+}
+
+// isGuardStatusQuery reports whether the guard is run only to report its
+// state. The hook client reads events from stdin, and a PostToolUse event can
+// record an approval, so running it with input, or with anything else, is
+// not a query.
+func isGuardStatusQuery(args []string, piped bool) bool {
+	query := strings.Join(args, " ")
+	return !piped && (query == "daemon status" || query == "doctor")
+}
+
+// selfProtectionReadOnlyCommands may inspect protected files without asking.
+var selfProtectionReadOnlyCommands = map[string]bool{
+	"cat": true, "head": true, "tail": true, "less": true, "more": true,
+	"file": true, "stat": true, "wc": true, "ls": true, "tree": true,
+	"grep": true, "rg": true, "ag": true, "ack": true, "jq": true, "yq": true,
+	"diff": true, "du": true, "realpath": true, "dirname": true, "basename": true,
+	"echo": true, "printf": true, "test": true, "[": true, "cd": true,
+}
+
+// protectedPaths returns the files and directories that must never be changed
+// without the user's approval.
+func protectedPaths(workDir string) []string {
+	paths := []string{configDir()}
+
+	if home := os.Getenv("HOME"); home != "" {
+		paths = append(paths,
+			filepath.Join(home, ".claude", "settings.json"),
+			filepath.Join(home, ".claude", "settings.local.json"),
+		)
+	}
+
+	if workDir != "" {
+		paths = append(paths,
+			filepath.Join(workDir, ".claude", "settings.json"),
+			filepath.Join(workDir, ".claude", "settings.local.json"),
+		)
+	}
+
+	// Plugin installation (hooks/hooks.json, bin/run.sh and the binary)
+	if root := os.Getenv("CLAUDE_PLUGIN_ROOT"); root != "" {
+		paths = append(paths, filepath.Clean(root))
+	}
+
+	if exe, err := os.Executable(); err == nil && filepath.Base(exe) == guardBinaryName {
+		paths = append(paths, exe)
+	}
+
+	return paths
+}
+
+// isProtectedPath reports whether an absolute path is, or lives inside, a
+// protected path.
+func isProtectedPath(path, workDir string) bool {
+	for _, p := range protectedPaths(workDir) {
+		if isWithinDir(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionsProtectedPath reports whether a shell word or free-form string refers
+// to a protected path. Home-relative forms (~, $HOME) are expanded, relative
+// words are resolved against workDir, and protected paths embedded in longer
+// strings (--file=..., inline scripts) are detected by substring.
+func mentionsProtectedPath(s, workDir string) bool {
+	s = strings.Trim(s, `"'`)
+	if s == "" {
+		return false
+	}
+	if i := strings.Index(s, "="); i >= 0 && strings.HasPrefix(s, "-") {
+		s = s[i+1:]
+	}
+	expanded := expandHome(s)
+
+	for _, p := range protectedPaths(workDir) {
+		if strings.Contains(expanded, p) {
+			return true
+		}
+	}
+
+	// A glob that matches a protected path or a directory holding one
+	// (rm ~/.claude/settings*, rm -r ~/.conf*)
+	if strings.ContainsAny(expanded, "*?[") {
+		pattern := resolvePath(expanded, workDir)
+		for _, p := range protectedPaths(workDir) {
+			for dir := p; ; dir = filepath.Dir(dir) {
+				if shellGlobMatch(pattern, dir) {
+					return true
+				}
+				if dir == filepath.Dir(dir) {
+					break
+				}
+			}
+		}
+	}
+
+	if !strings.ContainsAny(expanded, " ()'\"") && isProtectedPath(resolvePath(expanded, workDir), workDir) {
+		return true
+	}
+
+	// Project settings referenced relative to some other directory
+	return strings.Contains(expanded, filepath.Join(".claude", "settings.json")) ||
+		strings.Contains(expanded, filepath.Join(".claude", "settings.local.json")) ||
+		strings.Contains(expanded, filepath.Join(".config", guardBinaryName))
+}
+
+// shellGlobMatch matches a path against a glob the way the shell expands
+// it: component by component, with a leading dot matched only explicitly.
+func shellGlobMatch(pattern, path string) bool {
+	patterns, names := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(patterns) != len(names) {
+		return false
+	}
+	for i, name := range names {
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(patterns[i], ".") {
+			return false
+		}
+		if matched, _ := filepath.Match(patterns[i], name); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// mentionsProtectedParent reports whether a shell word names the directory
+// directly holding a protected path, such as ~/.claude.
+func mentionsProtectedParent(s, workDir string) bool {
+	s = strings.Trim(s, `"'`)
+	if s == "" || strings.HasPrefix(s, "-") {
+		return false
+	}
+	expanded := expandHome(s)
+	if strings.ContainsAny(expanded, " ()'\"$`") {
+		return false
+	}
+	path := resolvePath(expanded, workDir)
+	for _, p := range protectedPaths(workDir) {
+		if path == filepath.Dir(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHome replaces ~, $HOME and ${HOME} with the home directory.
+func expandHome(s string) string {
+	home := os.Getenv("HOME")
+	if home == "" {
+		return s
+	}
+	s = strings.ReplaceAll(s, "${HOME}", home)
+	s = strings.ReplaceAll(s, "$HOME", home)
+	if s == "~" || strings.HasPrefix(s, "~/") {
+		s = home + s[1:]
+	}
+	return s
+}
+
+// resolvePath makes a path absolute relative to workDir and cleans it.
+func resolvePath(path, workDir string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// hasOutputRedirect reports whether a segment writes to a file via > or >>,
+// ignoring quoted text, fd duplications like 2>&1 and redirects to /dev/null.
+func hasOutputRedirect(segment string) bool {
+	inSingle, inDouble := false, false
+	for i := 0; i < len(segment); i++ {
+		switch ch := segment[i]; {
+		case ch == '\'' && !inDouble:
+			inSingle = !inSingle
+		case ch == '"' && !inSingle:
+			inDouble = !inDouble
+		case ch == '>' && !inSingle && !inDouble:
+			if i+1 < len(segment) && segment[i+1] == '&' {
+				continue
+			}
+			if redirectsToDevNull(segment[i+1:]) {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// redirectsToDevNull reports whether the text after a > names /dev/null.
+func redirectsToDevNull(rest string) bool {
+	rest = strings.TrimLeft(strings.TrimPrefix(strings.TrimPrefix(rest, ">"), "|"), " \t")
+	if !strings.HasPrefix(rest, "/dev/null") {
+		return false
+	}
+	rest = rest[len("/dev/null"):]
+	return rest == "" || strings.ContainsRune(" \t;&|)", rune(rest[0]))
+}
+
+// collectStrings returns every string value in a decoded JSON document.
+func collectStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			out = append(out, collectStrings(item)...)
+		}
+		return out
+	case map[string]interface{}:
+		var out []string
+		for _, item := range val {
+			out = append(out, collectStrings(item)...)
+		}
+		return out
+	}
+	return nil
+}
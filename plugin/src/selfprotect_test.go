@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfProtection(t *testing.T) {
+	home := os.Getenv("HOME")
+	workDir := "/Users/victor/projects/myapp"
+	guardDir := filepath.Join(home, ".config", "almost-yolo-guard")
+
+	tests := []struct {
+		name     string
+		toolName string
+		input    string
+		want     Verdict
+	}{
+		// ===== Bash: guard process control =====
+		{"daemon stop", "Bash", `{"command":"almost-yolo-guard daemon stop"}`, VerdictAsk},
+		{"daemon restart", "Bash", `{"command":"almost-yolo-guard daemon restart"}`, VerdictAsk},
+		{"daemon status", "Bash", `{"command":"almost-yolo-guard daemon status"}`, VerdictAllow},
+		{"doctor", "Bash", `{"command":"almost-yolo-guard doctor"}`, VerdictAllow},
+		{"hook client", "Bash", `{"command":"almost-yolo-guard"}`, VerdictAsk},
+		{"hook client with piped event", "Bash", `{"command":"echo '{\"hook_event_name\":\"PostToolUse\"}' | almost-yolo-guard"}`, VerdictAsk},
+		{"hook client with input file", "Bash", `{"command":"almost-yolo-guard < event.json"}`, VerdictAsk},
+		{"hook client with heredoc", "Bash", `{"command":"almost-yolo-guard <<'EOF'\n{}\nEOF"}`, VerdictAsk},
+		{"piped status", "Bash", `{"command":"cat event.json | almost-yolo-guard daemon status"}`, VerdictAsk},
+		{"session forget", "Bash", `{"command":"almost-yolo-guard session forget"}`, VerdictAsk},
+		{"pkill guard", "Bash", `{"command":"pkill almost-yolo-guard"}`, VerdictAsk},
+		{"pkill -f guard", "Bash", `{"command":"pkill -f 'almost-yolo-guard daemon'"}`, VerdictAsk},
+		{"killall guard", "Bash", `{"command":"killall almost-yolo-guard"}`, VerdictAsk},
+		{"kill by pid file", "Bash", `{"command":"kill $(cat ~/.config/almost-yolo-guard/daemon.pid)"}`, VerdictAsk},
+		{"plugin uninstall", "Bash", `{"command":"claude plugin uninstall almost-yolo-guard"}`, VerdictAsk},
+		{"pkill other", "Bash", `{"command":"pkill node"}`, VerdictAllow},
+		{"pkill partial name", "Bash", `{"command":"pkill -f almost-yolo"}`, VerdictAsk},
+		{"pkill pattern", "Bash", `{"command":"pkill -f 'yolo-g.*daemon'"}`, VerdictAsk},
+
+		// ===== Bash: guard control nested in other commands =====
+		{"sh -c daemon stop", "Bash", `{"command":"sh -c \"almost-yolo-guard daemon stop\""}`, VerdictAsk},
+		{"bash -c pkill", "Bash", `{"command":"bash -c 'pkill almost-yolo-guard'"}`, VerdictAsk},
+		{"timeout daemon stop", "Bash", `{"command":"timeout 5 almost-yolo-guard daemon stop"}`, VerdictAsk},
+		{"nohup daemon stop", "Bash", `{"command":"nohup almost-yolo-guard daemon stop"}`, VerdictAsk},
+		{"exec daemon stop", "Bash", `{"command":"exec almost-yolo-guard daemon stop"}`, VerdictAsk},
+		{"function daemon stop", "Bash", `{"command":"f(){ almost-yolo-guard daemon stop; }; f"}`, VerdictAsk},
+		{"ssh daemon stop", "Bash", `{"command":"ssh localhost almost-yolo-guard daemon stop"}`, VerdictAsk},
+		{"sh -c forged event", "Bash", `{"command":"sh -c 'echo {} | almost-yolo-guard'"}`, VerdictAsk},
+		{"sh -c daemon status", "Bash", `{"command":"sh -c 'almost-yolo-guard daemon status'"}`, VerdictAllow},
+
+		// ===== Bash: protected files =====
+		{"tail log", "Bash", `{"command":"tail -f ~/.config/almost-yolo-guard/decisions.log"}`, VerdictAllow},
+		{"cat settings", "Bash", `{"command":"cat ~/.claude/settings.json"}`, VerdictAllow},
+		{"rm log", "Bash", `{"command":"rm ~/.config/almost-yolo-guard/decisions.log"}`, VerdictAsk},
+		{"rm config dir", "Bash", `{"command":"rm -rf $HOME/.config/almost-yolo-guard"}`, VerdictAsk},
+		{"rm parent dir", "Bash", `{"command":"rm -rf ~/.config"}`, VerdictAsk},
+		{"echo into settings", "Bash", `{"command":"echo '{}' > ~/.claude/settings.json"}`, VerdictAsk},
+		{"sed settings", "Bash", `{"command":"sed -i 's/hooks//' ~/.claude/settings.json"}`, VerdictAsk},
+		{"jq into project settings", "Bash", `{"command":"jq '.hooks = {}' .claude/settings.json > /tmp/s && mv /tmp/s .claude/settings.json"}`, VerdictAsk},
+		{"python writes settings", "Bash", `{"command":"python3 -c \"open('` + home + `/.claude/settings.json','w').write('{}')\""}`, VerdictAsk},
+		{"rm settings glob", "Bash", `{"command":"rm ~/.claude/settings*"}`, VerdictAsk},
+		{"rm config glob", "Bash", `{"command":"rm -rf ~/.conf*"}`, VerdictAsk},
+		{"find delete in settings dir", "Bash", `{"command":"find ~/.claude -delete"}`, VerdictAsk},
+		{"ls settings dir", "Bash", `{"command":"ls ~/.claude"}`, VerdictAllow},
+		{"cd then rm settings", "Bash", `{"command":"cd ~/.claude && rm settings.json"}`, VerdictAsk},
+		{"cd then rm guard log", "Bash", `{"command":"cd ~/.config/almost-yolo-guard; rm decisions.log"}`, VerdictAsk},
+		{"cd then rm other", "Bash", `{"command":"cd dist && rm app.js"}`, VerdictAllow},
+		{"unrelated rm", "Bash", `{"command":"rm -rf dist/"}`, VerdictAllow},
+
+		// ===== File tools =====
+		{"write user settings", "Write", `{"file_path":"` + home + `/.claude/settings.json","content":"{}"}`, VerdictAsk},
+		{"edit local settings", "Edit", `{"file_path":"` + home + `/.claude/settings.local.json","old_string":"a","new_string":"b"}`, VerdictAsk},
+		{"write project settings", "Write", `{"file_path":"` + workDir + `/.claude/settings.json","content":"{}"}`, VerdictAsk},
+		{"edit guard log", "Edit", `{"file_path":"` + guardDir + `/decisions.log","old_string":"ASK","new_string":"ALLOW"}`, VerdictAsk},
+		{"write project file", "Write", `{"file_path":"` + workDir + `/.claude/commands/review.md","content":"x"}`, VerdictAllow},
+
+		// ===== Other tools =====
+		{"mcp writes settings", "mcp__fs__write_file", `{"path":"~/.claude/settings.json","content":"{}"}`, VerdictAsk},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := EvaluateRules(tt.toolName, json.RawMessage(tt.input), workDir)
+			if got != tt.want {
+				t.Errorf("EvaluateRules(%s, %s) = %v (%s), want %v",
+					tt.toolName, truncate(tt.input, 80), got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelfProtectionTaskRecipes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir()) // keep the verdict cache out of the real config dir
+	dir := t.TempDir()
+	files := map[string]string{
+		"Makefile":     "stop:\n\tpkill almost-yolo-guard\n",
+		"package.json": `{"scripts":{"unguard":"almost-yolo-guard daemon stop"}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, command := range []string{"make stop", "npm run unguard"} {
+		input, _ := json.Marshal(map[string]string{"command": command})
+		if got, reason := EvaluateRules("Bash", input, dir); got != VerdictAsk {
+			t.Errorf("EvaluateRules(%q) = %v (%s), want ASK", command, got, reason)
+		}
+	}
+}
+
+func TestSelfProtectionPluginRoot(t *testing.T) {
+	root := "/Users/victor/.claude/plugins/cache/almost-yolo-guard"
+	t.Setenv("CLAUDE_PLUGIN_ROOT", root)
+
+	tests := []struct {
+		name     string
+		toolName string
+		input    string
+		want     Verdict
+	}{
+		{"rm hooks.json", "Bash", `{"command":"rm ` + root + `/hooks/hooks.json"}`, VerdictAsk},
+		{"write hooks.json", "Write", `{"file_path":"` + root + `/hooks/hooks.json","content":"{}"}`, VerdictAsk},
+		{"read hooks.json", "Bash", `{"command":"cat ` + root + `/hooks/hooks.json"}`, VerdictAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := EvaluateRules(tt.toolName, json.RawMessage(tt.input), "/tmp/project")
+			if got != tt.want {
+				t.Errorf("EvaluateRules(%s, %s) = %v (%s), want %v",
+					tt.toolName, truncate(tt.input, 80), got, reason, tt.want)
+			}
+		})
+	}
+}
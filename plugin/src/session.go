@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Calls the user approves after an ASK are remembered by the daemon for the
+// rest of the session, so the identical call is allowed without asking
+// again. The approval comes from the PostToolUse outcome: a tool only runs
+// after an ASK if the user said yes. Approvals are persisted so they survive
+// the daemon's idle exit, and expire after the policy's TTL.
+
+// Normalizations for SessionApprovalPolicy.Normalize.
+const (
+	NormalizeWhitespace = "whitespace" // runs of blanks in unquoted commands are one space
+	NormalizeCwd        = "cwd"        // the same call from another directory counts
+)
+
+var knownNormalizations = map[string]bool{NormalizeWhitespace: true, NormalizeCwd: true}
+
+// approvalKey identifies a call for session approvals. Only the Bash
+// command counts, not Claude's description of it; other tools use their
+// whole input. The notes of the evaluation that asked are part of the key,
+// so an approval doesn't carry over to the same command once the script it
+// runs, the kube context or the target profile has changed.
+func approvalKey(policy *Policy, toolName string, toolInput json.RawMessage, workDir string) string {
+	normalize := map[string]bool{}
+	for _, name := range policy.SessionApprovals.Normalize {
+		normalize[name] = true
+	}
+
+	var input interface{}
+	json.Unmarshal(toolInput, &input)
+	subject := string(toolInput)
+	if data, err := json.Marshal(input); err == nil {
+		subject = string(data)
+	}
+	if fields, ok := input.(map[string]interface{}); ok && toolName == "Bash" {
+		command, _ := fields["command"].(string)
+		command = strings.TrimSpace(command)
+		if normalize[NormalizeWhitespace] && !strings.ContainsAny(command, "'\"\\\n") {
+			command = strings.Join(strings.Fields(command), " ")
+		}
+		subject = command
+	}
+	if normalize[NormalizeCwd] {
+		workDir = ""
+	}
+	return contentKey("approval", toolName, workDir, subject, strings.Join(ruleNoteList(), "\n"))
+}
+
+// rememberable reports whether an ASK flagged with these categories may be
+// remembered. Anything in a deny-class category (denied by default or by the
+// policy) asks every time, even where the policy downgrades it to ask.
+func (p *Policy) rememberable(categories []string) bool {
+	if !p.SessionApprovals.enabled() {
+		return false
+	}
+	for _, category := range categories {
+		if defaultCategories[category] == CategoryDeny || p.Categories[category] == CategoryDeny {
+			return false
+		}
+	}
+	return true
+}
+
+// sessionApprovals is the daemon's approval memory: session → key → expiry.
+type sessionApprovals struct {
+	mu      sync.Mutex
+	path    string
+	loaded  bool
+	entries map[string]map[string]time.Time
+}
+
+func defaultSessionApprovalsPath() string {
+	return filepath.Join(configDir(), "session-approvals.json")
+}
+
+func newSessionApprovals(path string) *sessionApprovals {
+	return &sessionApprovals{path: path, entries: map[string]map[string]time.Time{}}
+}
+
+// load reads the persisted approvals once. Callers hold mu.
+func (s *sessionApprovals) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	if data, err := os.ReadFile(s.path); err == nil {
+		json.Unmarshal(data, &s.entries)
+	}
+	if s.entries == nil {
+		s.entries = map[string]map[string]time.Time{}
+	}
+}
+
+// save drops expired approvals and writes the rest. Callers hold mu.
+func (s *sessionApprovals) save() {
+	now := time.Now()
+	for session, keys := range s.entries {
+		for key, expires := range keys {
+			if now.After(expires) {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(s.entries, session)
+		}
+	}
+	data, err := json.Marshal(s.entries)
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(s.path), 0755)
+	tmp := s.path + ".tmp"
+	if os.WriteFile(tmp, data, 0600) == nil {
+		os.Rename(tmp, s.path)
+	}
+}
+
+func (s *sessionApprovals) remember(session, key string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	if s.entries[session] == nil {
+		s.entries[session] = map[string]time.Time{}
+	}
+	s.entries[session][key] = time.Now().Add(ttl)
+	s.save()
+}
+
+func (s *sessionApprovals) recall(session, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	expires, ok := s.entries[session][key]
+	return ok && time.Now().Before(expires)
+}
+
+// forget drops a session's approvals, or every session's if session is
+// empty, and returns how many were dropped.
+func (s *sessionApprovals) forget(session string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	count := 0
+	for id, keys := range s.entries {
+		if session == "" || id == session {
+			count += len(keys)
+			delete(s.entries, id)
+		}
+	}
+	s.save()
+	return count
+}
+
+// handle answers a session approval request.
+func (s *sessionApprovals) handle(req EvalRequest) EvalResponse {
+	switch req.Type {
+	case RequestRemember:
+		if req.SessionID == "" || req.Key == "" || req.TTL <= 0 {
+			return EvalResponse{Decision: "ASK", Reason: "incomplete approval"}
+		}
+		s.remember(req.SessionID, req.Key, time.Duration(req.TTL)*time.Second)
+		return EvalResponse{Decision: "ALLOW", Reason: "remembered"}
+	case RequestRecall:
+		if req.SessionID != "" && s.recall(req.SessionID, req.Key) {
+			return EvalResponse{Decision: "ALLOW", Reason: "approved earlier in this session"}
+		}
+		return EvalResponse{Decision: "ASK", Reason: "not approved in this session"}
+	case RequestForget:
+		return EvalResponse{Decision: "ALLOW", Reason: fmt.Sprintf("forgot %d approvals", s.forget(req.SessionID))}
+	}
+	return EvalResponse{Decision: "ASK", Reason: "unknown request type " + req.Type}
+}
+
+// recallApproval asks the daemon whether the user already approved this call
+// in the session. Without a running daemon, the approvals file it keeps is
+// read directly; no daemon is started just for this.
+func recallApproval(sessionID, key string) bool {
+	if sessionID == "" {
+		return false
+	}
+	resp, err := sendDaemonRequest(defaultSocketPath(), EvalRequest{Type: RequestRecall, SessionID: sessionID, Key: key})
+	if err != nil {
+		return newSessionApprovals(defaultSessionApprovalsPath()).recall(sessionID, key)
+	}
+	return resp.Decision == "ALLOW"
+}
+
+// rememberApproval stores an approved ASK with the daemon, or in its
+// approvals file if it isn't running.
+func rememberApproval(sessionID, key string, ttl time.Duration) {
+	if sessionID == "" {
+		return
+	}
+	req := EvalRequest{Type: RequestRemember, SessionID: sessionID, Key: key, TTL: int64(ttl / time.Second)}
+	if _, err := sendDaemonRequest(defaultSocketPath(), req); err != nil {
+		newSessionApprovals(defaultSessionApprovalsPath()).remember(sessionID, key, ttl)
+	}
+}
+
+// --- Session commands ---
+
+// runSession is the entry point for `almost-yolo-guard session`.
+func runSession(args []string) {
+	if len(args) == 0 || args[0] != "forget" || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: almost-yolo-guard session forget [SESSION_ID]")
+		os.Exit(1)
+	}
+	session := ""
+	if len(args) == 2 {
+		session = args[1]
+	}
+	fmt.Println(sessionForget(session))
+}
+
+// sessionForget clears approvals through the running daemon, or in the
+// approvals file if no daemon is running.
+func sessionForget(session string) string {
+	resp, err := sendDaemonRequest(defaultSocketPath(), EvalRequest{Type: RequestForget, SessionID: session})
+	if err == nil {
+		return resp.Reason
+	}
+	return fmt.Sprintf("forgot %d approvals", newSessionApprovals(defaultSessionApprovalsPath()).forget(session))
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// sqlEffect is what a batch of SQL (or other database statements) does,
+// ordered from harmless to most dangerous.
+type sqlEffect int
+
+const (
+	sqlRead        sqlEffect = iota // Only reads data or session state
+	sqlUnknown                      // Could not be classified
+	sqlWrite                        // Changes data or schema
+	sqlDestructive                  // Drops databases, truncates, or modifies every row
+)
+
+// SQL dialects understood by the tokenizer. They differ in comment syntax and
+// client meta-commands.
+const (
+	dialectANSI     = "ansi"
+	dialectPostgres = "postgres" // psql backslash meta-commands, $$ quoting
+	dialectMySQL    = "mysql"    // # comments
+	dialectSQLite   = "sqlite"   // .dot commands (also duckdb)
+)
+
+// classifySQL tokenizes SQL text, ignoring comments and string contents, and
+// returns the most dangerous effect of any statement together with the
+// keyword that decided it.
+func classifySQL(text, dialect string) (sqlEffect, string) {
+	worst := sqlRead
+	keyword := ""
+	for _, stmt := range sqlStatements(text, dialect) {
+		effect, kw := classifyStatement(stmt)
+		if effect > worst || keyword == "" {
+			worst = effect
+			keyword = kw
+		}
+	}
+	return worst, keyword
+}
+
+// sqlStatements splits SQL into statements of upper-cased tokens. String
+// literals become "STR", quoted identifiers "IDENT"; punctuation other than
+// parentheses and '=' is dropped. Client meta-commands (psql \x, sqlite .x)
+// become single-token statements.
+func sqlStatements(text, dialect string) [][]string {
+	var statements [][]string
+	var current []string
+	runes := []rune(text)
+	atLineStart := true
+
+	flush := func() {
+		if len(current) > 0 {
+			statements = append(statements, current)
+			current = nil
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+
+		if ch == '\n' {
+			atLineStart = true
+			continue
+		}
+		if unicode.IsSpace(ch) {
+			continue
+		}
+
+		// sqlite dot commands only count at the start of a line; psql
+		// meta-commands also end a query on the same line (SELECT ... \gexec)
+		if (ch == '\\' && dialect == dialectPostgres) || (atLineStart && len(current) == 0 && ch == '.' && dialect == dialectSQLite) {
+			flush()
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			fields := strings.Fields(string(runes[i:end]))
+			meta := strings.ToLower(fields[0])
+			if (meta == `\g` || meta == `\gx`) && len(fields) > 1 {
+				meta += " FILE" // \g FILE and \g |COMMAND send the results elsewhere
+			}
+			statements = append(statements, []string{meta})
+			i = end - 1
+			continue
+		}
+		atLineStart = false
+
+		switch {
+		case ch == '-' && i+1 < len(runes) && runes[i+1] == '-',
+			ch == '#' && dialect == dialectMySQL:
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			i--
+		case ch == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+		case ch == '\'':
+			i = skipQuoted(runes, i, '\'')
+			current = append(current, "STR")
+		case ch == '"' || ch == '`':
+			i = skipQuoted(runes, i, ch)
+			current = append(current, "IDENT")
+		case ch == '$' && dialect == dialectPostgres:
+			// Dollar quoting: $$...$$ or $tag$...$tag$
+			end := i + 1
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			if end < len(runes) && runes[end] == '$' {
+				tag := string(runes[i : end+1])
+				rest := string(runes[end+1:])
+				if close := strings.Index(rest, tag); close >= 0 {
+					i = end + len([]rune(rest[:close])) + len([]rune(tag))
+				} else {
+					i = len(runes)
+				}
+				current = append(current, "STR")
+			}
+		case ch == ';':
+			flush()
+		case ch == '(' || ch == ')' || ch == '=':
+			current = append(current, string(ch))
+		case unicode.IsLetter(ch) || ch == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_' || runes[end] == '$') {
+				end++
+			}
+			current = append(current, strings.ToUpper(string(runes[i:end])))
+			i = end - 1
+		}
+	}
+	flush()
+	return statements
+}
+
+// skipQuoted returns the index of the closing quote, treating a doubled quote
+// or a backslash as an escape.
+func skipQuoted(runes []rune, start int, quote rune) int {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(runes) && runes[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(runes)
+}
+
+var sqlReadKeywords = map[string]bool{
+	"SHOW": true, "DESCRIBE": true, "DESC": true, "VALUES": true, "TABLE": true,
+	"USE": true, "BEGIN": true, "COMMIT": true, "ROLLBACK": true,
+	"START": true, "END": true, "SAVEPOINT": true, "RELEASE": true,
+	"SUMMARIZE": true, "EXISTS": true, "CHECK": true, "FETCH": true,
+	"DECLARE": true, "CLOSE": true, "LISTEN": true, "UNLISTEN": true,
+}
+
+var sqlWriteKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"REPLACE": true, "CREATE": true, "DROP": true, "ALTER": true, "GRANT": true,
+	"REVOKE": true, "VACUUM": true, "ANALYZE": true, "REINDEX": true, "CLUSTER": true,
+	"ATTACH": true, "DETACH": true, "RENAME": true, "LOAD": true, "CALL": true,
+	"DO": true, "EXEC": true, "EXECUTE": true, "LOCK": true, "REFRESH": true,
+	"COMMENT": true, "REASSIGN": true, "IMPORT": true, "EXPORT": true,
+	"INSTALL": true, "OPTIMIZE": true, "KILL": true, "SYSTEM": true, "FLUSH": true,
+	"UNLOAD": true, "PUT": true, "NOTIFY": true, "SECURITY": true, "DISCARD": true,
+	"CHECKPOINT": true, "PREPARE": true, "HANDLER": true, "PURGE": true,
+	"SET": true, // SET PASSWORD, SET GLOBAL read_only = 0
+}
+
+// sqlSideEffectFunctions turn an otherwise read-only SELECT into a write.
+var sqlSideEffectFunctions = map[string]bool{
+	"PG_TERMINATE_BACKEND": true, "PG_CANCEL_BACKEND": true, "PG_RELOAD_CONF": true,
+	"PG_ROTATE_LOGFILE": true, "PG_PROMOTE": true, "PG_DROP_REPLICATION_SLOT": true,
+	"SETVAL": true, "LO_IMPORT": true, "LO_EXPORT": true, "LO_UNLINK": true,
+	"DBLINK_EXEC": true, "PG_FILE_WRITE": true, "SYSTEM$CANCEL_QUERY": true,
+}
+
+// psqlReadMetaCommands only describe the database or change client display.
+var psqlReadMetaCommands = map[string]bool{
+	`\d`: true, `\dt`: true, `\dt+`: true, `\d+`: true, `\di`: true, `\dv`: true,
+	`\dn`: true, `\df`: true, `\ds`: true, `\du`: true, `\dx`: true, `\dp`: true,
+	`\l`: true, `\l+`: true, `\x`: true, `\timing`: true, `\conninfo`: true,
+	`\q`: true, `\echo`: true, `\pset`: true, `\a`: true, `\t`: true, `\H`: true,
+	`\encoding`: true, `\errverbose`: true, `\sf`: true, `\sv`: true, `\c`: true,
+	`\connect`: true, `\dd`: true, `\db`: true, `\dT`: true, `\dE`: true, `\dm`: true,
+	`\g`: true, `\gx`: true, `\gset`: true, `\gdesc`: true,
+}
+
+// sqliteReadDotCommands only inspect the database or change output format.
+var sqliteReadDotCommands = map[string]bool{
+	".tables": true, ".schema": true, ".indexes": true, ".indices": true,
+	".mode": true, ".headers": true, ".header": true, ".dump": true,
+	".databases": true, ".show": true, ".width": true, ".timer": true,
+	".nullvalue": true, ".separator": true, ".quit": true, ".exit": true,
+	".help": true, ".echo": true, ".changes": true, ".stats": true,
+	".dbinfo": true, ".fullschema": true, ".lint": true, ".eqp": true,
+	".columns": true, ".maxrows": true, ".print": true, ".bail": true,
+}
+
+// classifyStatement classifies one tokenized statement.
+func classifyStatement(tokens []string) (sqlEffect, string) {
+	if len(tokens) == 0 {
+		return sqlRead, ""
+	}
+	first := tokens[0]
+
+	switch {
+	case first == `\gexec`:
+		// Runs every cell of the result as a statement
+		return sqlWrite, `psql \gexec`
+	case strings.HasPrefix(first, `\`):
+		if psqlReadMetaCommands[first] {
+			return sqlRead, first
+		}
+		return sqlUnknown, "psql " + first
+	case strings.HasPrefix(first, "."):
+		if sqliteReadDotCommands[first] {
+			return sqlRead, first
+		}
+		return sqlUnknown, first
+	}
+
+	switch first {
+	case "SELECT", "WITH", "FROM":
+		for i, tok := range tokens[1:] {
+			switch {
+			case tok == "UPDATE" && (tokens[i] == "FOR" || tokens[i] == "KEY"):
+				// SELECT ... FOR UPDATE, FOR NO KEY UPDATE only lock rows
+			case tok == "INSERT" || tok == "UPDATE" || tok == "DELETE" || tok == "MERGE":
+				return sqlWrite, first + " ... " + tok
+			case tok == "INTO":
+				return sqlWrite, "SELECT INTO"
+			case sqlSideEffectFunctions[tok]:
+				return sqlWrite, tok
+			}
+		}
+		return sqlRead, first
+	case "EXPLAIN":
+		// EXPLAIN ANALYZE executes the statement
+		rest := tokens[1:]
+		analyze := false
+		for len(rest) > 0 && (rest[0] == "ANALYZE" || rest[0] == "VERBOSE" || rest[0] == "(" || rest[0] == ")" ||
+			rest[0] == "QUERY" || rest[0] == "PLAN" || rest[0] == "FORMAT" || rest[0] == "JSON" || rest[0] == "TRUE") {
+			if rest[0] == "ANALYZE" {
+				analyze = true
+			}
+			rest = rest[1:]
+		}
+		if !analyze {
+			return sqlRead, "EXPLAIN"
+		}
+		return classifyStatement(rest)
+	case "PRAGMA":
+		for _, tok := range tokens {
+			if tok == "=" {
+				return sqlWrite, "PRAGMA assignment"
+			}
+		}
+		return sqlRead, "PRAGMA"
+	case "COPY":
+		// COPY ... TO STDOUT only streams rows to the client
+		for i, tok := range tokens {
+			if tok == "TO" && i+1 < len(tokens) && tokens[i+1] == "STDOUT" {
+				return sqlRead, "COPY TO STDOUT"
+			}
+		}
+		return sqlWrite, "COPY"
+	case "TRUNCATE":
+		return sqlDestructive, "TRUNCATE"
+	case "DROP":
+		if len(tokens) > 1 && (tokens[1] == "DATABASE" || tokens[1] == "SCHEMA") {
+			return sqlDestructive, "DROP " + tokens[1]
+		}
+		return sqlWrite, "DROP"
+	case "DELETE", "UPDATE":
+		for _, tok := range tokens {
+			if tok == "WHERE" {
+				return sqlWrite, first
+			}
+		}
+		return sqlDestructive, first + " without WHERE"
+	}
+
+	if sqlReadKeywords[first] {
+		return sqlRead, first
+	}
+	if sqlWriteKeywords[first] {
+		return sqlWrite, first
+	}
+	return sqlUnknown, first
+}
+
+// classifyMongoScript classifies mongosh JavaScript by the collection and
+// database methods it calls.
+func classifyMongoScript(script string) (sqlEffect, string) {
+	worst := sqlRead
+	keyword := ""
+	found := false
+
+	for _, call := range mongoMethodCalls(script) {
+		found = true
+		effect, ok := mongoMethods[call]
+		if !ok {
+			effect = sqlUnknown
+		}
+		if call == "aggregate" && (strings.Contains(script, "$out") || strings.Contains(script, "$merge")) {
+			effect = sqlWrite
+		}
+		if effect > worst || keyword == "" {
+			worst = effect
+			keyword = call
+		}
+	}
+
+	if !found {
+		// show dbs, show collections, use db
+		trimmed := strings.TrimSpace(script)
+		if strings.HasPrefix(trimmed, "show ") || strings.HasPrefix(trimmed, "use ") {
+			return sqlRead, strings.Fields(trimmed)[0]
+		}
+		return sqlUnknown, "unrecognized script"
+	}
+	return worst, keyword
+}
+
+// mongoMethodCalls returns the names of all .method( calls in a script.
+func mongoMethodCalls(script string) []string {
+	var calls []string
+	for i := 0; i < len(script); i++ {
+		if script[i] != '.' {
+			continue
+		}
+		end := i + 1
+		for end < len(script) && (isIdentByte(script[end])) {
+			end++
+		}
+		if end > i+1 && end < len(script) && script[end] == '(' {
+			calls = append(calls, script[i+1:end])
+		}
+	}
+	return calls
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '$' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+var mongoMethods = map[string]sqlEffect{
+	// Reads
+	"find": sqlRead, "findOne": sqlRead, "aggregate": sqlRead, "count": sqlRead,
+	"countDocuments": sqlRead, "estimatedDocumentCount": sqlRead, "distinct": sqlRead,
+	"getCollection": sqlRead, "getCollectionNames": sqlRead, "getCollectionInfos": sqlRead,
+	"getIndexes": sqlRead, "stats": sqlRead, "explain": sqlRead, "getSiblingDB": sqlRead,
+	"limit": sqlRead, "sort": sqlRead, "skip": sqlRead, "toArray": sqlRead,
+	"forEach": sqlRead, "map": sqlRead, "pretty": sqlRead, "projection": sqlRead,
+	"hasNext": sqlRead, "next": sqlRead, "itcount": sqlRead, "getName": sqlRead,
+	"log": sqlRead, "stringify": sqlRead, "printjson": sqlRead, "print": sqlRead,
+	"version": sqlRead, "serverStatus": sqlRead, "hello": sqlRead, "isMaster": sqlRead,
+	"getMongo": sqlRead, "getDBNames": sqlRead, "currentOp": sqlRead,
+
+	// Writes
+	"insert": sqlWrite, "insertOne": sqlWrite, "insertMany": sqlWrite,
+	"update": sqlWrite, "updateOne": sqlWrite, "updateMany": sqlWrite,
+	"replaceOne": sqlWrite, "deleteOne": sqlWrite, "deleteMany": sqlWrite,
+	"remove": sqlWrite, "findOneAndUpdate": sqlWrite, "findOneAndReplace": sqlWrite,
+	"findOneAndDelete": sqlWrite, "findAndModify": sqlWrite, "bulkWrite": sqlWrite,
+	"createIndex": sqlWrite, "createIndexes": sqlWrite, "createCollection": sqlWrite,
+	"renameCollection": sqlWrite, "save": sqlWrite, "createUser": sqlWrite,
+	"updateUser": sqlWrite, "grantRolesToUser": sqlWrite, "killOp": sqlWrite,
+
+	// Destructive
+	"drop": sqlDestructive, "dropDatabase": sqlDestructive, "dropIndex": sqlDestructive,
+	"dropIndexes": sqlDestructive, "dropUser": sqlDestructive, "dropAllUsers": sqlDestructive,
+	"shutdownServer": sqlDestructive,
+}
+
+// classifyRedisCommand classifies a single redis-cli command line.
+func classifyRedisCommand(words []string) (sqlEffect, string) {
+	if len(words) == 0 {
+		return sqlRead, ""
+	}
+	cmd := strings.ToUpper(words[0])
+	if cmd == "CONFIG" || cmd == "CLIENT" || cmd == "SCRIPT" || cmd == "CLUSTER" ||
+		cmd == "SLOWLOG" || cmd == "MEMORY" || cmd == "OBJECT" || cmd == "ACL" || cmd == "XINFO" {
+		if len(words) > 1 {
+			cmd += " " + strings.ToUpper(words[1])
+		}
+	}
+
+	if effect, ok := redisCommands[cmd]; ok {
+		return effect, cmd
+	}
+	return sqlWrite, cmd
+}
+
+var redisCommands = map[string]sqlEffect{
+	"GET": sqlRead, "MGET": sqlRead, "GETRANGE": sqlRead, "STRLEN": sqlRead,
+	"EXISTS": sqlRead, "TYPE": sqlRead, "TTL": sqlRead, "PTTL": sqlRead,
+	"KEYS": sqlRead, "SCAN": sqlRead, "DBSIZE": sqlRead, "INFO": sqlRead,
+	"PING": sqlRead, "ECHO": sqlRead, "TIME": sqlRead, "LASTSAVE": sqlRead,
+	"HGET": sqlRead, "HMGET": sqlRead, "HGETALL": sqlRead, "HKEYS": sqlRead,
+	"HVALS": sqlRead, "HLEN": sqlRead, "HEXISTS": sqlRead, "HSCAN": sqlRead,
+	"LRANGE": sqlRead, "LLEN": sqlRead, "LINDEX": sqlRead, "LPOS": sqlRead,
+	"SMEMBERS": sqlRead, "SCARD": sqlRead, "SISMEMBER": sqlRead, "SSCAN": sqlRead,
+	"SRANDMEMBER": sqlRead, "ZRANGE": sqlRead, "ZCARD": sqlRead, "ZSCORE": sqlRead,
+	"ZRANK": sqlRead, "ZCOUNT": sqlRead, "ZSCAN": sqlRead, "ZRANGEBYSCORE": sqlRead,
+	"XRANGE": sqlRead, "XREVRANGE": sqlRead, "XLEN": sqlRead, "XREAD": sqlRead,
+	"PFCOUNT": sqlRead, "GETBIT": sqlRead, "BITCOUNT": sqlRead, "RANDOMKEY": sqlRead,
+	"MONITOR": sqlRead, "SUBSCRIBE": sqlRead, "PSUBSCRIBE": sqlRead, "ROLE": sqlRead,
+	"COMMAND": sqlRead, "DUMP": sqlRead, "SELECT": sqlRead, "AUTH": sqlRead,
+	"CONFIG GET": sqlRead, "CLIENT LIST": sqlRead, "CLIENT INFO": sqlRead,
+	"SLOWLOG GET": sqlRead, "SLOWLOG LEN": sqlRead, "MEMORY USAGE": sqlRead,
+	"MEMORY STATS": sqlRead, "OBJECT ENCODING": sqlRead, "CLUSTER INFO": sqlRead,
+	"CLUSTER NODES": sqlRead, "XINFO STREAM": sqlRead, "XINFO GROUPS": sqlRead,
+	"ACL LIST": sqlRead, "ACL WHOAMI": sqlRead,
+
+	"FLUSHALL": sqlDestructive, "FLUSHDB": sqlDestructive, "SHUTDOWN": sqlDestructive,
+	"DEBUG": sqlDestructive, "CONFIG SET": sqlDestructive, "SCRIPT FLUSH": sqlDestructive,
+	"CLUSTER RESET": sqlDestructive, "REPLICAOF": sqlDestructive, "SLAVEOF": sqlDestructive,
+	"EVAL": sqlUnknown, "EVALSHA": sqlUnknown, "FCALL": sqlUnknown, "MODULE": sqlUnknown,
+}
@@ -0,0 +1,824 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// taskFile is a parsed Makefile, package.json, justfile, Taskfile or
+// mise.toml: named tasks with the tasks they depend on and the commands they
+// run. Parsing is deliberately shallow; anything the parsers don't follow
+// (includes, shebang recipes, pattern rules) is reported so the caller can
+// hand the decision to the evaluator.
+type taskFile struct {
+	tasks    map[string]*taskDef
+	first    string   // task run when none is named
+	preamble []string // commands run on every invocation ($(shell) in Makefile variables)
+	includes bool     // tasks may be defined in files that weren't parsed
+}
+
+type taskDef struct {
+	deps     []string
+	commands []string
+	script   string // interpreter of a recipe that isn't shell lines
+}
+
+func newTaskFile() *taskFile {
+	return &taskFile{tasks: map[string]*taskDef{}}
+}
+
+// task returns the named task, creating it on first use.
+func (tf *taskFile) task(name string) *taskDef {
+	task, ok := tf.tasks[name]
+	if !ok {
+		task = &taskDef{}
+		tf.tasks[name] = task
+		if tf.first == "" {
+			tf.first = name
+		}
+	}
+	return task
+}
+
+// resolve lists the commands the named tasks run, dependencies first, or
+// the reason they can't be listed.
+func (tf *taskFile) resolve(targets []string) ([]string, string) {
+	if len(targets) == 0 {
+		if tf.first == "" {
+			return nil, "no default task"
+		}
+		targets = []string{tf.first}
+	}
+
+	commands := append([]string(nil), tf.preamble...)
+	visited := map[string]bool{}
+	var walk func(name string) string
+	walk = func(name string) string {
+		if visited[name] {
+			return ""
+		}
+		visited[name] = true
+		task, ok := tf.tasks[name]
+		if !ok {
+			if tf.includes {
+				return "no task " + name + ", may come from an included file"
+			}
+			return "no task " + name
+		}
+		if task.script != "" {
+			return "task " + name + " is a " + task.script + " script"
+		}
+		for _, dep := range task.deps {
+			if problem := walk(dep); problem != "" {
+				return problem
+			}
+		}
+		commands = append(commands, task.commands...)
+		return ""
+	}
+	for _, target := range targets {
+		if problem := walk(target); problem != "" {
+			return nil, problem
+		}
+	}
+	return commands, ""
+}
+
+// joinContinuations joins lines ending in a backslash with the next line.
+func joinContinuations(content string) []string {
+	var lines []string
+	var current strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		if current.Len() > 0 {
+			current.WriteString(strings.TrimLeft(line, " \t"))
+			line = current.String()
+			current.Reset()
+		}
+		lines = append(lines, line)
+	}
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+	return lines
+}
+
+// --- Makefile ---
+
+// makefile holds what parseMakefile needs between reading the rules and
+// expanding their recipes.
+type makefile struct {
+	vars    map[string]string
+	recipes map[string][]string
+	deps    map[string][]string
+	goal    string
+	shell   []string // $(shell ...) calls and != assignments
+}
+
+var makeAssignment = regexp.MustCompile(`^(?:(?:export|override|private)\s+)*([A-Za-z0-9_.\-]+)\s*(:::=|::=|:=|\?=|\+=|!=|=)\s*(.*)$`)
+
+var makeDirectives = map[string]bool{
+	"ifeq": true, "ifneq": true, "ifdef": true, "ifndef": true, "else": true,
+	"endif": true, "export": true, "unexport": true, "vpath": true, "undefine": true,
+}
+
+// parseMakefile reads rules and variables from a Makefile. Both branches of
+// conditionals are kept, so a target gets every command it might run.
+// Variables given on the command line override the file's.
+func parseMakefile(content, dir string, overrides map[string]string) *taskFile {
+	mf := &makefile{
+		vars:    map[string]string{"MAKE": "make", "RM": "rm -f", "CURDIR": dir, "SHELL": "/bin/sh"},
+		recipes: map[string][]string{},
+		deps:    map[string][]string{},
+	}
+	for name, value := range overrides {
+		mf.vars[name] = value
+	}
+	tf := newTaskFile()
+
+	var current []string
+	inDefine := false
+	for _, line := range joinContinuations(content) {
+		if inDefine {
+			inDefine = strings.TrimSpace(line) != "endef"
+			continue
+		}
+		if strings.HasPrefix(line, "\t") {
+			for _, target := range current {
+				mf.recipes[target] = append(mf.recipes[target], line[1:])
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(stripMakeComment(line))
+		if trimmed == "" {
+			continue
+		}
+		keyword := strings.Fields(trimmed)[0]
+		switch {
+		case keyword == "define":
+			inDefine, current = true, nil
+			continue
+		case keyword == "include" || keyword == "-include" || keyword == "sinclude":
+			tf.includes, current = true, nil
+			continue
+		}
+
+		if m := makeAssignment.FindStringSubmatch(trimmed); m != nil {
+			current = nil
+			name, op, value := m[1], m[2], m[3]
+			if _, fromCommandLine := overrides[name]; fromCommandLine {
+				continue
+			}
+			switch op {
+			case "?=":
+				if _, ok := mf.vars[name]; !ok {
+					mf.vars[name] = value
+				}
+			case "+=":
+				mf.vars[name] = strings.TrimSpace(mf.vars[name] + " " + value)
+			case "!=":
+				mf.shell = append(mf.shell, mf.expand(value, nil, 0))
+				mf.vars[name] = ""
+			case ":=", "::=", ":::=":
+				mf.vars[name] = mf.expand(value, nil, 0)
+			default:
+				mf.vars[name] = value
+			}
+			continue
+		}
+		if makeDirectives[keyword] {
+			continue
+		}
+
+		colon := makeRuleColon(trimmed)
+		if colon < 0 {
+			current = nil
+			continue
+		}
+		targets := strings.Fields(mf.expand(trimmed[:colon], nil, 0))
+		rest := strings.TrimPrefix(trimmed[colon+1:], ":")
+		inline := ""
+		if semi := strings.Index(rest, ";"); semi >= 0 {
+			rest, inline = rest[:semi], rest[semi+1:]
+		}
+		current = nil
+		if strings.Contains(rest, "=") {
+			continue // target-specific variable
+		}
+		deps := strings.Fields(strings.ReplaceAll(mf.expand(rest, nil, 0), "|", " "))
+		for _, target := range targets {
+			if strings.Contains(target, "%") {
+				continue // pattern rule
+			}
+			if mf.goal == "" && !strings.HasPrefix(target, ".") {
+				mf.goal = target
+			}
+			mf.deps[target] = append(mf.deps[target], deps...)
+			if _, ok := mf.recipes[target]; !ok {
+				mf.recipes[target] = nil
+			}
+			if strings.TrimSpace(inline) != "" {
+				mf.recipes[target] = append(mf.recipes[target], inline)
+			}
+			current = append(current, target)
+		}
+	}
+
+	// $(shell ...) in variables runs on every invocation; in a recipe, only
+	// when that recipe runs
+	tf.preamble = mf.shell
+	for target, recipe := range mf.recipes {
+		if strings.HasPrefix(target, ".") {
+			continue
+		}
+		mf.shell = nil
+		task := tf.task(target)
+		for _, dep := range mf.deps[target] {
+			// Prerequisites without a rule are files
+			if _, ok := mf.recipes[dep]; ok {
+				task.deps = append(task.deps, dep)
+			}
+		}
+		auto := map[string]string{"@": target, "<": strings.Join(firstN(mf.deps[target], 1), ""), "^": strings.Join(mf.deps[target], " "), "*": ""}
+		for _, line := range recipe {
+			line = strings.TrimLeft(line, "@-+ \t")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if command := strings.TrimSpace(mf.expand(line, auto, 0)); command != "" {
+				task.commands = append(task.commands, command)
+			}
+		}
+		task.commands = append(mf.shell, task.commands...)
+	}
+
+	tf.first = mf.goal
+	if goal := strings.TrimSpace(mf.vars[".DEFAULT_GOAL"]); goal != "" {
+		tf.first = goal
+	}
+	return tf
+}
+
+// stripMakeComment removes a # comment that isn't escaped.
+func stripMakeComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] != '\\') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// makeRuleColon finds the colon separating targets from prerequisites,
+// skipping colons inside $(...) and those of := assignments.
+func makeRuleColon(line string) int {
+	depth := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '(', '{':
+			depth++
+		case ')', '}':
+			depth--
+		case ':':
+			if depth == 0 && !strings.HasPrefix(line[i:], ":=") && !strings.HasPrefix(line[i:], "::=") {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// expand substitutes variable references the way make does: $(VAR) and
+// ${VAR} recursively, automatic variables from auto, $$ as $. Undefined
+// variables are empty. $(shell ...) calls are recorded and expand to nothing;
+// other functions are left in place.
+func (mf *makefile) expand(s string, auto map[string]string, depth int) string {
+	if depth > 10 || !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; next {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '(', '{':
+			end := matchingBracket(s, i+1)
+			if end < 0 {
+				b.WriteString(s[i:])
+				return b.String()
+			}
+			b.WriteString(mf.reference(s[i+2:end], auto, depth))
+			i = end
+		default:
+			b.WriteString(mf.lookup(string(next), auto, depth))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func (mf *makefile) reference(inner string, auto map[string]string, depth int) string {
+	if function, args, ok := strings.Cut(inner, " "); ok {
+		if function == "shell" {
+			mf.shell = append(mf.shell, mf.expand(args, auto, depth+1))
+			return ""
+		}
+		return "$(" + inner + ")"
+	}
+	// $(VAR:.c=.o) substitution references expand the variable
+	name, _, _ := strings.Cut(inner, ":")
+	return mf.lookup(name, auto, depth)
+}
+
+func (mf *makefile) lookup(name string, auto map[string]string, depth int) string {
+	if value, ok := auto[name]; ok {
+		return value
+	}
+	return mf.expand(mf.vars[name], auto, depth+1)
+}
+
+// matchingBracket returns the index of the bracket closing the one at open.
+func matchingBracket(s string, open int) int {
+	closer := byte(')')
+	if s[open] == '{' {
+		closer = '}'
+	}
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case s[open]:
+			depth++
+		case closer:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// --- package.json ---
+
+// parsePackageScripts reads package.json scripts. Running a script also runs
+// its pre and post scripts, so those become dependencies.
+func parsePackageScripts(content string) (*taskFile, bool) {
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal([]byte(content), &pkg); err != nil {
+		return nil, false
+	}
+	tf := newTaskFile()
+	for name, body := range pkg.Scripts {
+		task := tf.task(name)
+		task.commands = []string{body}
+		if _, ok := pkg.Scripts["pre"+name]; ok {
+			task.deps = append(task.deps, "pre"+name)
+		}
+		if _, ok := pkg.Scripts["post"+name]; ok {
+			task.deps = append(task.deps, "post"+name)
+		}
+	}
+	tf.first = ""
+	return tf, true
+}
+
+// --- justfile ---
+
+var justAssignment = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_-]*)\s*:=\s*(.*)$`)
+
+var justInterpolation = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// parseJustfile reads recipes from a justfile. Recipe bodies are the
+// indented lines after a header; {{var}} interpolations of simple string
+// variables are substituted.
+func parseJustfile(content string) *taskFile {
+	tf := newTaskFile()
+	vars := map[string]string{}
+	aliases := map[string]string{}
+	var current *taskDef
+	bodyStart := false
+
+	for _, line := range joinContinuations(content) {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if current == nil {
+				continue
+			}
+			body := strings.TrimSpace(line)
+			if bodyStart && strings.HasPrefix(body, "#!") {
+				current.script = strings.TrimSpace(strings.TrimPrefix(body, "#!"))
+			}
+			bodyStart = false
+			if body == "" || strings.HasPrefix(body, "#") {
+				continue
+			}
+			body = strings.TrimLeft(body, "@-")
+			current.commands = append(current.commands, body)
+			continue
+		}
+
+		current = nil
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		keyword := strings.Fields(trimmed)[0]
+		switch keyword {
+		case "set":
+			continue
+		case "import", "import?", "mod", "mod?":
+			tf.includes = true
+			continue
+		case "alias":
+			if name, target, ok := strings.Cut(strings.TrimPrefix(trimmed, "alias"), ":="); ok {
+				aliases[strings.TrimSpace(name)] = strings.TrimSpace(target)
+			}
+			continue
+		}
+		if m := justAssignment.FindStringSubmatch(trimmed); m != nil {
+			vars[m[1]] = strings.Trim(strings.TrimSpace(m[2]), `"'`)
+			continue
+		}
+
+		colon := justHeaderColon(trimmed)
+		if colon < 0 {
+			continue
+		}
+		header := strings.Fields(strings.TrimPrefix(trimmed[:colon], "@"))
+		if len(header) == 0 {
+			continue
+		}
+		current = tf.task(header[0])
+		current.deps = justDeps(trimmed[colon+1:])
+		bodyStart = true
+	}
+
+	for alias, target := range aliases {
+		if task, ok := tf.tasks[target]; ok {
+			tf.tasks[alias] = task
+		}
+	}
+	for _, task := range tf.tasks {
+		for i, command := range task.commands {
+			task.commands[i] = justInterpolation.ReplaceAllStringFunc(command, func(m string) string {
+				name := justInterpolation.FindStringSubmatch(m)[1]
+				if value, ok := vars[name]; ok {
+					return value
+				}
+				return m
+			})
+		}
+	}
+	return tf
+}
+
+var justIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// justDeps lists the recipes a header depends on. `(dep arg)` calls name
+// the recipe first; recipes after && run afterwards, which doesn't matter
+// here.
+func justDeps(s string) []string {
+	var deps []string
+	afterParen := false
+	for _, field := range strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s)) {
+		switch {
+		case field == "(":
+			afterParen = true
+		case field == ")" || field == "&&":
+		case afterParen:
+			deps = append(deps, field)
+			afterParen = false
+		case justIdentifier.MatchString(field):
+			deps = append(deps, field)
+		}
+	}
+	return deps
+}
+
+// justHeaderColon finds the colon ending a recipe header, outside quoted
+// parameter defaults.
+func justHeaderColon(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch ch := line[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ':':
+			if strings.HasPrefix(line[i:], ":=") {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// --- Taskfile.yml ---
+
+// parseTaskfile reads the tasks section of a Taskfile. A task is either a
+// command string, a list of commands, or a map with cmds/cmd and deps;
+// `- task: name` entries in cmds call another task.
+func parseTaskfile(content string) *taskFile {
+	tf := newTaskFile()
+	lines := strings.Split(content, "\n")
+	inTasks := false
+	taskIndent := -1
+	var current *taskDef
+	key := "" // key of the current task being filled: cmds, deps, ...
+	keyIndent := 0
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if indent == 0 {
+			inTasks = trimmed == "tasks:"
+			if trimmed == "includes:" {
+				tf.includes = true
+			}
+			current = nil
+			continue
+		}
+		if !inTasks {
+			continue
+		}
+		if taskIndent < 0 {
+			taskIndent = indent
+		}
+
+		if indent == taskIndent {
+			name, value, _ := yamlKey(trimmed)
+			current = tf.task(name)
+			key, keyIndent = "", indent
+			if value = strings.TrimSpace(value); value != "" {
+				current.commands = append(current.commands, yamlScalar(value))
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		// Shorthand list of commands directly under the task name
+		if strings.HasPrefix(trimmed, "- ") && key == "" {
+			key, keyIndent = "cmds", taskIndent
+		}
+		if !strings.HasPrefix(trimmed, "- ") && (key == "" || indent <= keyIndent) {
+			name, value, _ := yamlKey(trimmed)
+			key, keyIndent = name, indent
+			value = strings.TrimSpace(value)
+			switch {
+			case name == "cmd" && value != "":
+				command, next := yamlBlock(value, lines, i, indent)
+				current.commands = append(current.commands, command)
+				i = next
+			case name == "deps" && strings.HasPrefix(value, "["):
+				for _, dep := range strings.Split(strings.Trim(value, "[]"), ",") {
+					if dep = yamlScalar(strings.TrimSpace(dep)); dep != "" {
+						current.deps = append(current.deps, dep)
+					}
+				}
+			}
+			continue
+		}
+
+		item := strings.TrimSpace(strings.TrimPrefix(trimmed, "-"))
+		field, value, isMap := yamlKey(item)
+		switch {
+		case key == "deps":
+			if isMap && field == "task" {
+				current.deps = append(current.deps, yamlScalar(value))
+			} else if !isMap {
+				current.deps = append(current.deps, yamlScalar(item))
+			}
+		case key == "cmds":
+			switch {
+			case isMap && field == "task":
+				current.deps = append(current.deps, yamlScalar(value))
+			case isMap && (field == "cmd" || field == "defer"):
+				command, next := yamlBlock(value, lines, i, indent)
+				current.commands = append(current.commands, command)
+				i = next
+			case isMap && !strings.Contains(field, " "):
+				// other keys of a command map (silent, ignore_error, ...)
+			default:
+				command, next := yamlBlock(item, lines, i, indent)
+				current.commands = append(current.commands, command)
+				i = next
+			}
+		}
+	}
+	tf.first = ""
+	if _, ok := tf.tasks["default"]; ok {
+		tf.first = "default"
+	}
+	return tf
+}
+
+// yamlKey splits a "key: value" mapping entry. The key ends at the first
+// colon followed by a space or the end of the line, so "db:reset:" is the
+// key db:reset; quoted keys and scalars that aren't mappings return false.
+func yamlKey(s string) (string, string, bool) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 || !strings.HasPrefix(s[end+2:], ":") {
+			return s, "", false
+		}
+		return s[1 : end+1], strings.TrimSpace(s[end+3:]), true
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == ':' && (i+1 == len(s) || s[i+1] == ' ') {
+			return s[:i], strings.TrimSpace(s[i+1:]), true
+		}
+	}
+	return s, "", false
+}
+
+// yamlScalar unquotes a plain YAML scalar.
+func yamlScalar(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// yamlBlock returns a scalar value, reading the following more-indented lines
+// for | and > block scalars, and the index of the last line consumed.
+func yamlBlock(value string, lines []string, i, indent int) (string, int) {
+	if value != "|" && value != ">" && value != "|-" && value != ">-" {
+		return yamlScalar(value), i
+	}
+	var body []string
+	for i+1 < len(lines) {
+		line := strings.TrimRight(lines[i+1], "\r")
+		if strings.TrimSpace(line) != "" && len(line)-len(strings.TrimLeft(line, " ")) <= indent {
+			break
+		}
+		body = append(body, strings.TrimSpace(line))
+		i++
+	}
+	return strings.TrimSpace(strings.Join(body, "\n")), i
+}
+
+// --- mise.toml ---
+
+// parseMiseToml reads [tasks.<name>] tables (run and depends keys) and
+// `name = "command"` entries of a [tasks] table.
+func parseMiseToml(content string) *taskFile {
+	tf := newTaskFile()
+	var current *taskDef
+	inTasks := false
+
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(strings.TrimRight(lines[i], "\r"))
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			section := strings.Trim(trimmed, "[] ")
+			current, inTasks = nil, section == "tasks"
+			if name, ok := strings.CutPrefix(section, "tasks."); ok {
+				current = tf.task(strings.Trim(name, `"'`))
+			}
+			if section == "task_config" {
+				tf.includes = true
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value, i = tomlValue(strings.TrimSpace(value), lines, i)
+
+		switch {
+		case inTasks:
+			task := tf.task(key)
+			if strings.HasPrefix(value, "{") {
+				// inline table: name = { run = "...", depends = [...] }
+				for _, part := range tomlInlineTable(value) {
+					k, v, _ := strings.Cut(part, "=")
+					addMiseKey(task, strings.TrimSpace(k), strings.TrimSpace(v))
+				}
+				continue
+			}
+			addMiseKey(task, "run", value)
+		case current != nil:
+			addMiseKey(current, key, value)
+		}
+	}
+	tf.first = ""
+	if _, ok := tf.tasks["default"]; ok {
+		tf.first = "default"
+	}
+	return tf
+}
+
+func addMiseKey(task *taskDef, key, value string) {
+	switch key {
+	case "run":
+		task.commands = append(task.commands, tomlStrings(value)...)
+	case "depends", "depends_post":
+		task.deps = append(task.deps, tomlStrings(value)...)
+	case "file":
+		task.script = "file"
+	}
+}
+
+// tomlValue returns a value, continuing over the following lines for
+// multi-line strings and arrays, and the index of the last line consumed.
+func tomlValue(value string, lines []string, i int) (string, int) {
+	for _, delim := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(value, delim) && (len(value) < 6 || !strings.HasSuffix(value, delim)) {
+			for i+1 < len(lines) && !strings.Contains(value[3:], delim) {
+				i++
+				value += "\n" + strings.TrimRight(lines[i], "\r")
+			}
+			return value, i
+		}
+	}
+	for (strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{")) && i+1 < len(lines) &&
+		strings.Count(value, "[")+strings.Count(value, "{") > strings.Count(value, "]")+strings.Count(value, "}") {
+		i++
+		value += " " + strings.TrimSpace(lines[i])
+	}
+	return value, i
+}
+
+// tomlStrings returns the strings in a string or array-of-strings value.
+func tomlStrings(value string) []string {
+	value = strings.TrimSpace(value)
+	for _, delim := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(value, delim) {
+			return []string{strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(value, delim), delim))}
+		}
+	}
+	if !strings.HasPrefix(value, "[") {
+		return []string{yamlScalar(value)}
+	}
+	var out []string
+	for _, item := range tomlInlineTable(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")) {
+		if item = yamlScalar(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// tomlInlineTable splits the items of an inline table or array on commas
+// outside quotes and brackets.
+func tomlInlineTable(value string) []string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "{"), "}")
+	}
+	var items []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(value); i++ {
+		switch ch := value[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[' || ch == '{':
+			depth++
+		case ch == ']' || ch == '}':
+			depth--
+		case ch == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(value[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(value[start:]); rest != "" {
+		items = append(items, rest)
+	}
+	return items
+}